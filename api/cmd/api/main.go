@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -22,7 +23,9 @@ import (
 	"tomerab.com/cam-hub/internal/events/rabbitmq"
 	"tomerab.com/cam-hub/internal/httpserver"
 	"tomerab.com/cam-hub/internal/mtxapi"
+	objectstorage "tomerab.com/cam-hub/internal/object_storage"
 	"tomerab.com/cam-hub/internal/repos"
+	"tomerab.com/cam-hub/internal/secrets"
 	"tomerab.com/cam-hub/internal/services"
 	"tomerab.com/cam-hub/internal/utils"
 )
@@ -51,7 +54,18 @@ func main() {
 	discoveryServiceLogger := slog.New(base).With("service", "discovery")
 	cameraServiceLogger := slog.New(base).With("service", "camera")
 	ptzServiceLogger := slog.New(base).With("service", "ptz")
+	ptzToursServiceLogger := slog.New(base).With("service", "ptz_tours")
+	ptzLockServiceLogger := slog.New(base).With("service", "ptz_lock")
 	mtxServiceLogger := slog.New(base).With("service", "mtx")
+	recordingsServiceLogger := slog.New(base).With("service", "recordings")
+	retentionServiceLogger := slog.New(base).With("service", "retention")
+	continuousRecordingServiceLogger := slog.New(base).With("service", "continuous_recording")
+	exportsServiceLogger := slog.New(base).With("service", "exports")
+	motionSettingsServiceLogger := slog.New(base).With("service", "motion_settings")
+	streamsServiceLogger := slog.New(base).With("service", "streams")
+	workersServiceLogger := slog.New(base).With("service", "workers")
+	authServiceLogger := slog.New(base).With("service", "auth")
+	minioLogger := slog.New(base).With("component", "MinIO")
 
 	rootCtx := context.Background()
 	dbpool, err := pgxpool.New(rootCtx, os.Getenv("POSTGRES_DSN"))
@@ -89,6 +103,20 @@ func main() {
 
 	camRepo := repos.NewPgxCameraRepo(dbpool)
 	ptzRepo := repos.NewPgxPtzTokenRepo(dbpool)
+	ptzPresetsRepo := repos.NewPgxPtzPresetsRepo(dbpool)
+	ptzCapsRepo := repos.NewPgxPtzCapabilitiesRepo(dbpool)
+	ptzToursRepo := repos.NewPgxPtzToursRepo(dbpool)
+	recordingsRepo := repos.NewPgxRecordingsRepo(dbpool)
+	motionSettingsRepo := repos.NewPgxMotionSettingsRepo(dbpool)
+	motionZonesRepo := repos.NewPgxMotionZonesRepo(dbpool)
+	usersRepo := repos.NewPgxUsersRepo(dbpool)
+	recordingSettingsRepo := repos.NewPgxRecordingSettingsRepo(dbpool)
+	recordingSegmentsRepo := repos.NewPgxRecordingSegmentsRepo(dbpool)
+
+	minioClient, err := objectstorage.NewMinIOStore(rootCtx, minioLogger, false)
+	if err != nil {
+		panic(err.Error())
+	}
 
 	sseChan := make(chan v1.DiscoveryEvent, 24)
 	camsEventProxyChan := make(chan v1.CameraProxyEvent, 8)
@@ -107,9 +135,32 @@ func main() {
 	if err != nil {
 		panic(err.Error())
 	}
+
+	retentionSvc := &services.RetentionService{
+		RecordingsRepo: recordingsRepo,
+		Store:          minioClient,
+		Sched:          sched,
+		Logger:         retentionServiceLogger,
+		Interval:       utils.EnvDuration("RETENTION_INTERVAL", time.Hour),
+		BatchSize:      utils.EnvInt("RETENTION_BATCH_SIZE", 100),
+		MaxBatches:     utils.EnvInt("RETENTION_MAX_BATCHES", 10),
+		DryRun:         utils.EnvBool("RETENTION_DRY_RUN", false),
+	}
+	if err := retentionSvc.InitJobs(rootCtx); err != nil {
+		panic(err.Error())
+	}
 	dscSvc.Sched.Start()
 
-	credsRepo := repos.NewPgxCameraCredsRepo(dbpool)
+	credsKeys, err := secrets.LoadKeyring()
+	if err != nil {
+		panic(fmt.Sprintf("failed to load the creds master keys: %s", err.Error()))
+	}
+	credsRepo := repos.NewPgxCameraCredsRepo(dbpool, credsKeys)
+	if n, err := credsRepo.EncryptPlaintext(rootCtx); err != nil {
+		panic(err.Error())
+	} else if n > 0 {
+		appLogger.Info("encrypted plaintext camera credentials", "rows", n)
+	}
 	bus, err := rabbitmq.NewBus(os.Getenv("RABBITMQ_ADDR"))
 	if err != nil {
 		panic(err.Error())
@@ -118,13 +169,92 @@ func main() {
 	if err := bus.DeclareQueue("motion.detections", true, nil); err != nil {
 		panic(err.Error())
 	}
+	if err := bus.DeclareExchange(v1.MotionConfigExchange, "topic", true); err != nil {
+		panic(err.Error())
+	}
+	if err := bus.DeclareExchange(v1.RecordingSegmentsExchange, "topic", true); err != nil {
+		panic(err.Error())
+	}
+	if err := bus.DeclareQueue(os.Getenv("RABBITMQ_WORKERS_KEY"), true, nil); err != nil {
+		panic(err.Error())
+	}
+	if err := bus.DeclareQueue(os.Getenv("RABBITMQ_SYNC_KEY"), true, nil); err != nil {
+		panic(err.Error())
+	}
+	if err := bus.DeclareQueue(os.Getenv("RABBITMQ_SNAPSHOT_KEY"), true, nil); err != nil {
+		panic(err.Error())
+	}
 	inMemPubSub := inmemory.NewInMemoryPubSub()
+	streamsRepo := repos.NewPgxCameraStreamsRepo(dbpool)
+	selectionRepo := repos.NewPgxStreamSelectionRepo(dbpool)
 
 	mtxClient := &mtxapi.MtxClient{
-		Logger:       mtxServiceLogger,
+		Logger:        mtxServiceLogger,
+		CamRepo:       camRepo,
+		CamCredsRepo:  credsRepo,
+		StreamsRepo:   streamsRepo,
+		SelectionRepo: selectionRepo,
+		RecordingRepo: recordingSettingsRepo,
+		Cache:         dscSvc.Rdb,
+		HttpClient:    &httpClient,
+	}
+
+	continuousRecordingSvc := &services.ContinuousRecordingService{
+		SettingsRepo:  recordingSettingsRepo,
+		SegmentsRepo:  recordingSegmentsRepo,
+		CamRepo:       camRepo,
+		MtxClient:     mtxClient,
+		Bus:           bus,
+		Sched:         sched,
+		Logger:        continuousRecordingServiceLogger,
+		PruneInterval: utils.EnvDuration("RECORDING_SEGMENTS_PRUNE_INTERVAL", time.Hour),
+	}
+	if err := continuousRecordingSvc.InitJobs(rootCtx); err != nil {
+		panic(err.Error())
+	}
+
+	exportsSvc := &services.ExportsService{
+		CamRepo:     camRepo,
+		MtxClient:   mtxClient,
+		Store:       minioClient,
+		PubSub:      inMemPubSub,
+		Sched:       sched,
+		Logger:      exportsServiceLogger,
+		Bucket:      os.Getenv("MINIO_BUCKET_NAME"),
+		MaxDuration: utils.EnvDuration("EXPORTS_MAX_DURATION", time.Hour),
+		MaxJobs:     utils.EnvInt("EXPORTS_MAX_JOBS", 2),
+		TTL:         utils.EnvDuration("EXPORTS_TTL", 24*time.Hour),
+	}
+	if err := exportsSvc.InitJobs(rootCtx); err != nil {
+		panic(err.Error())
+	}
+
+	ptzSvc := &services.PtzService{
 		CamRepo:      camRepo,
+		PtzTokenRepo: ptzRepo,
+		PresetsRepo:  ptzPresetsRepo,
+		CapsRepo:     ptzCapsRepo,
 		CamCredsRepo: credsRepo,
-		HttpClient:   &httpClient,
+		Rdb:          dscSvc.Rdb,
+		Logger:       ptzServiceLogger,
+	}
+	ptzLockSvc := &services.PtzLockService{
+		Rdb:    dscSvc.Rdb,
+		PubSub: inMemPubSub,
+		TTL:    utils.EnvDuration("PTZ_LOCK_TTL", services.DefaultPtzLeaseTTL),
+		Logger: ptzLockServiceLogger,
+	}
+	ptzToursSvc := &services.PtzToursService{
+		ToursRepo:   ptzToursRepo,
+		CamRepo:     camRepo,
+		GotoPreset:  ptzSvc.GotoPreset,
+		Locks:       ptzLockSvc,
+		Sched:       sched,
+		IdleTimeout: utils.EnvDuration("PTZ_TOUR_IDLE_TIMEOUT", services.DefaultTourIdleTimeout),
+		Logger:      ptzToursServiceLogger,
+	}
+	if err := ptzToursSvc.InitJobs(rootCtx); err != nil {
+		panic(err.Error())
 	}
 
 	app := &application.Application{
@@ -138,24 +268,64 @@ func main() {
 		CameraService: &services.CameraService{
 			CamRepo:            camRepo,
 			CamCredsRepo:       credsRepo,
+			StreamsRepo:        streamsRepo,
+			PtzCapsRepo:        ptzCapsRepo,
 			Rdms:               dscSvc.Rdb,
 			MtxClient:          mtxClient,
 			InMemCache:         inMemPubSub,
 			CamsEventProxyChan: camsEventProxyChan,
 			Logger:             cameraServiceLogger,
 		},
-		PtzService: &services.PtzService{
+		PtzService:      ptzSvc,
+		PtzToursService: ptzToursSvc,
+		PtzLockService:  ptzLockSvc,
+		RecordingsService: services.NewRecordingsService(
+			recordingsServiceLogger,
+			recordingsRepo,
+			camRepo,
+			minioClient,
+		),
+		RetentionService:           retentionSvc,
+		ContinuousRecordingService: continuousRecordingSvc,
+		ExportsService:             exportsSvc,
+		MotionSettingsService: &services.MotionSettingsService{
+			SettingsRepo: motionSettingsRepo,
+			ZonesRepo:    motionZonesRepo,
 			CamRepo:      camRepo,
-			PtzTokenRepo: ptzRepo,
-			CamCredsRepo: credsRepo,
-			Rdb:          dscSvc.Rdb,
-			Logger:       ptzServiceLogger,
+			Bus:          bus,
+			Logger:       motionSettingsServiceLogger,
+		},
+		StreamsService: &services.StreamsService{
+			CamRepo:            camRepo,
+			StreamsRepo:        streamsRepo,
+			SelectionRepo:      selectionRepo,
+			MtxClient:          mtxClient,
+			CamsEventProxyChan: camsEventProxyChan,
+			Logger:             streamsServiceLogger,
+		},
+		WorkersService: services.NewWorkersService(workersServiceLogger),
+		AuthService: &services.AuthService{
+			UsersRepo: usersRepo,
+			Sessions: &repos.RedisRepo{
+				Rdb:    rdb,
+				Logger: redisRepoLogger,
+			},
+			SessionTTL: utils.EnvDuration("AUTH_SESSION_TTL", services.DefaultSessionTTL),
+			Logger:     authServiceLogger,
 		},
 		MtxClient: mtxClient,
 		Bus:       bus,
 		PubSub:    inMemPubSub,
 	}
 
+	if err := app.AuthService.Bootstrap(
+		rootCtx,
+		os.Getenv("AUTH_BOOTSTRAP_ADMIN_USERNAME"),
+		os.Getenv("AUTH_BOOTSTRAP_ADMIN_PASSWORD"),
+	); err != nil {
+		panic(err.Error())
+	}
+
 	app.Bus.Consume(rootCtx, "motion.detections", "", func(ctx context.Context, m events.Message) events.AckAction {
 		uuid, ok := m.Headers["uuid"].(string)
 		if !ok {
@@ -167,6 +337,27 @@ func main() {
 		return events.Ack
 	})
 
+	app.Bus.Consume(rootCtx, os.Getenv("RABBITMQ_WORKERS_KEY"), "", func(ctx context.Context, m events.Message) events.AckAction {
+		var state v1.WorkerState
+		if err := json.Unmarshal(m.Body, &state); err != nil {
+			app.Logger.Error("failed to parse worker state", "err", err)
+			return events.NackDiscard
+		}
+
+		app.WorkersService.Apply(state)
+		return events.Ack
+	})
+
+	app.Bus.Consume(rootCtx, os.Getenv("RABBITMQ_SYNC_KEY"), "", func(ctx context.Context, m events.Message) events.AckAction {
+		if err := app.PublishPairedSnapshot(ctx); err != nil {
+			// The supervisor asks again on its next reconcile tick.
+			app.Logger.Error("failed to publish paired cameras snapshot", "err", err)
+			return events.NackDiscard
+		}
+
+		return events.Ack
+	})
+
 	app.OnStartup(rootCtx)
 
 	srv := http.Server{
@@ -175,16 +366,31 @@ func main() {
 		WriteTimeout: 0,
 		IdleTimeout:  0,
 	}
+	internalAddr := os.Getenv("INTERNAL_SERVER_ADDR")
+	if internalAddr == "" {
+		internalAddr = "127.0.0.1:8081"
+	}
+	internalSrv := http.Server{
+		Addr:    internalAddr,
+		Handler: httpserver.NewInternalRouter(app),
+	}
 	shutdownErrChan := make(chan error)
 	onShutdown := func() {
 		ctx, cancel := context.WithTimeout(rootCtx, 10*time.Second)
 		defer cancel()
 
-		shutdownErrChan <- srv.Shutdown(ctx)
+		shutdownErrChan <- errors.Join(srv.Shutdown(ctx), internalSrv.Shutdown(ctx))
 	}
 	_, cancel := utils.GracefullShutdown(rootCtx, onShutdown, syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	go func() {
+		appLogger.Info(fmt.Sprintf("Internal server is listening on %s", internalSrv.Addr))
+		if err := internalSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			panic(err.Error())
+		}
+	}()
+
 	appLogger.Info(fmt.Sprintf("Server is listening on %s", srv.Addr))
 	err = srv.ListenAndServe()
 
@@ -0,0 +1,75 @@
+// Command credskey manages the encryption of the camera credentials.
+//
+//	credskey genkey   prints a new master key to add to the keyring
+//	credskey migrate  encrypts the rows that still hold a plaintext password
+//	credskey rotate   rewraps every row with CREDS_MASTER_KEY_ID, old keys can be removed after it
+//	credskey decrypt  writes the passwords back in plaintext, before rolling back the migration
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+	"tomerab.com/cam-hub/internal/repos"
+	"tomerab.com/cam-hub/internal/secrets"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: credskey genkey|migrate|rotate|decrypt")
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(cmd string) error {
+	if cmd == "genkey" {
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	}
+
+	_ = godotenv.Load()
+
+	keys, err := secrets.LoadKeyring()
+	if err != nil {
+		return fmt.Errorf("failed to load the master keys: %w", err)
+	}
+
+	ctx := context.Background()
+	dbpool, err := pgxpool.New(ctx, os.Getenv("POSTGRES_DSN"))
+	if err != nil {
+		return err
+	}
+	defer dbpool.Close()
+
+	repo := repos.NewPgxCameraCredsRepo(dbpool, keys)
+
+	var n int
+	switch cmd {
+	case "migrate":
+		n, err = repo.EncryptPlaintext(ctx)
+	case "rotate":
+		n, err = repo.RotateKeys(ctx)
+	case "decrypt":
+		n, err = repo.DecryptAll(ctx)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d rows updated\n", cmd, n)
+	return nil
+}
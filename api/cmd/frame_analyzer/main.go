@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"os"
 	"syscall"
@@ -14,8 +15,10 @@ import (
 	"tomerab.com/cam-hub/internal/events"
 	"tomerab.com/cam-hub/internal/events/rabbitmq"
 	frameanalyzer "tomerab.com/cam-hub/internal/frame_analyzer"
+	"tomerab.com/cam-hub/internal/frame_analyzer/detector"
 	objectstorage "tomerab.com/cam-hub/internal/object_storage"
 	"tomerab.com/cam-hub/internal/repos"
+	"tomerab.com/cam-hub/internal/services"
 	"tomerab.com/cam-hub/internal/utils"
 )
 
@@ -62,10 +65,34 @@ func main() {
 	}
 	defer dbpool.Close()
 
+	modelCfg, err := detector.LoadModelConfig(os.Getenv("DETECTOR_MODEL_CONFIG"))
+	if err != nil {
+		panic(err.Error())
+	}
+
+	det, err := newDetector(modelCfg)
+	if err != nil {
+		panic(err.Error())
+	}
+	defer det.Close()
+	logger.Info("using detector model", "name", modelCfg.Name, "version", modelCfg.Version)
+
 	recordingsRepo := repos.NewPgxRecordingsRepo(dbpool)
 	camerasRepo := repos.NewPgxCameraRepo(dbpool)
 
-	analyzer := frameanalyzer.New(ctx, logger, bus, minioClient, recordingsRepo, camerasRepo)
+	// Zones are always applied to motion, dropping the detections outside of them is opt-in.
+	var zonesRepo repos.MotionZonesRepoIface
+	if utils.EnvBool("DETECTOR_ZONE_FILTER", false) {
+		zonesRepo = repos.NewPgxMotionZonesRepo(dbpool)
+	}
+
+	recordingsSvc := services.NewRecordingsService(
+		slog.New(logger.Handler()).With("service", "recordings"),
+		recordingsRepo,
+		camerasRepo,
+		minioClient,
+	)
+	analyzer := frameanalyzer.New(ctx, logger, bus, minioClient, det, recordingsSvc, zonesRepo)
 
 	bus.Consume(ctx, "motion.analyze", "", func(ctx context.Context, m events.Message) events.AckAction {
 		var msg v1.AnalyzeImgsEvent
@@ -80,3 +107,16 @@ func main() {
 
 	analyzer.Run(ctx)
 }
+
+// The detections come from OVMS by default, "fake" runs the pipeline without a model server and
+// discards every clip since it detects nothing.
+func newDetector(cfg *detector.ModelConfig) (detector.Detector, error) {
+	switch backend := os.Getenv("DETECTOR_BACKEND"); backend {
+	case "", "ovms":
+		return detector.NewOvmsDetector(os.Getenv("OVMS_GRPC_ADDR"), cfg)
+	case "fake":
+		return detector.NewFakeDetector(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown detector backend %q", backend)
+	}
+}
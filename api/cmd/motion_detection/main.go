@@ -2,27 +2,22 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"strings"
 	"syscall"
-	"time"
 
 	"github.com/joho/godotenv"
-	"gocv.io/x/gocv"
 	"gopkg.in/lumberjack.v3"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
 	"tomerab.com/cam-hub/internal/events/rabbitmq"
 	"tomerab.com/cam-hub/internal/motion"
 	objectstorage "tomerab.com/cam-hub/internal/object_storage"
 	"tomerab.com/cam-hub/internal/utils"
-)
-
-var (
-	lastMotionEvent time.Time
-	motionCoolDown  = 10 * time.Second
-	bucketName      = os.Getenv("MINIO_BUCKET_NAME")
+	"tomerab.com/cam-hub/internal/zones"
 )
 
 func main() {
@@ -30,14 +25,43 @@ func main() {
 		panic(fmt.Sprintf("failed to connect to load .env: %s", err.Error()))
 	}
 
+	defaults := v1.DefaultMotionSettings()
 	addr := flag.String("addr", "", "rtsp url")
+	camUUID := flag.String("uuid", "", "camera uuid, defaults to the last segment of -addr")
+	threshold := flag.Float64("threshold", float64(defaults.Threshold), "binary threshold after MOG2")
+	minArea := flag.Int("min-area", defaults.MinArea, "minimum foreground pixels to call motion")
+	warmupFrames := flag.Int("warmup-frames", defaults.WarmupFrames, "frames to ignore while the background stabilizes")
+	tickMs := flag.Int("tick-ms", defaults.TickMs, "interval between sampled frames in ms")
+	cooldownMs := flag.Int("cooldown-ms", defaults.CooldownMs, "minimum gap between the end of a motion event and the next one in ms")
+	preRollMs := flag.Int("pre-roll-ms", defaults.PreRollMs, "recorded before the first detection of an event in ms")
+	postRollMs := flag.Int("post-roll-ms", defaults.PostRollMs, "recorded after the last detection of an event in ms")
+	maxEventMs := flag.Int("max-event-ms", defaults.MaxEventMs, "longest a motion event is extended for in ms")
+	source := flag.String("source", defaults.Source, "where the detections come from: server, camera (its onvif events) or both")
+	zonesJSON := flag.String("zones", "", "json list of include/exclude zones with normalized points")
+	recordPath := flag.String("record-path", "", "mediamtx path the clips are cut from, defaults to the camera uuid")
 	flag.Parse()
 	if *addr == "" {
 		panic("missing -addr")
 	}
+	if !v1.ValidMotionSource(*source) {
+		panic(fmt.Sprintf("invalid -source %q", *source))
+	}
 
-	parts := strings.Split(*addr, "/")
-	cameraUUID := parts[len(parts)-1]
+	var camZones []zones.Zone
+	if *zonesJSON != "" {
+		if err := json.Unmarshal([]byte(*zonesJSON), &camZones); err != nil {
+			panic(fmt.Sprintf("invalid -zones: %s", err.Error()))
+		}
+		if err := zones.Validate(camZones); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	cameraUUID := *camUUID
+	if cameraUUID == "" {
+		parts := strings.Split(*addr, "/")
+		cameraUUID = parts[len(parts)-1]
+	}
 
 	fileHandler, err := lumberjack.New(
 		lumberjack.WithFileName(os.Getenv("LOGGER_PATH")+fmt.Sprintf("/%s/", cameraUUID)+"motion.log"),
@@ -52,23 +76,6 @@ func main() {
 		Level: slog.LevelDebug,
 	}))
 
-	cap, err := gocv.VideoCaptureFile(*addr)
-	if err != nil {
-		panic(err)
-	}
-	defer cap.Close()
-
-	cap.Set(gocv.VideoCaptureBufferSize, 1)
-
-	frame := gocv.NewMat()
-	defer frame.Close()
-
-	det := motion.NewMotionDetector(50, 15000, 150)
-	defer det.Close()
-
-	ticker := time.NewTicker(50 * time.Millisecond)
-	defer ticker.Stop()
-
 	ctx, cancel := utils.GracefullShutdown(context.Background(), func() {
 		logger.Debug("caught signal, terminating")
 	}, syscall.SIGTERM, syscall.SIGINT)
@@ -78,10 +85,7 @@ func main() {
 	if err != nil {
 		panic(err.Error())
 	}
-
-	if err := bus.DeclareQueue("motion.analyze", true, nil); err != nil {
-		panic(err.Error())
-	}
+	defer bus.Close()
 
 	minioLogger := slog.New(logger.Handler()).With("component", "MinIO")
 	minioClient, err := objectstorage.NewMinIOStore(ctx, minioLogger, false)
@@ -90,37 +94,33 @@ func main() {
 		panic(err.Error())
 	}
 
-	if exists, err := minioClient.BucketExists(bucketName); !exists {
-		if err != nil {
-			minioLogger.Error("bucket exists check failed", "err", err.Error())
-		}
-
-		if err := minioClient.CreateBucket(bucketName); err != nil {
-			minioLogger.Error("bucket creation failed", "err", err.Error())
-			panic(err.Error())
-		}
+	if err := minioClient.EnsureBucket(os.Getenv("MINIO_BUCKET_NAME")); err != nil {
+		panic(err.Error())
 	}
 
-	runner := motion.NewRunner(ctx, minioClient, bus, logger, 8)
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			if ok := cap.Read(&frame); !ok || frame.Empty() {
-				continue
-			}
-
-			isMotion, score := det.Detect(&frame)
-			if isMotion && time.Since(lastMotionEvent) > motionCoolDown {
-				lastMotionEvent = time.Now().UTC()
-				logger.Info("motion detected posting new job", "motion_time", lastMotionEvent)
-				go runner.PostJob(motion.MotionCtx{
-					UUID:      cameraUUID,
-					Score:     score,
-					TimePoint: lastMotionEvent,
-				})
-			}
-		}
+	if err := motion.RunDetection(ctx, motion.DetectionParams{
+		CamUUID:    cameraUUID,
+		StreamUrl:  *addr,
+		RecordPath: *recordPath,
+		Settings: v1.MotionSettings{
+			Threshold:    float32(*threshold),
+			MinArea:      *minArea,
+			WarmupFrames: *warmupFrames,
+			TickMs:       *tickMs,
+			CooldownMs:   *cooldownMs,
+			PreRollMs:    *preRollMs,
+			PostRollMs:   *postRollMs,
+			MaxEventMs:   *maxEventMs,
+			Source:       *source,
+		},
+		Zones:  camZones,
+		Bus:    bus,
+		Store:  minioClient,
+		Logger: logger,
+		// The camera credentials come from the internal API so they never show in the args.
+		OnvifSource: motion.InternalOnvifSource(os.Getenv("CAMHUB_API_ADDR"), os.Getenv("INTERNAL_API_TOKEN"), cameraUUID),
+	}); err != nil {
+		logger.Error("motion detection stopped", "err", err.Error())
+		os.Exit(1)
 	}
 }
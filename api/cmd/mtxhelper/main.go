@@ -0,0 +1,215 @@
+// Command mtxhelper runs inside the mediamtx container as the runOnDemand command of the
+// camera paths, so the camera credentials never appear in mediamtx's config or logs.
+//
+//	mtxhelper source -path <uuid>/<profile>
+//
+// resolves the RTSP source of the camera's profile through the internal API (CAMHUB_API_ADDR,
+// authenticated with INTERNAL_API_TOKEN) and runs ffmpeg to publish it back to the path. The
+// url only lives in the ffmpeg process for as long as the path is read, ffmpeg reads it from a
+// pipe rather than its argv and its output is redacted.
+//
+// Browser compatible video is copied rather than re-encoded, the api decides per camera, and so
+// is G.711 or Opus audio. It is still relayed by ffmpeg instead of being set as the path's
+// source, which would put the credentials back in mediamtx's config.
+//
+//	mtxhelper segment -path <uuid>/<profile> -file <segment> -duration <seconds>
+//
+// is the runOnRecordSegmentComplete command of the recorded paths, it posts the completed
+// segment to the internal API which indexes it for the recordings timeline and notifies the
+// motion runners cutting clips out of it.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/mtxapi"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "source":
+		fs := flag.NewFlagSet("source", flag.ExitOnError)
+		path := fs.String("path", os.Getenv("MTX_PATH"), "mediamtx path, the camera uuid and its profile")
+		apiAddr := fs.String("api", os.Getenv("CAMHUB_API_ADDR"), "base url of the cam-hub api")
+		ffmpeg := fs.String("ffmpeg", "/usr/bin/ffmpeg", "ffmpeg binary")
+		fs.Parse(os.Args[2:])
+
+		os.Exit(runSource(*path, *apiAddr, *ffmpeg))
+	case "segment":
+		fs := flag.NewFlagSet("segment", flag.ExitOnError)
+		path := fs.String("path", os.Getenv("MTX_PATH"), "mediamtx path, the camera uuid and its profile")
+		file := fs.String("file", os.Getenv("MTX_SEGMENT_PATH"), "the completed segment")
+		duration := fs.String("duration", os.Getenv("MTX_SEGMENT_DURATION"), "the segment's duration")
+		apiAddr := fs.String("api", os.Getenv("CAMHUB_API_ADDR"), "base url of the cam-hub api")
+		fs.Parse(os.Args[2:])
+
+		os.Exit(runSegment(*path, *file, *duration, *apiAddr))
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: mtxhelper source -path <uuid>/<profile>")
+	fmt.Fprintln(os.Stderr, "       mtxhelper segment -path <uuid>/<profile> -file <segment> -duration <seconds>")
+	os.Exit(2)
+}
+
+func runSource(path, apiAddr, ffmpeg string) int {
+	if path == "" || apiAddr == "" {
+		fmt.Fprintln(os.Stderr, "mtxhelper: -path and -api (or MTX_PATH and CAMHUB_API_ADDR) are required")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	source, err := fetchSource(ctx, apiAddr, path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mtxhelper: failed to resolve the source of %s: %s\n", path, err)
+		return 1
+	}
+
+	rtspPort := os.Getenv("RTSP_PORT")
+	if rtspPort == "" {
+		rtspPort = "8554"
+	}
+	target := fmt.Sprintf("rtsp://127.0.0.1:%s/%s", rtspPort, path)
+
+	fmt.Fprintf(os.Stderr, "mtxhelper: publishing %s, codec=%q audio=%q transcode=%t\n", path, source.VideoCodec, source.AudioCodec, source.Transcode)
+	cmd := exec.Command(ffmpeg, mtxapi.FFmpegArgs(target, source.Transcode, mtxapi.AudioCompatible(source.AudioCodec))...)
+	cmd.Stdout = os.Stdout
+	// ffmpeg prints the input url on errors, the credentials are masked before they reach the logs.
+	cmd.Stderr = mtxapi.NewRedactWriter(os.Stderr, source.Url)
+	// The url is read from a pipe, in the argv it would be readable by anyone through ps.
+	closeSource, err := mtxapi.PipeSource(cmd, source.Url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mtxhelper: failed to pass the source to ffmpeg: %s\n", err)
+		return 1
+	}
+	err = cmd.Start()
+	closeSource()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mtxhelper: failed to start ffmpeg: %s\n", err)
+		return 1
+	}
+
+	// mediamtx stops the command with SIGINT/SIGTERM when the path closes.
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		_ = cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case err = <-done:
+		case <-time.After(5 * time.Second):
+			_ = cmd.Process.Kill()
+			err = <-done
+		}
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+func fetchSource(ctx context.Context, apiAddr, path string) (*v1.CameraSource, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	uuid, profile := mtxapi.ParsePathName(path)
+	endpoint := fmt.Sprintf("%s/internal/v1/cameras/%s/source?profile=%s",
+		strings.TrimSuffix(apiAddr, "/"), url.PathEscape(uuid), url.QueryEscape(profile))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("INTERNAL_API_TOKEN"))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("api returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	var source v1.CameraSource
+	if err := json.NewDecoder(resp.Body).Decode(&source); err != nil {
+		return nil, err
+	}
+	if source.Url == "" {
+		return nil, errors.New("api returned an empty source")
+	}
+
+	return &source, nil
+}
+
+func runSegment(path, file, duration, apiAddr string) int {
+	if path == "" || file == "" || apiAddr == "" {
+		fmt.Fprintln(os.Stderr, "mtxhelper: -path, -file and -api (or MTX_PATH, MTX_SEGMENT_PATH and CAMHUB_API_ADDR) are required")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	body, err := json.Marshal(v1.RecordingSegmentEvent{Path: path, File: file, Duration: duration})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mtxhelper: %s\n", err)
+		return 1
+	}
+
+	endpoint := strings.TrimSuffix(apiAddr, "/") + "/internal/v1/recordings/segments"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mtxhelper: %s\n", err)
+		return 1
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("INTERNAL_API_TOKEN"))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mtxhelper: failed to index segment %s: %s\n", file, err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		fmt.Fprintf(os.Stderr, "mtxhelper: failed to index segment %s: api returned %s: %s\n", file, resp.Status, bytes.TrimSpace(msg))
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,32 @@
+//go:build inproc
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"tomerab.com/cam-hub/internal/events/rabbitmq"
+	objectstorage "tomerab.com/cam-hub/internal/object_storage"
+	visor "tomerab.com/cam-hub/internal/supervisor"
+	"tomerab.com/cam-hub/internal/supervisor/inproc"
+)
+
+func newInprocLauncher(logger *slog.Logger, bus *rabbitmq.AMQPBus) (visor.Launcher, error) {
+	minioLogger := slog.New(logger.Handler()).With("component", "MinIO")
+	store, err := objectstorage.NewMinIOStore(context.Background(), minioLogger, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.EnsureBucket(os.Getenv("MINIO_BUCKET_NAME")); err != nil {
+		return nil, err
+	}
+	return &inproc.Launcher{
+		Bus:      bus,
+		Store:    store,
+		Logger:   logger,
+		ApiAddr:  os.Getenv("CAMHUB_API_ADDR"),
+		ApiToken: os.Getenv("INTERNAL_API_TOKEN"),
+	}, nil
+}
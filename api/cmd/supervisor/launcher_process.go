@@ -0,0 +1,17 @@
+//go:build !inproc
+
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"tomerab.com/cam-hub/internal/events/rabbitmq"
+	visor "tomerab.com/cam-hub/internal/supervisor"
+)
+
+// The default build stays free of cgo, the detectors are only linked by the motion detection
+// binary the process launcher runs.
+func newInprocLauncher(logger *slog.Logger, bus *rabbitmq.AMQPBus) (visor.Launcher, error) {
+	return nil, errors.New(`worker mode "inproc" needs a supervisor built with -tags inproc`)
+}
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"os"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"gopkg.in/lumberjack.v3"
@@ -23,8 +24,11 @@ func main() {
 	}
 
 	var (
-		PairKey   = os.Getenv("RABBITMQ_PAIR_KEY")
-		UnpairKey = os.Getenv("RABBITMQ_UNPAIR_KEY")
+		PairKey     = os.Getenv("RABBITMQ_PAIR_KEY")
+		UnpairKey   = os.Getenv("RABBITMQ_UNPAIR_KEY")
+		WorkersKey  = os.Getenv("RABBITMQ_WORKERS_KEY")
+		SyncKey     = os.Getenv("RABBITMQ_SYNC_KEY")
+		SnapshotKey = os.Getenv("RABBITMQ_SNAPSHOT_KEY")
 	)
 
 	fileHandler, err := lumberjack.New(
@@ -46,7 +50,36 @@ func main() {
 		Level: slog.LevelDebug,
 	}))
 
-	supervisor := visor.NewSupervisor(10, logger)
+	policy, err := visor.ParseRestartPolicy(os.Getenv("SUPERVISOR_RESTART_POLICY"))
+	if err != nil {
+		panic(err.Error())
+	}
+
+	launcher, err := newLauncher(logger, bus)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	// States are published from a single goroutine so reporting never blocks the supervisor.
+	statesCh := make(chan v1.WorkerState, 64)
+	supervisor := visor.NewSupervisor(visor.Params{
+		MaxProcs:       10,
+		Logger:         logger,
+		Launcher:       launcher,
+		Policy:         policy,
+		BackoffInitial: utils.EnvDuration("SUPERVISOR_BACKOFF_INITIAL", time.Second),
+		BackoffMax:     utils.EnvDuration("SUPERVISOR_BACKOFF_MAX", time.Minute),
+		MaxRestarts:    utils.EnvInt("SUPERVISOR_MAX_RESTARTS", 5),
+		StableAfter:    utils.EnvDuration("SUPERVISOR_STABLE_AFTER", time.Minute),
+		StateInterval:  utils.EnvDuration("SUPERVISOR_STATE_INTERVAL", 30*time.Second),
+		OnState: func(state v1.WorkerState) {
+			select {
+			case statesCh <- state:
+			default:
+				logger.Warn("dropping worker state, publisher is behind", "uuid", state.UUID, "status", state.Status)
+			}
+		},
+	})
 	onShutdown := func() {
 		supervisor.Shutdown()
 		_ = bus.Close()
@@ -56,6 +89,26 @@ func main() {
 
 	bus.DeclareQueue(PairKey, true, nil)
 	bus.DeclareQueue(UnpairKey, true, nil)
+	bus.DeclareQueue(WorkersKey, true, nil)
+	bus.DeclareQueue(SyncKey, true, nil)
+	bus.DeclareQueue(SnapshotKey, true, nil)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case state := <-statesCh:
+				bytes, err := json.Marshal(state)
+				if err != nil {
+					continue
+				}
+				if err := bus.Publish(ctx, "", WorkersKey, bytes, nil); err != nil {
+					logger.Error("failed to publish worker state", "uuid", state.UUID, "err", err)
+				}
+			}
+		}
+	}()
 
 	bus.Consume(ctx, PairKey, "supervisor", func(ctx context.Context, m events.Message) events.AckAction {
 		var ev v1.CameraPairedEvent
@@ -63,31 +116,22 @@ func main() {
 			return events.NackRequeue
 		}
 
-		rev, err := supervisor.GetCameraRevision(ev.UUID)
-		if err != nil {
-			supervisor.NotifyCtrl(visor.CtrlEvent{
-				Kind:    visor.CtrlRegister,
-				CamUUID: ev.UUID,
-				Args: []string{
-					"run", "./cmd/motion_detection",
-					"-addr", ev.StreamUrl,
-				},
-			})
-		} else {
-			if rev < ev.Revision {
-				supervisor.NotifyCtrl(visor.CtrlEvent{
-					Kind:    visor.CtrlUnregister,
-					CamUUID: ev.UUID,
-				})
-			}
-
-			supervisor.NotifyCtrl(visor.CtrlEvent{
-				Kind:    visor.CtrlRegister,
-				CamUUID: ev.UUID,
-				Args:    []string{"-addr", ev.StreamUrl},
-			})
+		spec := visor.WorkerSpec{
+			CamUUID:    ev.UUID,
+			StreamUrl:  ev.StreamUrl,
+			RecordPath: ev.RecordPath,
+			Revision:   ev.Revision,
+			Motion:     ev.Motion,
+			Zones:      ev.Zones,
 		}
 
+		// The supervisor replaces the running worker on a newer revision and ignores replays.
+		supervisor.NotifyCtrl(visor.CtrlEvent{
+			Kind:    visor.CtrlRegister,
+			CamUUID: ev.UUID,
+			Spec:    spec,
+		})
+
 		return events.Ack
 	})
 
@@ -105,5 +149,78 @@ func main() {
 		return events.Ack
 	})
 
+	bus.Consume(ctx, SnapshotKey, "supervisor", func(ctx context.Context, m events.Message) events.AckAction {
+		var snapshot v1.PairedCamerasSnapshot
+		if err := json.Unmarshal(m.Body, &snapshot); err != nil {
+			return events.NackDiscard
+		}
+
+		desired := &visor.Desired{
+			Specs:    make([]visor.WorkerSpec, 0, len(snapshot.Cameras)),
+			Complete: snapshot.Complete,
+			AsOf:     snapshot.GeneratedAt,
+		}
+		for _, ev := range snapshot.Cameras {
+			desired.Specs = append(desired.Specs, visor.WorkerSpec{
+				CamUUID:    ev.UUID,
+				StreamUrl:  ev.StreamUrl,
+				RecordPath: ev.RecordPath,
+				Revision:   ev.Revision,
+				Motion:     ev.Motion,
+				Zones:      ev.Zones,
+			})
+		}
+
+		supervisor.NotifyCtrl(visor.CtrlEvent{
+			Kind:    visor.CtrlReconcile,
+			Desired: desired,
+		})
+
+		return events.Ack
+	})
+
+	// Cameras paired before a restart have no worker until the desired state is reconciled,
+	// the periodic sync also recovers from missed pair and unpair messages.
+	go requestSync(ctx, bus, SyncKey, utils.EnvDuration("SUPERVISOR_RECONCILE_INTERVAL", 5*time.Minute), logger)
+
 	supervisor.Run(ctx)
 }
+
+func requestSync(ctx context.Context, bus events.BusIface, key string, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		bytes, err := json.Marshal(v1.WorkersSyncRequest{RequestedAt: time.Now().UTC()})
+		if err == nil {
+			err = bus.Publish(ctx, "", key, bytes, nil)
+		}
+		if err != nil {
+			logger.Error("failed to request workers sync", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Workers run as a prebuilt motion detection binary per camera by default, "inproc" runs them
+// as goroutines of the supervisor so small installs only need a single binary. The inproc
+// launcher links the detectors (gocv), it is only built with the "inproc" tag.
+func newLauncher(logger *slog.Logger, bus *rabbitmq.AMQPBus) (visor.Launcher, error) {
+	switch mode := os.Getenv("SUPERVISOR_WORKER_MODE"); mode {
+	case "", "process":
+		bin := os.Getenv("MOTION_DETECTOR_BIN")
+		if bin == "" {
+			return nil, fmt.Errorf("MOTION_DETECTOR_BIN is required in process mode")
+		}
+		return visor.NewProcessLauncher(bin, logger), nil
+	case "inproc":
+		return newInprocLauncher(logger, bus)
+	default:
+		return nil, fmt.Errorf("unknown worker mode %q", mode)
+	}
+}
@@ -5,17 +5,24 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"tomerab.com/cam-hub/internal/api"
+	"tomerab.com/cam-hub/internal/api/v1/models"
 	"tomerab.com/cam-hub/internal/application"
 	v1 "tomerab.com/cam-hub/internal/contracts/v1"
 	dvripclient "tomerab.com/cam-hub/internal/dvrip"
+	"tomerab.com/cam-hub/internal/mtxapi"
 	"tomerab.com/cam-hub/internal/onvif"
 	"tomerab.com/cam-hub/internal/onvif/discovery"
 	"tomerab.com/cam-hub/internal/repos"
+	"tomerab.com/cam-hub/internal/services"
+	"tomerab.com/cam-hub/internal/zones"
 )
 
 func filterUUIDS(ctx context.Context, camRepo repos.CameraRepoIface, matches []discovery.WsDiscoveryMatch) ([]discovery.WsDiscoveryMatch, error) {
@@ -225,12 +232,24 @@ func getCameraStream(app *application.Application) http.HandlerFunc {
 		defer cancel()
 
 		uuid := r.PathValue("uuid")
-		streamUrl, err := app.MtxClient.Publish(ctx, uuid)
+		profile := r.URL.Query().Get("profile")
+		if profile != "" && !v1.ValidStreamProfile(profile) {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": "unknown stream profile"}, http.StatusBadRequest)
+			return
+		}
+
+		urls, err := app.MtxClient.Publish(ctx, uuid)
 		if err != nil {
 			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusInternalServerError)
 			return
 		}
 
+		// The viewer's profile unless the client asks for a specific one.
+		streamUrl := urls.Viewer
+		if profile != "" {
+			streamUrl = mtxapi.WhepUrl(uuid, profile)
+		}
+
 		app.WriteJSON(w, r, v1.CameraStreamUrl{Url: streamUrl}, http.StatusOK)
 	}
 }
@@ -251,6 +270,60 @@ func deleteCameraStream(app *application.Application) http.HandlerFunc {
 	}
 }
 
+func getCameraSource(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		uuid := r.PathValue("uuid")
+		profile := r.URL.Query().Get("profile")
+		if profile == "" {
+			profile = models.StreamMain
+		}
+		if !v1.ValidStreamProfile(profile) {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": "unknown stream profile"}, http.StatusBadRequest)
+			return
+		}
+
+		source, err := app.MtxClient.Source(ctx, uuid, profile)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+				return
+			}
+			serverError(w, r, err, app.Logger)
+			return
+		}
+
+		app.Logger.Info("handed out camera source", "uuid", uuid, "profile", profile,
+			"transcode", source.Transcode, "codec", source.VideoCodec, "audio", source.AudioCodec, "remote", r.RemoteAddr)
+		app.WriteJSON(w, r, source, http.StatusOK)
+	}
+}
+
+func getCameraOnvif(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		uuid := r.PathValue("uuid")
+		source, err := app.CameraService.OnvifSource(ctx, uuid)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+				return
+			}
+			serverError(w, r, err, app.Logger)
+			return
+		}
+
+		app.Logger.Info("handed out camera onvif source", "uuid", uuid, "remote", r.RemoteAddr)
+		// the body holds the camera credentials
+		w.Header().Set("Cache-Control", "no-store")
+		app.WriteJSON(w, r, source, http.StatusOK)
+	}
+}
+
 func moveCamera(app *application.Application) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -275,22 +348,1251 @@ func moveCamera(app *application.Application) http.HandlerFunc {
 		dto := v1.MoveCameraReq{
 			Translation: req.Translation,
 			Zoom:        req.Zoom,
+			TimeoutMs:   req.TimeoutMs,
 		}
 
-		if err := app.PtzService.MoveCamera(ctx, uuid, dto); err != nil {
-			switch {
-			case errors.Is(err, repos.ErrNotFound):
-				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
-			case errors.Is(err, onvif.ErrNoPtz):
-				app.WriteJSON(w, r, api.ErrorEnvp{"error": "ptz not supported for this camera"}, http.StatusUnprocessableEntity)
-			case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
-				app.WriteJSON(w, r, api.ErrorEnvp{"error": "operation timed out"}, http.StatusGatewayTimeout)
-			default:
-				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
-			}
+		if err := app.PtzService.Controlled(ptzControl(app, r)).MoveCamera(ctx, uuid, dto); err != nil {
+			writePtzError(ctx, app, w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func absoluteMoveCamera(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req v1.AbsoluteMoveReq
+		if err := dec.Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		if err := app.PtzService.Controlled(ptzControl(app, r)).AbsoluteMove(ctx, r.PathValue("uuid"), req); err != nil {
+			writePtzError(ctx, app, w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func relativeMoveCamera(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req v1.RelativeMoveReq
+		if err := dec.Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		if err := app.PtzService.Controlled(ptzControl(app, r)).RelativeMove(ctx, r.PathValue("uuid"), req); err != nil {
+			writePtzError(ctx, app, w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func stopCamera(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		// the body is optional, both axes are stopped without one
+		var req v1.StopCameraReq
+		if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		if err := app.PtzService.Controlled(ptzControl(app, r)).Stop(ctx, r.PathValue("uuid"), req); err != nil {
+			writePtzError(ctx, app, w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func gotoHomePosition(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := app.PtzService.Controlled(ptzControl(app, r)).GotoHome(ctx, r.PathValue("uuid")); err != nil {
+			writePtzError(ctx, app, w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func getPtzStatus(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		status, err := app.PtzService.Status(ctx, r.PathValue("uuid"))
+		if err != nil {
+			writePtzError(ctx, app, w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, r, status, http.StatusOK)
+	}
+}
+
+func getCameraCapabilities(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		refresh, _ := strconv.ParseBool(r.URL.Query().Get("refresh"))
+		caps, err := app.PtzService.Capabilities(ctx, r.PathValue("uuid"), refresh)
+		if err != nil {
+			writePtzError(ctx, app, w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, r, caps, http.StatusOK)
+	}
+}
+
+func getPtzPresets(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		refresh, _ := strconv.ParseBool(r.URL.Query().Get("refresh"))
+		presets, err := app.PtzService.Presets(ctx, r.PathValue("uuid"), refresh)
+		if err != nil {
+			writePtzError(ctx, app, w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, r, presets, http.StatusOK)
+	}
+}
+
+func postPtzPreset(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req v1.SetPresetReq
+		if err := dec.Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		preset, err := app.PtzService.SetPreset(ctx, r.PathValue("uuid"), req)
+		if err != nil {
+			writePtzError(ctx, app, w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, r, preset, http.StatusCreated)
+	}
+}
+
+func gotoPtzPreset(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := app.PtzService.Controlled(ptzControl(app, r)).GotoPreset(ctx, r.PathValue("uuid"), r.PathValue("preset")); err != nil {
+			writePtzError(ctx, app, w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func deletePtzPreset(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := app.PtzService.RemovePreset(ctx, r.PathValue("uuid"), r.PathValue("preset")); err != nil {
+			writePtzError(ctx, app, w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func getPtzTours(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		tours, err := app.PtzToursService.List(ctx, r.PathValue("uuid"))
+		if err != nil {
+			writePtzTourError(app, w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, r, tours, http.StatusOK)
+	}
+}
+
+func postPtzTour(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req v1.PtzTourReq
+		if err := dec.Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		tour, err := app.PtzToursService.Create(ctx, r.PathValue("uuid"), req)
+		if err != nil {
+			writePtzTourError(app, w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, r, tour, http.StatusCreated)
+	}
+}
+
+func putPtzTour(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req v1.PtzTourReq
+		if err := dec.Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		tour, err := app.PtzToursService.Update(ctx, r.PathValue("uuid"), r.PathValue("id"), req)
+		if err != nil {
+			writePtzTourError(app, w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, r, tour, http.StatusOK)
+	}
+}
+
+func deletePtzTour(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := app.PtzToursService.Delete(ctx, r.PathValue("uuid"), r.PathValue("id")); err != nil {
+			writePtzTourError(app, w, r, err)
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+func startPtzTour(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		state, err := app.PtzToursService.Start(ctx, r.PathValue("uuid"), r.PathValue("id"))
+		if err != nil {
+			writePtzTourError(app, w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, r, state, http.StatusAccepted)
+	}
+}
+
+func stopPtzTour(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := app.PtzToursService.Stop(ctx, r.PathValue("uuid")); err != nil {
+			writePtzTourError(app, w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func getPtzTourState(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		state, err := app.PtzToursService.State(ctx, r.PathValue("uuid"))
+		if err != nil {
+			writePtzTourError(app, w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, r, state, http.StatusOK)
+	}
+}
+
+func getPtzLock(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		uuid := r.PathValue("uuid")
+		if !app.CameraService.CameraExists(ctx, uuid) {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			return
+		}
+
+		state, err := app.PtzLockService.State(ctx, uuid)
+		if err != nil {
+			serverError(w, r, err, app.Logger)
+			return
+		}
+
+		app.WriteJSON(w, r, state, http.StatusOK)
+	}
+}
+
+// postPtzLock takes or renews the user's lease, moves take a default length lease by themselves.
+func postPtzLock(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		// the body is optional, the default lease length is used without one
+		var req v1.AcquirePtzLockReq
+		if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		uuid := r.PathValue("uuid")
+		if !app.CameraService.CameraExists(ctx, uuid) {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			return
+		}
+
+		lease, err := app.PtzLockService.Lock(ctx, uuid, services.PtzUserHolder(userFromCtx(r.Context())), req)
+		if err != nil {
+			writePtzError(ctx, app, w, r, err)
+			return
+		}
+
+		app.PtzToursService.Interrupt(uuid)
+		app.WriteJSON(w, r, lease, http.StatusOK)
+	}
+}
+
+func deletePtzLock(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := app.PtzLockService.Release(ctx, r.PathValue("uuid"), services.PtzUserHolder(userFromCtx(r.Context()))); err != nil {
+			writePtzError(ctx, app, w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writePtzTourError(app *application.Application, w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTour):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+	case errors.Is(err, services.ErrCameraNotFound):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+	case errors.Is(err, repos.ErrTourNotFound):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusNotFound)
+	case errors.Is(err, repos.ErrTourNameTaken):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusConflict)
+	default:
+		serverError(w, r, err, app.Logger)
+	}
+}
+
+// ptzControl leases the camera to the user right before a manual move, the camera's tour pauses
+// while the user is in control.
+func ptzControl(app *application.Application, r *http.Request) services.PtzControlFunc {
+	holder := services.PtzUserHolder(userFromCtx(r.Context()))
+	return func(ctx context.Context, uuid string) error {
+		if _, err := app.PtzLockService.Acquire(ctx, uuid, holder, 0); err != nil {
+			return err
+		}
+
+		app.PtzToursService.Interrupt(uuid)
+		return nil
+	}
+}
+
+func writePtzError(ctx context.Context, app *application.Application, w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPtzRequest):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+	case errors.Is(err, repos.ErrNotFound), errors.Is(err, services.ErrCameraNotFound):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+	case errors.Is(err, services.ErrPresetNotFound):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusNotFound)
+	case errors.Is(err, services.ErrPtzLocked):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusConflict)
+	case errors.Is(err, onvif.ErrNoPtz):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": "ptz not supported for this camera"}, http.StatusUnprocessableEntity)
+	case errors.Is(err, services.ErrPtzUnsupported):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusUnprocessableEntity)
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": "operation timed out"}, http.StatusGatewayTimeout)
+	default:
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+	}
+}
+
+func parseRecordingsFilter(r *http.Request) (models.RecordingsFilter, error) {
+	queryParams := r.URL.Query()
+	filter := models.RecordingsFilter{
+		CamUUID: queryParams.Get("cam_id"),
+		State:   queryParams.Get("state"),
+	}
+
+	parseScore := func(key string) (*float32, error) {
+		raw := queryParams.Get(key)
+		if raw == "" {
+			return nil, nil
+		}
+		val, err := strconv.ParseFloat(raw, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		score := float32(val)
+		return &score, nil
+	}
+
+	parseTime := func(key string) (*time.Time, error) {
+		raw := queryParams.Get(key)
+		if raw == "" {
+			return nil, nil
+		}
+		ts, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		return &ts, nil
+	}
+
+	var err error
+	if filter.MinScore, err = parseScore("min_score"); err != nil {
+		return filter, err
+	}
+	if filter.MaxScore, err = parseScore("max_score"); err != nil {
+		return filter, err
+	}
+	if filter.From, err = parseTime("from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTime("to"); err != nil {
+		return filter, err
+	}
+
+	if raw := queryParams.Get("limit"); raw != "" {
+		if filter.Limit, err = strconv.Atoi(raw); err != nil {
+			return filter, fmt.Errorf("invalid limit: %w", err)
+		}
+	}
+
+	return filter, nil
+}
+
+func getRecordings(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		filter, err := parseRecordingsFilter(r)
+		if err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		page, err := app.RecordingsService.Query(ctx, filter, r.URL.Query().Get("cursor"))
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidCursor) || errors.Is(err, services.ErrInvalidFilter):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, page, http.StatusOK)
+	}
+}
+
+func getRecording(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		id := r.PathValue("id")
+		if err := uuid.Validate(id); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": "invalid recording id"}, http.StatusBadRequest)
+			return
+		}
+
+		details, err := app.RecordingsService.GetWithUrls(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, repos.ErrRecordingNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "recording not found"}, http.StatusNotFound)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, details, http.StatusOK)
+	}
+}
+
+func getRetentionStats(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		app.WriteJSON(w, r, app.RetentionService.Stats(), http.StatusOK)
+	}
+}
+
+func getMotionSettings(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		settings, err := app.MotionSettingsService.Get(ctx, r.PathValue("uuid"))
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrCameraNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, settings, http.StatusOK)
+	}
+}
+
+func putMotionSettings(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req v1.MotionSettings
+		if err := dec.Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		if err := app.MotionSettingsService.Update(ctx, r.PathValue("uuid"), req); err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidMotionSettings):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			case errors.Is(err, services.ErrCameraNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, req, http.StatusOK)
+	}
+}
+
+func getMotionZones(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		zs, err := app.MotionSettingsService.GetZones(ctx, r.PathValue("uuid"))
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrCameraNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, v1.MotionZones{Zones: zs}, http.StatusOK)
+	}
+}
+
+func putMotionZones(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req v1.MotionZones
+		if err := dec.Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if req.Zones == nil {
+			req.Zones = []zones.Zone{}
+		}
+
+		if err := app.MotionSettingsService.UpdateZones(ctx, r.PathValue("uuid"), req.Zones); err != nil {
+			switch {
+			case errors.Is(err, zones.ErrInvalidZone):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			case errors.Is(err, services.ErrCameraNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, req, http.StatusOK)
+	}
+}
+
+func getWorkers(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		app.WriteJSON(w, r, app.WorkersService.List(), http.StatusOK)
+	}
+}
+
+func getWorker(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, ok := app.WorkersService.Get(r.PathValue("uuid"))
+		if !ok {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": "worker not found"}, http.StatusNotFound)
+			return
+		}
+
+		app.WriteJSON(w, r, state, http.StatusOK)
+	}
+}
+
+func login(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		var req v1.LoginReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		resp, err := app.AuthService.Login(ctx, req.Username, req.Password)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidCredentials):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusUnauthorized)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, resp, http.StatusOK)
+	}
+}
+
+func logout(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := app.AuthService.Logout(ctx, sessionToken(r)); err != nil {
+			serverError(w, r, err, app.Logger)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func getMe(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		app.WriteJSON(w, r, userFromCtx(r.Context()), http.StatusOK)
+	}
+}
+
+func getUsers(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		users, err := app.AuthService.ListUsers(ctx)
+		if err != nil {
+			serverError(w, r, err, app.Logger)
+			return
+		}
+
+		app.WriteJSON(w, r, users, http.StatusOK)
+	}
+}
+
+func createUser(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req v1.CreateUserReq
+		if err := dec.Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		user, err := app.AuthService.CreateUser(ctx, req)
+		if err != nil {
+			writeUserError(app, w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, r, user, http.StatusCreated)
+	}
+}
+
+func updateUser(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req v1.UpdateUserReq
+		if err := dec.Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		user, err := app.AuthService.UpdateUser(ctx, r.PathValue("id"), req, sessionToken(r))
+		if err != nil {
+			writeUserError(app, w, r, err)
+			return
+		}
+
+		app.WriteJSON(w, r, user, http.StatusOK)
+	}
+}
+
+func deleteUser(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := app.AuthService.DeleteUser(ctx, r.PathValue("id")); err != nil {
+			writeUserError(app, w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeUserError(app *application.Application, w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidUser):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+	case errors.Is(err, repos.ErrUserNotFound):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusNotFound)
+	case errors.Is(err, repos.ErrUsernameTaken), errors.Is(err, services.ErrLastAdmin):
+		app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusConflict)
+	default:
+		serverError(w, r, err, app.Logger)
+	}
+}
+
+func getCameraStreams(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		streams, err := app.StreamsService.Get(ctx, r.PathValue("uuid"))
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrCameraNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, streams, http.StatusOK)
+	}
+}
+
+func putStreamSelection(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req v1.StreamSelection
+		if err := dec.Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		if err := app.StreamsService.UpdateSelection(ctx, r.PathValue("uuid"), req); err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidStreamSelection):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			case errors.Is(err, services.ErrCameraNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, req, http.StatusOK)
+	}
+}
+
+func putTranscodePolicy(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req v1.TranscodePolicyReq
+		if err := dec.Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		if err := app.StreamsService.UpdateTranscodePolicy(ctx, r.PathValue("uuid"), req.Policy); err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidTranscodePolicy):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			case errors.Is(err, services.ErrCameraNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, req, http.StatusOK)
+	}
+}
+
+func getRecordingSettings(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		settings, err := app.ContinuousRecordingService.GetSettings(ctx, r.PathValue("uuid"))
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrCameraNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, settings, http.StatusOK)
+	}
+}
+
+func putRecordingSettings(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req v1.RecordingSettings
+		if err := dec.Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		if err := app.ContinuousRecordingService.UpdateSettings(ctx, r.PathValue("uuid"), req); err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidRecordingSettings):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			case errors.Is(err, services.ErrCameraNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, req, http.StatusOK)
+	}
+}
+
+// parseTimelineDay returns the bounds of the requested day (date=YYYY-MM-DD, today when
+// missing) in the requested time zone (tz, an IANA name, UTC when missing).
+func parseTimelineDay(r *http.Request) (time.Time, time.Time, error) {
+	queryParams := r.URL.Query()
+
+	loc := time.UTC
+	if raw := queryParams.Get("tz"); raw != "" {
+		var err error
+		if loc, err = time.LoadLocation(raw); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid tz: %w", err)
+		}
+	}
+
+	day := time.Now().In(loc)
+	if raw := queryParams.Get("date"); raw != "" {
+		var err error
+		if day, err = time.ParseInLocation(time.DateOnly, raw, loc); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid date: %w", err)
+		}
+	}
+
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	return from, from.AddDate(0, 0, 1), nil
+}
+
+func getRecordingTimeline(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		from, to, err := parseTimelineDay(r)
+		if err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		timeline, err := app.ContinuousRecordingService.Timeline(ctx, r.PathValue("uuid"), from, to)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrCameraNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, timeline, http.StatusOK)
+	}
+}
+
+// getRecordingPlayback streams a span of the timeline (profile, start as RFC 3339 and duration
+// in seconds, the query of the span's playback_url) from the mediamtx playback server.
+func getRecordingPlayback(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		queryParams := r.URL.Query()
+
+		start, err := time.Parse(time.RFC3339Nano, queryParams.Get("start"))
+		if err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": "invalid start: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		secs, err := strconv.ParseFloat(queryParams.Get("duration"), 64)
+		if err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": "invalid duration: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		profile := queryParams.Get("profile")
+		if profile == "" {
+			profile = models.StreamMain
+		}
+
+		resp, err := app.ContinuousRecordingService.Playback(r.Context(), r.PathValue("uuid"), profile, start, time.Duration(secs*float64(time.Second)))
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidPlayback):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			case errors.Is(err, services.ErrCameraNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			case errors.Is(err, mtxapi.ErrNoRecordings):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusNotFound)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+		defer resp.Body.Close()
+
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		if length := resp.Header.Get("Content-Length"); length != "" {
+			w.Header().Set("Content-Length", length)
+		}
+		w.WriteHeader(http.StatusOK)
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			app.Logger.Warn("playback download interrupted", "uuid", r.PathValue("uuid"), "err", err)
+		}
+	}
+}
+
+func postRecordingSegment(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		var req v1.RecordingSegmentEvent
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		if err := app.ContinuousRecordingService.IndexSegment(ctx, req); err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidSegment):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func postExport(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+
+		var req v1.ExportReq
+		if err := dec.Decode(&req); err != nil {
+			app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		job, err := app.ExportsService.Create(ctx, r.PathValue("uuid"), req)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidExportRange):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusBadRequest)
+			case errors.Is(err, services.ErrCameraNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "camera not found"}, http.StatusNotFound)
+			case errors.Is(err, services.ErrNoRecordings):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusUnprocessableEntity)
+			case errors.Is(err, services.ErrTooManyExports):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusTooManyRequests)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, job, http.StatusAccepted)
+	}
+}
+
+func getExport(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		job, err := app.ExportsService.Get(ctx, r.PathValue("id"))
+		if err == nil && job.UUID != r.PathValue("uuid") {
+			err = services.ErrExportNotFound
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrExportNotFound):
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "export not found"}, http.StatusNotFound)
+			default:
+				serverError(w, r, err, app.Logger)
+			}
+			return
+		}
+
+		app.WriteJSON(w, r, job, http.StatusOK)
+	}
+}
+
+// ptzLockSSE sends the camera's current lease followed by every change, including the lease
+// running out.
+func ptzLockSSE(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := r.Context()
+		uuid := r.PathValue("uuid")
+		if !app.CameraService.CameraExists(ctx, uuid) {
+			http.Error(w, fmt.Sprintf("cameras (%s) does not exist", uuid), http.StatusBadRequest)
+			return
+		}
+
+		topic := app.PtzLockService.Topic(uuid)
+		// Subscribed before the state is read so no change falls between the two.
+		subCh := app.PubSub.Subscribe(topic)
+		defer app.PubSub.Unsubscribe(topic, subCh)
+
+		state, err := app.PtzLockService.State(ctx, uuid)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		bytes, _ := json.Marshal(state)
+		fmt.Fprintf(w, "data: %s\n\n", bytes)
+		flusher.Flush()
+
+		keepAliveTicker := time.NewTicker(time.Second * 10)
+		defer keepAliveTicker.Stop()
+
+		for {
+			select {
+			case msg := <-subCh:
+				fmt.Fprintf(w, "data: %s\n\n", msg)
+				flusher.Flush()
+			case <-keepAliveTicker.C:
+				fmt.Fprint(w, ":\n\n")
+				flusher.Flush()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// exportSSE sends the export's current state followed by its updates, the stream ends once the
+// export is done or failed.
+func exportSSE(app *application.Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := r.Context()
+		id := r.PathValue("id")
+		topic := app.ExportsService.Topic(id)
+		// Subscribed before the state is read so no update falls between the two.
+		subCh := app.PubSub.Subscribe(topic)
+		defer app.PubSub.Unsubscribe(topic, subCh)
+
+		job, err := app.ExportsService.Get(ctx, id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("export (%s) does not exist", id), http.StatusNotFound)
+			return
+		}
+
+		send := func(job *v1.ExportJob) bool {
+			bytes, err := json.Marshal(job)
+			if err != nil {
+				return false
+			}
+			fmt.Fprintf(w, "data: %s\n\n", bytes)
+			flusher.Flush()
+			return job.Status != v1.ExportDone && job.Status != v1.ExportFailed
+		}
+		if !send(job) {
+			return
+		}
+
+		keepAliveTicker := time.NewTicker(time.Second * 10)
+		defer keepAliveTicker.Stop()
+
+		for {
+			select {
+			case msg := <-subCh:
+				var job v1.ExportJob
+				if err := json.Unmarshal(msg, &job); err != nil {
+					continue
+				}
+				if !send(&job) {
+					return
+				}
+			case <-keepAliveTicker.C:
+				fmt.Fprint(w, ":\n\n")
+				flusher.Flush()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"tomerab.com/cam-hub/internal/api"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	"tomerab.com/cam-hub/internal/application"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/services"
+)
+
+type ctxKey int
+
+const userCtxKey ctxKey = iota
+
+// authenticate rejects requests without a valid session and stores the user in the context.
+func authenticate(app *application.Application) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			user, err := app.AuthService.Authenticate(ctx, sessionToken(r))
+			cancel()
+			if err != nil {
+				if errors.Is(err, services.ErrInvalidSession) {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					app.WriteJSON(w, r, api.ErrorEnvp{"error": err.Error()}, http.StatusUnauthorized)
+					return
+				}
+				serverError(w, r, err, app.Logger)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userCtxKey, user)))
+		})
+	}
+}
+
+// requireRole must run after authenticate, roles rank viewer < operator < admin.
+func requireRole(app *application.Application, role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := userFromCtx(r.Context())
+			if user == nil || !v1.RoleAllows(user.Role, role) {
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "requires the " + role + " role"}, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireInternalToken rejects every request when no INTERNAL_API_TOKEN is configured.
+func requireInternalToken(app *application.Application) func(http.Handler) http.Handler {
+	token := os.Getenv("INTERNAL_API_TOKEN")
+	if token == "" {
+		app.Logger.Warn("INTERNAL_API_TOKEN is not set, the internal api is disabled")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token == "" || !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				app.WriteJSON(w, r, api.ErrorEnvp{"error": "invalid internal token"}, http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func userFromCtx(ctx context.Context) *models.User {
+	user, _ := ctx.Value(userCtxKey).(*models.User)
+	return user
+}
+
+// The token comes from the Authorization header, the access_token query param of the SSE
+// endpoints is moved there before the request is logged (see httpserver.queryAccessToken).
+func sessionToken(r *http.Request) string {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return strings.TrimSpace(token)
+}
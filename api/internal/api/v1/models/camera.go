@@ -10,4 +10,5 @@ type Camera struct {
 	HardwareId      string `json:"hardware_id"`
 	Addr            string `json:"addr"`
 	Version         int    `json:"version"`
+	TranscodePolicy string `json:"transcode_policy"`
 }
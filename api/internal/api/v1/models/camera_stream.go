@@ -0,0 +1,24 @@
+package models
+
+const (
+	StreamMain = "main"
+	StreamSub  = "sub"
+)
+
+type CameraStream struct {
+	CamUUID       string `json:"cam_id" db:"cam_id"`
+	Profile       string `json:"profile"`
+	Token         string `json:"token"`
+	Uri           string `json:"uri"`
+	Encoding      string `json:"encoding"`
+	AudioEncoding string `json:"audio_encoding" db:"audio_encoding"`
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+}
+
+type StreamSelection struct {
+	UUID     string `json:"uuid" db:"id"`
+	Viewer   string `json:"viewer"`
+	Recorder string `json:"recorder"`
+	Motion   string `json:"motion"`
+}
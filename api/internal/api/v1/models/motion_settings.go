@@ -0,0 +1,25 @@
+package models
+
+import "tomerab.com/cam-hub/internal/zones"
+
+type MotionSettings struct {
+	UUID         string  `json:"uuid" db:"id"`
+	Threshold    float32 `json:"threshold"`
+	MinArea      int     `json:"min_area"`
+	WarmupFrames int     `json:"warmup_frames"`
+	TickMs       int     `json:"tick_ms"`
+	CooldownMs   int     `json:"cooldown_ms"`
+	PreRollMs    int     `json:"pre_roll_ms"`
+	PostRollMs   int     `json:"post_roll_ms"`
+	MaxEventMs   int     `json:"max_event_ms"`
+	Source       string  `json:"source"`
+}
+
+type MotionZone struct {
+	Id        string        `json:"id"`
+	CamUUID   string        `json:"cam_id" db:"cam_id"`
+	Name      string        `json:"name"`
+	Kind      string        `json:"kind"`
+	Points    []zones.Point `json:"points" db:"-"`
+	PointsRaw []byte        `json:"-" db:"points"`
+}
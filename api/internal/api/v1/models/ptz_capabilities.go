@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+type PtzRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+type PtzRange2D struct {
+	X PtzRange `json:"x"`
+	Y PtzRange `json:"y"`
+}
+
+// PtzSpaces are the ranges of the generic spaces the moves are sent in, nil when the camera
+// doesn't support the space.
+type PtzSpaces struct {
+	AbsolutePanTilt   *PtzRange2D `json:"absolute_pan_tilt,omitempty"`
+	AbsoluteZoom      *PtzRange   `json:"absolute_zoom,omitempty"`
+	RelativePanTilt   *PtzRange2D `json:"relative_pan_tilt,omitempty"`
+	RelativeZoom      *PtzRange   `json:"relative_zoom,omitempty"`
+	ContinuousPanTilt *PtzRange2D `json:"continuous_pan_tilt,omitempty"`
+	ContinuousZoom    *PtzRange   `json:"continuous_zoom,omitempty"`
+	PanTiltSpeed      *PtzRange   `json:"pan_tilt_speed,omitempty"`
+	ZoomSpeed         *PtzRange   `json:"zoom_speed,omitempty"`
+}
+
+type PtzCapabilities struct {
+	CamUUID       string    `json:"cam_id" db:"cam_id"`
+	Supported     bool      `json:"supported"`
+	Spaces        PtzSpaces `json:"spaces" db:"-"`
+	SpacesRaw     []byte    `json:"-" db:"spaces"`
+	MaxPresets    int       `json:"max_presets" db:"max_presets"`
+	HomeSupported bool      `json:"home_supported" db:"home_supported"`
+	MinTimeoutMs  *int      `json:"min_timeout_ms" db:"min_timeout_ms"`
+	MaxTimeoutMs  *int      `json:"max_timeout_ms" db:"max_timeout_ms"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,10 @@
+package models
+
+type PtzPreset struct {
+	CamUUID string   `json:"cam_id" db:"cam_id"`
+	Token   string   `json:"token"`
+	Name    string   `json:"name"`
+	Pan     *float64 `json:"pan"`
+	Tilt    *float64 `json:"tilt"`
+	Zoom    *float64 `json:"zoom"`
+}
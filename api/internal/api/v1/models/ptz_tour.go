@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type PtzTourStop struct {
+	Preset  string `json:"preset"`
+	DwellMs int    `json:"dwell_ms"`
+}
+
+type PtzTour struct {
+	Id        string        `json:"id"`
+	CamUUID   string        `json:"cam_id" db:"cam_id"`
+	Name      string        `json:"name"`
+	Stops     []PtzTourStop `json:"stops" db:"-"`
+	StopsRaw  []byte        `json:"-" db:"stops"`
+	Schedule  *string       `json:"schedule"`
+	Loops     int           `json:"loops"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at" db:"updated_at"`
+}
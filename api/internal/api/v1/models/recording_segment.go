@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type RecordingSettings struct {
+	UUID          string `json:"uuid" db:"id"`
+	Continuous    bool   `json:"continuous"`
+	RetentionDays int    `json:"retention_days" db:"retention_days"`
+}
+
+// RecordingSegment is a segment file mediamtx completed for a continuously recorded path.
+type RecordingSegment struct {
+	Id       int64     `json:"id"`
+	CamUUID  string    `json:"cam_id" db:"cam_id"`
+	MtxPath  string    `json:"mtx_path" db:"mtx_path"`
+	FileName string    `json:"file_name" db:"file_name"`
+	StartTs  time.Time `json:"start_ts" db:"start_ts"`
+	EndTs    time.Time `json:"end_ts" db:"end_ts"`
+}
+
+// RecordingSpan is a run of segments of the same path with no gap between them.
+type RecordingSpan struct {
+	MtxPath string    `json:"mtx_path" db:"mtx_path"`
+	StartTs time.Time `json:"start_ts" db:"start_ts"`
+	EndTs   time.Time `json:"end_ts" db:"end_ts"`
+}
@@ -10,21 +10,42 @@ type Evidence struct {
 	Ymin float32 `json:"y_min"`
 	Xmax float32 `json:"x_max"`
 	Ymax float32 `json:"y_max"`
+	// Class label of the detection and the index of the extracted frame it was found in.
+	Label string `json:"label"`
+	Frame int    `json:"frame"`
 }
 
 type Recordings struct {
-	Id                 string    `json:"id" db:"id"`
-	CamUUID            string    `json:"cam_id" db:"cam_id"`
-	BucketName         string    `json:"bucket_name" db:"bucket_name"`
-	VidBucketKey       string    `json:"vid_key" db:"vid_key"`
-	BestFrameBucketKey string    `json:"best_frame_key" db:"best_frame_key"`
-	Evidence           Evidence  `json:"evidence" db:"-"`
-	EvidenceRaw        []byte    `json:"-" db:"evidence"`
-	Score              float32   `json:"score" db:"score"`
-	State              string    `json:"state" db:"state"`
-	NeedsPublish       bool      `json:"needs_publish" db:"needs_publish"`
-	PromotedAt         time.Time `json:"promoted_at" db:"promoted_at"`
-	RetentionDays      int       `json:"retention_days" db:"retention_days"`
-	StartTs            time.Time `json:"start_ts" db:"start_ts"`
-	EndTs              time.Time `json:"end_ts" db:"end_ts"`
+	Id                 string     `json:"id" db:"id"`
+	CamUUID            string     `json:"cam_id" db:"cam_id"`
+	BucketName         string     `json:"bucket_name" db:"bucket_name"`
+	VidBucketKey       string     `json:"vid_key" db:"vid_key"`
+	BestFrameBucketKey string     `json:"best_frame_key" db:"best_frame_key"`
+	Evidence           []Evidence `json:"evidence" db:"-"`
+	EvidenceRaw        []byte     `json:"-" db:"evidence"`
+	Score              float32    `json:"score" db:"score"`
+	State              string     `json:"state" db:"state"`
+	NeedsPublish       bool       `json:"needs_publish" db:"needs_publish"`
+	PromotedAt         time.Time  `json:"promoted_at" db:"promoted_at"`
+	RetentionDays      int        `json:"retention_days" db:"retention_days"`
+	StartTs            time.Time  `json:"start_ts" db:"start_ts"`
+	EndTs              time.Time  `json:"end_ts" db:"end_ts"`
+}
+
+// RecordingsCursor points at the last row of a page, rows are ordered by
+// (promoted_at DESC, id DESC) so the pair is enough to resume the scan.
+type RecordingsCursor struct {
+	PromotedAt time.Time
+	Id         string
+}
+
+type RecordingsFilter struct {
+	CamUUID  string
+	State    string
+	MinScore *float32
+	MaxScore *float32
+	From     *time.Time // the clip ends at or after From
+	To       *time.Time // the clip starts before To
+	After    *RecordingsCursor
+	Limit    int
 }
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type User struct {
+	Id           string    `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-" db:"password_hash"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
@@ -6,27 +6,112 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"tomerab.com/cam-hub/internal/application"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
 )
 
 func LoadRoutes(app *application.Application) *chi.Mux {
 	r := chi.NewRouter()
 
-	r.Route("/cameras", func(r chi.Router) {
-		rt := r.With(middleware.Timeout(60 * time.Second))
+	r.Post("/auth/login", login(app))
 
-		rt.Get("/discovery", getDiscoveredDevices(app))
-		rt.Get("/{uuid}/stream", getCameraStream(app))
-		rt.Delete("/{uuid}/stream", deleteCameraStream(app))
-		rt.Get("/", getCameras(app))
-		rt.Post("/{uuid}/pair", pairCamera(app))
-		rt.Delete("/{uuid}/pair", unpairCamera(app))
-		rt.Post("/{uuid}/ptz/move", moveCamera(app))
+	r.Group(func(r chi.Router) {
+		r.Use(authenticate(app))
+
+		operator := requireRole(app, v1.RoleOperator)
+		admin := requireRole(app, v1.RoleAdmin)
+
+		r.Post("/auth/logout", logout(app))
+		r.Get("/auth/me", getMe(app))
+
+		r.Route("/users", func(r chi.Router) {
+			r.Use(admin)
+
+			r.Get("/", getUsers(app))
+			r.Post("/", createUser(app))
+			r.Patch("/{id}", updateUser(app))
+			r.Delete("/{id}", deleteUser(app))
+		})
+
+		r.Route("/cameras", func(r chi.Router) {
+			rt := r.With(middleware.Timeout(60 * time.Second))
+
+			rt.Get("/discovery", getDiscoveredDevices(app))
+			rt.Get("/{uuid}/stream", getCameraStream(app))
+			rt.With(operator).Delete("/{uuid}/stream", deleteCameraStream(app))
+			rt.Get("/{uuid}/streams", getCameraStreams(app))
+			rt.Get("/{uuid}/capabilities", getCameraCapabilities(app))
+			rt.With(operator).Put("/{uuid}/streams/selection", putStreamSelection(app))
+			rt.With(operator).Put("/{uuid}/streams/transcode", putTranscodePolicy(app))
+			rt.Get("/", getCameras(app))
+			rt.With(admin).Post("/{uuid}/pair", pairCamera(app))
+			rt.With(admin).Delete("/{uuid}/pair", unpairCamera(app))
+			rt.With(operator).Post("/{uuid}/ptz/move", moveCamera(app))
+			rt.With(operator).Post("/{uuid}/ptz/absolute", absoluteMoveCamera(app))
+			rt.With(operator).Post("/{uuid}/ptz/relative", relativeMoveCamera(app))
+			rt.With(operator).Post("/{uuid}/ptz/stop", stopCamera(app))
+			rt.With(operator).Post("/{uuid}/ptz/home", gotoHomePosition(app))
+			rt.Get("/{uuid}/ptz/status", getPtzStatus(app))
+			rt.Get("/{uuid}/ptz/presets", getPtzPresets(app))
+			rt.With(operator).Post("/{uuid}/ptz/presets", postPtzPreset(app))
+			rt.With(operator).Post("/{uuid}/ptz/presets/{preset}/goto", gotoPtzPreset(app))
+			rt.With(operator).Delete("/{uuid}/ptz/presets/{preset}", deletePtzPreset(app))
+			rt.Get("/{uuid}/ptz/tours", getPtzTours(app))
+			rt.With(operator).Post("/{uuid}/ptz/tours", postPtzTour(app))
+			rt.With(operator).Put("/{uuid}/ptz/tours/{id}", putPtzTour(app))
+			rt.With(operator).Delete("/{uuid}/ptz/tours/{id}", deletePtzTour(app))
+			rt.With(operator).Post("/{uuid}/ptz/tours/{id}/start", startPtzTour(app))
+			rt.Get("/{uuid}/ptz/tour", getPtzTourState(app))
+			rt.With(operator).Post("/{uuid}/ptz/tour/stop", stopPtzTour(app))
+			rt.Get("/{uuid}/ptz/lock", getPtzLock(app))
+			rt.With(operator).Post("/{uuid}/ptz/lock", postPtzLock(app))
+			rt.With(operator).Delete("/{uuid}/ptz/lock", deletePtzLock(app))
+			rt.Get("/{uuid}/motion/settings", getMotionSettings(app))
+			rt.With(operator).Put("/{uuid}/motion/settings", putMotionSettings(app))
+			rt.Get("/{uuid}/motion/zones", getMotionZones(app))
+			rt.With(operator).Put("/{uuid}/motion/zones", putMotionZones(app))
+			rt.Get("/{uuid}/recording/settings", getRecordingSettings(app))
+			rt.With(admin).Put("/{uuid}/recording/settings", putRecordingSettings(app))
+			rt.Get("/{uuid}/recording/timeline", getRecordingTimeline(app))
+			// no timeout, a clip of a long span takes a while to download
+			r.Get("/{uuid}/recording/playback", getRecordingPlayback(app))
+			rt.With(operator).Post("/{uuid}/exports", postExport(app))
+			rt.Get("/{uuid}/exports/{id}", getExport(app))
+		})
+
+		r.Route("/recordings", func(r chi.Router) {
+			rt := r.With(middleware.Timeout(60 * time.Second))
+
+			rt.Get("/", getRecordings(app))
+			rt.Get("/{id}", getRecording(app))
+		})
+
+		r.Get("/retention/stats", getRetentionStats(app))
+
+		r.Get("/workers", getWorkers(app))
+		r.Get("/workers/{uuid}", getWorker(app))
+
+		r.With(admin).Get("/test", playground(app))
+
+		r.Get("/events/discovery", discoverySSE(app))
+		r.Get("/events/recordings/{uuid}", alertsSSE(app))
+		r.Get("/events/exports/{id}", exportSSE(app))
+		r.Get("/events/ptz/{uuid}", ptzLockSSE(app))
 	})
 
-	r.Get("/test", playground(app))
+	return r
+}
+
+// LoadInternalRoutes serves the services running next to the api (e.g. the mediamtx helper and
+// the motion detectors), they authenticate with the shared INTERNAL_API_TOKEN instead of a user
+// session. The routes hand out camera credentials, they are only served on the internal listener
+// (see httpserver.NewInternalRouter), never next to LoadRoutes.
+func LoadInternalRoutes(app *application.Application) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(requireInternalToken(app))
 
-	r.Get("/events/discovery", discoverySSE(app))
-	r.Get("/events/recordings/{uuid}", alertsSSE(app))
+	r.Get("/cameras/{uuid}/source", getCameraSource(app))
+	r.Get("/cameras/{uuid}/onvif", getCameraOnvif(app))
+	r.Post("/recordings/segments", postRecordingSegment(app))
 
 	return r
 }
@@ -17,18 +17,28 @@ import (
 )
 
 type Application struct {
-	Logger             *slog.Logger
-	DB                 *pgxpool.Pool
-	HttpClient         *http.Client
-	CameraService      *services.CameraService
-	DiscoveryService   *services.DiscoveryService
-	PtzService         *services.PtzService
-	MtxClient          *mtxapi.MtxClient
-	Bus                events.BusIface
-	PubSub             *inmemory.InMemoryPubSub
-	SseChan            chan v1.DiscoveryEvent
-	CamsEventProxyChan chan v1.CameraProxyEvent
-	LogSink            lumberjack.Writer
+	Logger                     *slog.Logger
+	DB                         *pgxpool.Pool
+	HttpClient                 *http.Client
+	CameraService              *services.CameraService
+	DiscoveryService           *services.DiscoveryService
+	PtzService                 *services.PtzService
+	PtzToursService            *services.PtzToursService
+	PtzLockService             *services.PtzLockService
+	RecordingsService          *services.RecordingsService
+	RetentionService           *services.RetentionService
+	ContinuousRecordingService *services.ContinuousRecordingService
+	ExportsService             *services.ExportsService
+	MotionSettingsService      *services.MotionSettingsService
+	StreamsService             *services.StreamsService
+	WorkersService             *services.WorkersService
+	AuthService                *services.AuthService
+	MtxClient                  *mtxapi.MtxClient
+	Bus                        events.BusIface
+	PubSub                     *inmemory.InMemoryPubSub
+	SseChan                    chan v1.DiscoveryEvent
+	CamsEventProxyChan         chan v1.CameraProxyEvent
+	LogSink                    lumberjack.Writer
 }
 
 func (app *Application) OnStartup(ctx context.Context) {
@@ -79,6 +89,7 @@ func (app *Application) publishEvent(ctx context.Context, key string, ev *v1.Cam
 
 	switch key {
 	case keyPair:
+		app.attachMotionSettings(ctx, ev.CameraPairedEvent)
 		bytes, err = json.Marshal(ev.CameraPairedEvent)
 	case keyUnpair:
 		bytes, err = json.Marshal(ev.CameraUnpairedEvent)
@@ -90,3 +101,46 @@ func (app *Application) publishEvent(ctx context.Context, key string, ev *v1.Cam
 
 	return app.Bus.Publish(ctx, "", key, bytes, nil)
 }
+
+// PublishPairedSnapshot answers a supervisor sync request with every paired camera.
+func (app *Application) PublishPairedSnapshot(ctx context.Context) error {
+	snapshot, err := app.CameraService.PairedSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range snapshot.Cameras {
+		app.attachMotionSettings(ctx, &snapshot.Cameras[i])
+	}
+
+	bytes, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	return app.Bus.Publish(ctx, "", os.Getenv("RABBITMQ_SNAPSHOT_KEY"), bytes, nil)
+}
+
+// The supervisor starts the detector with the settings and zones carried by the paired event,
+// when they can't be loaded the detector falls back to its defaults.
+func (app *Application) attachMotionSettings(ctx context.Context, ev *v1.CameraPairedEvent) {
+	if app.MotionSettingsService == nil {
+		return
+	}
+
+	if ev.Motion == nil {
+		settings, err := app.MotionSettingsService.Get(ctx, ev.UUID)
+		if err != nil {
+			app.Logger.Warn("failed to load motion settings for paired camera", "uuid", ev.UUID, "err", err)
+		}
+		ev.Motion = settings
+	}
+
+	if ev.Zones == nil {
+		zones, err := app.MotionSettingsService.GetZones(ctx, ev.UUID)
+		if err != nil {
+			app.Logger.Warn("failed to load motion zones for paired camera", "uuid", ev.UUID, "err", err)
+		}
+		ev.Zones = zones
+	}
+}
@@ -1,9 +1,12 @@
 package v1
 
 import (
+	"path"
 	"time"
 
+	"tomerab.com/cam-hub/internal/api/v1/models"
 	"tomerab.com/cam-hub/internal/utils"
+	"tomerab.com/cam-hub/internal/zones"
 )
 
 type PairDeviceReq struct {
@@ -27,9 +30,12 @@ type DiscoveryEvent struct {
 }
 
 type CameraPairedEvent struct {
-	UUID      string `json:"uuid"`
-	StreamUrl string `json:"url"`
-	Revision  int    `json:"revision"`
+	UUID       string          `json:"uuid"`
+	StreamUrl  string          `json:"url"` // the profile selected for motion detection
+	RecordPath string          `json:"record_path,omitempty"`
+	Revision   int             `json:"revision"`
+	Motion     *MotionSettings `json:"motion,omitempty"`
+	Zones      []zones.Zone    `json:"zones,omitempty"`
 }
 
 type CameraProxyEvent struct {
@@ -38,10 +44,18 @@ type CameraProxyEvent struct {
 }
 
 type AnalyzeImgsEvent struct {
-	UUID       string   `json:"uuid"`
-	Tp         string   `json:"tp"`
-	VidPath    string   `json:"vid_path"`
-	FramePaths []string `json:"frame_paths"`
+	UUID       string    `json:"uuid"`
+	Tp         string    `json:"tp"`
+	VidPath    string    `json:"vid_path"`
+	FramePaths []string  `json:"frame_paths"`
+	StartTs    time.Time `json:"start_ts"` // bounds of the clip, pre and post-roll included
+	EndTs      time.Time `json:"end_ts"`
+}
+
+// StoredKey is the key a staged object of the event is kept under once the analyzer classified
+// it, <whereToStore>/<uuid>/<tp>/<name>.
+func (ev *AnalyzeImgsEvent) StoredKey(whereToStore, stagedKey string) string {
+	return path.Join(whereToStore, ev.UUID, ev.Tp, path.Base(stagedKey))
 }
 
 type CameraUnpairedEvent struct {
@@ -52,25 +66,280 @@ type CameraStreamUrl struct {
 	Url string `json:"url"`
 }
 
+// Which profile the viewer, the recorder and the motion detector of a camera consume.
+type StreamSelection struct {
+	Viewer   string `json:"viewer"`
+	Recorder string `json:"recorder"`
+	Motion   string `json:"motion"`
+}
+
+// Motion runs on the cheap substream, the viewer and the recorder get the full resolution.
+func DefaultStreamSelection() StreamSelection {
+	return StreamSelection{
+		Viewer:   models.StreamMain,
+		Recorder: models.StreamMain,
+		Motion:   models.StreamSub,
+	}
+}
+
+func ValidStreamProfile(profile string) bool {
+	return profile == models.StreamMain || profile == models.StreamSub
+}
+
+const (
+	TranscodeAuto   = "auto"   // copy browser compatible video, re-encode the rest
+	TranscodeAlways = "always" // re-encode everything
+	TranscodeNever  = "never"  // copy the video even when browsers can't play it
+)
+
+func ValidTranscodePolicy(policy string) bool {
+	return policy == TranscodeAuto || policy == TranscodeAlways || policy == TranscodeNever
+}
+
+type CameraStreams struct {
+	Streams         []*models.CameraStream `json:"streams"`
+	Selection       StreamSelection        `json:"selection"`
+	TranscodePolicy string                 `json:"transcode_policy"`
+}
+
+type TranscodePolicyReq struct {
+	Policy string `json:"policy"`
+}
+
+// Returned by the internal API to the mtxhelper, it holds the camera credentials.
+type CameraSource struct {
+	Url        string `json:"url"`
+	Transcode  bool   `json:"transcode"`
+	VideoCodec string `json:"video_codec,omitempty"` // empty when it couldn't be detected
+	AudioCodec string `json:"audio_codec,omitempty"` // empty when unreported or without audio
+}
+
+// Continuous recording keeps every segment of the recorder's profile for RetentionDays,
+// otherwise only the segments the motion clips are cut from are kept.
+type RecordingSettings struct {
+	Continuous    bool `json:"continuous"`
+	RetentionDays int  `json:"retention_days"`
+}
+
+func DefaultRecordingSettings() RecordingSettings {
+	return RecordingSettings{
+		Continuous:    false,
+		RetentionDays: 7,
+	}
+}
+
+// Every segment the mtxhelper posts is republished on this topic exchange with the camera uuid
+// as the routing key, the motion runners cut their clips once the segments they need complete.
+const RecordingSegmentsExchange = "recording.segments"
+
+// Posted by the mtxhelper to the internal API when mediamtx completes a segment.
+type RecordingSegmentEvent struct {
+	Path     string `json:"path"`
+	File     string `json:"file"`
+	Duration string `json:"duration"`
+}
+
+type TimelineSpan struct {
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Profile     string    `json:"profile"`
+	PlaybackUrl string    `json:"playback_url"`
+}
+
+// The spans of a day the camera has continuous recordings for, oldest first.
+type RecordingTimeline struct {
+	UUID  string         `json:"uuid"`
+	From  time.Time      `json:"from"`
+	To    time.Time      `json:"to"`
+	Spans []TimelineSpan `json:"spans"`
+}
+
+type ExportStatus = string
+
+const (
+	ExportQueued    ExportStatus = "queued"
+	ExportRunning   ExportStatus = "running"
+	ExportUploading ExportStatus = "uploading"
+	ExportDone      ExportStatus = "done"
+	ExportFailed    ExportStatus = "failed"
+)
+
+type ExportReq struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// A clip of the recorder's segments between Start and End, the download url is set once it is done.
+// It is also the payload of the export's SSE events.
+type ExportJob struct {
+	Id           string       `json:"id"`
+	UUID         string       `json:"uuid"`
+	Start        time.Time    `json:"start"`
+	End          time.Time    `json:"end"`
+	Status       ExportStatus `json:"status"`
+	Progress     float64      `json:"progress"`
+	Error        string       `json:"error,omitempty"`
+	DownloadUrl  string       `json:"download_url,omitempty"`
+	UrlExpiresAt *time.Time   `json:"url_expires_at,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
 type MoveCameraReq struct {
 	Translation *utils.Vec2D `json:"translation"`
 	Zoom        *float32     `json:"zoom"`
+	TimeoutMs   *int         `json:"timeout_ms"` // the camera stops on its own once it passes, defaults to 1000
+}
+
+// A PTZ position, translation or speed, the axes left unset aren't touched.
+type PtzVector struct {
+	PanTilt *utils.Vec2D `json:"pan_tilt,omitempty"`
+	Zoom    *float32     `json:"zoom,omitempty"`
+}
+
+type AbsoluteMoveReq struct {
+	Position PtzVector  `json:"position"`
+	Speed    *PtzVector `json:"speed,omitempty"` // the camera's default speed when unset
+}
+
+type RelativeMoveReq struct {
+	Translation PtzVector  `json:"translation"`
+	Speed       *PtzVector `json:"speed,omitempty"`
+}
+
+// Both axes are stopped when neither is set.
+type StopCameraReq struct {
+	PanTilt bool `json:"pan_tilt"`
+	Zoom    bool `json:"zoom"`
+}
+
+type PtzStatus struct {
+	Position      PtzVector  `json:"position"`
+	PanTiltStatus string     `json:"pan_tilt_status,omitempty"` // IDLE, MOVING or UNKNOWN
+	ZoomStatus    string     `json:"zoom_status,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	UtcTime       *time.Time `json:"utc_time,omitempty"`
+}
+
+type PtzPreset struct {
+	Token    string     `json:"token"`
+	Name     string     `json:"name"`
+	Position *PtzVector `json:"position,omitempty"` // unset when the camera doesn't report it
+}
+
+// Stores the current position as a preset, an existing preset is overwritten when its token is set.
+type SetPresetReq struct {
+	Name  string `json:"name"`
+	Token string `json:"token,omitempty"`
+}
+
+type PtzCapabilities struct {
+	Supported    bool             `json:"supported"`
+	PanTilt      bool             `json:"pan_tilt"`
+	Zoom         bool             `json:"zoom"`
+	Home         bool             `json:"home"`
+	MaxPresets   int              `json:"max_presets"` // 0 when the camera doesn't report a limit
+	Spaces       models.PtzSpaces `json:"spaces"`
+	MinTimeoutMs *int             `json:"min_timeout_ms,omitempty"`
+	MaxTimeoutMs *int             `json:"max_timeout_ms,omitempty"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+}
+
+// The controls the camera supports, the UI hides the others.
+type CameraCapabilities struct {
+	Ptz PtzCapabilities `json:"ptz"`
+}
+
+type PtzTourStop struct {
+	Preset  string `json:"preset"` // token or name
+	DwellMs int    `json:"dwell_ms"`
+}
+
+type PtzTourReq struct {
+	Name     string        `json:"name"`
+	Stops    []PtzTourStop `json:"stops"`
+	Schedule string        `json:"schedule,omitempty"` // cron expression, the tour only runs on demand without one
+	Loops    *int          `json:"loops"`              // passes over the stops per run, 0 runs until stopped, defaults to 1
+}
+
+type PtzTour struct {
+	Id        string        `json:"id"`
+	UUID      string        `json:"uuid"`
+	Name      string        `json:"name"`
+	Stops     []PtzTourStop `json:"stops"`
+	Schedule  string        `json:"schedule,omitempty"`
+	Loops     int           `json:"loops"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+type PtzTourStatus = string
+
+const (
+	PtzTourIdle    PtzTourStatus = "idle"
+	PtzTourRunning PtzTourStatus = "running"
+	PtzTourPaused  PtzTourStatus = "paused" // a user took manual control, the tour resumes at ResumesAt
+)
+
+type PtzTourState struct {
+	Status    PtzTourStatus `json:"status"`
+	TourId    string        `json:"tour_id,omitempty"`
+	TourName  string        `json:"tour_name,omitempty"`
+	Stop      int           `json:"stop"` // index of the current stop
+	Preset    string        `json:"preset,omitempty"`
+	Loop      int           `json:"loop"` // current pass over the stops, starting at 1
+	StartedAt *time.Time    `json:"started_at,omitempty"`
+	ResumesAt *time.Time    `json:"resumes_at,omitempty"`
+}
+
+type PtzLockPriority = string
+
+// Lock priorities from lowest to highest, a holder is preempted by a higher priority.
+const (
+	PtzLockAutomation PtzLockPriority = "automation" // tours and other moves nobody is watching
+	PtzLockOperator   PtzLockPriority = "operator"
+	PtzLockAdmin      PtzLockPriority = "admin"
+)
+
+type PtzLockHolder struct {
+	Id       string          `json:"id"`
+	Name     string          `json:"name"`
+	Priority PtzLockPriority `json:"priority"`
+}
+
+type PtzLease struct {
+	Holder     PtzLockHolder `json:"holder"`
+	AcquiredAt time.Time     `json:"acquired_at"`
+	ExpiresAt  time.Time     `json:"expires_at"`
+}
+
+// PtzLockState is sent over the camera's PTZ SSE whenever the lease changes, Lease is nil while
+// the camera is free.
+type PtzLockState struct {
+	CamUUID string    `json:"cam_uuid"`
+	Lease   *PtzLease `json:"lease"`
+}
+
+type AcquirePtzLockReq struct {
+	TtlMs *int `json:"ttl_ms"`
 }
 
 type Evidence struct {
-	Conf float32 `json:"conf"`
-	Xmin float32 `json:"x_min"`
-	Ymin float32 `json:"y_min"`
-	Xmax float32 `json:"x_max"`
-	Ymax float32 `json:"y_max"`
+	Conf  float32 `json:"conf"`
+	Xmin  float32 `json:"x_min"`
+	Ymin  float32 `json:"y_min"`
+	Xmax  float32 `json:"x_max"`
+	Ymax  float32 `json:"y_max"`
+	Label string  `json:"label"`
+	Frame int     `json:"frame"`
 }
 
 type AddRecordingReq struct {
-	BucketName         string   `json:"bucket_name"`
-	VidBucketKey       string   `json:"vid_key"`
-	BestFrameBucketKey string   `json:"best_frame_key"`
-	Evidence           Evidence `json:"evidence"`
-	Score              float32  `json:"score"`
+	BucketName         string     `json:"bucket_name"`
+	VidBucketKey       string     `json:"vid_key"`
+	BestFrameBucketKey string     `json:"best_frame_key"`
+	Evidence           []Evidence `json:"evidence"`
+	Score              float32    `json:"score"`
 
 	RetentionDays int `json:"retention_days"`
 
@@ -78,6 +347,195 @@ type AddRecordingReq struct {
 	EndTs   time.Time `json:"end_ts"`
 }
 
+type RecordingsPage struct {
+	Recordings []*models.Recordings `json:"recordings"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+type RecordingDetails struct {
+	*models.Recordings
+	VideoUrl     string    `json:"video_url"`
+	BestFrameUrl string    `json:"best_frame_url,omitempty"`
+	UrlsExpireAt time.Time `json:"urls_expire_at"`
+}
+
+type RetentionStats struct {
+	Runs              int64     `json:"runs"`
+	RecordingsDeleted int64     `json:"recordings_deleted"`
+	ObjectsDeleted    int64     `json:"objects_deleted"`
+	Failures          int64     `json:"failures"`
+	DryRunMatches     int64     `json:"dry_run_matches"`
+	LastRunAt         time.Time `json:"last_run_at"`
+	LastRunDuration   string    `json:"last_run_duration"`
+	DryRun            bool      `json:"dry_run"`
+}
+
 type SetLightModeReq struct {
 	Mode string `json:"mode"`
 }
+
+type MotionSettings struct {
+	Threshold    float32 `json:"threshold"`     // binary threshold after MOG2
+	MinArea      int     `json:"min_area"`      // minimum foreground pixels to call "motion"
+	WarmupFrames int     `json:"warmup_frames"` // frames to ignore while background stabilizes
+	TickMs       int     `json:"tick_ms"`       // interval between sampled frames
+	CooldownMs   int     `json:"cooldown_ms"`   // minimum gap between the end of an event and the next one
+	PreRollMs    int     `json:"pre_roll_ms"`   // recorded before the first detection of an event
+	PostRollMs   int     `json:"post_roll_ms"`  // recorded after the last detection, the event ends once it passes quietly
+	MaxEventMs   int     `json:"max_event_ms"`  // longest an event is extended for, longer motion is split in several clips
+	Source       string  `json:"source"`        // where the detections come from, see MotionSourceServer
+}
+
+// The values the motion detector used before they were configurable per camera, the rolls
+// replaced the fixed segment before and after the motion along with the 10s cooldown.
+func DefaultMotionSettings() MotionSettings {
+	return MotionSettings{
+		Threshold:    50,
+		MinArea:      15000,
+		WarmupFrames: 150,
+		TickMs:       50,
+		CooldownMs:   0,
+		PreRollMs:    3000,
+		PostRollMs:   3000,
+		MaxEventMs:   60000,
+		Source:       MotionSourceServer,
+	}
+}
+
+const (
+	MaxMotionRollMs  = 60000
+	MaxMotionEventMs = 10 * 60 * 1000
+	// The segment length of continuously recorded paths, they complete a segment a minute
+	// instead of every second so a camera isn't indexed 86400 times a day.
+	ContinuousSegmentDuration = time.Minute
+	// How long a motion runner waits for the last segment of a clip to complete, the segment
+	// of a continuously recorded camera may have just started.
+	MotionSegmentWait = ContinuousSegmentDuration + 30*time.Second
+	// How long the segments the motion clips are cut from are kept: the longest event with both
+	// rolls, the wait for its last segment and a margin for the jobs queued in the runner.
+	MotionSegmentsRetention = time.Duration(MaxMotionRollMs+MaxMotionEventMs+MaxMotionRollMs)*time.Millisecond +
+		MotionSegmentWait + 90*time.Second
+)
+
+const (
+	MotionSourceServer = "server" // MOG2 on the motion profile
+	MotionSourceCamera = "camera" // the camera's own analytics, pulled from its ONVIF events
+	MotionSourceBoth   = "both"   // either of them starts or extends an event
+)
+
+func ValidMotionSource(source string) bool {
+	return source == MotionSourceServer || source == MotionSourceCamera || source == MotionSourceBoth
+}
+
+// Returned by the internal API to the motion detectors that pull the camera's ONVIF events, it
+// holds the camera credentials and is only served on the internal listener.
+type CameraOnvif struct {
+	Xaddr    string `json:"xaddr"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+const (
+	MotionConfigExchange = "motion.config"
+
+	// Header telling the detector which event the message body holds.
+	MotionConfigTypeHeader   = "type"
+	MotionConfigTypeSettings = "settings"
+	MotionConfigTypeZones    = "zones"
+)
+
+// Published on the motion config exchange with the camera uuid as the routing key,
+// running detectors apply it without a restart.
+type MotionSettingsEvent struct {
+	UUID     string         `json:"uuid"`
+	Settings MotionSettings `json:"settings"`
+}
+
+type MotionZones struct {
+	Zones []zones.Zone `json:"zones"`
+}
+
+type MotionZonesEvent struct {
+	UUID  string       `json:"uuid"`
+	Zones []zones.Zone `json:"zones"`
+}
+
+const (
+	WorkerRunning = "running"
+	WorkerBackoff = "backoff" // waiting to be restarted after a crash
+	WorkerFailed  = "failed"  // crash-looped or exited and won't be restarted
+	WorkerExited  = "exited"  // exited cleanly and the policy doesn't restart it
+	WorkerStopped = "stopped" // unregistered
+)
+
+// Published by the supervisor on every state change and periodically as a snapshot.
+type WorkerState struct {
+	UUID          string     `json:"uuid"`
+	Status        string     `json:"status"`
+	Pid           int        `json:"pid,omitempty"`
+	Restarts      int        `json:"restarts"`
+	LastExitCode  *int       `json:"last_exit_code,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextRestartAt *time.Time `json:"next_restart_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Published by the supervisor to ask for the cameras that should have a motion worker.
+type WorkersSyncRequest struct {
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// The reply to a sync request, the supervisor starts the missing workers and stops the rest.
+// Orphans are only stopped when the snapshot is complete, a camera that couldn't be
+// published to mediamtx is left out and must not lose its worker because of it.
+type PairedCamerasSnapshot struct {
+	Cameras     []CameraPairedEvent `json:"cameras"`
+	Complete    bool                `json:"complete"`
+	GeneratedAt time.Time           `json:"generated_at"`
+}
+
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+)
+
+var roleRanks = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleAllows reports whether role grants at least the permissions of required.
+func RoleAllows(role, required string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[required]
+}
+
+type LoginReq struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type LoginResp struct {
+	Token     string       `json:"token"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	User      *models.User `json:"user"`
+}
+
+type CreateUserReq struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// Only the set fields are updated.
+type UpdateUserReq struct {
+	Password *string `json:"password"`
+	Role     *string `json:"role"`
+}
@@ -47,7 +47,11 @@ func (bus *AMQPBus) Consume(ctx context.Context, queue, consumer string, h event
 	go func() {
 		for {
 			select {
-			case m := <-msgs:
+			case m, ok := <-msgs:
+				if !ok {
+					return
+				}
+
 				msg := events.Message{
 					Body:        m.Body,
 					Key:         m.RoutingKey,
@@ -65,6 +69,7 @@ func (bus *AMQPBus) Consume(ctx context.Context, queue, consumer string, h event
 				}
 			case <-ctx.Done():
 				_ = bus.ch.Cancel(consumerTag, false)
+				return
 			}
 		}
 	}()
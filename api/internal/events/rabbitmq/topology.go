@@ -9,6 +9,11 @@ func (bus *AMQPBus) DeclareQueue(name string, durable bool, args map[string]any)
 	return err
 }
 
+func (bus *AMQPBus) DeclareAutoDeleteQueue(name string, args map[string]any) error {
+	_, err := bus.ch.QueueDeclare(name, false, true, false, false, args)
+	return err
+}
+
 func (bus *AMQPBus) Bind(queue, exch, key string, args map[string]any) error {
 	return bus.ch.QueueBind(queue, key, exch, false, args)
 }
@@ -3,5 +3,8 @@ package events
 type DeclarerIface interface {
 	DeclareExchange(name, kind string, durable bool) error
 	DeclareQueue(name string, durable bool, args map[string]any) error
+	// DeclareAutoDeleteQueue declares a transient queue the broker drops once its last
+	// consumer is gone, for the queues of a single worker.
+	DeclareAutoDeleteQueue(name string, args map[string]any) error
 	Bind(queue, exch, key string, args map[string]any) error
 }
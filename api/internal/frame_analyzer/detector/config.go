@@ -0,0 +1,125 @@
+package detector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// OutputLayout describes which column of a detection row holds which value.
+type OutputLayout struct {
+	RowSize  int `json:"row_size"`
+	ImageIdx int `json:"image_idx"`
+	Label    int `json:"label"`
+	Conf     int `json:"conf"`
+	Xmin     int `json:"x_min"`
+	Ymin     int `json:"y_min"`
+	Xmax     int `json:"x_max"`
+	Ymax     int `json:"y_max"`
+}
+
+type ModelConfig struct {
+	Name          string       `json:"name"`
+	Version       int64        `json:"version"`
+	SignatureName string       `json:"signature_name"`
+	InputName     string       `json:"input_name"`
+	OutputName    string       `json:"output_name"`
+	InputWidth    int          `json:"input_width"`
+	InputHeight   int          `json:"input_height"`
+	InputChannels int          `json:"input_channels"`
+	BatchSize     int          `json:"batch_size"`
+	Labels        []string     `json:"labels"` // indexed by class id
+	Layout        OutputLayout `json:"output_layout"`
+	ConfThreshold float32      `json:"conf_threshold"` // detections below are dropped
+	NmsIoU        float32      `json:"nms_iou"`        // overlapping detections above are merged
+}
+
+// DefaultModelConfig describes OpenVINO's person-detection-retail-0013, the model cam-hub ships with.
+// Its output is [1, 1, N, 7] rows of [image_id, label, conf, x_min, y_min, x_max, y_max].
+func DefaultModelConfig() *ModelConfig {
+	return &ModelConfig{
+		Name:          "person-detection-retail-0013",
+		Version:       1,
+		SignatureName: "serving_default",
+		InputName:     "data",
+		OutputName:    "detection_out",
+		InputWidth:    544,
+		InputHeight:   320,
+		InputChannels: 3,
+		BatchSize:     4,
+		Labels:        []string{"background", "person"},
+		Layout: OutputLayout{
+			RowSize:  7,
+			ImageIdx: 0,
+			Label:    1,
+			Conf:     2,
+			Xmin:     3,
+			Ymin:     4,
+			Xmax:     5,
+			Ymax:     6,
+		},
+		ConfThreshold: 0.5,
+		NmsIoU:        0.45,
+	}
+}
+
+// LoadModelConfig reads a json model config from path on top of the defaults,
+// fields missing from the file keep their default value. An empty path returns the defaults.
+func LoadModelConfig(path string) (*ModelConfig, error) {
+	cfg := DefaultModelConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model config: %w", err)
+	}
+
+	if err := json.Unmarshal(raw, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse model config: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (cfg *ModelConfig) Validate() error {
+	if cfg.Name == "" || cfg.InputName == "" || cfg.OutputName == "" {
+		return errors.New("model config: name, input_name and output_name are required")
+	}
+	if cfg.InputWidth <= 0 || cfg.InputHeight <= 0 || cfg.InputChannels <= 0 || cfg.BatchSize <= 0 {
+		return errors.New("model config: input dimensions and batch size must be positive")
+	}
+
+	if cfg.ConfThreshold < 0 || cfg.ConfThreshold > 1 || cfg.NmsIoU <= 0 || cfg.NmsIoU > 1 {
+		return errors.New("model config: conf_threshold must be in [0, 1] and nms_iou in (0, 1]")
+	}
+
+	l := cfg.Layout
+	for _, col := range []int{l.ImageIdx, l.Label, l.Conf, l.Xmin, l.Ymin, l.Xmax, l.Ymax} {
+		if col < 0 || col >= l.RowSize {
+			return fmt.Errorf("model config: output column %d is outside of row size %d", col, l.RowSize)
+		}
+	}
+
+	return nil
+}
+
+// FrameSize is the number of float32 values of a single frame in the input tensor.
+func (cfg *ModelConfig) FrameSize() int {
+	return cfg.InputWidth * cfg.InputHeight * cfg.InputChannels
+}
+
+func (cfg *ModelConfig) LabelOf(classID int) string {
+	if classID >= 0 && classID < len(cfg.Labels) {
+		return cfg.Labels[classID]
+	}
+
+	return strconv.Itoa(classID)
+}
@@ -0,0 +1,100 @@
+package detector
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	ErrMalformedOutput = errors.New("malformed detector output")
+)
+
+// Detector runs an object detection model over a batch of frames.
+//
+// The input tensor is a float32 NCHW batch laid out according to the model config
+// (BatchSize x InputChannels x InputHeight x InputWidth, BGR channel order).
+type Detector interface {
+	Predict(ctx context.Context, tensor []byte) (*Output, error)
+	Config() *ModelConfig
+	Close() error
+}
+
+// Output is the raw, decoded output tensor of a model.
+type Output struct {
+	Shape []int64
+	Data  []float32
+}
+
+type Detection struct {
+	Frame   int // index of the frame in the batch
+	ClassID int
+	Label   string
+	Conf    float32
+	Xmin    float32
+	Ymin    float32
+	Xmax    float32
+	Ymax    float32
+}
+
+// ParseDetections splits the output into detection rows according to the model's output layout.
+// Padding rows (negative image index, as emitted by SSD DetectionOutput layers) are skipped.
+func ParseDetections(out *Output, cfg *ModelConfig) ([]Detection, error) {
+	layout := cfg.Layout
+	if layout.RowSize <= 0 || len(out.Data)%layout.RowSize != 0 {
+		return nil, fmt.Errorf("%w: %d values do not split into rows of %d", ErrMalformedOutput, len(out.Data), layout.RowSize)
+	}
+
+	numRows := len(out.Data) / layout.RowSize
+	dets := make([]Detection, 0)
+	for i := 0; i < numRows; i++ {
+		row := out.Data[i*layout.RowSize : (i+1)*layout.RowSize]
+
+		frame := int(row[layout.ImageIdx])
+		if frame < 0 {
+			continue
+		}
+		if frame >= cfg.BatchSize {
+			return nil, fmt.Errorf("%w: image index %d out of batch range", ErrMalformedOutput, frame)
+		}
+
+		classID := int(row[layout.Label])
+		dets = append(dets, Detection{
+			Frame:   frame,
+			ClassID: classID,
+			Label:   cfg.LabelOf(classID),
+			Conf:    row[layout.Conf],
+			Xmin:    row[layout.Xmin],
+			Ymin:    row[layout.Ymin],
+			Xmax:    row[layout.Xmax],
+			Ymax:    row[layout.Ymax],
+		})
+	}
+
+	return dets, nil
+}
+
+// decodeFloats decodes a little-endian float32 tensor content.
+func decodeFloats(raw []byte) ([]float32, error) {
+	if len(raw)%4 != 0 {
+		return nil, fmt.Errorf("%w: content length %d is not a multiple of 4", ErrMalformedOutput, len(raw))
+	}
+
+	out := make([]float32, len(raw)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+
+	return out, nil
+}
+
+func encodeFloats(vals []float32) []byte {
+	raw := make([]byte, len(vals)*4)
+	for i, val := range vals {
+		binary.LittleEndian.PutUint32(raw[i*4:], math.Float32bits(val))
+	}
+
+	return raw
+}
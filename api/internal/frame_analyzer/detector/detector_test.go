@@ -0,0 +1,155 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"tomerab.com/cam-hub/internal/frame_analyzer/tensorflow/core/framework"
+)
+
+func TestLoadModelConfig(t *testing.T) {
+	t.Run("empty path - should return defaults", func(t *testing.T) {
+		cfg, err := LoadModelConfig("")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if !reflect.DeepEqual(cfg, DefaultModelConfig()) {
+			t.Errorf("expected defaults, got: %+v", cfg)
+		}
+	})
+
+	t.Run("partial file - should override on top of defaults", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "model.json")
+		content := `{"name": "vehicle-detection-0200", "input_width": 256, "input_height": 256, "labels": ["vehicle"]}`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+
+		cfg, err := LoadModelConfig(path)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if cfg.Name != "vehicle-detection-0200" || cfg.InputWidth != 256 || cfg.InputHeight != 256 {
+			t.Errorf("expected overrides to apply, got: %+v", cfg)
+		}
+		if cfg.OutputName != "detection_out" || cfg.Layout.RowSize != 7 {
+			t.Errorf("expected defaults to be kept, got: %+v", cfg)
+		}
+	})
+
+	t.Run("column outside of row - should fail validation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "model.json")
+		content := `{"output_layout": {"row_size": 5, "conf": 6}}`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+
+		if _, err := LoadModelConfig(path); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	})
+}
+
+func TestParseDetections(t *testing.T) {
+	cfg := DefaultModelConfig()
+
+	t.Run("padding rows - should be skipped", func(t *testing.T) {
+		out := &Output{Data: []float32{
+			0, 1, 0.9, 0.1, 0.1, 0.2, 0.2,
+			-1, 0, 0, 0, 0, 0, 0,
+			2, 1, 0.4, 0.5, 0.5, 0.6, 0.6,
+		}}
+
+		dets, err := ParseDetections(out, cfg)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		expected := []Detection{
+			{Frame: 0, ClassID: 1, Label: "person", Conf: 0.9, Xmin: 0.1, Ymin: 0.1, Xmax: 0.2, Ymax: 0.2},
+			{Frame: 2, ClassID: 1, Label: "person", Conf: 0.4, Xmin: 0.5, Ymin: 0.5, Xmax: 0.6, Ymax: 0.6},
+		}
+		if !reflect.DeepEqual(dets, expected) {
+			t.Errorf("expected: %+v, got: %+v", expected, dets)
+		}
+	})
+
+	t.Run("truncated row - should return ErrMalformedOutput", func(t *testing.T) {
+		_, err := ParseDetections(&Output{Data: []float32{0, 1, 0.9}}, cfg)
+		if !errors.Is(err, ErrMalformedOutput) {
+			t.Errorf("expected ErrMalformedOutput, got: %v", err)
+		}
+	})
+
+	t.Run("image index past the batch - should return ErrMalformedOutput", func(t *testing.T) {
+		_, err := ParseDetections(&Output{Data: []float32{9, 1, 0.9, 0, 0, 1, 1}}, cfg)
+		if !errors.Is(err, ErrMalformedOutput) {
+			t.Errorf("expected ErrMalformedOutput, got: %v", err)
+		}
+	})
+}
+
+func TestOutputFromProto(t *testing.T) {
+	vals := []float32{0, 1, 0.75, 0.1, 0.2, 0.3, 0.4}
+	proto := &framework.TensorProto{
+		Dtype: framework.DataType_DT_FLOAT,
+		TensorShape: &framework.TensorShapeProto{
+			Dim: []*framework.TensorShapeProto_Dim{{Size: 1}, {Size: 1}, {Size: 1}, {Size: 7}},
+		},
+		TensorContent: encodeFloats(vals),
+	}
+
+	out, err := outputFromProto(proto)
+	if err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+
+	if !reflect.DeepEqual(out.Data, vals) {
+		t.Errorf("expected: %v, got: %v", vals, out.Data)
+	}
+	if !reflect.DeepEqual(out.Shape, []int64{1, 1, 1, 7}) {
+		t.Errorf("unexpected shape: %v", out.Shape)
+	}
+}
+
+func TestFakeDetector(t *testing.T) {
+	cfg := DefaultModelConfig()
+	expected := []Detection{
+		{Frame: 1, ClassID: 1, Label: "person", Conf: 0.8, Xmin: 0.1, Ymin: 0.2, Xmax: 0.3, Ymax: 0.4},
+	}
+
+	t.Run("canned detections - should round trip through the layout", func(t *testing.T) {
+		det := NewFakeDetector(cfg, expected...)
+
+		out, err := det.Predict(context.Background(), []byte{1, 2, 3})
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		got, err := ParseDetections(out, cfg)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected: %+v, got: %+v", expected, got)
+		}
+		if len(det.Calls()) != 1 {
+			t.Errorf("expected 1 call, got: %d", len(det.Calls()))
+		}
+	})
+
+	t.Run("with error - should fail", func(t *testing.T) {
+		det := NewFakeDetector(cfg).WithError(errors.New("model unavailable"))
+
+		if _, err := det.Predict(context.Background(), nil); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	})
+}
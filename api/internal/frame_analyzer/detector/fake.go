@@ -0,0 +1,77 @@
+package detector
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeDetector is an in-process Detector that returns canned detections,
+// it is meant for tests and for running the pipeline without a model server.
+type FakeDetector struct {
+	cfg        *ModelConfig
+	detections []Detection
+	err        error
+
+	mtx     sync.Mutex
+	tensors [][]byte
+}
+
+func NewFakeDetector(cfg *ModelConfig, detections ...Detection) *FakeDetector {
+	return &FakeDetector{
+		cfg:        cfg,
+		detections: detections,
+	}
+}
+
+// WithError makes every following Predict call fail with err.
+func (det *FakeDetector) WithError(err error) *FakeDetector {
+	det.err = err
+	return det
+}
+
+func (det *FakeDetector) Config() *ModelConfig {
+	return det.cfg
+}
+
+func (det *FakeDetector) Close() error {
+	return nil
+}
+
+// Predict encodes the canned detections into an output tensor using the model's layout,
+// so callers go through the same parsing path as with a real model.
+func (det *FakeDetector) Predict(ctx context.Context, tensor []byte) (*Output, error) {
+	det.mtx.Lock()
+	det.tensors = append(det.tensors, tensor)
+	det.mtx.Unlock()
+
+	if det.err != nil {
+		return nil, det.err
+	}
+
+	layout := det.cfg.Layout
+	data := make([]float32, 0, len(det.detections)*layout.RowSize)
+	for _, d := range det.detections {
+		row := make([]float32, layout.RowSize)
+		row[layout.ImageIdx] = float32(d.Frame)
+		row[layout.Label] = float32(d.ClassID)
+		row[layout.Conf] = d.Conf
+		row[layout.Xmin] = d.Xmin
+		row[layout.Ymin] = d.Ymin
+		row[layout.Xmax] = d.Xmax
+		row[layout.Ymax] = d.Ymax
+		data = append(data, row...)
+	}
+
+	return &Output{
+		Shape: []int64{1, 1, int64(len(det.detections)), int64(layout.RowSize)},
+		Data:  data,
+	}, nil
+}
+
+// Calls returns the tensors passed to Predict so far.
+func (det *FakeDetector) Calls() [][]byte {
+	det.mtx.Lock()
+	defer det.mtx.Unlock()
+
+	return det.tensors
+}
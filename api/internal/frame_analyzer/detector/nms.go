@@ -0,0 +1,118 @@
+package detector
+
+import (
+	"sort"
+
+	"tomerab.com/cam-hub/internal/zones"
+)
+
+// FilterByConf keeps the detections whose confidence is at least minConf.
+func FilterByConf(dets []Detection, minConf float32) []Detection {
+	out := make([]Detection, 0, len(dets))
+	for _, det := range dets {
+		if det.Conf >= minConf {
+			out = append(out, det)
+		}
+	}
+
+	return out
+}
+
+// FilterByZones keeps the detections whose bottom-center, where a person touches the ground,
+// is allowed by the zones. Boxes are expected to be normalized like the zones.
+func FilterByZones(dets []Detection, zs []zones.Zone) []Detection {
+	if len(zs) == 0 {
+		return dets
+	}
+
+	out := make([]Detection, 0, len(dets))
+	for _, det := range dets {
+		if zones.Allows(zs, (det.Xmin+det.Xmax)/2, det.Ymax) {
+			out = append(out, det)
+		}
+	}
+
+	return out
+}
+
+// NonMaxSuppression merges overlapping detections of the same class in the same frame,
+// keeping the most confident one of every group whose IoU exceeds iouThreshold.
+// The result is ordered by frame and then by descending confidence.
+func NonMaxSuppression(dets []Detection, iouThreshold float32) []Detection {
+	sorted := make([]Detection, len(dets))
+	copy(sorted, dets)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Frame != sorted[j].Frame {
+			return sorted[i].Frame < sorted[j].Frame
+		}
+		return sorted[i].Conf > sorted[j].Conf
+	})
+
+	kept := make([]Detection, 0, len(sorted))
+	suppressed := make([]bool, len(sorted))
+	for i := range sorted {
+		if suppressed[i] {
+			continue
+		}
+		kept = append(kept, sorted[i])
+
+		for j := i + 1; j < len(sorted); j++ {
+			if sorted[j].Frame != sorted[i].Frame {
+				break
+			}
+			if suppressed[j] || sorted[j].ClassID != sorted[i].ClassID {
+				continue
+			}
+			if IoU(sorted[i], sorted[j]) > iouThreshold {
+				suppressed[j] = true
+			}
+		}
+	}
+
+	return kept
+}
+
+// IoU returns the intersection over union of the boxes of a and b.
+func IoU(a, b Detection) float32 {
+	interW := min(a.Xmax, b.Xmax) - max(a.Xmin, b.Xmin)
+	interH := min(a.Ymax, b.Ymax) - max(a.Ymin, b.Ymin)
+	if interW <= 0 || interH <= 0 {
+		return 0
+	}
+
+	inter := interW * interH
+	union := area(a) + area(b) - inter
+	if union <= 0 {
+		return 0
+	}
+
+	return inter / union
+}
+
+func area(d Detection) float32 {
+	return max(d.Xmax-d.Xmin, 0) * max(d.Ymax-d.Ymin, 0)
+}
+
+// BestFrame picks the frame with the most detections, ties are broken by the highest confidence.
+// It returns -1 when there are no detections.
+func BestFrame(dets []Detection) int {
+	counts := make(map[int]int)
+	maxConf := make(map[int]float32)
+	for _, det := range dets {
+		counts[det.Frame]++
+		maxConf[det.Frame] = max(maxConf[det.Frame], det.Conf)
+	}
+
+	best := -1
+	for frame, count := range counts {
+		switch {
+		case best == -1,
+			count > counts[best],
+			count == counts[best] && maxConf[frame] > maxConf[best],
+			count == counts[best] && maxConf[frame] == maxConf[best] && frame < best:
+			best = frame
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,152 @@
+package detector
+
+import (
+	"testing"
+
+	"tomerab.com/cam-hub/internal/zones"
+)
+
+func box(frame, classID int, conf, xmin, ymin, xmax, ymax float32) Detection {
+	return Detection{Frame: frame, ClassID: classID, Conf: conf, Xmin: xmin, Ymin: ymin, Xmax: xmax, Ymax: ymax}
+}
+
+func TestIoU(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Detection
+		want float32
+	}{
+		{"identical boxes", box(0, 1, 1, 0, 0, 1, 1), box(0, 1, 1, 0, 0, 1, 1), 1},
+		{"disjoint boxes", box(0, 1, 1, 0, 0, 0.5, 0.5), box(0, 1, 1, 0.5, 0.5, 1, 1), 0},
+		{"half overlap", box(0, 1, 1, 0, 0, 1, 1), box(0, 1, 1, 0, 0, 0.5, 1), 0.5},
+		{"degenerate box", box(0, 1, 1, 0, 0, 0, 0), box(0, 1, 1, 0, 0, 1, 1), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IoU(tt.a, tt.b); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFilterByConf(t *testing.T) {
+	dets := []Detection{box(0, 1, 0.3, 0, 0, 1, 1), box(0, 1, 0.5, 0, 0, 1, 1), box(1, 1, 0.9, 0, 0, 1, 1)}
+
+	got := FilterByConf(dets, 0.5)
+	if len(got) != 2 || got[0].Conf != 0.5 || got[1].Conf != 0.9 {
+		t.Errorf("expected the 0.5 and 0.9 detections, got: %+v", got)
+	}
+}
+
+func TestNonMaxSuppression(t *testing.T) {
+	t.Run("overlapping boxes of the same class - should keep the most confident", func(t *testing.T) {
+		dets := []Detection{
+			box(0, 1, 0.7, 0, 0, 0.5, 0.5),
+			box(0, 1, 0.9, 0.02, 0.02, 0.52, 0.52),
+		}
+
+		got := NonMaxSuppression(dets, 0.45)
+		if len(got) != 1 || got[0].Conf != 0.9 {
+			t.Errorf("expected a single 0.9 detection, got: %+v", got)
+		}
+	})
+
+	t.Run("separate people in the same frame - should keep both", func(t *testing.T) {
+		dets := []Detection{
+			box(0, 1, 0.8, 0, 0, 0.3, 0.6),
+			box(0, 1, 0.9, 0.6, 0.2, 0.9, 0.8),
+		}
+
+		if got := NonMaxSuppression(dets, 0.45); len(got) != 2 {
+			t.Errorf("expected 2 detections, got: %+v", got)
+		}
+	})
+
+	t.Run("same box on different frames or classes - should keep all", func(t *testing.T) {
+		dets := []Detection{
+			box(0, 1, 0.8, 0, 0, 0.5, 0.5),
+			box(1, 1, 0.8, 0, 0, 0.5, 0.5),
+			box(0, 2, 0.8, 0, 0, 0.5, 0.5),
+		}
+
+		if got := NonMaxSuppression(dets, 0.45); len(got) != 3 {
+			t.Errorf("expected 3 detections, got: %+v", got)
+		}
+	})
+
+	t.Run("result - should be ordered by frame then confidence", func(t *testing.T) {
+		dets := []Detection{
+			box(2, 1, 0.6, 0, 0, 0.1, 0.1),
+			box(0, 1, 0.6, 0, 0, 0.1, 0.1),
+			box(0, 1, 0.9, 0.5, 0.5, 0.6, 0.6),
+		}
+
+		got := NonMaxSuppression(dets, 0.45)
+		if len(got) != 3 || got[0].Conf != 0.9 || got[1].Frame != 0 || got[2].Frame != 2 {
+			t.Errorf("unexpected order: %+v", got)
+		}
+	})
+}
+
+func TestBestFrame(t *testing.T) {
+	t.Run("no detections - should return -1", func(t *testing.T) {
+		if got := BestFrame(nil); got != -1 {
+			t.Errorf("expected -1, got %d", got)
+		}
+	})
+
+	t.Run("frame with most detections - should win", func(t *testing.T) {
+		dets := []Detection{
+			box(0, 1, 0.99, 0, 0, 1, 1),
+			box(2, 1, 0.6, 0, 0, 0.3, 0.3),
+			box(2, 1, 0.6, 0.5, 0.5, 0.9, 0.9),
+		}
+
+		if got := BestFrame(dets); got != 2 {
+			t.Errorf("expected frame 2, got %d", got)
+		}
+	})
+
+	t.Run("tie on count - should prefer the higher confidence, then the earlier frame", func(t *testing.T) {
+		dets := []Detection{
+			box(3, 1, 0.7, 0, 0, 1, 1),
+			box(1, 1, 0.8, 0, 0, 1, 1),
+			box(0, 1, 0.7, 0, 0, 1, 1),
+		}
+		if got := BestFrame(dets); got != 1 {
+			t.Errorf("expected frame 1, got %d", got)
+		}
+
+		dets[1].Conf = 0.7
+		if got := BestFrame(dets); got != 0 {
+			t.Errorf("expected frame 0, got %d", got)
+		}
+	})
+}
+
+func TestFilterByZones(t *testing.T) {
+	dets := []Detection{
+		box(0, 1, 0.9, 0.1, 0.1, 0.3, 0.4), // bottom-center (0.2, 0.4)
+		box(0, 1, 0.9, 0.6, 0.5, 0.8, 0.9), // bottom-center (0.7, 0.9)
+	}
+
+	t.Run("no zones - should keep everything", func(t *testing.T) {
+		if got := FilterByZones(dets, nil); len(got) != 2 {
+			t.Errorf("expected 2 detections, got: %+v", got)
+		}
+	})
+
+	t.Run("include zone - should keep only the boxes standing inside it", func(t *testing.T) {
+		zs := []zones.Zone{{
+			Kind:   zones.KindInclude,
+			Points: []zones.Point{{X: 0.5, Y: 0.5}, {X: 1, Y: 0.5}, {X: 1, Y: 1}, {X: 0.5, Y: 1}},
+		}}
+
+		got := FilterByZones(dets, zs)
+		if len(got) != 1 || got[0].Xmin != 0.6 {
+			t.Errorf("expected only the second detection, got: %+v", got)
+		}
+	})
+}
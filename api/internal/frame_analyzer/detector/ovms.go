@@ -0,0 +1,104 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+
+	google_protobuf "github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"tomerab.com/cam-hub/internal/frame_analyzer/tensorflow/core/framework"
+	pb "tomerab.com/cam-hub/internal/frame_analyzer/tensorflow_serving/apis"
+)
+
+// OvmsDetector talks to an OpenVINO Model Server (or any TF-Serving compatible server)
+// over the gRPC Predict API. The connection is created once and reused for every call.
+type OvmsDetector struct {
+	cfg    *ModelConfig
+	conn   *grpc.ClientConn
+	client pb.PredictionServiceClient
+}
+
+func NewOvmsDetector(addr string, cfg *ModelConfig) (*OvmsDetector, error) {
+	conn, err := grpc.NewClient(
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OvmsDetector{
+		cfg:    cfg,
+		conn:   conn,
+		client: pb.NewPredictionServiceClient(conn),
+	}, nil
+}
+
+func (det *OvmsDetector) Config() *ModelConfig {
+	return det.cfg
+}
+
+func (det *OvmsDetector) Close() error {
+	return det.conn.Close()
+}
+
+func (det *OvmsDetector) Predict(ctx context.Context, tensor []byte) (*Output, error) {
+	predictReq := &pb.PredictRequest{
+		ModelSpec: &pb.ModelSpec{
+			Name:          det.cfg.Name,
+			SignatureName: det.cfg.SignatureName,
+			VersionChoice: &pb.ModelSpec_Version{
+				Version: &google_protobuf.Int64Value{
+					Value: det.cfg.Version,
+				},
+			},
+		},
+		Inputs: map[string]*framework.TensorProto{
+			det.cfg.InputName: {
+				Dtype: framework.DataType_DT_FLOAT,
+				TensorShape: &framework.TensorShapeProto{
+					Dim: []*framework.TensorShapeProto_Dim{
+						{Size: int64(det.cfg.BatchSize)},
+						{Size: int64(det.cfg.InputChannels)},
+						{Size: int64(det.cfg.InputHeight)},
+						{Size: int64(det.cfg.InputWidth)},
+					},
+				},
+				TensorContent: tensor,
+			},
+		},
+	}
+
+	predictResp, err := det.client.Predict(ctx, predictReq)
+	if err != nil {
+		return nil, fmt.Errorf("gRPC request failed: %w", err)
+	}
+
+	respProto, ok := predictResp.Outputs[det.cfg.OutputName]
+	if !ok {
+		return nil, fmt.Errorf("expected output: %s does not exist in the response", det.cfg.OutputName)
+	}
+
+	return outputFromProto(respProto)
+}
+
+func outputFromProto(respProto *framework.TensorProto) (*Output, error) {
+	dims := respProto.GetTensorShape().GetDim()
+	shape := make([]int64, len(dims))
+	for i, dim := range dims {
+		shape[i] = dim.GetSize()
+	}
+
+	// Servers either send the raw content or the typed repeated field.
+	if vals := respProto.GetFloatVal(); len(vals) > 0 {
+		return &Output{Shape: shape, Data: vals}, nil
+	}
+
+	data, err := decodeFloats(respProto.GetTensorContent())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Output{Shape: shape, Data: data}, nil
+}
@@ -10,65 +10,57 @@ import (
 	"os"
 	"path"
 	"strconv"
-	"strings"
 
-	google_protobuf "github.com/golang/protobuf/ptypes/wrappers"
 	"gocv.io/x/gocv"
 	"golang.org/x/sync/errgroup"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"tomerab.com/cam-hub/internal/api/v1/models"
 	v1 "tomerab.com/cam-hub/internal/contracts/v1"
 	"tomerab.com/cam-hub/internal/events"
-	"tomerab.com/cam-hub/internal/frame_analyzer/tensorflow/core/framework"
-	pb "tomerab.com/cam-hub/internal/frame_analyzer/tensorflow_serving/apis"
-	objectstorage "tomerab.com/cam-hub/internal/object_storage"
+	"tomerab.com/cam-hub/internal/frame_analyzer/detector"
 	"tomerab.com/cam-hub/internal/repos"
 	"tomerab.com/cam-hub/internal/services"
+	"tomerab.com/cam-hub/internal/utils"
+	"tomerab.com/cam-hub/internal/zones"
 )
 
 const (
 	maxConcurrentAnalysis = 16
-	tensorW               = 544 // tensor width
-	tensorH               = 320 // tensor heigt
-	tensorC               = 3   // tensor channels (3 channels R-G-B)
-	tensorN               = 4   // tensor batch size
-	frameSz               = tensorW * tensorH * tensorC
 )
 
 type FrameAnalyzer struct {
-	logger           *slog.Logger
-	bus              events.BusIface
-	minioClient      *objectstorage.MinIOStore
-	recordingService *services.RecordingsService
-	ctx              context.Context
-	imgAnalysisCh    chan v1.AnalyzeImgsEvent
+	logger        *slog.Logger
+	bus           events.BusIface
+	store         ObjectStoreIface
+	detector      detector.Detector
+	recordings    RecordingsIface
+	zonesRepo     repos.MotionZonesRepoIface // nil when detections aren't filtered by zones
+	ctx           context.Context
+	imgAnalysisCh chan v1.AnalyzeImgsEvent
 }
 
 type tensorData struct {
-	numClasses int
-	numRows    int
 	imageIndex int
 	maxConf    float32
-	maxLoc     image.Point
-	evidence   v1.Evidence
+	evidence   []v1.Evidence
 }
 
 func New(ctx context.Context,
 	logger *slog.Logger,
 	bus events.BusIface,
-	minioClient *objectstorage.MinIOStore,
-	recordingsRepo repos.RecordingsRepoIface,
-	camerasRepo repos.CameraRepoIface,
+	store ObjectStoreIface,
+	det detector.Detector,
+	recordings RecordingsIface,
+	zonesRepo repos.MotionZonesRepoIface,
 ) *FrameAnalyzer {
-	recordingServiceLogger := slog.New(logger.Handler()).With("service", "recordings")
-
 	return &FrameAnalyzer{
-		logger:           logger,
-		bus:              bus,
-		minioClient:      minioClient,
-		recordingService: services.NewRecordingsService(recordingServiceLogger, recordingsRepo, camerasRepo),
-		ctx:              ctx,
-		imgAnalysisCh:    make(chan v1.AnalyzeImgsEvent, maxConcurrentAnalysis),
+		logger:        logger,
+		bus:           bus,
+		store:         store,
+		detector:      det,
+		recordings:    recordings,
+		zonesRepo:     zonesRepo,
+		ctx:           ctx,
+		imgAnalysisCh: make(chan v1.AnalyzeImgsEvent, maxConcurrentAnalysis),
 	}
 }
 
@@ -90,16 +82,22 @@ func (analyzer *FrameAnalyzer) NotifyCtrl(ev v1.AnalyzeImgsEvent) {
 }
 
 func (analyzer *FrameAnalyzer) buildTensor(paths []string) ([]byte, error) {
-	batch := make([]byte, tensorW*tensorH*tensorC*tensorN*4) // *4 for float32
+	cfg := analyzer.detector.Config()
+	frameSz := cfg.FrameSize()
+	batch := make([]byte, frameSz*cfg.BatchSize*4) // *4 for float32
 	var eg errgroup.Group
 
+	if len(paths) > cfg.BatchSize {
+		paths = paths[:cfg.BatchSize]
+	}
+
 	for i, path := range paths {
 		p := path
 		start := i * frameSz * 4
 		end := (i + 1) * frameSz * 4
 
 		eg.Go(func() error {
-			obj, err := analyzer.minioClient.GetObject(os.Getenv("MINIO_BUCKET_NAME"), p)
+			obj, err := analyzer.store.GetObject(os.Getenv("MINIO_BUCKET_NAME"), p)
 			if err != nil {
 				return err
 			}
@@ -115,15 +113,20 @@ func (analyzer *FrameAnalyzer) buildTensor(paths []string) ([]byte, error) {
 				return fmt.Errorf("imdecode failed: %w", err)
 			}
 			defer mat.Close()
-			if mat.Empty() {
+			if mat.Empty() || mat.Channels() != cfg.InputChannels {
 				return fmt.Errorf("unexpected dims for %s: got %dx%dx%d (HWC)", p, mat.Rows(), mat.Cols(), mat.Channels())
 			}
 
+			// Frames are extracted at the default model's size, other models need a resize.
+			if mat.Cols() != cfg.InputWidth || mat.Rows() != cfg.InputHeight {
+				gocv.Resize(mat, &mat, image.Pt(cfg.InputWidth, cfg.InputHeight), 0, 0, gocv.InterpolationLinear)
+			}
+
 			bgrFloat := gocv.NewMat()
 			defer bgrFloat.Close()
 			mat.ConvertTo(&bgrFloat, gocv.MatTypeCV32F)
 
-			reshaped := bgrFloat.ReshapeWithSize(1, []int{1, tensorH, tensorW, tensorC})
+			reshaped := bgrFloat.ReshapeWithSize(1, []int{1, cfg.InputHeight, cfg.InputWidth, cfg.InputChannels})
 			defer reshaped.Close()
 
 			transposed := gocv.NewMat()
@@ -152,13 +155,11 @@ func (analyzer *FrameAnalyzer) minioMoveObjects(whereToStore string, ev *v1.Anal
 	dirPrefix := path.Join(stagingKey, ev.UUID, ev.Tp)
 	allObjs := append([]string{ev.VidPath}, ev.FramePaths...)
 	for _, srcKey := range allObjs {
-		objName := strings.TrimPrefix(srcKey, stagingKey+"/")
-
-		if _, err := analyzer.minioClient.CopyObjectWithinBucket(
+		if _, err := analyzer.store.CopyObjectWithinBucket(
 			bucketName,
-			stagingKey,
-			whereToStore,
-			objName,
+			path.Dir(srcKey),
+			path.Dir(ev.StoredKey(whereToStore, srcKey)),
+			path.Base(srcKey),
 		); err != nil {
 			return err
 		}
@@ -167,7 +168,7 @@ func (analyzer *FrameAnalyzer) minioMoveObjects(whereToStore string, ev *v1.Anal
 	// bulk remove all the objects under 'dirPrefix' (all the objects we remove are under the
 	// same 'dir' object)
 	analyzer.logger.Debug("deleting objects from", "bucket_name", bucketName, "prefix", dirPrefix)
-	if err := analyzer.minioClient.RemoveObjects(bucketName, dirPrefix); err != nil {
+	if err := analyzer.store.RemoveObjects(bucketName, dirPrefix); err != nil {
 		return err
 	}
 
@@ -175,25 +176,23 @@ func (analyzer *FrameAnalyzer) minioMoveObjects(whereToStore string, ev *v1.Anal
 }
 
 func (analyzer *FrameAnalyzer) onAnalyze(ev *v1.AnalyzeImgsEvent) error {
-	const OUTPUT_NAME = "detection_out"
-
 	tensor, err := analyzer.buildTensor(ev.FramePaths)
 	if err != nil {
 		analyzer.logger.Error("onAnalyzer: failed to create tensor", "err", err.Error())
 		return err
 	}
 
-	predictResp, err := analyzer.predict(tensor)
+	out, err := analyzer.detector.Predict(analyzer.ctx, tensor)
 	if err != nil {
 		return err
 	}
 
-	respProto, ok := predictResp.Outputs[OUTPUT_NAME]
-	if !ok {
-		return fmt.Errorf("expected output: %s does not exist in the response", OUTPUT_NAME)
+	camZones, err := analyzer.cameraZones(ev.UUID)
+	if err != nil {
+		return err
 	}
 
-	tensorData, err := analyzer.extractDataFromTensor(respProto)
+	tensorData, err := analyzer.extractDataFromTensor(out, len(ev.FramePaths), camZones)
 	if err != nil {
 		return err
 	}
@@ -201,7 +200,7 @@ func (analyzer *FrameAnalyzer) onAnalyze(ev *v1.AnalyzeImgsEvent) error {
 	state := services.StateDiscarded
 	whereToStore := os.Getenv("MINIO_FALSE_POSITIVES_KEY")
 	retentionDaysStr := os.Getenv("MINIO_FALSE_POSITIVES_DAYS")
-	if tensorData.maxConf >= 0.5 {
+	if len(tensorData.evidence) > 0 {
 		state = services.StatePromoted
 		whereToStore = os.Getenv("MINIO_DETECTIONS_KEY")
 		retentionDaysStr = os.Getenv("MINIO_DETECTIONS_DAYS")
@@ -218,21 +217,28 @@ func (analyzer *FrameAnalyzer) onAnalyze(ev *v1.AnalyzeImgsEvent) error {
 
 	req := v1.AddRecordingReq{
 		BucketName:         os.Getenv("MINIO_BUCKET_NAME"),
-		VidBucketKey:       path.Join(whereToStore, ev.VidPath),
-		BestFrameBucketKey: path.Join(whereToStore, ev.FramePaths[tensorData.imageIndex]),
+		VidBucketKey:       ev.StoredKey(whereToStore, ev.VidPath),
+		BestFrameBucketKey: ev.StoredKey(whereToStore, ev.FramePaths[tensorData.imageIndex]),
 		Evidence:           tensorData.evidence,
 		Score:              tensorData.maxConf,
 		RetentionDays:      retentionDays,
+		StartTs:            ev.StartTs,
+		EndTs:              ev.EndTs,
 	}
 
-	model, err := analyzer.recordingService.Upsert(analyzer.ctx, ev.UUID, state, req)
+	model, err := analyzer.recordings.Upsert(analyzer.ctx, ev.UUID, state, req)
 	if err != nil {
 		return err
 	}
 
-	analyzer.logger.Debug("upserted new recording", "where_to_store", whereToStore, "state", state, "max_conf", tensorData.maxConf)
+	analyzer.logger.Debug("upserted new recording",
+		"where_to_store", whereToStore,
+		"state", state,
+		"max_conf", tensorData.maxConf,
+		"detections", len(tensorData.evidence),
+	)
 
-	if model.Score >= 0.5 {
+	if state == services.StatePromoted {
 		modelBytes, err := json.Marshal(model)
 		if err != nil {
 			return err
@@ -245,99 +251,64 @@ func (analyzer *FrameAnalyzer) onAnalyze(ev *v1.AnalyzeImgsEvent) error {
 	return nil
 }
 
-func (analyzer *FrameAnalyzer) extractDataFromTensor(respProto *framework.TensorProto) (*tensorData, error) {
-	// shape should be [4, 1, 200, 7]
-	// 4 - batch size, 1 - class (person or not), 200 - max number of detections per image, 7 - detection info
-	responseContent := respProto.GetTensorContent()
-	dim := respProto.GetTensorShape().GetDim()
-	numClasses := int(dim[3].GetSize())
-	numRows := int(dim[2].GetSize())
+func (analyzer *FrameAnalyzer) cameraZones(camUUID string) ([]zones.Zone, error) {
+	if analyzer.zonesRepo == nil {
+		return nil, nil
+	}
 
-	outMat, err := gocv.NewMatFromBytes(numRows, numClasses, gocv.MatTypeCV32FC1, responseContent)
+	rows, err := analyzer.zonesRepo.FindByCamera(analyzer.ctx, camUUID)
 	if err != nil {
-		return nil, err
-	}
-	defer outMat.Close()
-
-	confCol := outMat.Col(2)
-	defer confCol.Close()
-
-	_, maxConf, _, maxLoc := gocv.MinMaxLoc(confCol)
-	imageIndex := int(maxLoc.Y / numRows)
-	detectionRow := outMat.Row(maxLoc.Y)
-	defer detectionRow.Close()
-
-	evidence := v1.Evidence{
-		Conf: maxConf,
-		Xmin: detectionRow.GetFloatAt(0, 3),
-		Ymin: detectionRow.GetFloatAt(0, 4),
-		Xmax: detectionRow.GetFloatAt(0, 5),
-		Ymax: detectionRow.GetFloatAt(0, 6),
+		return nil, fmt.Errorf("failed to load zones: %w", err)
 	}
 
-	return &tensorData{
-		numClasses: numClasses,
-		numRows:    numRows,
-		imageIndex: imageIndex,
-		evidence:   evidence,
-		maxConf:    maxConf,
-		maxLoc:     maxLoc,
-	}, nil
+	return utils.Map(rows, func(row *models.MotionZone) zones.Zone {
+		return zones.Zone{Name: row.Name, Kind: row.Kind, Points: row.Points}
+	}), nil
 }
 
-func (analyzer *FrameAnalyzer) predict(tensor []byte) (*pb.PredictResponse, error) {
-	const MODEL_NAME = "person-detection-retail-0013"
-	const INPUT_NAME = "data"
-
-	predicRequest := &pb.PredictRequest{
-		ModelSpec: &pb.ModelSpec{
-			Name:          MODEL_NAME,
-			SignatureName: "serving_default",
-			VersionChoice: &pb.ModelSpec_Version{
-				Version: &google_protobuf.Int64Value{
-					Value: 1,
-				},
-			},
-		},
-		Inputs: map[string]*framework.TensorProto{
-			INPUT_NAME: {
-				Dtype: framework.DataType_DT_FLOAT,
-				TensorShape: &framework.TensorShapeProto{
-					Dim: []*framework.TensorShapeProto_Dim{
-						{
-							Size: tensorN,
-						},
-						{
-							Size: tensorC,
-						},
-						{
-							Size: tensorH,
-						},
-						{
-							Size: tensorW,
-						},
-					},
-				},
-				TensorContent: tensor,
-			},
-		},
-	}
-
-	conn, err := grpc.NewClient(
-		os.Getenv("OVMS_GRPC_ADDR"),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
-
+// extractDataFromTensor keeps every detection above the model's confidence threshold across
+// all the frames and inside the camera's zones, overlapping boxes of the same class are
+// merged with non-maximum suppression. When none is kept the score and best frame are those
+// of the strongest detection that was dropped, so discarded clips can still be told apart.
+func (analyzer *FrameAnalyzer) extractDataFromTensor(out *detector.Output, numFrames int, camZones []zones.Zone) (*tensorData, error) {
+	cfg := analyzer.detector.Config()
+	dets, err := detector.ParseDetections(out, cfg)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
 
-	client := pb.NewPredictionServiceClient(conn)
-	predictResp, err := client.Predict(analyzer.ctx, predicRequest)
-	if err != nil {
-		return nil, fmt.Errorf("gRPC request failed: %w", err)
+	// Slots past the last frame are zero padding.
+	inRange := make([]detector.Detection, 0, len(dets))
+	for _, det := range dets {
+		if det.Frame < numFrames {
+			inRange = append(inRange, det)
+		}
+	}
+	dets = detector.FilterByZones(detector.FilterByConf(inRange, cfg.ConfThreshold), camZones)
+	dets = detector.NonMaxSuppression(dets, cfg.NmsIoU)
+
+	data := &tensorData{
+		imageIndex: max(detector.BestFrame(dets), 0),
+		evidence:   make([]v1.Evidence, 0, len(dets)),
+	}
+	if len(dets) == 0 {
+		data.imageIndex = max(detector.BestFrame(inRange), 0)
+		for _, det := range inRange {
+			data.maxConf = max(data.maxConf, det.Conf)
+		}
+	}
+	for _, det := range dets {
+		data.maxConf = max(data.maxConf, det.Conf)
+		data.evidence = append(data.evidence, v1.Evidence{
+			Conf:  det.Conf,
+			Xmin:  det.Xmin,
+			Ymin:  det.Ymin,
+			Xmax:  det.Xmax,
+			Ymax:  det.Ymax,
+			Label: det.Label,
+			Frame: det.Frame,
+		})
 	}
 
-	return predictResp, nil
+	return data, nil
 }
@@ -0,0 +1,187 @@
+package frameanalyzer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"path"
+	"slices"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/events"
+	"tomerab.com/cam-hub/internal/frame_analyzer/detector"
+	"tomerab.com/cam-hub/internal/services"
+)
+
+type fakeObjectStore struct {
+	objects map[string][]byte
+	copied  []string
+	removed []string
+}
+
+func (store *fakeObjectStore) GetObject(bucketName, objectName string) (io.ReadCloser, error) {
+	obj, ok := store.objects[objectName]
+	if !ok {
+		return nil, fmt.Errorf("object %s not found", objectName)
+	}
+	return io.NopCloser(bytes.NewReader(obj)), nil
+}
+
+func (store *fakeObjectStore) CopyObjectWithinBucket(bucket, srcPrefix, dstPrefix, objName string) (minio.UploadInfo, error) {
+	store.copied = append(store.copied, path.Join(dstPrefix, objName))
+	return minio.UploadInfo{}, nil
+}
+
+func (store *fakeObjectStore) RemoveObjects(bucketName, objPrefix string) error {
+	store.removed = append(store.removed, objPrefix)
+	return nil
+}
+
+type fakeRecordings struct {
+	state services.RecordingState
+	req   v1.AddRecordingReq
+}
+
+func (recordings *fakeRecordings) Upsert(ctx context.Context, camUUID string, state services.RecordingState, req v1.AddRecordingReq) (*models.Recordings, error) {
+	recordings.state = state
+	recordings.req = req
+	return &models.Recordings{CamUUID: camUUID, Score: req.Score}, nil
+}
+
+type fakeBus struct {
+	events.BusIface
+	published []string
+}
+
+func (bus *fakeBus) Publish(ctx context.Context, exc, key string, body []byte, headers map[string]any) error {
+	bus.published = append(bus.published, key)
+	return nil
+}
+
+func setupFrameAnalyzerTest(t *testing.T, dets ...detector.Detection) (*FrameAnalyzer, *fakeObjectStore, *fakeRecordings, *fakeBus, *v1.AnalyzeImgsEvent) {
+	t.Helper()
+
+	t.Setenv("MINIO_BUCKET_NAME", "camhub")
+	t.Setenv("MINIO_STAGING_KEY", "staging")
+	t.Setenv("MINIO_DETECTIONS_KEY", "detections")
+	t.Setenv("MINIO_DETECTIONS_DAYS", "30")
+	t.Setenv("MINIO_FALSE_POSITIVES_KEY", "false_positives")
+	t.Setenv("MINIO_FALSE_POSITIVES_DAYS", "3")
+
+	cfg := detector.DefaultModelConfig()
+	frame := encodeFrame(t, cfg.InputWidth, cfg.InputHeight)
+
+	ev := &v1.AnalyzeImgsEvent{UUID: "cam", Tp: "tp", VidPath: "staging/cam/tp/motion_cam_tp.mp4"}
+	store := &fakeObjectStore{objects: map[string][]byte{}}
+	for i := range 2 {
+		key := fmt.Sprintf("staging/cam/tp/motion_frame_%04d.png", i+1)
+		ev.FramePaths = append(ev.FramePaths, key)
+		store.objects[key] = frame
+	}
+
+	recordings := &fakeRecordings{}
+	bus := &fakeBus{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	det := detector.NewFakeDetector(cfg, dets...)
+
+	return New(context.Background(), logger, bus, store, det, recordings, nil), store, recordings, bus, ev
+}
+
+func encodeFrame(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("failed to encode frame: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestOnAnalyze(t *testing.T) {
+	person := detector.Detection{Frame: 1, ClassID: 1, Label: "person", Conf: 0.9, Xmin: 0.1, Ymin: 0.1, Xmax: 0.4, Ymax: 0.6}
+
+	t.Run("person detected - should promote and publish the recording", func(t *testing.T) {
+		analyzer, store, recordings, bus, ev := setupFrameAnalyzerTest(t, person)
+
+		if err := analyzer.onAnalyze(ev); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if recordings.state != services.StatePromoted {
+			t.Errorf("expected: %s, got: %s", services.StatePromoted, recordings.state)
+		}
+		if recordings.req.Score != person.Conf || len(recordings.req.Evidence) != 1 {
+			t.Errorf("expected the detection as evidence, got: %+v", recordings.req)
+		}
+		expectedVid := "detections/cam/tp/motion_cam_tp.mp4"
+		if recordings.req.VidBucketKey != expectedVid || !slices.Contains(store.copied, expectedVid) {
+			t.Errorf("expected the clip under %s, got: %s (copied: %v)", expectedVid, recordings.req.VidBucketKey, store.copied)
+		}
+		if recordings.req.BestFrameBucketKey != "detections/cam/tp/motion_frame_0002.png" {
+			t.Errorf("expected the frame of the detection, got: %s", recordings.req.BestFrameBucketKey)
+		}
+		if !slices.Equal(store.removed, []string{"staging/cam/tp"}) {
+			t.Errorf("expected the staged objects to be removed, got: %v", store.removed)
+		}
+		if !slices.Equal(bus.published, []string{"motion.detections"}) {
+			t.Errorf("expected the detection to be published, got: %v", bus.published)
+		}
+	})
+
+	t.Run("nothing detected - should discard the recording", func(t *testing.T) {
+		analyzer, store, recordings, bus, ev := setupFrameAnalyzerTest(t)
+
+		if err := analyzer.onAnalyze(ev); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if recordings.state != services.StateDiscarded || recordings.req.RetentionDays != 3 {
+			t.Errorf("expected a discarded recording, got: %s %+v", recordings.state, recordings.req)
+		}
+		if !slices.Contains(store.copied, "false_positives/cam/tp/motion_cam_tp.mp4") {
+			t.Errorf("expected the clip under false_positives, got: %v", store.copied)
+		}
+		if len(bus.published) != 0 {
+			t.Errorf("expected nothing to be published, got: %v", bus.published)
+		}
+	})
+
+	t.Run("weak detection - should discard the recording with its score", func(t *testing.T) {
+		weak := person
+		weak.Conf = 0.3
+		analyzer, _, recordings, _, ev := setupFrameAnalyzerTest(t, weak)
+
+		if err := analyzer.onAnalyze(ev); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if recordings.state != services.StateDiscarded || len(recordings.req.Evidence) != 0 {
+			t.Errorf("expected a discarded recording, got: %s %+v", recordings.state, recordings.req)
+		}
+		if recordings.req.Score != weak.Conf {
+			t.Errorf("expected the score of the dropped detection %v, got: %v", weak.Conf, recordings.req.Score)
+		}
+		if recordings.req.BestFrameBucketKey != "false_positives/cam/tp/motion_frame_0002.png" {
+			t.Errorf("expected the frame of the dropped detection, got: %s", recordings.req.BestFrameBucketKey)
+		}
+	})
+
+	t.Run("detector fails - should return the error", func(t *testing.T) {
+		analyzer, store, _, _, ev := setupFrameAnalyzerTest(t)
+		analyzer.detector = detector.NewFakeDetector(detector.DefaultModelConfig()).WithError(fmt.Errorf("model unavailable"))
+
+		if err := analyzer.onAnalyze(ev); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if len(store.copied) != 0 {
+			t.Errorf("expected the staged objects to stay, got: %v", store.copied)
+		}
+	})
+}
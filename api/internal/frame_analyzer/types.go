@@ -1,5 +1,28 @@
 package frameanalyzer
 
+import (
+	"context"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/services"
+)
+
+// ObjectStoreIface reads the staged frames and moves the classified objects to where they are
+// kept, objectstorage.MinIOStore implements it.
+type ObjectStoreIface interface {
+	GetObject(bucketName, objectName string) (io.ReadCloser, error)
+	CopyObjectWithinBucket(bucket, srcPrefix, dstPrefix, objName string) (minio.UploadInfo, error)
+	RemoveObjects(bucketName, objPrefix string) error
+}
+
+// RecordingsIface stores the classified recordings, services.RecordingsService implements it.
+type RecordingsIface interface {
+	Upsert(ctx context.Context, camUUID string, state services.RecordingState, req v1.AddRecordingReq) (*models.Recordings, error)
+}
+
 type AnalyzeImgsEvent struct {
 	UUID       string
 	VidPath    string
@@ -2,6 +2,9 @@ package httpserver
 
 import (
 	"log/slog"
+	"net/http"
+	"os"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -12,28 +15,89 @@ import (
 )
 
 func NewRouter(app *application.Application) *chi.Mux {
+	r := newBaseRouter(app)
+
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   allowedOrigins(),
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: false,
+		MaxAge:           300,
+	}))
+
+	r.Mount("/api/v1", v1.LoadRoutes(app))
+
+	return r
+}
+
+// NewInternalRouter serves the internal api on its own listener, which hands out camera
+// credentials and must not be reachable from wherever the public api is.
+func NewInternalRouter(app *application.Application) *chi.Mux {
+	r := newBaseRouter(app)
+	r.Mount("/internal/v1", v1.LoadInternalRoutes(app))
+
+	return r
+}
+
+func newBaseRouter(app *application.Application) *chi.Mux {
 	r := chi.NewRouter()
 	logger := slog.New(slog.NewJSONHandler(app.LogSink, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	}))
 
+	r.Use(queryAccessToken)
 	r.Use(httplog.RequestLogger(logger, &httplog.Options{
 		Level:  slog.LevelDebug,
 		Schema: httplog.SchemaECS,
 	}))
 	r.Use(middleware.Recoverer)
-	r.Use(commonHeaders)
 
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
-		MaxAge:           300,
-	}))
+	return r
+}
 
-	r.Mount("/api/v1", v1.LoadRoutes(app))
+// The SSE routes are the only ones taking the session token in the access_token query param,
+// EventSource can't set headers.
+const sseRoutesPrefix = "/api/v1/events/"
 
-	return r
+// queryAccessToken moves the access_token query param of the SSE routes to the Authorization
+// header and drops it from every url before the request is logged, so session tokens never
+// end up in the logs and the other routes only accept the header.
+func queryAccessToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if !query.Has("access_token") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := query.Get("access_token")
+		query.Del("access_token")
+
+		r = r.Clone(r.Context())
+		r.URL.RawQuery = query.Encode()
+		r.RequestURI = r.URL.RequestURI()
+		if strings.HasPrefix(r.URL.Path, sseRoutesPrefix) && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Browsers send the session token in the Authorization header, so origins can be narrowed
+// down to the ui with a comma separated CORS_ALLOWED_ORIGINS.
+func allowedOrigins() []string {
+	raw := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if raw == "" {
+		return []string{"*"}
+	}
+
+	origins := []string{}
+	for _, origin := range strings.Split(raw, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
 }
@@ -0,0 +1,59 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryAccessToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		header     string
+		wantAuth   string
+		wantTarget string
+	}{
+		{
+			name:       "sse route - should move the token to the header",
+			target:     "/api/v1/events/recordings/cam?access_token=secret&since=1",
+			wantAuth:   "Bearer secret",
+			wantTarget: "/api/v1/events/recordings/cam?since=1",
+		},
+		{
+			name:       "sse route with a header - should keep the header",
+			target:     "/api/v1/events/discovery?access_token=secret",
+			header:     "Bearer other",
+			wantAuth:   "Bearer other",
+			wantTarget: "/api/v1/events/discovery",
+		},
+		{
+			name:       "other route - should drop the token",
+			target:     "/api/v1/cameras?access_token=secret",
+			wantAuth:   "",
+			wantTarget: "/api/v1/cameras",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			handler := queryAccessToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if auth := got.Header.Get("Authorization"); auth != tt.wantAuth {
+				t.Errorf("expected Authorization: %q, got: %q", tt.wantAuth, auth)
+			}
+			if target := got.URL.String(); target != tt.wantTarget || got.RequestURI != tt.wantTarget {
+				t.Errorf("expected: %s, got: %s (%s)", tt.wantTarget, target, got.RequestURI)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package motion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gocv.io/x/gocv"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/events"
+	objectstorage "tomerab.com/cam-hub/internal/object_storage"
+	"tomerab.com/cam-hub/internal/onvif/event"
+	"tomerab.com/cam-hub/internal/zones"
+)
+
+const defaultMaxJobs = 8
+
+type DetectionParams struct {
+	CamUUID    string
+	StreamUrl  string
+	RecordPath string // mediamtx path the clips are cut from, defaults to CamUUID
+	Settings   v1.MotionSettings
+	Zones      []zones.Zone
+	Bus        DetectionBusIface
+	Store      *objectstorage.MinIOStore
+	Logger     *slog.Logger
+	MaxJobs    int // concurrent motion jobs, defaults to 8
+	// Resolves the camera's ONVIF source when the settings' source pulls its events.
+	OnvifSource OnvifSourceFunc
+}
+
+// RunDetection detects motion until ctx is cancelled and posts a job for every motion event once
+// it ends. Depending on the settings' source the detections come from sampling the camera stream,
+// from the camera's ONVIF events or from both. Settings and zones published for the camera are
+// applied without a restart.
+func RunDetection(ctx context.Context, params DetectionParams) error {
+	if params.MaxJobs <= 0 {
+		params.MaxJobs = defaultMaxJobs
+	}
+	if params.RecordPath == "" {
+		params.RecordPath = params.CamUUID
+	}
+	logger := params.Logger
+
+	// The stream is only read while the detections come from the server.
+	var cap *gocv.VideoCapture
+	defer func() {
+		if cap != nil {
+			cap.Close()
+		}
+	}()
+	syncCapture := func(source string) error {
+		switch {
+		case usesServerDetection(source) && cap == nil:
+			opened, err := gocv.VideoCaptureFile(params.StreamUrl)
+			if err != nil {
+				return fmt.Errorf("failed to open stream: %w", err)
+			}
+			opened.Set(gocv.VideoCaptureBufferSize, 1)
+			cap = opened
+		case !usesServerDetection(source) && cap != nil:
+			cap.Close()
+			cap = nil
+		}
+		return nil
+	}
+
+	frame := gocv.NewMat()
+	defer frame.Close()
+
+	settings := params.Settings
+	det := NewMotionDetector(settings.Threshold, settings.MinArea, settings.WarmupFrames)
+	defer det.Close()
+	det.SetZones(params.Zones)
+
+	// The camera's events are pulled while its source uses them.
+	camMotion := newCameraMotion()
+	onvifCh := make(chan onvifUpdate, 1)
+	var stopOnvif context.CancelFunc
+	defer func() {
+		if stopOnvif != nil {
+			stopOnvif()
+		}
+	}()
+	syncOnvif := func(source string) {
+		switch {
+		case usesCameraEvents(source) && stopOnvif == nil:
+			if params.OnvifSource == nil {
+				logger.Warn("no onvif source to pull the camera events from", "source", source)
+				return
+			}
+			var onvifCtx context.Context
+			onvifCtx, stopOnvif = context.WithCancel(ctx)
+			go pullOnvifEvents(onvifCtx, onvifSubscriber(params.OnvifSource, logger), logger, onvifCh)
+		case !usesCameraEvents(source) && stopOnvif != nil:
+			stopOnvif()
+			stopOnvif = nil
+			camMotion.reset()
+		}
+	}
+
+	if err := syncCapture(settings.Source); err != nil {
+		return err
+	}
+	syncOnvif(settings.Source)
+
+	tracker := newEventTracker(settings)
+	ticker := time.NewTicker(time.Duration(settings.TickMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	if err := params.Bus.DeclareQueue("motion.analyze", true, nil); err != nil {
+		return err
+	}
+
+	settingsCh, zonesCh, err := consumeConfig(ctx, params.Bus, params.CamUUID)
+	if err != nil {
+		return err
+	}
+
+	notifier := NewSegmentNotifier(params.RecordPath)
+	if err := consumeSegments(ctx, params.Bus, params.CamUUID, notifier); err != nil {
+		return err
+	}
+
+	runner := NewRunner(ctx, params.Store, params.Bus, notifier, logger, params.MaxJobs)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case settings := <-settingsCh:
+			det.Threshold = settings.Threshold
+			det.MinAreaPixels = settings.MinArea
+			det.WarmupFrames = settings.WarmupFrames
+			tracker.apply(settings)
+			ticker.Reset(time.Duration(settings.TickMs) * time.Millisecond)
+			if err := syncCapture(settings.Source); err != nil {
+				return err
+			}
+			syncOnvif(settings.Source)
+			logger.Info("applied new motion settings", "settings", settings)
+		case zs := <-zonesCh:
+			det.SetZones(zs)
+			logger.Info("applied new motion zones", "zones", len(zs))
+		case update := <-onvifCh:
+			// Left over from a subscription that was stopped since.
+			if stopOnvif == nil {
+				continue
+			}
+			if update.reset {
+				camMotion.reset()
+			}
+
+			now := time.Now().UTC()
+			for _, ev := range update.events {
+				if ev.Kind == event.KindTamper && ev.Active {
+					logger.Warn("camera reported tampering", "topic", ev.Topic, "source", ev.Source)
+				}
+				if camMotion.apply(ev) && tracker.motion(now, 0) {
+					logger.Info("camera detected motion", "topic", ev.Topic, "source", ev.Source, "motion_time", now)
+				}
+			}
+		case <-ticker.C:
+			now := time.Now().UTC()
+			if ev, ok := tracker.tick(now); ok {
+				logger.Info("motion event ended posting new job", "start", ev.Start, "end", ev.End)
+				go runner.PostJob(MotionCtx{
+					UUID:      params.CamUUID,
+					Score:     ev.Score,
+					TimePoint: ev.Start,
+					ClipStart: ev.ClipStart,
+					ClipEnd:   ev.ClipEnd,
+				})
+			}
+
+			// The camera doesn't repeat a state while it holds, the event is extended until
+			// its motion stops.
+			if camMotion.ongoing() {
+				tracker.motion(now, 0)
+			}
+
+			if cap == nil {
+				continue
+			}
+			if ok := cap.Read(&frame); !ok || frame.Empty() {
+				continue
+			}
+
+			isMotion, score := det.Detect(&frame)
+			if isMotion && tracker.motion(now, score) {
+				logger.Info("motion detected", "motion_time", now)
+			}
+		}
+	}
+}
+
+// Subscribes to the settings and zones updates of the camera, they are handed over to the
+// detection loop so the detector is only ever touched from one goroutine. The queue goes away
+// with the detector, a new one is started with the current settings.
+func consumeConfig(ctx context.Context, bus DetectionBusIface, camUUID string) (<-chan v1.MotionSettings, <-chan []zones.Zone, error) {
+	queue := v1.MotionConfigExchange + "." + camUUID
+	if err := bus.DeclareExchange(v1.MotionConfigExchange, "topic", true); err != nil {
+		return nil, nil, err
+	}
+	if err := bus.DeclareAutoDeleteQueue(queue, nil); err != nil {
+		return nil, nil, err
+	}
+	if err := bus.Bind(queue, v1.MotionConfigExchange, camUUID, nil); err != nil {
+		return nil, nil, err
+	}
+
+	settingsCh := make(chan v1.MotionSettings, 1)
+	zonesCh := make(chan []zones.Zone, 1)
+	err := bus.Consume(ctx, queue, "motion_detection", func(ctx context.Context, m events.Message) events.AckAction {
+		switch m.Headers[v1.MotionConfigTypeHeader] {
+		case v1.MotionConfigTypeZones:
+			var ev v1.MotionZonesEvent
+			if err := json.Unmarshal(m.Body, &ev); err != nil || zones.Validate(ev.Zones) != nil {
+				return events.NackDiscard
+			}
+
+			select {
+			case zonesCh <- ev.Zones:
+				return events.Ack
+			case <-ctx.Done():
+				return events.NackRequeue
+			}
+		default:
+			var ev v1.MotionSettingsEvent
+			if err := json.Unmarshal(m.Body, &ev); err != nil {
+				return events.NackDiscard
+			}
+
+			select {
+			case settingsCh <- ev.Settings:
+				return events.Ack
+			case <-ctx.Done():
+				return events.NackRequeue
+			}
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return settingsCh, zonesCh, nil
+}
@@ -6,8 +6,10 @@ package motion
 
 import (
 	"image"
+	"image/color"
 
 	"gocv.io/x/gocv"
+	"tomerab.com/cam-hub/internal/zones"
 )
 
 type MotionDetector struct {
@@ -23,6 +25,10 @@ type MotionDetector struct {
 	kDil   gocv.Mat
 	kClose gocv.Mat
 
+	zones      []zones.Zone
+	zoneMask   gocv.Mat // 255 where motion counts, rebuilt when the zones or frame size change
+	zonesDirty bool
+
 	frameCount int
 }
 
@@ -38,6 +44,8 @@ func NewMotionDetector(threshold float32, minAreaPixels, warmupFrames int) *Moti
 		kErode: gocv.GetStructuringElement(gocv.MorphEllipse, image.Pt(2, 2)),
 		kDil:   gocv.GetStructuringElement(gocv.MorphEllipse, image.Pt(5, 5)),
 		kClose: gocv.GetStructuringElement(gocv.MorphEllipse, image.Pt(3, 3)),
+
+		zoneMask: gocv.NewMat(),
 	}
 }
 
@@ -48,6 +56,14 @@ func (d *MotionDetector) Close() {
 	d.kErode.Close()
 	d.kDil.Close()
 	d.kClose.Close()
+	d.zoneMask.Close()
+}
+
+// SetZones limits the counted motion pixels to the include zones minus the exclude zones,
+// no zones means the whole frame is watched.
+func (d *MotionDetector) SetZones(zs []zones.Zone) {
+	d.zones = zs
+	d.zonesDirty = true
 }
 
 // Detect takes a BGR frame (Mat) and returns:
@@ -75,7 +91,10 @@ func (d *MotionDetector) Detect(frame *gocv.Mat) (isMotion bool, score int) {
 	gocv.Dilate(d.mask, &d.mask, d.kDil)
 	gocv.MorphologyEx(d.mask, &d.mask, gocv.MorphClose, d.kClose)
 
-	// 5) score
+	// 5) drop the pixels outside of the zones
+	d.applyZones(frame.Cols(), frame.Rows())
+
+	// 6) score
 	score = gocv.CountNonZero(d.mask)
 
 	// Warmup: let background settle
@@ -95,3 +114,40 @@ func (d *MotionDetector) ScoreRatio(frameWidth, frameHeight int, score int) floa
 	}
 	return float64(score) / float64(total)
 }
+
+func (d *MotionDetector) applyZones(width, height int) {
+	if len(d.zones) == 0 {
+		return
+	}
+
+	if d.zonesDirty || d.zoneMask.Cols() != width || d.zoneMask.Rows() != height {
+		d.buildZoneMask(width, height)
+	}
+
+	gocv.BitwiseAnd(d.mask, d.zoneMask, &d.mask)
+}
+
+func (d *MotionDetector) buildZoneMask(width, height int) {
+	include, exclude := zones.Pixels(d.zones, width, height)
+
+	d.zoneMask.Close()
+	d.zoneMask = gocv.Zeros(height, width, gocv.MatTypeCV8U)
+	if len(include) == 0 {
+		d.zoneMask.SetTo(gocv.NewScalar(255, 0, 0, 0))
+	} else {
+		fillZones(&d.zoneMask, include, color.RGBA{R: 255})
+	}
+	fillZones(&d.zoneMask, exclude, color.RGBA{})
+
+	d.zonesDirty = false
+}
+
+func fillZones(mask *gocv.Mat, polys [][]image.Point, c color.RGBA) {
+	if len(polys) == 0 {
+		return
+	}
+
+	pts := gocv.NewPointsVectorFromPoints(polys)
+	defer pts.Close()
+	gocv.FillPoly(mask, pts, c)
+}
@@ -0,0 +1,85 @@
+package motion
+
+import (
+	"time"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+)
+
+// MotionEvent spans the detections of an event, the clip adds the pre-roll before its first
+// detection and the post-roll after its last one.
+type MotionEvent struct {
+	Start     time.Time // first detection
+	End       time.Time // last detection
+	ClipStart time.Time
+	ClipEnd   time.Time
+	Score     int // highest score of the detections
+}
+
+// eventTracker groups the detections into events. An event is extended while motion is detected
+// within the post-roll of the last detection, up to the max event length.
+type eventTracker struct {
+	preRoll  time.Duration
+	postRoll time.Duration
+	maxEvent time.Duration
+	cooldown time.Duration
+
+	ongoing   *MotionEvent
+	lastEnded time.Time
+}
+
+func newEventTracker(settings v1.MotionSettings) *eventTracker {
+	tracker := &eventTracker{}
+	tracker.apply(settings)
+	return tracker
+}
+
+// apply takes effect on the ongoing event too.
+func (tracker *eventTracker) apply(settings v1.MotionSettings) {
+	tracker.preRoll = time.Duration(settings.PreRollMs) * time.Millisecond
+	tracker.postRoll = time.Duration(settings.PostRollMs) * time.Millisecond
+	tracker.maxEvent = time.Duration(settings.MaxEventMs) * time.Millisecond
+	tracker.cooldown = time.Duration(settings.CooldownMs) * time.Millisecond
+}
+
+// motion records a detection at t, it reports whether the detection started a new event.
+// Detections within the cooldown of the previous event are ignored.
+func (tracker *eventTracker) motion(t time.Time, score int) bool {
+	if tracker.ongoing != nil {
+		tracker.ongoing.End = t
+		tracker.ongoing.Score = max(tracker.ongoing.Score, score)
+		return false
+	}
+
+	if !tracker.lastEnded.IsZero() && t.Sub(tracker.lastEnded) < tracker.cooldown {
+		return false
+	}
+
+	tracker.ongoing = &MotionEvent{Start: t, End: t, Score: score}
+	return true
+}
+
+// tick ends the ongoing event once its post-roll passed without motion or it reached the max
+// event length, the ended event is returned with its clip bounds.
+func (tracker *eventTracker) tick(t time.Time) (*MotionEvent, bool) {
+	ev := tracker.ongoing
+	if ev == nil {
+		return nil, false
+	}
+
+	quiet := t.Sub(ev.End) >= tracker.postRoll
+	tooLong := ev.End.Sub(ev.Start) >= tracker.maxEvent
+	if !quiet && !tooLong {
+		return nil, false
+	}
+
+	ev.ClipStart = ev.Start.Add(-tracker.preRoll)
+	ev.ClipEnd = ev.End.Add(tracker.postRoll)
+	tracker.ongoing = nil
+	// A split event goes on in the next one without a cooldown.
+	if quiet {
+		tracker.lastEnded = t
+	}
+
+	return ev, true
+}
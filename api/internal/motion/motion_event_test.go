@@ -0,0 +1,69 @@
+package motion
+
+import (
+	"testing"
+	"time"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+)
+
+func TestEventTracker(t *testing.T) {
+	settings := v1.MotionSettings{PreRollMs: 2000, PostRollMs: 3000, MaxEventMs: 10000, CooldownMs: 1000}
+	base := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+
+	t.Run("continued motion - should extend the event until the post-roll passes quietly", func(t *testing.T) {
+		tracker := newEventTracker(settings)
+
+		if !tracker.motion(at(0), 10) {
+			t.Fatal("expected the first detection to start an event")
+		}
+		if tracker.motion(at(2000), 30) || tracker.motion(at(4000), 20) {
+			t.Fatal("expected the detections to extend the event")
+		}
+		if _, ok := tracker.tick(at(6500)); ok {
+			t.Fatal("expected the event to last through its post-roll")
+		}
+
+		ev, ok := tracker.tick(at(7000))
+		if !ok {
+			t.Fatal("expected the event to end")
+		}
+		if !ev.Start.Equal(at(0)) || !ev.End.Equal(at(4000)) || ev.Score != 30 {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+		if !ev.ClipStart.Equal(at(-2000)) || !ev.ClipEnd.Equal(at(7000)) {
+			t.Errorf("expected the clip to hold the rolls, got: %v - %v", ev.ClipStart, ev.ClipEnd)
+		}
+	})
+
+	t.Run("motion right after an event - should wait for the cooldown", func(t *testing.T) {
+		tracker := newEventTracker(settings)
+		tracker.motion(at(0), 10)
+		if _, ok := tracker.tick(at(3000)); !ok {
+			t.Fatal("expected the event to end")
+		}
+
+		if tracker.motion(at(3500), 10) {
+			t.Error("expected the detection within the cooldown to be ignored")
+		}
+		if !tracker.motion(at(4000), 10) {
+			t.Error("expected a new event after the cooldown")
+		}
+	})
+
+	t.Run("endless motion - should split the events at the max length", func(t *testing.T) {
+		tracker := newEventTracker(settings)
+		for ms := 0; ms <= 10000; ms += 500 {
+			tracker.motion(at(ms), 10)
+		}
+
+		ev, ok := tracker.tick(at(10000))
+		if !ok || !ev.End.Equal(at(10000)) {
+			t.Fatalf("expected the event to be split at its max length, got: %+v", ev)
+		}
+		if !tracker.motion(at(10500), 10) {
+			t.Error("expected the motion to go on in a new event without a cooldown")
+		}
+	})
+}
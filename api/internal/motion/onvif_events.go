@@ -0,0 +1,224 @@
+package motion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/onvif"
+	"tomerab.com/cam-hub/internal/onvif/event"
+)
+
+const (
+	onvifSubscriptionTTL = time.Minute
+	onvifPullTimeout     = 10 * time.Second
+	onvifPullLimit       = 32
+	onvifRetryMin        = 2 * time.Second
+	onvifRetryMax        = time.Minute
+)
+
+// The event kinds that start or extend a motion event, tamper alarms are only logged.
+var onvifTriggers = map[string]bool{
+	event.KindMotion:       true,
+	event.KindLineCrossing: true,
+	event.KindIntrusion:    true,
+}
+
+// OnvifSourceFunc resolves the camera's ONVIF address and credentials, they are fetched when
+// needed so they never show in the detector's args.
+type OnvifSourceFunc func(ctx context.Context) (*v1.CameraOnvif, error)
+
+// InternalOnvifSource fetches the camera's ONVIF source from the internal API, authenticated
+// with the shared internal token. apiAddr is the api's internal listener (INTERNAL_SERVER_ADDR),
+// the public one doesn't serve the credentials.
+func InternalOnvifSource(apiAddr, token, camUUID string) OnvifSourceFunc {
+	return func(ctx context.Context) (*v1.CameraOnvif, error) {
+		if apiAddr == "" {
+			return nil, errors.New("the internal api address is not set")
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		endpoint := fmt.Sprintf("%s/internal/v1/cameras/%s/onvif", strings.TrimSuffix(apiAddr, "/"), url.PathEscape(camUUID))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return nil, fmt.Errorf("api returned %s: %s", resp.Status, bytes.TrimSpace(body))
+		}
+
+		var source v1.CameraOnvif
+		if err := json.NewDecoder(resp.Body).Decode(&source); err != nil {
+			return nil, err
+		}
+		if source.Xaddr == "" {
+			return nil, errors.New("api returned an empty onvif source")
+		}
+
+		return &source, nil
+	}
+}
+
+type pullPointIface interface {
+	Pull(timeout time.Duration, limit int) ([]event.Event, error)
+	Renew(ttl time.Duration) error
+	Unsubscribe() error
+}
+
+type subscribeFunc func(ctx context.Context) (pullPointIface, error)
+
+// onvifUpdate carries the events of a pull. Reset tells the subscription was lost, the states
+// the camera reported no longer hold until it reports them again on the next one.
+type onvifUpdate struct {
+	events []event.Event
+	reset  bool
+}
+
+func onvifSubscriber(resolve OnvifSourceFunc, logger *slog.Logger) subscribeFunc {
+	return func(ctx context.Context) (pullPointIface, error) {
+		source, err := resolve(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		client, err := onvif.NewOnvifClient(onvif.OnvifClientParams{
+			Xaddr:    source.Xaddr,
+			Username: source.Username,
+			Password: source.Password,
+			Logger:   logger,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return client.CreatePullPoint(onvifSubscriptionTTL)
+	}
+}
+
+// pullOnvifEvents keeps a PullPoint subscription to the camera's events until ctx is cancelled
+// and hands them to out. The subscription is renewed halfway through its ttl and recreated with
+// a backoff when the camera fails.
+func pullOnvifEvents(ctx context.Context, subscribe subscribeFunc, logger *slog.Logger, out chan<- onvifUpdate) {
+	retry := onvifRetryMin
+	for {
+		pp, err := subscribe(ctx)
+		if err == nil {
+			logger.Info("subscribed to onvif events")
+			retry = onvifRetryMin
+			err = pullUntilFailure(ctx, pp, out)
+			// Best effort, the camera drops it on its own once the ttl passes.
+			_ = pp.Unsubscribe()
+
+			select {
+			case out <- onvifUpdate{reset: true}:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		logger.Warn("onvif events subscription failed", "err", err, "retry_in", retry)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, onvifRetryMax)
+	}
+}
+
+func pullUntilFailure(ctx context.Context, pp pullPointIface, out chan<- onvifUpdate) error {
+	renewAt := time.Now().Add(onvifSubscriptionTTL / 2)
+	for ctx.Err() == nil {
+		if time.Now().After(renewAt) {
+			if err := pp.Renew(onvifSubscriptionTTL); err != nil {
+				return err
+			}
+			renewAt = time.Now().Add(onvifSubscriptionTTL / 2)
+		}
+
+		evs, err := pp.Pull(onvifPullTimeout, onvifPullLimit)
+		if err != nil {
+			return err
+		}
+		if len(evs) == 0 {
+			continue
+		}
+
+		select {
+		case out <- onvifUpdate{events: evs}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func usesServerDetection(source string) bool {
+	return source != v1.MotionSourceCamera
+}
+
+func usesCameraEvents(source string) bool {
+	return source == v1.MotionSourceCamera || source == v1.MotionSourceBoth
+}
+
+// cameraMotion follows the stateful topics of the camera, its motion goes on while any of them
+// is active.
+type cameraMotion struct {
+	active map[string]bool // by topic and source
+}
+
+func newCameraMotion() *cameraMotion {
+	return &cameraMotion{active: make(map[string]bool)}
+}
+
+// apply reports whether the event is a detection, stateful topics only detect when they turn active.
+func (cm *cameraMotion) apply(ev event.Event) bool {
+	if !onvifTriggers[ev.Kind] {
+		return false
+	}
+	if !ev.Stateful {
+		return true
+	}
+
+	key := ev.Topic + "|" + ev.Source
+	wasActive := cm.active[key]
+	if ev.Active {
+		cm.active[key] = true
+	} else {
+		delete(cm.active, key)
+	}
+
+	return ev.Active && !wasActive
+}
+
+func (cm *cameraMotion) ongoing() bool {
+	return len(cm.active) > 0
+}
+
+// reset forgets the topics' states, the camera reports them again when subscribing.
+func (cm *cameraMotion) reset() {
+	clear(cm.active)
+}
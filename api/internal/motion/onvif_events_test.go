@@ -0,0 +1,177 @@
+package motion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/onvif/event"
+)
+
+type fakePullPoint struct {
+	mtx          sync.Mutex
+	pulls        [][]event.Event // handed out in order, then the pulls fail
+	unsubscribed bool
+}
+
+func (pp *fakePullPoint) Pull(timeout time.Duration, limit int) ([]event.Event, error) {
+	pp.mtx.Lock()
+	defer pp.mtx.Unlock()
+
+	if len(pp.pulls) == 0 {
+		return nil, errors.New("subscription gone")
+	}
+	evs := pp.pulls[0]
+	pp.pulls = pp.pulls[1:]
+	return evs, nil
+}
+
+func (pp *fakePullPoint) Renew(ttl time.Duration) error {
+	return nil
+}
+
+func (pp *fakePullPoint) Unsubscribe() error {
+	pp.mtx.Lock()
+	defer pp.mtx.Unlock()
+
+	pp.unsubscribed = true
+	return nil
+}
+
+func readOnvifUpdate(t *testing.T, ch chan onvifUpdate) onvifUpdate {
+	t.Helper()
+
+	select {
+	case update := <-ch:
+		return update
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for an onvif update")
+		return onvifUpdate{}
+	}
+}
+
+func TestPullOnvifEvents(t *testing.T) {
+	t.Run("failing subscription - should hand out its events then reset", func(t *testing.T) {
+		motionEv := event.Event{Topic: "RuleEngine/CellMotionDetector/Motion", Kind: event.KindMotion, Active: true, Stateful: true}
+		pp := &fakePullPoint{pulls: [][]event.Event{{motionEv}, nil}}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ch := make(chan onvifUpdate)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		subscribe := func(ctx context.Context) (pullPointIface, error) { return pp, nil }
+		go pullOnvifEvents(ctx, subscribe, logger, ch)
+
+		if update := readOnvifUpdate(t, ch); update.reset || len(update.events) != 1 || update.events[0] != motionEv {
+			t.Errorf("expected the motion event, got: %+v", update)
+		}
+		if update := readOnvifUpdate(t, ch); !update.reset || len(update.events) != 0 {
+			t.Errorf("expected a reset once the pull failed, got: %+v", update)
+		}
+
+		pp.mtx.Lock()
+		defer pp.mtx.Unlock()
+		if !pp.unsubscribed {
+			t.Errorf("expected the failed subscription to be unsubscribed")
+		}
+	})
+}
+
+func TestCameraMotionApply(t *testing.T) {
+	cellMotion := func(active bool) event.Event {
+		return event.Event{Topic: "RuleEngine/CellMotionDetector/Motion", Kind: event.KindMotion, Source: "rule", Active: active, Stateful: true}
+	}
+
+	t.Run("stateful topic - should detect when it turns active and hold until inactive", func(t *testing.T) {
+		cm := newCameraMotion()
+
+		if !cm.apply(cellMotion(true)) || !cm.ongoing() {
+			t.Fatalf("expected a detection with ongoing motion")
+		}
+		if cm.apply(cellMotion(true)) {
+			t.Errorf("expected a repeated state not to detect again")
+		}
+		if cm.apply(cellMotion(false)) || cm.ongoing() {
+			t.Errorf("expected the motion to stop")
+		}
+	})
+
+	t.Run("momentary topic - should detect without holding", func(t *testing.T) {
+		cm := newCameraMotion()
+
+		if !cm.apply(event.Event{Topic: "RuleEngine/LineDetector/Crossed", Kind: event.KindLineCrossing, Active: true}) {
+			t.Errorf("expected the line crossing to detect")
+		}
+		if cm.ongoing() {
+			t.Errorf("expected no ongoing motion after a momentary event")
+		}
+	})
+
+	t.Run("tamper - should not detect", func(t *testing.T) {
+		cm := newCameraMotion()
+
+		if cm.apply(event.Event{Topic: "RuleEngine/TamperDetector/Tamper", Kind: event.KindTamper, Active: true, Stateful: true}) || cm.ongoing() {
+			t.Errorf("expected tampering not to count as motion")
+		}
+	})
+
+	t.Run("reset - should forget the states", func(t *testing.T) {
+		cm := newCameraMotion()
+		cm.apply(cellMotion(true))
+		cm.reset()
+
+		if cm.ongoing() {
+			t.Errorf("expected no ongoing motion after a reset")
+		}
+		if !cm.apply(cellMotion(true)) {
+			t.Errorf("expected the reported state to detect again after a reset")
+		}
+	})
+}
+
+func TestInternalOnvifSource(t *testing.T) {
+	source := v1.CameraOnvif{Xaddr: "10.0.0.12:80", Username: "admin", Password: "secret"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/internal/v1/cameras/cam/onvif" {
+			http.Error(w, `{"error":"camera not found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(source)
+	}))
+	defer srv.Close()
+
+	t.Run("known camera - should return its onvif source", func(t *testing.T) {
+		got, err := InternalOnvifSource(srv.URL+"/", "token", "cam")(context.Background())
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if *got != source {
+			t.Errorf("expected %+v, got %+v", source, *got)
+		}
+	})
+
+	t.Run("unknown camera - should return an error", func(t *testing.T) {
+		if _, err := InternalOnvifSource(srv.URL, "token", "missing")(context.Background()); err == nil {
+			t.Fatalf("expected an error")
+		}
+	})
+
+	t.Run("wrong token - should return an error", func(t *testing.T) {
+		if _, err := InternalOnvifSource(srv.URL, "nope", "cam")(context.Background()); err == nil {
+			t.Fatalf("expected an error")
+		}
+	})
+}
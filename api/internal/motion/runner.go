@@ -10,13 +10,14 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
-	"slices"
+	"strconv"
 	"time"
 
 	"golang.org/x/sync/errgroup"
 	v1 "tomerab.com/cam-hub/internal/contracts/v1"
 	"tomerab.com/cam-hub/internal/events"
 	objectstorage "tomerab.com/cam-hub/internal/object_storage"
+	"tomerab.com/cam-hub/internal/segments"
 	"tomerab.com/cam-hub/internal/utils"
 )
 
@@ -24,10 +25,15 @@ var (
 	bucketName = os.Getenv("MINIO_BUCKET_NAME")
 )
 
+// How long a job waits for the segments of its clip to complete.
+const segmentWaitTimeout = v1.MotionSegmentWait
+
 type MotionCtx struct {
 	UUID      string
 	Score     int
-	TimePoint time.Time
+	TimePoint time.Time // first detection of the event
+	ClipStart time.Time
+	ClipEnd   time.Time
 }
 
 type Runner struct {
@@ -35,16 +41,18 @@ type Runner struct {
 	ctx         context.Context
 	bus         events.BusIface
 	minioClient *objectstorage.MinIOStore
+	notifier    *SegmentNotifier
 	sem         chan struct{}
 }
 
-func NewRunner(ctx context.Context, minioClient *objectstorage.MinIOStore, bus events.BusIface, logger *slog.Logger, maxJobs int) *Runner {
+func NewRunner(ctx context.Context, minioClient *objectstorage.MinIOStore, bus events.BusIface, notifier *SegmentNotifier, logger *slog.Logger, maxJobs int) *Runner {
 	logger.Info("new runner created")
 	return &Runner{
 		logger:      logger,
 		ctx:         ctx,
 		bus:         bus,
 		minioClient: minioClient,
+		notifier:    notifier,
 		sem:         make(chan struct{}, maxJobs),
 	}
 }
@@ -56,20 +64,20 @@ func (runner *Runner) PostJob(ctx MotionCtx) error {
 }
 
 func (runner *Runner) process(ctx MotionCtx) error {
-	parts, err := onMotion(ctx.UUID, ctx.Score, ctx.TimePoint)
+	segs, err := runner.waitForSegments(ctx.ClipStart, ctx.ClipEnd)
 	if err != nil {
 		return err
 	}
 
 	tp := ctx.TimePoint.UTC().Format("2006-01-02_15-04-05")
 	outFileName := fmt.Sprintf("motion_%s_%s.mp4", ctx.UUID, tp)
-	runner.logger.Info("File part", "parts", parts.files)
-	if err := concatVideoFiles(runner.ctx, runner.logger, outFileName, parts.files); err != nil {
+	runner.logger.Info("File part", "segments", len(segs), "clip_start", ctx.ClipStart, "clip_end", ctx.ClipEnd)
+	if err := segments.Trim(runner.ctx, runner.logger, outFileName, segs, ctx.ClipStart, ctx.ClipEnd, nil); err != nil {
 		return err
 	}
 	runner.logger.Info("Created new file", "path", outFileName)
 
-	framePaths, err := extractFrames(runner.ctx, runner.logger, ctx, parts.files[1])
+	framePaths, err := extractFrames(runner.ctx, runner.logger, ctx, outFileName, ctx.TimePoint.Sub(ctx.ClipStart))
 	if err != nil {
 		return err
 	}
@@ -97,6 +105,8 @@ func (runner *Runner) process(ctx MotionCtx) error {
 		FramePaths: utils.Map(framePaths, func(p string) string {
 			return path.Join(objPath, p)
 		}),
+		StartTs: ctx.ClipStart,
+		EndTs:   ctx.ClipEnd,
 	})
 	if err != nil {
 		return err
@@ -169,18 +179,22 @@ func (runner *Runner) uploadFramesToStore(bucketName, objPath string, paths []st
 	return eg.Wait()
 }
 
-// extractFrames runs ffmpeg to extract up to 4 PNG frames at 10fps from
-// the given motionVideoPath. The output files are named with the motion
-// event UUID and timestamp, e.g. "motion_frame_<uuid>_<time>_0001.png".
+// extractFrames runs ffmpeg to extract up to 4 PNG frames at 1fps from
+// the given motionVideoPath, starting offset into it where the motion was
+// first detected. The output files are named with the motion event UUID and
+// timestamp, e.g. "motion_frame_<uuid>_<time>_0001.png".
 //
-// It returns the full list of expected output paths. If ffmpeg fails, an
+// It returns the paths of the frames ffmpeg wrote, fewer than 4 when the clip
+// ends less than 4s after offset. If ffmpeg fails or writes no frame, an
 // error is returned and details are logged with the provided logger.
 //
 // ctx is used to cancel the ffmpeg process early.
-func extractFrames(ctx context.Context, logger *slog.Logger, motionCtx MotionCtx, motionVideoPath string) ([]string, error) {
-	outFileName := fmt.Sprintf("motion_frame_%s_%s_%%04d.png", motionCtx.UUID, motionCtx.TimePoint.Format("2006-01-02_15-04-05"))
+func extractFrames(ctx context.Context, logger *slog.Logger, motionCtx MotionCtx, motionVideoPath string, offset time.Duration) ([]string, error) {
+	framePrefix := fmt.Sprintf("motion_frame_%s_%s_", motionCtx.UUID, motionCtx.TimePoint.Format("2006-01-02_15-04-05"))
+	outFileName := framePrefix + "%04d.png"
 	args := []string{
 		"-hide_banner", "-nostdin", "-y",
+		"-ss", strconv.FormatFloat(max(offset, 0).Seconds(), 'f', 3, 64),
 		"-i", motionVideoPath,
 		// Scale the video so it will complie with ovms retail model input
 		"-vf", "fps=1,scale=544:320:flags=bicubic",
@@ -203,158 +217,27 @@ func extractFrames(ctx context.Context, logger *slog.Logger, motionCtx MotionCtx
 		return nil, fmt.Errorf("ffmpeg failed: %w", err)
 	}
 
-	paths := make([]string, 0, 4)
-	for i := 1; i <= cap(paths); i++ {
-		paths = append(paths, fmt.Sprintf("motion_frame_%s_%s_%04d.png", motionCtx.UUID, motionCtx.TimePoint.Format("2006-01-02_15-04-05"), i))
-	}
-
-	return paths, nil
-}
-
-func getFileNames(entires []os.DirEntry) []string {
-	lst := make([]string, 0)
-	for _, entry := range entires {
-		if !entry.IsDir() {
-			lst = append(lst, entry.Name())
-		}
-	}
-
-	return lst
-}
-
-func recordingDir(camID string) string {
-	return filepath.Join("../recordings", camID)
-}
-
-func fileKeyFor(t time.Time) string {
-	base := t.UTC().Format("2006-01-02_15-04-05")
-	usec := t.Nanosecond() / 1_000
-	return fmt.Sprintf("%s-%06d", base, usec)
-}
-
-func getFileList(camID string, motionTime time.Time) ([]string, error) {
-	dir := recordingDir(camID)
-
-	entries, err := os.ReadDir(dir)
+	// Glob sorts the matches, so the frames keep their order
+	paths, err := filepath.Glob(framePrefix + "[0-9][0-9][0-9][0-9].png")
 	if err != nil {
 		return nil, err
 	}
-
-	names := getFileNames(entries)
-	key := fileKeyFor(motionTime)
-	idx, found := slices.BinarySearch(names, key)
-
-	out := make([]string, 0, 3)
-	if found {
-		if idx-1 >= 0 {
-			out = append(out, filepath.Join(dir, names[idx-1]))
-		}
-		out = append(out, filepath.Join(dir, names[idx]))
-		if idx+1 < len(names) {
-			out = append(out, filepath.Join(dir, names[idx+1]))
-		}
-		return out, nil
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("ffmpeg wrote no frames from %s", motionVideoPath)
 	}
 
-	if idx-1 >= 0 {
-		out = append(out, filepath.Join(dir, names[idx-1]))
-	}
-	if idx < len(names) {
-		out = append(out, filepath.Join(dir, names[idx]))
-	}
-	if idx+1 < len(names) {
-		out = append(out, filepath.Join(dir, names[idx+1]))
-	}
-
-	return out, nil
-}
-
-func waitForFiles(path string, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		file, err := os.Stat(path)
-		if err == nil {
-			sz := file.Size()
-			if sz > 0 {
-				time.Sleep(200 * time.Millisecond)
-				file, err := os.Stat(path)
-				if err == nil && file.Size() == sz {
-					return nil
-				}
-			}
-		}
-
-		time.Sleep(200 * time.Millisecond)
-	}
-
-	return fmt.Errorf("timeout waiting for %s", path)
-}
-
-func onMotion(uuid string, score int, motionTime time.Time) (*VideoArtifactParts, error) {
-	timeout := time.After(30 * time.Second)
-	ticker := time.NewTicker(200 * time.Millisecond)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-timeout:
-			return nil, fmt.Errorf("timeout waiting for video files")
-		case <-ticker.C:
-			fileList, err := getFileList(uuid, motionTime)
-			if err != nil {
-				return nil, err
-			}
-			if len(fileList) > 2 {
-				if err := waitForFiles(fileList[len(fileList)-1], 5*time.Second); err != nil {
-					return nil, err
-				}
-				return &VideoArtifactParts{score: score, files: fileList}, nil
-			}
-		}
-	}
+	return paths, nil
 }
 
-func concatVideoFiles(ctx context.Context, logger *slog.Logger, outputFileName string, fileList []string) error {
-	listFileName := fmt.Sprintf("tmp-%s", outputFileName)
-	if err := writeFileList(listFileName, reformatFileList(fileList)); err != nil {
-		return err
-	}
-	defer os.Remove(listFileName)
-
-	cmdName := "ffmpeg"
-	cmdArgs := []string{
-		"-f", "concat",
-		"-safe", "0",
-		"-i", listFileName,
-		"-c", "copy", outputFileName,
-	}
-
-	cmd := exec.CommandContext(ctx, cmdName, cmdArgs...)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		logger.Error(
-			"ffmpeg failed",
-			"fileList", fileList,
-			"stderr", stderr.String(),
-			"error", err.Error(),
-		)
-		return fmt.Errorf("ffmpeg failed: %w", err)
-	}
+// waitForSegments waits for the segments of the clip to complete.
+func (runner *Runner) waitForSegments(from, to time.Time) ([]segments.Segment, error) {
+	ctx, cancel := context.WithTimeout(runner.ctx, segmentWaitTimeout)
+	defer cancel()
 
-	return nil
-}
-
-func reformatFileList(fileList []string) string {
-	listContent := ""
-	for _, fileName := range fileList {
-		listContent += fmt.Sprintf("file '%s'\n", fileName)
+	segs, err := runner.notifier.Range(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for video files: %w", err)
 	}
 
-	return listContent
-}
-
-func writeFileList(dstName string, content string) error {
-	return os.WriteFile(dstName, []byte(content), 0644)
+	return segs, nil
 }
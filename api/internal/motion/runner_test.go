@@ -0,0 +1,76 @@
+package motion
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeFfmpeg puts an ffmpeg on PATH that writes the given number of frames
+// to the output pattern in its last argument.
+func fakeFfmpeg(t *testing.T, frames int) {
+	t.Helper()
+
+	bin := t.TempDir()
+	script := "#!/bin/sh\nfor arg; do out=$arg; done\ni=1\nwhile [ $i -le " + strconv.Itoa(frames) + " ]; do\n" +
+		"\t: > \"$(printf \"$out\" $i)\"\n\ti=$((i+1))\ndone\n"
+	if err := os.WriteFile(filepath.Join(bin, "ffmpeg"), []byte(script), 0o755); err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestExtractFrames(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	motionCtx := MotionCtx{
+		UUID:      "cam",
+		TimePoint: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("clip ends 2s after the offset - should return only the written frames", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		fakeFfmpeg(t, 2)
+		// A frame of another event of the same camera
+		if err := os.WriteFile("motion_frame_cam_2026-10-17_12-00-05_0003.png", nil, 0o644); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		paths, err := extractFrames(context.Background(), logger, motionCtx, "motion.mp4", time.Second)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		expected := []string{"motion_frame_cam_2026-10-17_12-00-00_0001.png", "motion_frame_cam_2026-10-17_12-00-00_0002.png"}
+		if !slices.Equal(paths, expected) {
+			t.Errorf("expected: %v, got: %v", expected, paths)
+		}
+	})
+
+	t.Run("full clip - should return 4 frames in order", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		fakeFfmpeg(t, 4)
+
+		paths, err := extractFrames(context.Background(), logger, motionCtx, "motion.mp4", time.Second)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if len(paths) != 4 || paths[3] != "motion_frame_cam_2026-10-17_12-00-00_0004.png" {
+			t.Errorf("expected 4 frames, got: %v", paths)
+		}
+	})
+
+	t.Run("no frames written - should return an error", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		fakeFfmpeg(t, 0)
+
+		if _, err := extractFrames(context.Background(), logger, motionCtx, "motion.mp4", time.Second); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	})
+}
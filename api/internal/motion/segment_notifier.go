@@ -0,0 +1,155 @@
+package motion
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/events"
+	"tomerab.com/cam-hub/internal/mtxapi"
+	"tomerab.com/cam-hub/internal/segments"
+)
+
+// Segments that ended this long before the newest one are forgotten, mediamtx deletes them by
+// then anyway.
+const segmentsHistory = v1.MotionSegmentsRetention
+
+type completedSegment struct {
+	path  string
+	start time.Time
+	end   time.Time
+}
+
+// SegmentNotifier keeps the segments mediamtx completed for a record path, as announced on the
+// recording segments exchange, and wakes up the jobs waiting for them.
+type SegmentNotifier struct {
+	recordPath string
+
+	mtx     sync.Mutex
+	segs    []completedSegment // ordered by start
+	changed chan struct{}      // closed and replaced whenever a segment completes
+}
+
+func NewSegmentNotifier(recordPath string) *SegmentNotifier {
+	return &SegmentNotifier{
+		recordPath: recordPath,
+		changed:    make(chan struct{}),
+	}
+}
+
+// Add records a completed segment, segments of other paths are ignored.
+func (n *SegmentNotifier) Add(ev v1.RecordingSegmentEvent) error {
+	if ev.Path != n.recordPath {
+		return nil
+	}
+
+	start, err := mtxapi.ParseSegmentStart(ev.File)
+	if err != nil {
+		return err
+	}
+	duration, err := mtxapi.ParseSegmentDuration(ev.Duration)
+	if err != nil {
+		return err
+	}
+
+	// The file is named after its path in the mediamtx container, the runner reads it from the
+	// recordings dir mounted on this side.
+	seg := completedSegment{
+		path:  filepath.Join(segments.Dir(n.recordPath), path.Base(ev.File)),
+		start: start,
+		end:   start.Add(duration),
+	}
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	// The hooks run concurrently so the segments may be announced out of order.
+	idx := sort.Search(len(n.segs), func(i int) bool { return !n.segs[i].start.Before(seg.start) })
+	if idx < len(n.segs) && n.segs[idx].start.Equal(seg.start) {
+		return nil
+	}
+	n.segs = append(n.segs, completedSegment{})
+	copy(n.segs[idx+1:], n.segs[idx:])
+	n.segs[idx] = seg
+
+	horizon := n.segs[len(n.segs)-1].end.Add(-segmentsHistory)
+	drop := sort.Search(len(n.segs), func(i int) bool { return n.segs[i].end.After(horizon) })
+	n.segs = n.segs[drop:]
+
+	close(n.changed)
+	n.changed = make(chan struct{})
+	return nil
+}
+
+// Range waits until the segments covering [from, to) completed and returns them, the first one
+// may start before from.
+func (n *SegmentNotifier) Range(ctx context.Context, from, to time.Time) ([]segments.Segment, error) {
+	for {
+		n.mtx.Lock()
+		segs, ok := n.inRange(from, to)
+		changed := n.changed
+		n.mtx.Unlock()
+
+		if ok {
+			return segs, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// inRange must be called with n.mtx held.
+func (n *SegmentNotifier) inRange(from, to time.Time) ([]segments.Segment, bool) {
+	if len(n.segs) == 0 || n.segs[len(n.segs)-1].end.Before(to) {
+		return nil, false
+	}
+
+	var out []segments.Segment
+	for _, seg := range n.segs {
+		if !seg.end.After(from) {
+			continue
+		}
+		if !seg.start.Before(to) {
+			break
+		}
+		out = append(out, segments.Segment{Path: seg.path, Start: seg.start})
+	}
+
+	return out, len(out) > 0
+}
+
+// Subscribes to the segments completed for the camera and hands them to the notifier, the queue
+// goes away with the detector so segments don't pile up for cameras that are gone.
+func consumeSegments(ctx context.Context, bus DetectionBusIface, camUUID string, notifier *SegmentNotifier) error {
+	queue := v1.RecordingSegmentsExchange + "." + camUUID
+	if err := bus.DeclareExchange(v1.RecordingSegmentsExchange, "topic", true); err != nil {
+		return err
+	}
+	if err := bus.DeclareAutoDeleteQueue(queue, nil); err != nil {
+		return err
+	}
+	if err := bus.Bind(queue, v1.RecordingSegmentsExchange, camUUID, nil); err != nil {
+		return err
+	}
+
+	return bus.Consume(ctx, queue, "motion_segments", func(ctx context.Context, m events.Message) events.AckAction {
+		var ev v1.RecordingSegmentEvent
+		if err := json.Unmarshal(m.Body, &ev); err != nil {
+			return events.NackDiscard
+		}
+		if err := notifier.Add(ev); err != nil {
+			return events.NackDiscard
+		}
+
+		return events.Ack
+	})
+}
@@ -0,0 +1,96 @@
+package motion
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/segments"
+)
+
+func segmentEvent(recordPath string, start time.Time) v1.RecordingSegmentEvent {
+	return v1.RecordingSegmentEvent{
+		Path:     recordPath,
+		File:     "/recordings/" + recordPath + "/" + start.Format("2006-01-02_15-04-05") + "-000000.mp4",
+		Duration: "1",
+	}
+}
+
+func TestSegmentNotifierRange(t *testing.T) {
+	t.Setenv("RECORDINGS_DIR", "/data/recordings")
+	base := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	from, to := base.Add(1500*time.Millisecond), base.Add(3500*time.Millisecond)
+	file := func(sec int) string {
+		return filepath.Join("/data/recordings/cam/main", base.Add(time.Duration(sec)*time.Second).Format("2006-01-02_15-04-05")+"-000000.mp4")
+	}
+
+	t.Run("segments announced out of order - should return once the range completed", func(t *testing.T) {
+		notifier := NewSegmentNotifier("cam/main")
+		for _, sec := range []int{2, 0, 1} {
+			if err := notifier.Add(segmentEvent("cam/main", base.Add(time.Duration(sec)*time.Second))); err != nil {
+				t.Fatalf("expected nil, got err: %v", err)
+			}
+		}
+
+		done := make(chan []segments.Segment, 1)
+		go func() {
+			segs, err := notifier.Range(context.Background(), from, to)
+			if err != nil {
+				t.Errorf("expected nil, got err: %v", err)
+			}
+			done <- segs
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("expected to wait for the segment holding the end of the range")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		if err := notifier.Add(segmentEvent("cam/main", base.Add(3*time.Second))); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		select {
+		case segs := <-done:
+			var got []string
+			for _, seg := range segs {
+				got = append(got, seg.Path)
+			}
+			if expected := []string{file(1), file(2), file(3)}; !slices.Equal(got, expected) {
+				t.Errorf("expected: %v, got: %v", expected, got)
+			}
+			if !segs[0].Start.Equal(base.Add(time.Second)) {
+				t.Errorf("expected the first segment to start at %v, got: %v", base.Add(time.Second), segs[0].Start)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the segments")
+		}
+	})
+
+	t.Run("other paths - should be ignored", func(t *testing.T) {
+		notifier := NewSegmentNotifier("cam/main")
+		for sec := range 5 {
+			if err := notifier.Add(segmentEvent("cam/sub", base.Add(time.Duration(sec)*time.Second))); err != nil {
+				t.Fatalf("expected nil, got err: %v", err)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := notifier.Range(ctx, from, to); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected context.DeadlineExceeded, got: %v", err)
+		}
+	})
+
+	t.Run("malformed segment - should return an error", func(t *testing.T) {
+		notifier := NewSegmentNotifier("cam/main")
+		if err := notifier.Add(v1.RecordingSegmentEvent{Path: "cam/main", File: "segment.mp4", Duration: "1"}); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
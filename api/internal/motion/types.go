@@ -1,6 +1,8 @@
 package motion
 
-type VideoArtifactParts struct {
-	score int
-	files []string
+import "tomerab.com/cam-hub/internal/events"
+
+type DetectionBusIface interface {
+	events.BusIface
+	events.DeclarerIface
 }
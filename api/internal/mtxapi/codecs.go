@@ -0,0 +1,125 @@
+package mtxapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const probeTimeout = 10 * time.Second
+
+// FFmpegArgs publishes the source read from the source pipe (see PipeSource) to target. The
+// video is re-encoded to H.264 baseline when transcode is set and copied as is otherwise, the
+// audio is copied when copyAudio is set (see AudioCompatible) and re-encoded to Opus otherwise.
+//
+// Even when both are copied the stream is relayed by ffmpeg rather than set as the mediamtx
+// path's source, the source url holds the camera credentials and mediamtx would keep it in its
+// config, which its api serves to anyone reaching it.
+func FFmpegArgs(target string, transcode, copyAudio bool) []string {
+	args := append([]string{"-loglevel", "warning"}, PipeInputArgs()...)
+	args = append(args, "-map", "0:v", "-map", "0:a?")
+
+	if transcode {
+		args = append(args,
+			"-c:v", "libx264", "-pix_fmt", "yuv420p", "-profile:v", "baseline", "-level:v", "3.1",
+			"-preset", "veryfast", "-tune", "zerolatency", "-g", "60", "-keyint_min", "60", "-sc_threshold", "0",
+		)
+	} else {
+		args = append(args, "-c:v", "copy")
+	}
+
+	if copyAudio {
+		args = append(args, "-c:a", "copy")
+	} else {
+		args = append(args, "-c:a", "libopus", "-ar", "48000", "-ac", "2", "-b:a", "64k")
+	}
+
+	return append(args, "-f", "rtsp", "-rtsp_transport", "tcp", target)
+}
+
+// NormalizeCodec maps the encodings reported by ONVIF ("H264", "H265", "JPEG") and the
+// codec names of ffprobe ("h264", "hevc", "mjpeg") to the latter.
+func NormalizeCodec(codec string) string {
+	codec = strings.ToLower(strings.TrimSpace(codec))
+	switch strings.NewReplacer(".", "", "-", "").Replace(codec) {
+	case "h264", "avc":
+		return "h264"
+	case "h265", "hevc":
+		return "hevc"
+	case "jpeg", "mjpeg":
+		return "mjpeg"
+	case "mpeg4", "mp4v":
+		return "mpeg4"
+	}
+	return codec
+}
+
+// BrowserCompatible reports whether the video can be relayed to the browsers without being
+// re-encoded, only H.264 is supported by all of them over WebRTC.
+func BrowserCompatible(codec string) bool {
+	return NormalizeCodec(codec) == "h264"
+}
+
+// NormalizeAudioCodec maps the audio encodings reported by ONVIF ("G711", "G726", "AAC") and the
+// codec names of ffprobe ("pcm_mulaw", "pcm_alaw", "opus") to the former, lower cased.
+func NormalizeAudioCodec(codec string) string {
+	codec = strings.ToLower(strings.TrimSpace(codec))
+	switch strings.NewReplacer(".", "", "-", "", "_", "").Replace(codec) {
+	case "g711", "pcmmulaw", "pcmalaw", "pcmu", "pcma":
+		return "g711"
+	case "g726":
+		return "g726"
+	case "aac", "mpeg4generic":
+		return "aac"
+	case "opus":
+		return "opus"
+	}
+	return codec
+}
+
+// AudioCompatible reports whether the audio can be relayed to the browsers without being
+// re-encoded, WebRTC carries G.711 and Opus but not the AAC most cameras send.
+func AudioCompatible(codec string) bool {
+	switch NormalizeAudioCodec(codec) {
+	case "g711", "opus":
+		return true
+	}
+	return false
+}
+
+// ProbeVideoCodec asks ffprobe for the codec of the source's first video stream, it is the
+// fallback for the cameras that don't report their encoding over ONVIF. The source is handed to
+// ffprobe on the source pipe, it carries the credentials.
+func ProbeVideoCodec(ctx context.Context, ffprobe, source string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	args := append([]string{"-v", "error"}, PipeInputArgs()...)
+	args = append(args, "-select_streams", "v:0", "-show_entries", "stream=codec_name", "-of", "csv=p=0")
+	cmd := exec.CommandContext(ctx, ffprobe, args...)
+	closeSource, err := PipeSource(cmd, source)
+	if err != nil {
+		return "", err
+	}
+	defer closeSource()
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	// ffprobe prints the source on errors.
+	cmd.Stderr = NewRedactWriter(&stderr, source)
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("ffprobe failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	codec := NormalizeCodec(stdout.String())
+	if codec == "" {
+		return "", errors.New("ffprobe found no video stream")
+	}
+
+	return codec, nil
+}
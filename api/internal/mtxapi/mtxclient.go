@@ -4,103 +4,322 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
 	"tomerab.com/cam-hub/internal/repos"
 )
 
 const (
 	RtspPort = 554
-	// Template command to send mediamtx to run on demand, uses ffmpeg to change video and audio encoding to a browser friendly one.
-	FFMPEGTemplate = `/usr/bin/ffmpeg
-					 -loglevel warning -rtsp_transport tcp -i 
-					  rtsp://%s:%s@%s:%d/channel=1_stream=0.sdp?real_stream -map 0:v -map 0:a? -c:v libx264 -pix_fmt yuv420p -profile:v baseline
-					  -level:v 3.1 -preset veryfast -tune zerolatency -g 60 -keyint_min 60
-					  -sc_threshold 0 -c:a libopus -ar 48000 -ac 2 -b:a 64k -f rtsp -rtsp_transport tcp rtsp://%s:$RTSP_PORT/$MTX_PATH`
+	// The streams of the XiongMai firmwares (0 is main, 1 is sub), only used for cameras
+	// paired before their streams were resolved over ONVIF.
+	CameraRtspPathTemplate  = "/channel=1_stream=%d.sdp?real_stream"
 	MediaMtxAddCameraUrl    = "/v3/config/paths/add/"
+	MediaMtxPatchCameraUrl  = "/v3/config/paths/patch/"
 	MediaMtxDeleteCameraUrl = "/v3/config/paths/delete/"
+
+	defaultHelperBin = "/mtxhelper"
+	probeCacheTTL    = 24 * time.Hour
+	// How long the segments of cameras without continuous recording are kept, they only
+	// have to outlive the motion clips cut from them.
+	motionRecordDeleteAfter = v1.MotionSegmentsRetention
+	// The motion clips are cut as soon as the segments they need complete.
+	motionSegmentDuration = time.Second
 )
 
 type MtxClient struct {
-	HttpClient   *http.Client
-	Logger       *slog.Logger
-	CamRepo      repos.CameraRepoIface
-	CamCredsRepo repos.CameraCredsRepoIface
-	Cache        repos.RedisIface
+	HttpClient    *http.Client
+	Logger        *slog.Logger
+	CamRepo       repos.CameraRepoIface
+	CamCredsRepo  repos.CameraCredsRepoIface
+	StreamsRepo   repos.CameraStreamsRepoIface
+	SelectionRepo repos.StreamSelectionRepoIface
+	RecordingRepo repos.RecordingSettingsRepoIface
+	Cache         repos.RedisIface
 }
 
-type camDetails struct {
-	addr     string
-	username string
-	password string
+// StreamUrls are the WHEP urls of the profiles selected for each consumer of the camera.
+type StreamUrls struct {
+	Viewer     string
+	Recorder   string
+	Motion     string
+	RecordPath string // the path whose recordings hold the camera's clips
 }
 
 type addPathRequest struct {
-	RunOnDemand             string `json:"runOnDemand"`
-	RunOnDemandRestart      bool   `json:"runOnDemandRestart"`
-	RunOnDemandStartTimeout string `json:"runOnDemandStartTimeout"`
-	RunOnDemandCloseAfter   string `json:"runOnDemandCloseAfter"`
+	RunOnInit                  string `json:"runOnInit"`
+	RunOnInitRestart           bool   `json:"runOnInitRestart"`
+	RunOnDemand                string `json:"runOnDemand"`
+	RunOnDemandRestart         bool   `json:"runOnDemandRestart"`
+	RunOnDemandStartTimeout    string `json:"runOnDemandStartTimeout"`
+	RunOnDemandCloseAfter      string `json:"runOnDemandCloseAfter"`
+	Record                     bool   `json:"record"`
+	RecordSegmentDuration      string `json:"recordSegmentDuration"`
+	RecordDeleteAfter          string `json:"recordDeleteAfter"`
+	RunOnRecordSegmentComplete string `json:"runOnRecordSegmentComplete"`
+}
+
+type pathConf struct {
+	RunOnInit                  string `json:"runOnInit"`
+	RunOnDemand                string `json:"runOnDemand"`
+	Record                     bool   `json:"record"`
+	RecordSegmentDuration      string `json:"recordSegmentDuration"`
+	RecordDeleteAfter          string `json:"recordDeleteAfter"`
+	RunOnRecordSegmentComplete string `json:"runOnRecordSegmentComplete"`
 }
 
+// matches reports whether the path already runs with the config of the request.
+func (conf *pathConf) matches(req addPathRequest) bool {
+	if conf.RunOnInit != req.RunOnInit || conf.RunOnDemand != req.RunOnDemand ||
+		conf.Record != req.Record || conf.RunOnRecordSegmentComplete != req.RunOnRecordSegmentComplete {
+		return false
+	}
+
+	return sameDuration(conf.RecordSegmentDuration, req.RecordSegmentDuration) &&
+		sameDuration(conf.RecordDeleteAfter, req.RecordDeleteAfter)
+}
+
+// sameDuration compares the durations, mediamtx formats the ones it returns, e.g. "2m" comes
+// back as "2m0s".
+func sameDuration(got, want string) bool {
+	gotDuration, err := time.ParseDuration(got)
+	if err != nil {
+		return false
+	}
+	wantDuration, err := time.ParseDuration(want)
+	return err == nil && gotDuration == wantDuration
+}
+
+// ErrNoRecordings is returned by Playback when the path has no recordings in the range.
+var ErrNoRecordings = errors.New("no recordings in range")
+
 type MtxErrorDto struct {
 	Error string `json:"error"`
 }
 
-func (client *MtxClient) Publish(ctx context.Context, uuid string) (string, error) {
-	whepURL := fmt.Sprintf("http://%s:8889/%s/whep", os.Getenv("MEDIAMTX_HOST"), uuid)
-	if client.doesStreamExists(ctx, uuid) {
-		return whepURL, nil
+// PathName is the mediamtx path of one of the camera's profiles.
+func PathName(uuid, profile string) string {
+	return uuid + "/" + profile
+}
+
+// ParsePathName splits a mediamtx path back to the camera and its profile, paths published
+// before the profiles were split carry the main stream.
+func ParsePathName(path string) (uuid, profile string) {
+	uuid, profile, ok := strings.Cut(path, "/")
+	if !ok {
+		return path, models.StreamMain
 	}
+	return uuid, profile
+}
 
-	onDemandCmd, err := client.getRunOnDemandCmd(ctx, uuid)
+func WhepUrl(uuid, profile string) string {
+	return fmt.Sprintf("http://%s:8889/%s/whep", os.Getenv("MEDIAMTX_HOST"), PathName(uuid, profile))
+}
+
+// Publish adds a path per profile of the camera, only the recorder's profile is recorded.
+func (client *MtxClient) Publish(ctx context.Context, uuid string) (*StreamUrls, error) {
+	selection, err := client.Selection(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	recording, err := client.RecordingSettings(ctx, uuid)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	// The single path of the camera from before the profiles were split.
+	if err := client.deletePath(ctx, uuid); err != nil {
+		return nil, err
+	}
+
+	for _, profile := range []string{models.StreamMain, models.StreamSub} {
+		reqBody := buildPathRequest(profile == selection.Recorder, recording)
+		if err := client.publishPath(ctx, PathName(uuid, profile), reqBody); err != nil {
+			return nil, err
+		}
 	}
 
+	return &StreamUrls{
+		Viewer:     WhepUrl(uuid, selection.Viewer),
+		Recorder:   WhepUrl(uuid, selection.Recorder),
+		Motion:     WhepUrl(uuid, selection.Motion),
+		RecordPath: PathName(uuid, selection.Recorder),
+	}, nil
+}
+
+// buildPathRequest starts the source on demand, unless the path is recorded continuously in
+// which case it must run without readers and keeps longer segments. Every completed segment of
+// a recorded path is posted to the api, which indexes it and notifies the motion runners.
+func buildPathRequest(recorded bool, recording *v1.RecordingSettings) addPathRequest {
 	reqBody := addPathRequest{
-		RunOnDemand:             onDemandCmd,
 		RunOnDemandRestart:      true,
 		RunOnDemandStartTimeout: "15s",
 		RunOnDemandCloseAfter:   "15s",
+		Record:                  recorded,
+		RecordSegmentDuration:   motionSegmentDuration.String(),
+		RecordDeleteAfter:       motionRecordDeleteAfter.String(),
+	}
+	if recorded {
+		reqBody.RunOnRecordSegmentComplete = getRunOnSegmentCompleteCmd()
+	}
+
+	if !recorded || !recording.Continuous {
+		reqBody.RunOnDemand = getRunOnDemandCmd()
+		return reqBody
+	}
+
+	reqBody.RunOnInit = getRunOnDemandCmd()
+	reqBody.RunOnInitRestart = true
+	reqBody.RecordSegmentDuration = v1.ContinuousSegmentDuration.String()
+	reqBody.RecordDeleteAfter = (time.Duration(recording.RetentionDays) * 24 * time.Hour).String()
+	return reqBody
+}
+
+// RecordingSettings returns the camera's continuous recording settings, cameras that were
+// never configured don't record continuously.
+func (client *MtxClient) RecordingSettings(ctx context.Context, uuid string) (*v1.RecordingSettings, error) {
+	defaults := v1.DefaultRecordingSettings()
+	if client.RecordingRepo == nil {
+		return &defaults, nil
+	}
+
+	settings, err := client.RecordingRepo.FindOne(ctx, uuid)
+	if errors.Is(err, repos.ErrRecordingSettingsNotFound) {
+		return &defaults, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.RecordingSettings{
+		Continuous:    settings.Continuous,
+		RetentionDays: settings.RetentionDays,
+	}, nil
+}
+
+// Selection returns the profiles the camera's consumers read, cameras that were never
+// configured get the defaults.
+func (client *MtxClient) Selection(ctx context.Context, uuid string) (*v1.StreamSelection, error) {
+	selection, err := client.SelectionRepo.FindOne(ctx, uuid)
+	if errors.Is(err, repos.ErrStreamSelectionNotFound) {
+		defaults := v1.DefaultStreamSelection()
+		return &defaults, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.StreamSelection{
+		Viewer:   selection.Viewer,
+		Recorder: selection.Recorder,
+		Motion:   selection.Motion,
+	}, nil
+}
+
+// publishPath adds the path, or patches it when its config is out of date.
+func (client *MtxClient) publishPath(ctx context.Context, name string, reqBody addPathRequest) error {
+	method, endpoint := http.MethodPost, MediaMtxAddCameraUrl
+	if conf, exists := client.getPath(ctx, name); exists {
+		if conf.matches(reqBody) {
+			return nil
+		}
+		method, endpoint = http.MethodPatch, MediaMtxPatchCameraUrl
 	}
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	mediaMtxUrl := fmt.Sprintf("%s%s%s", os.Getenv("MEDIAMTX_ADDR"), MediaMtxAddCameraUrl, uuid)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, mediaMtxUrl, bytes.NewReader(body))
+	mediaMtxUrl := fmt.Sprintf("%s%s%s", os.Getenv("MEDIAMTX_ADDR"), endpoint, name)
+	req, err := http.NewRequestWithContext(ctx, method, mediaMtxUrl, bytes.NewReader(body))
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := client.HttpClient.Do(req)
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		var mtxErr MtxErrorDto
 		json.NewDecoder(resp.Body).Decode(&mtxErr)
-		return "", fmt.Errorf("mediamtx returned %s: %s", resp.Status, mtxErr.Error)
+		return fmt.Errorf("mediamtx returned %s: %s", resp.Status, mtxErr.Error)
 	}
 
-	return whepURL, nil
+	return nil
 }
 
+// Playback opens [start, start+duration) of the path's recordings as a single mp4, the caller
+// closes the body. Only ctx bounds the download, a long clip outlasts the client's timeout.
+func (client *MtxClient) Playback(ctx context.Context, path string, start time.Time, duration time.Duration) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, PlaybackUrl(path, start, duration), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{Transport: client.HttpClient.Transport}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNoRecordings
+	default:
+		defer resp.Body.Close()
+		var mtxErr MtxErrorDto
+		_ = json.NewDecoder(resp.Body).Decode(&mtxErr)
+		return nil, fmt.Errorf("mediamtx returned %s: %s", resp.Status, mtxErr.Error)
+	}
+}
+
+// Restart re-adds the camera's paths so the running helpers are stopped and the next reader
+// starts them with the current source.
+func (client *MtxClient) Restart(ctx context.Context, uuid string) (*StreamUrls, error) {
+	if err := client.Delete(ctx, uuid); err != nil {
+		return nil, err
+	}
+
+	return client.Publish(ctx, uuid)
+}
+
+// Delete removes every path of the camera.
 func (client *MtxClient) Delete(ctx context.Context, uuid string) error {
-	if !client.doesStreamExists(ctx, uuid) {
+	for _, name := range []string{uuid, PathName(uuid, models.StreamMain), PathName(uuid, models.StreamSub)} {
+		if err := client.deletePath(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (client *MtxClient) deletePath(ctx context.Context, name string) error {
+	if !client.doesStreamExists(ctx, name) {
 		return nil
 	}
 
-	mediaMtxUrl := fmt.Sprintf("%s%s%s", os.Getenv("MEDIAMTX_ADDR"), MediaMtxDeleteCameraUrl, uuid)
+	mediaMtxUrl := fmt.Sprintf("%s%s%s", os.Getenv("MEDIAMTX_ADDR"), MediaMtxDeleteCameraUrl, name)
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, mediaMtxUrl, nil)
 	if err != nil {
 		return err
@@ -121,49 +340,180 @@ func (client *MtxClient) Delete(ctx context.Context, uuid string) error {
 	return nil
 }
 
-func (client *MtxClient) doesStreamExists(ctx context.Context, uuid string) bool {
-	mediaMtxUrl := fmt.Sprintf("%s/v3/config/paths/get/%s", os.Getenv("MEDIAMTX_ADDR"), uuid)
+func (client *MtxClient) doesStreamExists(ctx context.Context, name string) bool {
+	_, exists := client.getPath(ctx, name)
+	return exists
+}
+
+func (client *MtxClient) getPath(ctx context.Context, name string) (*pathConf, bool) {
+	mediaMtxUrl := fmt.Sprintf("%s/v3/config/paths/get/%s", os.Getenv("MEDIAMTX_ADDR"), name)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaMtxUrl, nil)
 	if err != nil {
 		client.Logger.Error(err.Error())
-		return false
+		return nil, false
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := client.HttpClient.Do(req)
 	if err != nil {
 		client.Logger.Error(err.Error())
-		return false
+		return nil, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false
 	}
 
-	return resp.StatusCode == http.StatusOK
+	var conf pathConf
+	if err := json.NewDecoder(resp.Body).Decode(&conf); err != nil {
+		client.Logger.Warn("failed to decode mediamtx path", "path", name, "err", err)
+	}
+	return &conf, true
 }
 
-func (client *MtxClient) getCameraDetails(ctx context.Context, uuid string) (*camDetails, error) {
+// Source resolves the camera's profile for the mtxhelper, the url holds the credentials so it
+// must never end up in mediamtx's config or in logs. Cameras without a substream serve their
+// main stream on both paths.
+func (client *MtxClient) Source(ctx context.Context, uuid, profile string) (*v1.CameraSource, error) {
 	cam, err := client.CamRepo.FindOne(ctx, uuid)
 	if err != nil {
 		return nil, err
 	}
 
-	cleanAddr := strings.Split(cam.Addr, ":")
-	addr := cleanAddr[0]
 	creds, err := client.CamCredsRepo.FindOne(ctx, uuid)
 	if err != nil {
 		return nil, err
 	}
 
-	return &camDetails{
-		addr:     addr,
-		username: creds.Username,
-		password: creds.Password,
-	}, nil
+	source := &v1.CameraSource{}
+	stream, err := client.StreamsRepo.FindOne(ctx, uuid, profile)
+	if errors.Is(err, repos.ErrStreamNotFound) && profile != models.StreamMain {
+		stream, err = client.StreamsRepo.FindOne(ctx, uuid, models.StreamMain)
+	}
+	switch {
+	case errors.Is(err, repos.ErrStreamNotFound):
+		vendorUrl := url.URL{
+			Scheme: "rtsp",
+			User:   url.UserPassword(creds.Username, creds.Password),
+			Host:   fmt.Sprintf("%s:%d", hostOf(cam.Addr), RtspPort),
+		}
+		streamIdx := 0
+		if profile == models.StreamSub {
+			streamIdx = 1
+		}
+		source.Url = vendorUrl.String() + fmt.Sprintf(CameraRtspPathTemplate, streamIdx)
+	case err != nil:
+		return nil, err
+	default:
+		if source.Url, err = sourceFromStreamUri(stream.Uri, cam.Addr, creds.Username, creds.Password); err != nil {
+			return nil, err
+		}
+		source.VideoCodec = NormalizeCodec(stream.Encoding)
+		source.AudioCodec = NormalizeAudioCodec(stream.AudioEncoding)
+	}
+
+	switch cam.TranscodePolicy {
+	case v1.TranscodeAlways:
+		source.Transcode = true
+	case v1.TranscodeNever:
+		source.Transcode = false
+	default:
+		if source.VideoCodec == "" {
+			source.VideoCodec = client.probeVideoCodec(ctx, uuid, profile, source.Url)
+		}
+		// Re-encoding is the safe choice when the codec is unknown.
+		source.Transcode = !BrowserCompatible(source.VideoCodec)
+	}
+
+	return source, nil
+}
+
+// probeVideoCodec is the fallback for the cameras whose encoding wasn't reported over ONVIF,
+// the result is cached since probing opens an RTSP session to the camera.
+func (client *MtxClient) probeVideoCodec(ctx context.Context, uuid, profile, source string) string {
+	key := fmt.Sprintf("codec:%s:%s", uuid, profile)
+	if client.Cache != nil {
+		if codec, err := client.Cache.Get(ctx, key); err == nil && codec != "" {
+			return codec
+		}
+	}
+
+	ffprobe := os.Getenv("FFPROBE_BIN")
+	if ffprobe == "" {
+		ffprobe = "ffprobe"
+	}
+
+	codec, err := ProbeVideoCodec(ctx, ffprobe, source)
+	if err != nil {
+		client.Logger.Warn("failed to probe the camera's video codec", "uuid", uuid, "profile", profile, "err", err)
+		return ""
+	}
+
+	if client.Cache != nil {
+		if err := client.Cache.Set(ctx, key, codec, probeCacheTTL); err != nil {
+			client.Logger.Warn("failed to cache the camera's video codec", "uuid", uuid, "err", err)
+		}
+	}
+	return codec
+}
+
+// ForgetProbes drops the cached codecs of the camera, e.g. after it was re-paired.
+func (client *MtxClient) ForgetProbes(ctx context.Context, uuid string) error {
+	if client.Cache == nil {
+		return nil
+	}
+
+	return client.Cache.Del(ctx,
+		fmt.Sprintf("codec:%s:%s", uuid, models.StreamMain),
+		fmt.Sprintf("codec:%s:%s", uuid, models.StreamSub),
+	)
 }
 
-func (client *MtxClient) getRunOnDemandCmd(ctx context.Context, uuid string) (string, error) {
-	details, err := client.getCameraDetails(ctx, uuid)
+// sourceFromStreamUri adds the credentials to the uri the camera reported. The host is taken
+// from the camera's address since the camera reports the address it had when it was asked,
+// its port and path are kept.
+func sourceFromStreamUri(uri, camAddr, username, password string) (string, error) {
+	source, err := url.Parse(uri)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("invalid stream uri: %w", err)
+	}
+	if source.Scheme != "rtsp" && source.Scheme != "rtsps" {
+		return "", fmt.Errorf("unsupported stream uri scheme %q", source.Scheme)
+	}
+
+	port := source.Port()
+	if port == "" {
+		port = strconv.Itoa(RtspPort)
+	}
+
+	source.Host = net.JoinHostPort(hostOf(camAddr), port)
+	source.User = url.UserPassword(username, password)
+	return source.String(), nil
+}
+
+func hostOf(addr string) string {
+	return strings.Split(addr, ":")[0]
+}
+
+// The helper asks the API for the camera's source when mediamtx starts the path, so the
+// command only holds the path name. MTX_PATH is expanded by mediamtx.
+func getRunOnDemandCmd() string {
+	bin := os.Getenv("MEDIAMTX_HELPER_BIN")
+	if bin == "" {
+		bin = defaultHelperBin
+	}
+
+	return bin + " source -path $MTX_PATH"
+}
+
+// Indexes the segment through the api, MTX_SEGMENT_PATH and MTX_SEGMENT_DURATION are expanded
+// by mediamtx.
+func getRunOnSegmentCompleteCmd() string {
+	bin := os.Getenv("MEDIAMTX_HELPER_BIN")
+	if bin == "" {
+		bin = defaultHelperBin
 	}
 
-	return fmt.Sprintf(FFMPEGTemplate, details.username, details.password, details.addr, RtspPort, "127.0.0.1"), nil
+	return bin + " segment -path $MTX_PATH -file $MTX_SEGMENT_PATH -duration $MTX_SEGMENT_DURATION"
 }
@@ -0,0 +1,48 @@
+package mtxapi
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+)
+
+type RedactWriter struct {
+	w       io.Writer
+	secrets [][]byte
+}
+
+// NewRedactWriter masks the credentials of source in everything written to w.
+func NewRedactWriter(w io.Writer, source string) *RedactWriter {
+	return &RedactWriter{w: w, secrets: secretsOf(source)}
+}
+
+// Write masks the secrets of every chunk, ffmpeg writes whole log lines so a secret isn't
+// split between two writes.
+func (rw *RedactWriter) Write(p []byte) (int, error) {
+	out := p
+	for _, secret := range rw.secrets {
+		out = bytes.ReplaceAll(out, secret, []byte("***"))
+	}
+
+	if _, err := rw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// secretsOf returns the forms the credentials of the url may be printed in.
+func secretsOf(source string) [][]byte {
+	u, err := url.Parse(source)
+	if err != nil || u.User == nil {
+		return nil
+	}
+
+	secrets := [][]byte{}
+	if userinfo := u.User.String(); userinfo != "" {
+		secrets = append(secrets, []byte(userinfo))
+	}
+	if password, ok := u.User.Password(); ok && password != "" {
+		secrets = append(secrets, []byte(password), []byte(url.QueryEscape(password)))
+	}
+	return secrets
+}
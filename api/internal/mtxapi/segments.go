@@ -0,0 +1,62 @@
+package mtxapi
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// The layout of recordPath in mediamtx.yml, the microseconds are optional.
+	segmentTimeLayout = "2006-01-02_15-04-05"
+)
+
+// ParseSegmentStart reads the start of a segment from its file name, mediamtx names the
+// segments after their start time in UTC.
+func ParseSegmentStart(fileName string) (time.Time, error) {
+	name := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	if len(name) < len(segmentTimeLayout) {
+		return time.Time{}, fmt.Errorf("invalid segment name %q", fileName)
+	}
+
+	start, err := time.ParseInLocation(segmentTimeLayout, name[:len(segmentTimeLayout)], time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid segment name %q: %w", fileName, err)
+	}
+
+	if usecPart, ok := strings.CutPrefix(name[len(segmentTimeLayout):], "-"); ok {
+		usec, err := strconv.Atoi(usecPart)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid segment name %q: %w", fileName, err)
+		}
+		start = start.Add(time.Duration(usec) * time.Microsecond)
+	}
+
+	return start, nil
+}
+
+// ParseSegmentDuration reads MTX_SEGMENT_DURATION, mediamtx reports it in seconds.
+func ParseSegmentDuration(raw string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(raw, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+
+	return time.ParseDuration(raw)
+}
+
+// PlaybackUrl serves [start, start+duration) of the path's recordings as a single mp4
+// through the mediamtx playback server. Only the api may reach the playback server, clients
+// download through the api's playback route.
+func PlaybackUrl(path string, start time.Time, duration time.Duration) string {
+	query := url.Values{}
+	query.Set("path", path)
+	query.Set("start", start.UTC().Format(time.RFC3339Nano))
+	query.Set("duration", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64))
+	query.Set("format", "mp4")
+
+	return fmt.Sprintf("%s/get?%s", os.Getenv("MEDIAMTX_PLAYBACK_ADDR"), query.Encode())
+}
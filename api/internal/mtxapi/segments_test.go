@@ -0,0 +1,76 @@
+package mtxapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSegmentStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		expected time.Time
+	}{
+		{
+			name:     "should read the microseconds",
+			fileName: "/recordings/cam/main/2026-10-17_12-30-05-250000.mp4",
+			expected: time.Date(2026, 10, 17, 12, 30, 5, 250_000_000, time.UTC),
+		},
+		{
+			name:     "should accept names without microseconds",
+			fileName: "2026-10-17_12-30-05.mp4",
+			expected: time.Date(2026, 10, 17, 12, 30, 5, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSegmentStart(tt.fileName)
+			if err != nil {
+				t.Fatalf("expected nil, got err: %v", err)
+			}
+			if !got.Equal(tt.expected) {
+				t.Errorf("expected: %s, got: %s", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("should reject names that aren't timestamps", func(t *testing.T) {
+		if _, err := ParseSegmentStart("/recordings/cam/main/clip.mp4"); err == nil {
+			t.Error("expected err, got nil")
+		}
+	})
+}
+
+func TestParseSegmentDuration(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected time.Duration
+	}{
+		{raw: "1.5", expected: 1500 * time.Millisecond},
+		{raw: "60", expected: time.Minute},
+		{raw: "2m0s", expected: 2 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := ParseSegmentDuration(tt.raw)
+			if err != nil {
+				t.Fatalf("expected nil, got err: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected: %s, got: %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPlaybackUrl(t *testing.T) {
+	t.Setenv("MEDIAMTX_PLAYBACK_ADDR", "http://mtx.local:9996")
+
+	got := PlaybackUrl("cam/main", time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC), 90*time.Second)
+	expected := "http://mtx.local:9996/get?duration=90&format=mp4&path=cam%2Fmain&start=2026-10-17T12%3A00%3A00Z"
+	if got != expected {
+		t.Errorf("expected: %s, got: %s", expected, got)
+	}
+}
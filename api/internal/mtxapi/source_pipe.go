@@ -0,0 +1,57 @@
+package mtxapi
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// sourcePipeFd is the fd ffmpeg and ffprobe read the source from, the first of ExtraFiles.
+const sourcePipeFd = 3
+
+// PipeInputArgs make ffmpeg (or ffprobe) read its input from the concat script on the source
+// pipe. The source never shows up in the argv, which any user of the host can read through ps
+// or /proc/<pid>/cmdline.
+func PipeInputArgs() []string {
+	return []string{
+		"-f", "concat", "-safe", "0",
+		"-protocol_whitelist", "pipe,rtsp,rtsps,rtp,srtp,tcp,udp,tls",
+		"-i", fmt.Sprintf("pipe:%d", sourcePipeFd),
+	}
+}
+
+// ConcatScript wraps the source in an ffconcat script of a single file, the RTSP options that
+// would otherwise be input options apply to it through the option directive.
+func ConcatScript(source string) []byte {
+	quoted := "'" + strings.ReplaceAll(source, "'", `'\''`) + "'"
+	return []byte("ffconcat version 1.0\n" +
+		"file " + quoted + "\n" +
+		"option rtsp_transport tcp\n")
+}
+
+// PipeSource hands the source to cmd on the source pipe, cmd must have no other ExtraFiles.
+// The returned func closes the parent's end of the pipe, it is called once cmd started.
+func PipeSource(cmd *exec.Cmd, source string) (func(), error) {
+	if len(cmd.ExtraFiles) != 0 {
+		return nil, fmt.Errorf("the source must be fd %d, the command already has extra files", sourcePipeFd)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	// The script is far below the pipe's buffer, it is written whole before the command reads it.
+	_, err = w.Write(ConcatScript(source))
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	cmd.ExtraFiles = []*os.File{r}
+	return func() { r.Close() }, nil
+}
@@ -2,6 +2,8 @@ package objectstorage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/url"
@@ -20,6 +22,14 @@ const (
 	UrlPng   ContentType = "image/png"
 )
 
+type ObjectStoreIface interface {
+	RemoveObject(bucketName, objName string) error
+	RemoveObjects(bucketName, objPrefix string) error
+	RemoveObjectsBefore(bucketName, objPrefix string, before time.Time) (int, error)
+	FPutObject(bucketName, objName, filePath string, contentType ContentType) error
+	PresignedViewUrl(bucketName, objName string, contentType ContentType, expiry time.Duration) (string, error)
+}
+
 type MinIOStore struct {
 	client *minio.Client
 	logger *slog.Logger
@@ -60,6 +70,24 @@ func (store *MinIOStore) BucketExists(bucketName string) (bool, error) {
 	return store.client.BucketExists(store.ctx, bucketName)
 }
 
+// EnsureBucket creates the bucket when it doesn't exist yet.
+func (store *MinIOStore) EnsureBucket(bucketName string) error {
+	exists, err := store.BucketExists(bucketName)
+	if err != nil {
+		store.logger.Error("bucket exists check failed", "err", err.Error())
+	}
+	if exists {
+		return nil
+	}
+
+	if err := store.CreateBucket(bucketName); err != nil {
+		store.logger.Error("bucket creation failed", "err", err.Error())
+		return err
+	}
+
+	return nil
+}
+
 func (store *MinIOStore) RemoveBucket(bucketName string) error {
 	return store.client.RemoveBucket(store.ctx, bucketName)
 }
@@ -92,37 +120,99 @@ func (store *MinIOStore) PutObject(bucketName, objName string, reader io.Reader,
 	)
 }
 
+// FPutObject uploads the file at filePath, large files are uploaded in parts.
+func (store *MinIOStore) FPutObject(bucketName, objName, filePath string, contentType ContentType) error {
+	_, err := store.client.FPutObject(
+		store.ctx,
+		bucketName,
+		objName,
+		filePath,
+		minio.PutObjectOptions{
+			ContentType: contentType,
+		},
+	)
+	return err
+}
+
+// RemoveObjects removes every object under objPrefix.
 func (store *MinIOStore) RemoveObjects(bucketName, objPrefix string) error {
+	_, err := store.removeVersions(bucketName, objPrefix, nil)
+	return err
+}
+
+// RemoveObjectsBefore removes the objects under objPrefix last modified before the given time,
+// it returns how many were removed.
+func (store *MinIOStore) RemoveObjectsBefore(bucketName, objPrefix string, before time.Time) (int, error) {
+	return store.removeVersions(bucketName, objPrefix, func(obj minio.ObjectInfo) bool {
+		return obj.LastModified.Before(before)
+	})
+}
+
+// RemoveObject removes the object, objName is an exact key and not a prefix.
+func (store *MinIOStore) RemoveObject(bucketName, objName string) error {
+	_, err := store.removeVersions(bucketName, objName, func(obj minio.ObjectInfo) bool {
+		return obj.Key == objName
+	})
+	return err
+}
+
+// removeVersions removes every version, delete markers included, of the objects under prefix
+// that match accepts (every object when nil). The bucket is object locked and so versioned,
+// removing an object without its version only adds a delete marker and frees nothing. It
+// returns how many object versions were removed, failures of the listing or of the removals
+// are joined in the error.
+func (store *MinIOStore) removeVersions(bucketName, prefix string, accepts func(minio.ObjectInfo) bool) (int, error) {
 	objsCh := make(chan minio.ObjectInfo)
+	listErrCh := make(chan error, 1)
 
 	go func() {
 		defer close(objsCh)
+		defer close(listErrCh)
 
 		for obj := range store.client.ListObjects(store.ctx, bucketName, minio.ListObjectsOptions{
-			Prefix:    objPrefix,
-			Recursive: true,
+			Prefix:       prefix,
+			Recursive:    true,
+			WithVersions: true,
 		}) {
 			if obj.Err != nil {
-				store.logger.Error("removeObjects: failed to list object", "bucket_name", bucketName, "obj_prefix", objPrefix)
+				listErrCh <- fmt.Errorf("failed to list %s/%s: %w", bucketName, prefix, obj.Err)
 				return
 			}
+			if accepts != nil && !accepts(obj) {
+				continue
+			}
 
-			objsCh <- obj
+			select {
+			case objsCh <- obj:
+			case <-store.ctx.Done():
+				return
+			}
 		}
 	}()
 
-	for err := range store.client.RemoveObjects(store.ctx, bucketName, objsCh, minio.RemoveObjectsOptions{}) {
-		return err.Err
+	removed := 0
+	var errs []error
+	// Drained to the end, the lister stays blocked on objsCh otherwise.
+	for res := range store.client.RemoveObjectsWithResult(store.ctx, bucketName, objsCh, minio.RemoveObjectsOptions{}) {
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove %s (version %s): %w", res.ObjectName, res.ObjectVersionID, res.Err))
+			continue
+		}
+		if !res.DeleteMarker {
+			removed++
+		}
+	}
+	if err := <-listErrCh; err != nil {
+		errs = append(errs, err)
 	}
 
-	return nil
-}
-
-func (store *MinIOStore) RemoveObject(bucketName, objName string) error {
-	return store.client.RemoveObject(store.ctx, bucketName, objName, minio.RemoveObjectOptions{})
+	if len(errs) > 0 {
+		store.logger.Error("failed to remove objects", "bucket_name", bucketName, "obj_prefix", prefix, "removed", removed, "failures", len(errs))
+	}
+	return removed, errors.Join(errs...)
 }
 
-func (store *MinIOStore) GetObject(bucketName, objectName string) (*minio.Object, error) {
+func (store *MinIOStore) GetObject(bucketName, objectName string) (io.ReadCloser, error) {
 	return store.client.GetObject(store.ctx, bucketName, objectName, minio.GetObjectOptions{})
 }
 
@@ -0,0 +1,250 @@
+package objectstorage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type fakeVersion struct {
+	Key          string
+	VersionId    string
+	DeleteMarker bool
+	LastModified time.Time
+}
+
+// fakeS3 serves the versioned listing and the multi delete of a single bucket.
+type fakeS3 struct {
+	mu       sync.Mutex
+	versions []fakeVersion
+	failKeys []string // keys whose removal fails
+	listFail bool
+}
+
+func (s3 *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s3.mu.Lock()
+	defer s3.mu.Unlock()
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodGet && query.Has("versions") && s3.listFail:
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `<Error><Code>AccessDenied</Code><Message>denied</Message></Error>`)
+	case r.Method == http.MethodGet && query.Has("versions"):
+		s3.listVersions(w, query.Get("prefix"))
+	case r.Method == http.MethodPost && query.Has("delete"):
+		s3.deleteVersions(w, r.Body)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (s3 *fakeS3) listVersions(w http.ResponseWriter, prefix string) {
+	var body strings.Builder
+	body.WriteString(`<ListVersionsResult><Name>camhub</Name><IsTruncated>false</IsTruncated>`)
+	for _, ver := range s3.versions {
+		if !strings.HasPrefix(ver.Key, prefix) {
+			continue
+		}
+		elem := "Version"
+		if ver.DeleteMarker {
+			elem = "DeleteMarker"
+		}
+		fmt.Fprintf(&body, "<%s><Key>%s</Key><VersionId>%s</VersionId><LastModified>%s</LastModified></%s>",
+			elem, ver.Key, ver.VersionId, ver.LastModified.UTC().Format(time.RFC3339), elem)
+	}
+	body.WriteString(`</ListVersionsResult>`)
+
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, body.String())
+}
+
+func (s3 *fakeS3) deleteVersions(w http.ResponseWriter, reqBody io.Reader) {
+	var req struct {
+		Objects []struct {
+			Key       string
+			VersionId string
+		} `xml:"Object"`
+	}
+	if err := xml.NewDecoder(reqBody).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var body strings.Builder
+	body.WriteString(`<DeleteResult>`)
+	for _, obj := range req.Objects {
+		if slices.Contains(s3.failKeys, obj.Key) {
+			fmt.Fprintf(&body, "<Error><Key>%s</Key><VersionId>%s</VersionId><Code>AccessDenied</Code><Message>locked</Message></Error>", obj.Key, obj.VersionId)
+			continue
+		}
+
+		idx := slices.IndexFunc(s3.versions, func(ver fakeVersion) bool {
+			return ver.Key == obj.Key && ver.VersionId == obj.VersionId
+		})
+		if idx < 0 {
+			fmt.Fprintf(&body, "<Error><Key>%s</Key><VersionId>%s</VersionId><Code>NoSuchVersion</Code></Error>", obj.Key, obj.VersionId)
+			continue
+		}
+		fmt.Fprintf(&body, "<Deleted><Key>%s</Key><VersionId>%s</VersionId><DeleteMarker>%t</DeleteMarker></Deleted>",
+			obj.Key, obj.VersionId, s3.versions[idx].DeleteMarker)
+		s3.versions = slices.Delete(s3.versions, idx, idx+1)
+	}
+	body.WriteString(`</DeleteResult>`)
+
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, body.String())
+}
+
+func (s3 *fakeS3) keys() []string {
+	s3.mu.Lock()
+	defer s3.mu.Unlock()
+
+	var keys []string
+	for _, ver := range s3.versions {
+		keys = append(keys, ver.Key+"@"+ver.VersionId)
+	}
+	return keys
+}
+
+func setupMinIOStoreTest(t *testing.T, versions ...fakeVersion) (*MinIOStore, *fakeS3) {
+	t.Helper()
+
+	s3 := &fakeS3{versions: slices.Clone(versions)}
+	server := httptest.NewServer(s3)
+	t.Cleanup(server.Close)
+
+	endpoint, _ := url.Parse(server.URL)
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:  credentials.NewStaticV4("access", "secret", ""),
+		Region: "us-east-1",
+	})
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+
+	return &MinIOStore{
+		client: client,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		ctx:    context.Background(),
+	}, s3
+}
+
+func TestRemoveObjects(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	versions := []fakeVersion{
+		{Key: "detections/cam/tp/clip.mp4", VersionId: "v1", LastModified: old},
+		{Key: "detections/cam/tp/clip.mp4", VersionId: "v2", LastModified: old},
+		{Key: "detections/cam/tp/clip.mp4", VersionId: "m1", DeleteMarker: true, LastModified: old},
+		{Key: "detections/cam/tp/clip.mp4.png", VersionId: "v1", LastModified: old},
+		{Key: "detections/cam/tp/frame.png", VersionId: "v1", LastModified: old},
+		{Key: "detections/other/tp/clip.mp4", VersionId: "v1", LastModified: old},
+	}
+
+	t.Run("object with versions - should remove every version of the key only", func(t *testing.T) {
+		store, s3 := setupMinIOStoreTest(t, versions...)
+
+		if err := store.RemoveObject("camhub", "detections/cam/tp/clip.mp4"); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		expected := []string{"detections/cam/tp/clip.mp4.png@v1", "detections/cam/tp/frame.png@v1", "detections/other/tp/clip.mp4@v1"}
+		if got := s3.keys(); !slices.Equal(got, expected) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+	})
+
+	t.Run("prefix - should remove every version under it", func(t *testing.T) {
+		store, s3 := setupMinIOStoreTest(t, versions...)
+
+		if err := store.RemoveObjects("camhub", "detections/cam/"); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		expected := []string{"detections/other/tp/clip.mp4@v1"}
+		if got := s3.keys(); !slices.Equal(got, expected) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+	})
+
+	t.Run("failed removal - should return the error and remove the rest", func(t *testing.T) {
+		store, s3 := setupMinIOStoreTest(t, versions...)
+		s3.failKeys = []string{"detections/cam/tp/frame.png"}
+
+		if err := store.RemoveObjects("camhub", "detections/cam/"); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+
+		expected := []string{"detections/cam/tp/frame.png@v1", "detections/other/tp/clip.mp4@v1"}
+		if got := s3.keys(); !slices.Equal(got, expected) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+	})
+}
+
+func TestRemoveObjectsBefore(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	versions := []fakeVersion{
+		{Key: "exports/lost.mp4", VersionId: "v1", LastModified: now.Add(-2 * time.Hour)},
+		{Key: "exports/lost.mp4", VersionId: "m1", DeleteMarker: true, LastModified: now.Add(-time.Hour)},
+		{Key: "exports/orphan.mp4", VersionId: "v1", LastModified: now.Add(-time.Hour)},
+		{Key: "exports/fresh.mp4", VersionId: "v1", LastModified: now},
+		{Key: "detections/cam/tp/clip.mp4", VersionId: "v1", LastModified: now.Add(-2 * time.Hour)},
+	}
+
+	t.Run("old exports - should remove their versions and count the objects", func(t *testing.T) {
+		store, s3 := setupMinIOStoreTest(t, versions...)
+
+		removed, err := store.RemoveObjectsBefore("camhub", "exports/", now.Add(-time.Minute))
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if removed != 2 {
+			t.Errorf("expected 2 removed objects, got: %d", removed)
+		}
+
+		expected := []string{"exports/fresh.mp4@v1", "detections/cam/tp/clip.mp4@v1"}
+		if got := s3.keys(); !slices.Equal(got, expected) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+	})
+
+	t.Run("failed removal - should count only the removed objects", func(t *testing.T) {
+		store, s3 := setupMinIOStoreTest(t, versions...)
+		s3.failKeys = []string{"exports/orphan.mp4"}
+
+		removed, err := store.RemoveObjectsBefore("camhub", "exports/", now.Add(-time.Minute))
+		if err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if removed != 1 {
+			t.Errorf("expected 1 removed object, got: %d", removed)
+		}
+	})
+
+	t.Run("listing fails - should return the error", func(t *testing.T) {
+		store, s3 := setupMinIOStoreTest(t, versions...)
+		s3.listFail = true
+
+		removed, err := store.RemoveObjectsBefore("camhub", "exports/", now)
+		if err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if removed != 0 {
+			t.Errorf("expected nothing removed, got: %d", removed)
+		}
+	})
+}
@@ -0,0 +1,21 @@
+package event
+
+import "time"
+
+const (
+	KindMotion       = "motion"
+	KindLineCrossing = "line_crossing"
+	KindIntrusion    = "intrusion"
+	KindTamper       = "tamper"
+)
+
+// Event is a notification of the camera's analytics. Stateful topics report when their state
+// changes, momentary ones (e.g. a line crossing) are always active.
+type Event struct {
+	Topic    string // without the namespace prefixes, e.g. RuleEngine/CellMotionDetector/Motion
+	Kind     string
+	Source   string // the video source or rule that raised it, when the camera tells
+	Active   bool
+	Stateful bool
+	Time     time.Time
+}
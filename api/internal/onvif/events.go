@@ -0,0 +1,226 @@
+package onvif
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IOTechSystems/onvif/event"
+	"github.com/IOTechSystems/onvif/xsd"
+	dto "tomerab.com/cam-hub/internal/onvif/event"
+)
+
+// The analytics topics cam-hub reacts to, matched on the topic without its namespace prefixes.
+// Item is the data item holding the state of a stateful topic.
+var eventTopics = []struct {
+	prefix string
+	kind   string
+	item   string
+}{
+	{"RuleEngine/CellMotionDetector/Motion", dto.KindMotion, "IsMotion"},
+	{"RuleEngine/MotionRegionDetector/Motion", dto.KindMotion, "State"},
+	{"VideoSource/MotionAlarm", dto.KindMotion, "State"},
+	{"RuleEngine/LineDetector/Crossed", dto.KindLineCrossing, ""},
+	{"RuleEngine/FieldDetector/ObjectsInside", dto.KindIntrusion, "IsInside"},
+	{"RuleEngine/TamperDetector/Tamper", dto.KindTamper, "IsTamper"},
+	{"VideoSource/GlobalSceneChange", dto.KindTamper, "State"},
+	{"VideoSource/ImageTooBlurry", dto.KindTamper, "State"},
+	{"VideoSource/ImageTooDark", dto.KindTamper, "State"},
+	{"VideoSource/ImageTooBright", dto.KindTamper, "State"},
+}
+
+// The source items naming what raised the event, in order of preference.
+var eventSourceItems = []string{"Rule", "VideoSourceToken", "VideoSourceConfigurationToken", "Source"}
+
+// The library sends it in the events namespace, WS-BaseNotification expects it in wsnt.
+type unsubscribe struct {
+	XMLName string `xml:"wsnt:Unsubscribe"`
+}
+
+type createPullPointResp struct {
+	SubscriptionReference struct {
+		Address string
+	}
+}
+
+type pullMessagesResp struct {
+	NotificationMessage []notificationMessage
+}
+
+type notificationMessage struct {
+	Topic   string
+	Message struct {
+		Message struct {
+			UtcTime string `xml:"UtcTime,attr"`
+			Source  simpleItems
+			Data    simpleItems
+		}
+	}
+}
+
+type simpleItems struct {
+	SimpleItem []struct {
+		Name  string `xml:"Name,attr"`
+		Value string `xml:"Value,attr"`
+	}
+}
+
+func (items simpleItems) get(name string) (string, bool) {
+	for _, item := range items.SimpleItem {
+		if item.Name == name {
+			return item.Value, true
+		}
+	}
+	return "", false
+}
+
+// PullPoint is a PullPoint subscription to the device's events, it terminates unless it is
+// renewed within its ttl.
+type PullPoint struct {
+	client *OnvifClient
+	addr   string
+}
+
+func (client *OnvifClient) CreatePullPoint(ttl time.Duration) (*PullPoint, error) {
+	terminationTime := xsd.String(isoDuration(ttl))
+	resp, err := client.device.CallMethod(event.CreatePullPointSubscription{
+		InitialTerminationTime: &terminationTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var createResp createPullPointResp
+	if err := parseResp(resp, &createResp); err != nil {
+		return nil, err
+	}
+
+	addr, err := client.subscriptionAddr(createResp.SubscriptionReference.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PullPoint{client: client, addr: addr}, nil
+}
+
+// Pull waits up to timeout for the device's events and returns the ones of the analytics
+// topics, the rest are dropped.
+func (pp *PullPoint) Pull(timeout time.Duration, limit int) ([]dto.Event, error) {
+	var pullResp pullMessagesResp
+	if err := callPullPoint(pp, event.PullMessages{
+		Timeout:      xsd.Duration(isoDuration(timeout)),
+		MessageLimit: xsd.Int(limit),
+	}, &pullResp); err != nil {
+		return nil, err
+	}
+
+	return parseEvents(pullResp.NotificationMessage, time.Now()), nil
+}
+
+func (pp *PullPoint) Renew(ttl time.Duration) error {
+	var renewResp event.RenewResponse
+	return callPullPoint(pp, event.Renew{TerminationTime: xsd.String(isoDuration(ttl))}, &renewResp)
+}
+
+func (pp *PullPoint) Unsubscribe() error {
+	var unsubscribeResp event.UnsubscribeResponse
+	return callPullPoint(pp, unsubscribe{}, &unsubscribeResp)
+}
+
+// The subscription methods are sent to the subscription's own address rather than the events
+// service.
+func callPullPoint[T any](pp *PullPoint, method any, out *T) error {
+	body, err := xml.Marshal(method)
+	if err != nil {
+		return err
+	}
+
+	resp, err := pp.client.device.SendSoap(pp.addr, string(body))
+	if err != nil {
+		return err
+	}
+
+	return parseResp(resp, out)
+}
+
+// Devices behind NAT announce their internal address, the subscription is reached through the
+// address the device was dialed with like the library does for the services.
+func (client *OnvifClient) subscriptionAddr(addr string) (string, error) {
+	if addr == "" {
+		return "", fmt.Errorf("no subscription address in response")
+	}
+
+	u, err := url.Parse(strings.TrimSpace(addr))
+	if err != nil {
+		return "", fmt.Errorf("invalid subscription address: %w", err)
+	}
+	u.Host = client.device.GetDeviceParams().Xaddr
+
+	return u.String(), nil
+}
+
+func parseEvents(msgs []notificationMessage, now time.Time) []dto.Event {
+	var out []dto.Event
+	for _, msg := range msgs {
+		topic := stripTopicNamespaces(msg.Topic)
+
+		for _, known := range eventTopics {
+			if !strings.HasPrefix(topic, known.prefix) {
+				continue
+			}
+
+			body := msg.Message.Message
+			ev := dto.Event{
+				Topic:    topic,
+				Kind:     known.kind,
+				Active:   true,
+				Stateful: known.item != "",
+				Time:     now,
+			}
+			if t, err := time.Parse(time.RFC3339, body.UtcTime); err == nil {
+				ev.Time = t
+			}
+			for _, name := range eventSourceItems {
+				if v, ok := body.Source.get(name); ok {
+					ev.Source = v
+					break
+				}
+			}
+			if ev.Stateful {
+				// A state change without the state can't be told apart.
+				state, ok := body.Data.get(known.item)
+				if !ok {
+					break
+				}
+				ev.Active = parseEventState(state)
+			}
+
+			out = append(out, ev)
+			break
+		}
+	}
+
+	return out
+}
+
+// stripTopicNamespaces turns tns1:RuleEngine/tnsvendor:Detector into RuleEngine/Detector.
+func stripTopicNamespaces(topic string) string {
+	parts := strings.Split(strings.TrimSpace(topic), "/")
+	for i, part := range parts {
+		if _, name, ok := strings.Cut(part, ":"); ok {
+			parts[i] = name
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func parseEventState(v string) bool {
+	return strings.EqualFold(v, "true") || v == "1"
+}
+
+func isoDuration(d time.Duration) string {
+	return "PT" + strconv.FormatFloat(d.Round(time.Millisecond).Seconds(), 'f', -1, 64) + "S"
+}
@@ -0,0 +1,149 @@
+package onvif
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	dto "tomerab.com/cam-hub/internal/onvif/event"
+)
+
+const pullMessagesEnvelope = `<?xml version="1.0" encoding="UTF-8"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:tev="http://www.onvif.org/ver10/events/wsdl"
+	xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2" xmlns:tt="http://www.onvif.org/ver10/schema" xmlns:tns1="http://www.onvif.org/ver10/topics">
+<env:Body>
+<tev:PullMessagesResponse>
+	<tev:CurrentTime>2026-10-17T10:00:05Z</tev:CurrentTime>
+	<tev:TerminationTime>2026-10-17T10:01:05Z</tev:TerminationTime>
+	<wsnt:NotificationMessage>
+		<wsnt:Topic Dialect="http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet">tns1:RuleEngine/CellMotionDetector/Motion</wsnt:Topic>
+		<wsnt:Message>
+			<tt:Message UtcTime="2026-10-17T10:00:01Z" PropertyOperation="Changed">
+				<tt:Source>
+					<tt:SimpleItem Name="VideoSourceConfigurationToken" Value="VideoSourceToken"/>
+					<tt:SimpleItem Name="Rule" Value="MyMotionDetectorRule"/>
+				</tt:Source>
+				<tt:Data><tt:SimpleItem Name="IsMotion" Value="true"/></tt:Data>
+			</tt:Message>
+		</wsnt:Message>
+	</wsnt:NotificationMessage>
+	<wsnt:NotificationMessage>
+		<wsnt:Topic Dialect="http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet">tns1:Device/Trigger/DigitalInput</wsnt:Topic>
+		<wsnt:Message>
+			<tt:Message UtcTime="2026-10-17T10:00:02Z" PropertyOperation="Changed">
+				<tt:Data><tt:SimpleItem Name="LogicalState" Value="true"/></tt:Data>
+			</tt:Message>
+		</wsnt:Message>
+	</wsnt:NotificationMessage>
+	<wsnt:NotificationMessage>
+		<wsnt:Topic Dialect="http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet">tns1:VideoSource/MotionAlarm</wsnt:Topic>
+		<wsnt:Message>
+			<tt:Message UtcTime="2026-10-17T10:00:03Z" PropertyOperation="Changed">
+				<tt:Source><tt:SimpleItem Name="Source" Value="VideoSource_1"/></tt:Source>
+				<tt:Data><tt:SimpleItem Name="State" Value="false"/></tt:Data>
+			</tt:Message>
+		</wsnt:Message>
+	</wsnt:NotificationMessage>
+	<wsnt:NotificationMessage>
+		<wsnt:Topic Dialect="http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet">tns1:RuleEngine/tnsvendor:LineDetector/Crossed</wsnt:Topic>
+		<wsnt:Message>
+			<tt:Message UtcTime="2026-10-17T10:00:04Z">
+				<tt:Source><tt:SimpleItem Name="Rule" Value="Gate"/></tt:Source>
+				<tt:Data><tt:SimpleItem Name="ObjectId" Value="7"/></tt:Data>
+			</tt:Message>
+		</wsnt:Message>
+	</wsnt:NotificationMessage>
+	<wsnt:NotificationMessage>
+		<wsnt:Topic Dialect="http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet">tns1:RuleEngine/TamperDetector/Tamper</wsnt:Topic>
+		<wsnt:Message>
+			<tt:Message UtcTime="2026-10-17T10:00:05Z">
+				<tt:Data><tt:SimpleItem Name="Unexpected" Value="true"/></tt:Data>
+			</tt:Message>
+		</wsnt:Message>
+	</wsnt:NotificationMessage>
+</tev:PullMessagesResponse>
+</env:Body>
+</env:Envelope>`
+
+func TestParseEvents(t *testing.T) {
+	t.Run("pull messages response - should keep the analytics topics", func(t *testing.T) {
+		resp := &http.Response{Body: io.NopCloser(strings.NewReader(pullMessagesEnvelope))}
+
+		var pullResp pullMessagesResp
+		if err := parseResp(resp, &pullResp); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		got := parseEvents(pullResp.NotificationMessage, time.Now())
+		expected := []dto.Event{
+			{
+				Topic:    "RuleEngine/CellMotionDetector/Motion",
+				Kind:     dto.KindMotion,
+				Source:   "MyMotionDetectorRule",
+				Active:   true,
+				Stateful: true,
+				Time:     time.Date(2026, 10, 17, 10, 0, 1, 0, time.UTC),
+			},
+			{
+				Topic:    "VideoSource/MotionAlarm",
+				Kind:     dto.KindMotion,
+				Source:   "VideoSource_1",
+				Active:   false,
+				Stateful: true,
+				Time:     time.Date(2026, 10, 17, 10, 0, 3, 0, time.UTC),
+			},
+			{
+				Topic:  "RuleEngine/LineDetector/Crossed",
+				Kind:   dto.KindLineCrossing,
+				Source: "Gate",
+				Active: true,
+				Time:   time.Date(2026, 10, 17, 10, 0, 4, 0, time.UTC),
+			},
+		}
+
+		if len(got) != len(expected) {
+			t.Fatalf("expected %d events, got %d: %+v", len(expected), len(got), got)
+		}
+		for i := range expected {
+			if got[i] != expected[i] {
+				t.Errorf("event %d: expected %+v, got %+v", i, expected[i], got[i])
+			}
+		}
+	})
+
+	t.Run("missing utc time - should fall back to now", func(t *testing.T) {
+		now := time.Now()
+		msg := notificationMessage{Topic: "tns1:RuleEngine/LineDetector/Crossed"}
+
+		got := parseEvents([]notificationMessage{msg}, now)
+		if len(got) != 1 || !got[0].Time.Equal(now) {
+			t.Errorf("expected an event at %s, got: %+v", now, got)
+		}
+	})
+
+	t.Run("fault - should return an error", func(t *testing.T) {
+		fault := `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body><env:Fault>
+			<env:Code><env:Value>env:Receiver</env:Value></env:Code>
+			<env:Reason><env:Text>Unknown subscription</env:Text></env:Reason>
+		</env:Fault></env:Body></env:Envelope>`
+		resp := &http.Response{Body: io.NopCloser(strings.NewReader(fault))}
+
+		var pullResp pullMessagesResp
+		if err := parseResp(resp, &pullResp); err == nil || !strings.Contains(err.Error(), "Unknown subscription") {
+			t.Fatalf("expected the fault, got: %v", err)
+		}
+	})
+}
+
+func TestIsoDuration(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		90 * time.Second:        "PT90S",
+		1500 * time.Millisecond: "PT1.5S",
+	} {
+		if got := isoDuration(d); got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+	}
+}
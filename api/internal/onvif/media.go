@@ -9,7 +9,8 @@ import (
 )
 
 var (
-	ErrNoPtz = errors.New("this device does not support ptz")
+	ErrNoPtz     = errors.New("this device does not support ptz")
+	ErrNoStreams = errors.New("this device does not expose any rtsp stream")
 )
 
 type PtzProfileToken struct {
@@ -17,6 +18,18 @@ type PtzProfileToken struct {
 }
 
 func (client *OnvifClient) GetPtzProfile() (*PtzProfileToken, error) {
+	profile, err := client.ptzProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	return &PtzProfileToken{
+		Token: string(profile.Token),
+	}, nil
+}
+
+// ptzProfile returns the first media profile with a PTZ configuration.
+func (client *OnvifClient) ptzProfile() (*onvif.Profile, error) {
 	resp, err := client.device.CallMethod(media.GetProfiles{})
 	if err != nil {
 		return nil, err
@@ -35,7 +48,109 @@ func (client *OnvifClient) GetPtzProfile() (*PtzProfileToken, error) {
 		return nil, ErrNoPtz
 	}
 
-	return &PtzProfileToken{
-		Token: string(getProfilesResp.Profiles[firstElem].Token),
-	}, nil
+	return &getProfilesResp.Profiles[firstElem], nil
+}
+
+type StreamProfile struct {
+	Token         string
+	Name          string
+	Encoding      string
+	AudioEncoding string // empty when the profile has no audio
+	Width         int
+	Height        int
+	Uri           string
+}
+
+// GetStreamProfiles resolves the RTSP uri of every video profile of the device. Profiles the
+// device refuses to stream are skipped.
+func (client *OnvifClient) GetStreamProfiles() ([]StreamProfile, error) {
+	resp, err := client.device.CallMethod(media.GetProfiles{})
+	if err != nil {
+		return nil, err
+	}
+
+	var getProfilesResp media.GetProfilesResponse
+	if err := parseResp(resp, &getProfilesResp); err != nil {
+		return nil, err
+	}
+
+	stream := onvif.StreamType("RTP-Unicast")
+	protocol := onvif.TransportProtocol("RTSP")
+
+	var profiles []StreamProfile
+	for _, profile := range getProfilesResp.Profiles {
+		if profile.VideoEncoderConfiguration == nil {
+			continue
+		}
+
+		token := profile.Token
+		resp, err := client.device.CallMethod(media.GetStreamUri{
+			StreamSetup: &onvif.StreamSetup{
+				Stream:    &stream,
+				Transport: &onvif.Transport{Protocol: &protocol},
+			},
+			ProfileToken: &token,
+		})
+		if err != nil {
+			client.logger.Debug("failed to get stream uri", "profile", token, "err", err)
+			continue
+		}
+
+		var getStreamUriResp media.GetStreamUriResponse
+		if err := parseResp(resp, &getStreamUriResp); err != nil {
+			client.logger.Debug("failed to get stream uri", "profile", token, "err", err)
+			continue
+		}
+
+		streamProfile := StreamProfile{
+			Token: string(profile.Token),
+			Name:  string(profile.Name),
+			Uri:   string(getStreamUriResp.MediaUri.Uri),
+		}
+		if encoder := profile.VideoEncoderConfiguration; encoder.Encoding != nil {
+			streamProfile.Encoding = string(*encoder.Encoding)
+		}
+		if encoder := profile.AudioEncoderConfiguration; encoder != nil {
+			streamProfile.AudioEncoding = string(encoder.Encoding)
+		}
+		if res := profile.VideoEncoderConfiguration.Resolution; res != nil && res.Width != nil && res.Height != nil {
+			streamProfile.Width = int(*res.Width)
+			streamProfile.Height = int(*res.Height)
+		}
+		if streamProfile.Uri != "" {
+			profiles = append(profiles, streamProfile)
+		}
+	}
+
+	if len(profiles) == 0 {
+		return nil, ErrNoStreams
+	}
+
+	return profiles, nil
+}
+
+// PickStreams returns the highest resolution profile as the main stream and the lowest as the
+// sub stream, sub is nil when the device has a single profile.
+func PickStreams(profiles []StreamProfile) (main, sub *StreamProfile) {
+	for i := range profiles {
+		profile := &profiles[i]
+		if main == nil || pixels(profile) > pixels(main) {
+			main = profile
+		}
+	}
+	for i := range profiles {
+		profile := &profiles[i]
+		if profile == main || profile.Uri == main.Uri {
+			continue
+		}
+		if sub == nil || pixels(profile) < pixels(sub) {
+			sub = profile
+		}
+	}
+
+	return main, sub
+}
+
+func pixels(profile *StreamProfile) int {
+	return profile.Width * profile.Height
 }
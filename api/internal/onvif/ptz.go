@@ -1,6 +1,13 @@
 package onvif
 
 import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/IOTechSystems/onvif/ptz"
 	"github.com/IOTechSystems/onvif/xsd"
 	"github.com/IOTechSystems/onvif/xsd/onvif"
@@ -8,43 +15,485 @@ import (
 	"tomerab.com/cam-hub/internal/utils"
 )
 
+const defaultMoveTimeout = time.Second
+
+// The library's vectors omit zero coordinates, which cameras reject or read as "leave this axis
+// alone", the moves are sent with these instead.
+type ptzVector2D struct {
+	X float64 `xml:"x,attr"`
+	Y float64 `xml:"y,attr"`
+}
+
+type ptzVector1D struct {
+	X float64 `xml:"x,attr"`
+}
+
+type ptzVector struct {
+	PanTilt *ptzVector2D `xml:"onvif:PanTilt,omitempty"`
+	Zoom    *ptzVector1D `xml:"onvif:Zoom,omitempty"`
+}
+
+type continuousMove struct {
+	XMLName      string    `xml:"tptz:ContinuousMove"`
+	ProfileToken string    `xml:"tptz:ProfileToken"`
+	Velocity     ptzVector `xml:"tptz:Velocity"`
+	Timeout      string    `xml:"tptz:Timeout"`
+}
+
+type absoluteMove struct {
+	XMLName      string     `xml:"tptz:AbsoluteMove"`
+	ProfileToken string     `xml:"tptz:ProfileToken"`
+	Position     ptzVector  `xml:"tptz:Position"`
+	Speed        *ptzVector `xml:"tptz:Speed,omitempty"`
+}
+
+type relativeMove struct {
+	XMLName      string     `xml:"tptz:RelativeMove"`
+	ProfileToken string     `xml:"tptz:ProfileToken"`
+	Translation  ptzVector  `xml:"tptz:Translation"`
+	Speed        *ptzVector `xml:"tptz:Speed,omitempty"`
+}
+
+// The library keeps a single description per space while cameras list one per coordinate system,
+// the spaces are read with these instead.
+type floatRange struct {
+	Min float64
+	Max float64
+}
+
+type space2D struct {
+	URI    string
+	XRange floatRange
+	YRange floatRange
+}
+
+type space1D struct {
+	URI    string
+	XRange floatRange
+}
+
+type ptzSpaces struct {
+	AbsolutePanTiltPositionSpace    []space2D
+	AbsoluteZoomPositionSpace       []space1D
+	RelativePanTiltTranslationSpace []space2D
+	RelativeZoomTranslationSpace    []space1D
+	ContinuousPanTiltVelocitySpace  []space2D
+	ContinuousZoomVelocitySpace     []space1D
+	PanTiltSpeedSpace               []space1D
+	ZoomSpeedSpace                  []space1D
+}
+
+type ptzNode struct {
+	Token                  string `xml:"token,attr"`
+	SupportedPTZSpaces     ptzSpaces
+	MaximumNumberOfPresets int
+	HomeSupported          bool
+}
+
+type getNodesResp struct {
+	PTZNode []ptzNode
+}
+
+type ptzConfigurationOptions struct {
+	Spaces     *ptzSpaces
+	PTZTimeout *struct {
+		Min string
+		Max string
+	}
+}
+
+type getConfigurationOptionsResp struct {
+	PTZConfigurationOptions ptzConfigurationOptions
+}
+
 func (client *OnvifClient) MoveCamera(moveDto dto.MoveCameraDto) error {
-	tok := onvif.ReferenceToken(moveDto.Token)
-	timeout := xsd.Duration("PT1S")
-	resp, err := client.device.CallMethod(ptz.ContinuousMove{
-		ProfileToken: &tok,
-		Velocity: &onvif.PTZSpeed{
-			PanTilt: optVec2D(moveDto.Translation),
-			Zoom:    optVec1D(moveDto.Zoom),
-		},
-		Timeout: &timeout,
+	timeout := moveDto.Timeout
+	if timeout <= 0 {
+		timeout = defaultMoveTimeout
+	}
+
+	resp, err := client.callPtz(continuousMove{
+		ProfileToken: moveDto.Token,
+		Velocity:     toPtzVector(dto.Vector{PanTilt: moveDto.Translation, Zoom: moveDto.Zoom}),
+		Timeout:      isoDuration(timeout),
+	})
+	if err != nil {
+		return err
+	}
+
+	var continuousMoveResp ptz.ContinuousMoveResponse
+	return parseResp(resp, &continuousMoveResp)
+}
+
+func (client *OnvifClient) AbsoluteMove(moveDto dto.AbsoluteMoveDto) error {
+	resp, err := client.callPtz(absoluteMove{
+		ProfileToken: moveDto.Token,
+		Position:     toPtzVector(moveDto.Position),
+		Speed:        optPtzVector(moveDto.Speed),
+	})
+	if err != nil {
+		return err
+	}
+
+	var absoluteMoveResp ptz.AbsoluteMoveResponse
+	return parseResp(resp, &absoluteMoveResp)
+}
+
+func (client *OnvifClient) RelativeMove(moveDto dto.RelativeMoveDto) error {
+	resp, err := client.callPtz(relativeMove{
+		ProfileToken: moveDto.Token,
+		Translation:  toPtzVector(moveDto.Translation),
+		Speed:        optPtzVector(moveDto.Speed),
 	})
 	if err != nil {
 		return err
 	}
 
 	var relativeMoveResp ptz.RelativeMoveResponse
-	if err := parseResp(resp, &relativeMoveResp); err != nil {
+	return parseResp(resp, &relativeMoveResp)
+}
+
+func (client *OnvifClient) StopCamera(stopDto dto.StopCameraMovementDto) error {
+	resp, err := client.device.CallMethod(ptz.Stop{
+		ProfileToken: onvif.ReferenceToken(stopDto.Token),
+		PanTilt:      xsd.Boolean(stopDto.PanTilt),
+		Zoom:         xsd.Boolean(stopDto.Zoom),
+	})
+	if err != nil {
 		return err
 	}
 
-	return nil
+	var stopResp ptz.StopResponse
+	return parseResp(resp, &stopResp)
 }
 
-func optVec2D(vec *utils.Vec2D) *onvif.Vector2D {
+func (client *OnvifClient) GetPtzStatus(token string) (*dto.Status, error) {
+	resp, err := client.device.CallMethod(ptz.GetStatus{ProfileToken: onvif.ReferenceToken(token)})
+	if err != nil {
+		return nil, err
+	}
+
+	var statusResp ptz.GetStatusResponse
+	if err := parseResp(resp, &statusResp); err != nil {
+		return nil, err
+	}
+
+	status := statusResp.PTZStatus
+	out := &dto.Status{
+		Position:      fromPtzVector(status.Position),
+		PanTiltStatus: status.MoveStatus.PanTilt,
+		ZoomStatus:    status.MoveStatus.Zoom,
+		Error:         status.Error,
+	}
+	if t, err := time.Parse(time.RFC3339, status.UtcTime); err == nil {
+		out.UtcTime = t
+	}
+
+	return out, nil
+}
+
+func (client *OnvifClient) GetPresets(token string) ([]dto.Preset, error) {
+	resp, err := client.device.CallMethod(ptz.GetPresets{ProfileToken: onvif.ReferenceToken(token)})
+	if err != nil {
+		return nil, err
+	}
+
+	var presetsResp ptz.GetPresetsResponse
+	if err := parseResp(resp, &presetsResp); err != nil {
+		return nil, err
+	}
+
+	return utils.Map(presetsResp.Preset, func(preset onvif.PTZPreset) dto.Preset {
+		out := dto.Preset{Token: string(preset.Token), Name: string(preset.Name)}
+		if preset.PTZPosition.PanTilt != nil || preset.PTZPosition.Zoom != nil {
+			position := fromPtzVector(preset.PTZPosition)
+			out.Position = &position
+		}
+		return out
+	}), nil
+}
+
+// SetPreset stores the current position under name, an existing preset is overwritten when its
+// token is given. It returns the preset's token.
+func (client *OnvifClient) SetPreset(token, name, presetToken string) (string, error) {
+	profileToken := onvif.ReferenceToken(token)
+	req := ptz.SetPreset{ProfileToken: &profileToken}
+	if name != "" {
+		presetName := xsd.String(name)
+		req.PresetName = &presetName
+	}
+	if presetToken != "" {
+		ref := onvif.ReferenceToken(presetToken)
+		req.PresetToken = &ref
+	}
+
+	resp, err := client.device.CallMethod(req)
+	if err != nil {
+		return "", err
+	}
+
+	var setPresetResp ptz.SetPresetResponse
+	if err := parseResp(resp, &setPresetResp); err != nil {
+		return "", err
+	}
+
+	return string(setPresetResp.PresetToken), nil
+}
+
+func (client *OnvifClient) GotoPreset(token, presetToken string) error {
+	profileToken, ref := onvif.ReferenceToken(token), onvif.ReferenceToken(presetToken)
+	resp, err := client.device.CallMethod(ptz.GotoPreset{ProfileToken: &profileToken, PresetToken: &ref})
+	if err != nil {
+		return err
+	}
+
+	var gotoPresetResp ptz.GotoPresetResponse
+	return parseResp(resp, &gotoPresetResp)
+}
+
+func (client *OnvifClient) RemovePreset(token, presetToken string) error {
+	resp, err := client.device.CallMethod(ptz.RemovePreset{
+		ProfileToken: onvif.ReferenceToken(token),
+		PresetToken:  onvif.ReferenceToken(presetToken),
+	})
+	if err != nil {
+		return err
+	}
+
+	var removePresetResp ptz.RemovePresetResponse
+	return parseResp(resp, &removePresetResp)
+}
+
+func (client *OnvifClient) GotoHomePosition(token string) error {
+	profileToken := onvif.ReferenceToken(token)
+	resp, err := client.device.CallMethod(ptz.GotoHomePosition{ProfileToken: &profileToken})
+	if err != nil {
+		return err
+	}
+
+	var gotoHomeResp ptz.GotoHomePositionResponse
+	return parseResp(resp, &gotoHomeResp)
+}
+
+// GetPtzCapabilities reads the limits of the PTZ node behind the first PTZ profile, narrowed down
+// to the options of the profile's configuration when the camera reports them.
+func (client *OnvifClient) GetPtzCapabilities() (*dto.Capabilities, error) {
+	if client.device.GetEndpoint("ptz") == "" {
+		return nil, ErrNoPtz
+	}
+
+	profile, err := client.ptzProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.device.CallMethod(ptz.GetNodes{})
+	if err != nil {
+		return nil, err
+	}
+
+	var nodesResp getNodesResp
+	if err := parseResp(resp, &nodesResp); err != nil {
+		return nil, err
+	}
+	if len(nodesResp.PTZNode) == 0 {
+		return nil, ErrNoPtz
+	}
+
+	node := nodesResp.PTZNode[0]
+	if ref := profile.PTZConfiguration.NodeToken; ref != nil {
+		for _, n := range nodesResp.PTZNode {
+			if n.Token == string(*ref) {
+				node = n
+			}
+		}
+	}
+
+	caps := &dto.Capabilities{
+		Spaces:        node.SupportedPTZSpaces.toDto(),
+		MaxPresets:    node.MaximumNumberOfPresets,
+		HomeSupported: node.HomeSupported,
+	}
+
+	options, err := client.getConfigurationOptions(string(profile.PTZConfiguration.Token))
+	if err != nil {
+		client.logger.Debug("failed to get ptz configuration options, using the node's spaces", "err", err)
+		return caps, nil
+	}
+
+	if options.Spaces != nil {
+		caps.Spaces = options.Spaces.toDto()
+	}
+	if timeout := options.PTZTimeout; timeout != nil {
+		minTimeout, minErr := parseIsoDuration(timeout.Min)
+		maxTimeout, maxErr := parseIsoDuration(timeout.Max)
+		if minErr == nil && maxErr == nil && minTimeout <= maxTimeout {
+			caps.MinTimeout, caps.MaxTimeout = minTimeout, maxTimeout
+		}
+	}
+
+	return caps, nil
+}
+
+func (client *OnvifClient) getConfigurationOptions(configToken string) (*ptzConfigurationOptions, error) {
+	resp, err := client.device.CallMethod(ptz.GetConfigurationOptions{
+		ConfigurationToken: onvif.ReferenceToken(configToken),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var optionsResp getConfigurationOptionsResp
+	if err := parseResp(resp, &optionsResp); err != nil {
+		return nil, err
+	}
+
+	return &optionsResp.PTZConfigurationOptions, nil
+}
+
+// callPtz sends a request of ours to the PTZ service, CallMethod picks the service after the
+// package of the request.
+func (client *OnvifClient) callPtz(method any) (*http.Response, error) {
+	endpoint := client.device.GetEndpoint("ptz")
+	if endpoint == "" {
+		return nil, ErrNoPtz
+	}
+
+	body, err := xml.Marshal(method)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.device.SendSoap(endpoint, string(body))
+}
+
+func toPtzVector(vec dto.Vector) ptzVector {
+	var out ptzVector
+	if vec.PanTilt != nil {
+		out.PanTilt = &ptzVector2D{X: vec.PanTilt.X, Y: vec.PanTilt.Y}
+	}
+	if vec.Zoom != nil {
+		out.Zoom = &ptzVector1D{X: float64(*vec.Zoom)}
+	}
+	return out
+}
+
+func optPtzVector(vec *dto.Vector) *ptzVector {
 	if vec == nil {
 		return nil
 	}
-	return &onvif.Vector2D{
-		X: float64(vec.X),
-		Y: float64(vec.Y),
+	out := toPtzVector(*vec)
+	return &out
+}
+
+func fromPtzVector(vec onvif.PTZVector) dto.Vector {
+	var out dto.Vector
+	if vec.PanTilt != nil {
+		out.PanTilt = &utils.Vec2D{X: vec.PanTilt.X, Y: vec.PanTilt.Y}
+	}
+	if vec.Zoom != nil {
+		zoom := float32(vec.Zoom.X)
+		out.Zoom = &zoom
+	}
+	return out
+}
+
+func (spaces ptzSpaces) toDto() dto.Spaces {
+	return dto.Spaces{
+		AbsolutePanTilt:   pickSpace2D(spaces.AbsolutePanTiltPositionSpace),
+		AbsoluteZoom:      pickSpace1D(spaces.AbsoluteZoomPositionSpace),
+		RelativePanTilt:   pickSpace2D(spaces.RelativePanTiltTranslationSpace),
+		RelativeZoom:      pickSpace1D(spaces.RelativeZoomTranslationSpace),
+		ContinuousPanTilt: pickSpace2D(spaces.ContinuousPanTiltVelocitySpace),
+		ContinuousZoom:    pickSpace1D(spaces.ContinuousZoomVelocitySpace),
+		PanTiltSpeed:      pickSpace1D(spaces.PanTiltSpeedSpace),
+		ZoomSpeed:         pickSpace1D(spaces.ZoomSpeedSpace),
 	}
 }
 
-func optVec1D(f *float32) *onvif.Vector1D {
-	if f == nil {
+// The moves are sent without a space so the camera reads them in its default space, which is
+// the generic one unless the configuration says otherwise.
+func isGenericSpace(uri string) bool {
+	return strings.Contains(uri, "GenericSpace") || strings.Contains(uri, "GenericSpeedSpace")
+}
+
+func pickSpace2D(spaces []space2D) *dto.Range2D {
+	if len(spaces) == 0 {
+		return nil
+	}
+
+	picked := spaces[0]
+	for _, space := range spaces {
+		if isGenericSpace(space.URI) {
+			picked = space
+			break
+		}
+	}
+
+	return &dto.Range2D{X: dto.Range(picked.XRange), Y: dto.Range(picked.YRange)}
+}
+
+func pickSpace1D(spaces []space1D) *dto.Range {
+	if len(spaces) == 0 {
 		return nil
 	}
 
-	return &onvif.Vector1D{X: float64(*f)}
+	picked := spaces[0]
+	for _, space := range spaces {
+		if isGenericSpace(space.URI) {
+			picked = space
+			break
+		}
+	}
+
+	out := dto.Range(picked.XRange)
+	return &out
+}
+
+// parseIsoDuration reads the day and time parts of an xsd:duration, e.g. PT1M30S or P1DT2H.
+func parseIsoDuration(s string) (time.Duration, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(s), "P")
+	if !ok || rest == "" {
+		return 0, fmt.Errorf("invalid duration: %q", s)
+	}
+
+	var d time.Duration
+	inTime, parts := false, 0
+	for rest != "" {
+		if rest[0] == 'T' {
+			inTime, rest = true, rest[1:]
+			continue
+		}
+
+		i := strings.IndexFunc(rest, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if i <= 0 {
+			return 0, fmt.Errorf("invalid duration: %q", s)
+		}
+		n, err := strconv.ParseFloat(rest[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %q", s)
+		}
+
+		var unit time.Duration
+		switch {
+		case !inTime && rest[i] == 'D':
+			unit = 24 * time.Hour
+		case inTime && rest[i] == 'H':
+			unit = time.Hour
+		case inTime && rest[i] == 'M':
+			unit = time.Minute
+		case inTime && rest[i] == 'S':
+			unit = time.Second
+		default:
+			return 0, fmt.Errorf("unsupported duration: %q", s)
+		}
+		d += time.Duration(n * float64(unit))
+		rest = rest[i+1:]
+		parts++
+	}
+	if parts == 0 {
+		return 0, fmt.Errorf("invalid duration: %q", s)
+	}
+
+	return d, nil
 }
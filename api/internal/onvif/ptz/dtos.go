@@ -1,13 +1,83 @@
 package ptz
 
-import "tomerab.com/cam-hub/internal/utils"
+import (
+	"time"
+
+	"tomerab.com/cam-hub/internal/utils"
+)
 
 type MoveCameraDto struct {
 	Token       string
 	Translation *utils.Vec2D
 	Zoom        *float32
+	Timeout     time.Duration // the camera stops on its own once it passes, defaults to 1s
 }
 
 type StopCameraMovementDto struct {
-	Token string
+	Token   string
+	PanTilt bool
+	Zoom    bool
+}
+
+// Vector is a position, a translation or a speed, unset axes are left alone.
+type Vector struct {
+	PanTilt *utils.Vec2D
+	Zoom    *float32
+}
+
+type AbsoluteMoveDto struct {
+	Token    string
+	Position Vector
+	Speed    *Vector // the camera's default speed when unset
+}
+
+type RelativeMoveDto struct {
+	Token       string
+	Translation Vector
+	Speed       *Vector
+}
+
+type Status struct {
+	Position      Vector
+	PanTiltStatus string // IDLE, MOVING or UNKNOWN
+	ZoomStatus    string
+	Error         string
+	UtcTime       time.Time
+}
+
+type Preset struct {
+	Token    string
+	Name     string
+	Position *Vector // unset when the camera doesn't report it
+}
+
+type Range struct {
+	Min float64
+	Max float64
+}
+
+type Range2D struct {
+	X Range
+	Y Range
+}
+
+// Spaces are the ranges of the generic spaces the moves are sent in, nil when the camera doesn't
+// support the space.
+type Spaces struct {
+	AbsolutePanTilt   *Range2D
+	AbsoluteZoom      *Range
+	RelativePanTilt   *Range2D
+	RelativeZoom      *Range
+	ContinuousPanTilt *Range2D
+	ContinuousZoom    *Range
+	PanTiltSpeed      *Range
+	ZoomSpeed         *Range
+}
+
+type Capabilities struct {
+	Spaces        Spaces
+	MaxPresets    int
+	HomeSupported bool
+	MinTimeout    time.Duration // zero when the camera doesn't report a timeout range
+	MaxTimeout    time.Duration
 }
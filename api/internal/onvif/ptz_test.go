@@ -0,0 +1,129 @@
+package onvif
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	dto "tomerab.com/cam-hub/internal/onvif/ptz"
+	"tomerab.com/cam-hub/internal/utils"
+)
+
+const getNodesEnvelope = `<?xml version="1.0" encoding="UTF-8"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:tptz="http://www.onvif.org/ver20/ptz/wsdl" xmlns:tt="http://www.onvif.org/ver10/schema">
+<env:Body>
+<tptz:GetNodesResponse>
+	<tptz:PTZNode token="PTZNodeToken" FixedHomePosition="false">
+		<tt:Name>PTZNode</tt:Name>
+		<tt:SupportedPTZSpaces>
+			<tt:AbsolutePanTiltPositionSpace>
+				<tt:URI>http://www.onvif.org/ver10/tptz/PanTiltSpaces/SphericalPositionSpaceDegrees</tt:URI>
+				<tt:XRange><tt:Min>-180</tt:Min><tt:Max>180</tt:Max></tt:XRange>
+				<tt:YRange><tt:Min>-90</tt:Min><tt:Max>0</tt:Max></tt:YRange>
+			</tt:AbsolutePanTiltPositionSpace>
+			<tt:AbsolutePanTiltPositionSpace>
+				<tt:URI>http://www.onvif.org/ver10/tptz/PanTiltSpaces/PositionGenericSpace</tt:URI>
+				<tt:XRange><tt:Min>-1</tt:Min><tt:Max>1</tt:Max></tt:XRange>
+				<tt:YRange><tt:Min>-1</tt:Min><tt:Max>1</tt:Max></tt:YRange>
+			</tt:AbsolutePanTiltPositionSpace>
+			<tt:ContinuousPanTiltVelocitySpace>
+				<tt:URI>http://www.onvif.org/ver10/tptz/PanTiltSpaces/VelocityGenericSpace</tt:URI>
+				<tt:XRange><tt:Min>-1</tt:Min><tt:Max>1</tt:Max></tt:XRange>
+				<tt:YRange><tt:Min>-0.5</tt:Min><tt:Max>0.5</tt:Max></tt:YRange>
+			</tt:ContinuousPanTiltVelocitySpace>
+			<tt:PanTiltSpeedSpace>
+				<tt:URI>http://www.onvif.org/ver10/tptz/PanTiltSpaces/GenericSpeedSpace</tt:URI>
+				<tt:XRange><tt:Min>0</tt:Min><tt:Max>1</tt:Max></tt:XRange>
+			</tt:PanTiltSpeedSpace>
+		</tt:SupportedPTZSpaces>
+		<tt:MaximumNumberOfPresets>128</tt:MaximumNumberOfPresets>
+		<tt:HomeSupported>true</tt:HomeSupported>
+	</tptz:PTZNode>
+</tptz:GetNodesResponse>
+</env:Body>
+</env:Envelope>`
+
+func TestParsePtzNodes(t *testing.T) {
+	t.Run("several spaces - should pick the generic one", func(t *testing.T) {
+		resp := &http.Response{Body: io.NopCloser(strings.NewReader(getNodesEnvelope))}
+
+		var nodesResp getNodesResp
+		if err := parseResp(resp, &nodesResp); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if len(nodesResp.PTZNode) != 1 {
+			t.Fatalf("expected 1 node, got: %+v", nodesResp.PTZNode)
+		}
+
+		node := nodesResp.PTZNode[0]
+		if node.Token != "PTZNodeToken" || node.MaximumNumberOfPresets != 128 || !node.HomeSupported {
+			t.Errorf("unexpected node: %+v", node)
+		}
+
+		spaces := node.SupportedPTZSpaces.toDto()
+		unit := dto.Range{Min: -1, Max: 1}
+		if got := spaces.AbsolutePanTilt; got == nil || *got != (dto.Range2D{X: unit, Y: unit}) {
+			t.Errorf("expected the generic absolute space, got: %+v", got)
+		}
+		if got := spaces.ContinuousPanTilt; got == nil || got.Y != (dto.Range{Min: -0.5, Max: 0.5}) {
+			t.Errorf("expected the continuous space, got: %+v", got)
+		}
+		if got := spaces.PanTiltSpeed; got == nil || *got != (dto.Range{Min: 0, Max: 1}) {
+			t.Errorf("expected the speed space, got: %+v", got)
+		}
+		if spaces.AbsoluteZoom != nil || spaces.ContinuousZoom != nil {
+			t.Errorf("expected no zoom spaces, got: %+v", spaces)
+		}
+	})
+}
+
+func TestMarshalPtzMoves(t *testing.T) {
+	t.Run("zero coordinates - should still be sent", func(t *testing.T) {
+		zoom := float32(0)
+		body, err := xml.Marshal(absoluteMove{
+			ProfileToken: "profile",
+			Position:     toPtzVector(dto.Vector{PanTilt: &utils.Vec2D{X: 0, Y: 0.5}, Zoom: &zoom}),
+		})
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		for _, expected := range []string{`<onvif:PanTilt x="0" y="0.5">`, `<onvif:Zoom x="0">`} {
+			if !strings.Contains(string(body), expected) {
+				t.Errorf("expected %s in %s", expected, body)
+			}
+		}
+		if strings.Contains(string(body), "Speed") {
+			t.Errorf("expected no speed, got: %s", body)
+		}
+	})
+}
+
+func TestParseIsoDuration(t *testing.T) {
+	for s, expected := range map[string]time.Duration{
+		"PT1S":     time.Second,
+		"PT1M30S":  90 * time.Second,
+		"PT0.5S":   500 * time.Millisecond,
+		"P1DT2H":   26 * time.Hour,
+		"PT10M":    10 * time.Minute,
+		"P0DT0H0M": 0,
+	} {
+		got, err := parseIsoDuration(s)
+		if err != nil {
+			t.Errorf("%s: expected nil, got err: %v", s, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("%s: expected %s, got %s", s, expected, got)
+		}
+	}
+
+	for _, s := range []string{"", "1S", "PT", "P1Y", "PTxS"} {
+		if _, err := parseIsoDuration(s); err == nil {
+			t.Errorf("%q: expected err, got nil", s)
+		}
+	}
+}
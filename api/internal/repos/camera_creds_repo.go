@@ -7,6 +7,7 @@ import (
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/jackc/pgx/v5"
 	"tomerab.com/cam-hub/internal/api/v1/models"
+	"tomerab.com/cam-hub/internal/secrets"
 )
 
 type CameraCredsRepoIface interface {
@@ -14,25 +15,46 @@ type CameraCredsRepoIface interface {
 	FindOne(ctx context.Context, uuid string) (*models.CameraCreds, error)
 }
 
+// Passwords are envelope encrypted with the keyring, rows written before encryption was
+// introduced still hold the plaintext until EncryptPlaintext migrates them.
 type PgxCameraCredsRepo struct {
-	DB DBPoolIface
+	DB   DBPoolIface
+	Keys *secrets.Keyring
 }
 
-func NewPgxCameraCredsRepo(db DBPoolIface) *PgxCameraCredsRepo {
+type credsRow struct {
+	UUID        string  `db:"id"`
+	Username    string  `db:"username"`
+	Password    *string `db:"password"`
+	PasswordEnc []byte  `db:"password_enc"`
+	PasswordDek []byte  `db:"password_dek"`
+	KeyID       *string `db:"key_id"`
+}
+
+func NewPgxCameraCredsRepo(db DBPoolIface, keys *secrets.Keyring) *PgxCameraCredsRepo {
 	return &PgxCameraCredsRepo{
-		DB: db,
+		DB:   db,
+		Keys: keys,
 	}
 }
 
 func (repo *PgxCameraCredsRepo) InsertCreds(ctx context.Context, tx pgx.Tx, creds *models.CameraCreds) error {
+	env, err := repo.Keys.Seal([]byte(creds.Password), []byte(creds.UUID))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt creds: uuid=%s, err=%w", creds.UUID, err)
+	}
+
 	tag, err := tx.Exec(ctx, `
-		INSERT INTO camera_creds (id, username, password)
-		VALUES ($1,$2,$3)
+		INSERT INTO camera_creds (id, username, password, password_enc, password_dek, key_id)
+		VALUES ($1,$2,NULL,$3,$4,$5)
 		ON CONFLICT (id) DO UPDATE SET
 			username = EXCLUDED.username,
-			password = EXCLUDED.password
+			password = NULL,
+			password_enc = EXCLUDED.password_enc,
+			password_dek = EXCLUDED.password_dek,
+			key_id = EXCLUDED.key_id
 	`,
-		creds.UUID, creds.Username, creds.Password,
+		creds.UUID, creds.Username, env.Ciphertext, env.WrappedDEK, env.KeyID,
 	)
 
 	if !(tag.Insert() || tag.Update()) {
@@ -43,11 +65,124 @@ func (repo *PgxCameraCredsRepo) InsertCreds(ctx context.Context, tx pgx.Tx, cred
 }
 
 func (repo *PgxCameraCredsRepo) FindOne(ctx context.Context, uuid string) (*models.CameraCreds, error) {
-	var creds models.CameraCreds
-	err := pgxscan.Get(ctx, repo.DB, &creds, `SELECT id, username, password
+	var row credsRow
+	err := pgxscan.Get(ctx, repo.DB, &row, `SELECT id, username, password, password_enc, password_dek, key_id
 												FROM camera_creds
 												WHERE id = $1
 	`, uuid)
+	if err != nil {
+		return &models.CameraCreds{}, err
+	}
+
+	password, err := repo.password(&row)
+	if err != nil {
+		return &models.CameraCreds{}, err
+	}
+
+	return &models.CameraCreds{
+		UUID:     row.UUID,
+		Username: row.Username,
+		Password: password,
+	}, nil
+}
+
+// EncryptPlaintext encrypts the rows that still hold a plaintext password and returns how many
+// were migrated.
+func (repo *PgxCameraCredsRepo) EncryptPlaintext(ctx context.Context) (int, error) {
+	return repo.migrateRows(ctx, `password IS NOT NULL`, nil, func(row *credsRow) (*credsRow, error) {
+		env, err := repo.Keys.Seal([]byte(*row.Password), []byte(row.UUID))
+		if err != nil {
+			return nil, err
+		}
 
-	return &creds, err
+		return &credsRow{UUID: row.UUID, PasswordEnc: env.Ciphertext, PasswordDek: env.WrappedDEK, KeyID: &env.KeyID}, nil
+	})
+}
+
+// RotateKeys rewraps the data keys that aren't wrapped by the active master key, once it
+// returns the old master keys can be removed from the keyring.
+func (repo *PgxCameraCredsRepo) RotateKeys(ctx context.Context) (int, error) {
+	return repo.migrateRows(ctx, `key_id IS NOT NULL AND key_id <> $1`, []any{repo.Keys.ActiveID()}, func(row *credsRow) (*credsRow, error) {
+		env, err := repo.Keys.Rewrap(envelopeOf(row))
+		if err != nil {
+			return nil, err
+		}
+
+		return &credsRow{UUID: row.UUID, PasswordEnc: env.Ciphertext, PasswordDek: env.WrappedDEK, KeyID: &env.KeyID}, nil
+	})
+}
+
+// DecryptAll writes the passwords back in plaintext, only meant for rolling back the migration.
+func (repo *PgxCameraCredsRepo) DecryptAll(ctx context.Context) (int, error) {
+	return repo.migrateRows(ctx, `password IS NULL`, nil, func(row *credsRow) (*credsRow, error) {
+		password, err := repo.password(row)
+		if err != nil {
+			return nil, err
+		}
+
+		return &credsRow{UUID: row.UUID, Password: &password}, nil
+	})
+}
+
+// migrateRows rewrites the matching rows in a single transaction, either all of them are
+// migrated or none are.
+func (repo *PgxCameraCredsRepo) migrateRows(ctx context.Context, where string, args []any, migrate func(row *credsRow) (*credsRow, error)) (int, error) {
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var rows []*credsRow
+	if err := pgxscan.Select(ctx, tx, &rows, `SELECT id, username, password, password_enc, password_dek, key_id
+												FROM camera_creds
+												WHERE `+where+`
+												FOR UPDATE`, args...); err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		next, err := migrate(row)
+		if err != nil {
+			return 0, fmt.Errorf("failed to migrate creds: uuid=%s, err=%w", row.UUID, err)
+		}
+
+		if _, err := tx.Exec(ctx, `
+			UPDATE camera_creds
+			SET password = $2, password_enc = $3, password_dek = $4, key_id = $5
+			WHERE id = $1
+		`, next.UUID, next.Password, next.PasswordEnc, next.PasswordDek, next.KeyID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(rows), nil
+}
+
+func (repo *PgxCameraCredsRepo) password(row *credsRow) (string, error) {
+	if row.KeyID == nil {
+		if row.Password == nil {
+			return "", fmt.Errorf("creds of %s have no password", row.UUID)
+		}
+		return *row.Password, nil
+	}
+
+	plaintext, err := repo.Keys.Open(envelopeOf(row), []byte(row.UUID))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt creds: uuid=%s, err=%w", row.UUID, err)
+	}
+
+	return string(plaintext), nil
+}
+
+func envelopeOf(row *credsRow) *secrets.Envelope {
+	return &secrets.Envelope{
+		KeyID:      *row.KeyID,
+		WrappedDEK: row.PasswordDek,
+		Ciphertext: row.PasswordEnc,
+	}
 }
@@ -1,6 +1,7 @@
 package repos
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"reflect"
@@ -10,8 +11,11 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/pashagolub/pgxmock/v4"
 	"tomerab.com/cam-hub/internal/api/v1/models"
+	"tomerab.com/cam-hub/internal/secrets"
 )
 
+var credsCols = []string{"id", "username", "password", "password_enc", "password_dek", "key_id"}
+
 func makeCreds(uuid string) *models.CameraCreds {
 	return &models.CameraCreds{
 		UUID:     uuid,
@@ -20,6 +24,20 @@ func makeCreds(uuid string) *models.CameraCreds {
 	}
 }
 
+func testKeyring(t *testing.T) *secrets.Keyring {
+	t.Helper()
+
+	keys, err := secrets.NewKeyring("test", map[string][]byte{
+		"test": bytes.Repeat([]byte{1}, secrets.KeySize),
+		"next": bytes.Repeat([]byte{2}, secrets.KeySize),
+	})
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+
+	return keys
+}
+
 func setupCameraCredsRepoTest(t *testing.T) (*PgxCameraCredsRepo, pgxmock.PgxPoolIface, context.Context) {
 	t.Helper()
 
@@ -32,7 +50,7 @@ func setupCameraCredsRepoTest(t *testing.T) (*PgxCameraCredsRepo, pgxmock.PgxPoo
 		mock.Close()
 	})
 
-	repo := NewPgxCameraCredsRepo(mock)
+	repo := NewPgxCameraCredsRepo(mock, testKeyring(t))
 	ctx := context.Background()
 
 	return repo, mock, ctx
@@ -45,8 +63,8 @@ func TestInsertCreds(t *testing.T) {
 		creds := makeCreds("1")
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`.*INSERT INTO camera_creds.*\s*VALUES \(\$1,\$2,\$3\)\s*ON CONFLICT \(id\) DO UPDATE SET(\s*.*)*`).
-			WithArgs(creds.UUID, creds.Username, creds.Password).
+		mock.ExpectExec(`.*INSERT INTO camera_creds.*\s*VALUES \(\$1,\$2,NULL,\$3,\$4,\$5\)\s*ON CONFLICT \(id\) DO UPDATE SET(\s*.*)*`).
+			WithArgs(creds.UUID, creds.Username, pgxmock.AnyArg(), pgxmock.AnyArg(), "test").
 			WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 		tx, err := mock.Begin(ctx)
@@ -68,8 +86,8 @@ func TestInsertCreds(t *testing.T) {
 		creds := makeCreds("1")
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`.*INSERT INTO camera_creds.*\s*VALUES \(\$1,\$2,\$3\)\s*ON CONFLICT \(id\) DO UPDATE SET(\s*.*)*`).
-			WithArgs(creds.UUID, creds.Username, creds.Password).
+		mock.ExpectExec(`.*INSERT INTO camera_creds.*\s*VALUES \(\$1,\$2,NULL,\$3,\$4,\$5\)\s*ON CONFLICT \(id\) DO UPDATE SET(\s*.*)*`).
+			WithArgs(creds.UUID, creds.Username, pgxmock.AnyArg(), pgxmock.AnyArg(), "test").
 			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
 
 		tx, err := mock.Begin(ctx)
@@ -110,8 +128,8 @@ func TestInsertCreds(t *testing.T) {
 
 		expectedMsg := "failed to insert/update creds"
 		mock.ExpectBegin()
-		mock.ExpectExec(`.*INSERT INTO camera_creds.*\s*VALUES \(\$1,\$2,\$3\)\s*ON CONFLICT \(id\) DO UPDATE SET(\s*.*)*`).
-			WithArgs(creds.UUID, creds.Username, creds.Password).
+		mock.ExpectExec(`.*INSERT INTO camera_creds.*\s*VALUES \(\$1,\$2,NULL,\$3,\$4,\$5\)\s*ON CONFLICT \(id\) DO UPDATE SET(\s*.*)*`).
+			WithArgs(creds.UUID, creds.Username, pgxmock.AnyArg(), pgxmock.AnyArg(), "test").
 			WillReturnError(errors.New("failed to insert/update creds"))
 
 		tx, err := mock.Begin(ctx)
@@ -141,10 +159,15 @@ func TestCredsFindOne(t *testing.T) {
 	t.Run("uuid exist - should return creds", func(t *testing.T) {
 		expected := makeCreds("1")
 
+		env, err := repo.Keys.Seal([]byte(expected.Password), []byte(expected.UUID))
+		if err != nil {
+			t.Fatalf("failed to seal: %v", err)
+		}
+
 		mock.ExpectQuery(`SELECT.*\s*FROM\s*camera_creds.*\s*WHERE id = \$1`).
 			WithArgs(expected.UUID).
-			WillReturnRows(pgxmock.NewRows([]string{"id", "username", "password"}).
-				AddRow(expected.UUID, expected.Username, expected.Password))
+			WillReturnRows(pgxmock.NewRows(credsCols).
+				AddRow(expected.UUID, expected.Username, nil, env.Ciphertext, env.WrappedDEK, &env.KeyID))
 
 		got, err := repo.FindOne(ctx, "1")
 		if err != nil {
@@ -160,6 +183,48 @@ func TestCredsFindOne(t *testing.T) {
 		}
 	})
 
+	t.Run("plaintext row - should return the legacy password", func(t *testing.T) {
+		expected := makeCreds("1")
+
+		mock.ExpectQuery(`SELECT.*\s*FROM\s*camera_creds.*\s*WHERE id = \$1`).
+			WithArgs(expected.UUID).
+			WillReturnRows(pgxmock.NewRows(credsCols).
+				AddRow(expected.UUID, expected.Username, &expected.Password, nil, nil, nil))
+
+		got, err := repo.FindOne(ctx, "1")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("sealed for another row - should fail to decrypt", func(t *testing.T) {
+		env, err := repo.Keys.Seal([]byte("test-pass"), []byte("2"))
+		if err != nil {
+			t.Fatalf("failed to seal: %v", err)
+		}
+
+		mock.ExpectQuery(`SELECT.*\s*FROM\s*camera_creds.*\s*WHERE id = \$1`).
+			WithArgs("1").
+			WillReturnRows(pgxmock.NewRows(credsCols).
+				AddRow("1", "test-user", nil, env.Ciphertext, env.WrappedDEK, &env.KeyID))
+
+		if _, err := repo.FindOne(ctx, "1"); err == nil {
+			t.Fatal("expected err, got nil")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
 	t.Run("uuid does not exist - should return err", func(t *testing.T) {
 		expected := makeCreds("1")
 
@@ -181,3 +246,66 @@ func TestCredsFindOne(t *testing.T) {
 		}
 	})
 }
+
+func TestCredsEncryptPlaintext(t *testing.T) {
+	repo, mock, ctx := setupCameraCredsRepoTest(t)
+	password := "test-pass"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT.*\s*FROM\s*camera_creds\s*WHERE password IS NOT NULL\s*FOR UPDATE`).
+		WillReturnRows(pgxmock.NewRows(credsCols).
+			AddRow("1", "test-user", &password, nil, nil, nil))
+	mock.ExpectExec(`UPDATE camera_creds\s*SET password = \$2, password_enc = \$3, password_dek = \$4, key_id = \$5\s*WHERE id = \$1`).
+		WithArgs("1", (*string)(nil), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+	mock.ExpectRollback()
+
+	n, err := repo.EncryptPlaintext(ctx)
+	if err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 migrated row, got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestCredsRotateKeys(t *testing.T) {
+	repo, mock, ctx := setupCameraCredsRepoTest(t)
+
+	old, err := secrets.NewKeyring("next", map[string][]byte{"next": bytes.Repeat([]byte{2}, secrets.KeySize)})
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+	env, err := old.Seal([]byte("test-pass"), []byte("1"))
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT.*\s*FROM\s*camera_creds\s*WHERE key_id IS NOT NULL AND key_id <> \$1\s*FOR UPDATE`).
+		WithArgs("test").
+		WillReturnRows(pgxmock.NewRows(credsCols).
+			AddRow("1", "test-user", nil, env.Ciphertext, env.WrappedDEK, &env.KeyID))
+	mock.ExpectExec(`UPDATE camera_creds`).
+		WithArgs("1", (*string)(nil), env.Ciphertext, pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+	mock.ExpectRollback()
+
+	n, err := repo.RotateKeys(ctx)
+	if err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 rotated row, got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -17,6 +17,7 @@ type CameraRepoIface interface {
 	FindOne(ctx context.Context, uuid string) (*models.Camera, error)
 	FindMany(ctx context.Context, offset, limit int) ([]*models.Camera, error)
 	FindAllUUIDS(ctx context.Context) ([]string, error)
+	FindAll(ctx context.Context) ([]*models.Camera, error)
 	Save(ctx context.Context, cam *models.Camera) error
 	Delete(ctx context.Context, uuid string) error
 }
@@ -135,7 +136,8 @@ func (repo *PgxCameraRepo) FindOne(ctx context.Context, uuid string) (*models.Ca
 													serial_number,
 													hardware_id,
 													addr,
-													version
+													version,
+													transcode_policy
 												FROM cameras
 												WHERE id = $1`, uuid)
 
@@ -152,10 +154,11 @@ func (repo *PgxCameraRepo) Save(ctx context.Context, cam *models.Camera) error {
 			serial_number = $6,
 			hardware_id = $7,
 			addr = $8,
+			transcode_policy = $10,
 			version = version + 1
 		WHERE id = $1 and version = $9
 	`, cam.UUID, cam.CameraName, cam.Manufacturer, cam.Model, cam.FirmwareVersion, cam.SerialNumber,
-		cam.HardwareId, cam.Addr, cam.Version)
+		cam.HardwareId, cam.Addr, cam.Version, cam.TranscodePolicy)
 
 	if tag.RowsAffected() != 1 {
 		return fmt.Errorf("save failed: no rows were affected (id=%s)", cam.UUID)
@@ -188,7 +191,8 @@ func (repo *PgxCameraRepo) FindMany(ctx context.Context, offset, limit int) ([]*
 													serial_number,
 													hardware_id,
 													addr,
-													version
+													version,
+													transcode_policy
 												FROM cameras
 												ORDER BY created_at DESC, id
 												LIMIT $1 OFFSET $2
@@ -196,6 +200,26 @@ func (repo *PgxCameraRepo) FindMany(ctx context.Context, offset, limit int) ([]*
 	return cams, err
 }
 
+// FindAll returns every paired camera, only meant for the small fleets a hub manages.
+func (repo *PgxCameraRepo) FindAll(ctx context.Context) ([]*models.Camera, error) {
+	var cams []*models.Camera
+	err := pgxscan.Select(ctx, repo.DB, &cams, `SELECT
+													id,
+													name,
+													manufacturer,
+													model,
+													firmware_version,
+													serial_number,
+													hardware_id,
+													addr,
+													version,
+													transcode_policy
+												FROM cameras
+												ORDER BY id
+												`)
+	return cams, err
+}
+
 func (repo *PgxCameraRepo) FindAllUUIDS(ctx context.Context) ([]string, error) {
 	var uuids []string
 	err := pgxscan.Select(ctx, repo.DB, &uuids, `SELECT
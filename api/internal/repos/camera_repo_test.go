@@ -22,6 +22,8 @@ func makeCamera(uuid string) *models.Camera {
 		HardwareId:      "HW456",
 		Addr:            "192.168.1.100",
 		FirmwareVersion: "1.0.0",
+		Version:         1,
+		TranscodePolicy: "auto",
 	}
 }
 
@@ -80,6 +82,7 @@ func TestUpsertCamera(t *testing.T) {
 				camera.SerialNumber,
 				camera.HardwareId,
 				camera.Addr,
+				camera.Version,
 			).
 			WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
@@ -113,6 +116,7 @@ func TestUpsertCamera(t *testing.T) {
 				camera.SerialNumber,
 				camera.HardwareId,
 				camera.Addr,
+				camera.Version,
 			).
 			WillReturnError(errors.New("database connection failed"))
 
@@ -259,13 +263,15 @@ func TestCamerasFindOne(t *testing.T) {
 			WithArgs(cam.UUID).
 			WillReturnRows(pgxmock.NewRows([]string{
 				"id",
-				"camera_name",
+				"name",
 				"manufacturer",
 				"model",
 				"firmware_version",
 				"serial_number",
 				"hardware_id",
 				"addr",
+				"version",
+				"transcode_policy",
 			}).AddRow(cam.UUID,
 				cam.CameraName,
 				cam.Manufacturer,
@@ -274,6 +280,8 @@ func TestCamerasFindOne(t *testing.T) {
 				cam.SerialNumber,
 				cam.HardwareId,
 				cam.Addr,
+				cam.Version,
+				cam.TranscodePolicy,
 			))
 
 		got, err := repo.FindOne(ctx, "1")
@@ -353,6 +361,8 @@ func TestSave(t *testing.T) {
 				camera.SerialNumber,
 				camera.HardwareId,
 				camera.Addr,
+				camera.Version,
+				camera.TranscodePolicy,
 			).
 			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
 
@@ -379,6 +389,8 @@ func TestSave(t *testing.T) {
 				camera.SerialNumber,
 				camera.HardwareId,
 				camera.Addr,
+				camera.Version,
+				camera.TranscodePolicy,
 			).
 			WillReturnResult(pgxmock.NewResult("UPDATE", 0))
 
@@ -421,6 +433,8 @@ func TestSave(t *testing.T) {
 				camera.SerialNumber,
 				camera.HardwareId,
 				camera.Addr,
+				camera.Version,
+				camera.TranscodePolicy,
 			).
 			WillReturnResult(pgxmock.NewResult("UPDATE", 2))
 
@@ -0,0 +1,91 @@
+package repos
+
+import (
+	"context"
+	"errors"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+var (
+	ErrStreamNotFound = errors.New("camera stream not found")
+)
+
+type CameraStreamsRepoIface interface {
+	ReplaceTx(ctx context.Context, tx pgx.Tx, camUUID string, streams []*models.CameraStream) error
+	FindByCamera(ctx context.Context, camUUID string) ([]*models.CameraStream, error)
+	FindOne(ctx context.Context, camUUID, profile string) (*models.CameraStream, error)
+}
+
+type PgxCameraStreamsRepo struct {
+	DB DBPoolIface
+}
+
+func NewPgxCameraStreamsRepo(db DBPoolIface) *PgxCameraStreamsRepo {
+	return &PgxCameraStreamsRepo{
+		DB: db,
+	}
+}
+
+// ReplaceTx swaps the streams of the camera, it is part of the pairing transaction so a
+// re-paired camera never keeps the uris of its previous firmware.
+func (repo *PgxCameraStreamsRepo) ReplaceTx(ctx context.Context, tx pgx.Tx, camUUID string, streams []*models.CameraStream) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM camera_streams WHERE cam_id = $1`, camUUID); err != nil {
+		return err
+	}
+
+	for _, stream := range streams {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO camera_streams (cam_id, profile, token, uri, encoding, audio_encoding, width, height)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			camUUID,
+			stream.Profile,
+			stream.Token,
+			stream.Uri,
+			stream.Encoding,
+			stream.AudioEncoding,
+			stream.Width,
+			stream.Height); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (repo *PgxCameraStreamsRepo) FindByCamera(ctx context.Context, camUUID string) ([]*models.CameraStream, error) {
+	streams := []*models.CameraStream{}
+	if err := pgxscan.Select(ctx,
+		repo.DB,
+		&streams,
+		`SELECT cam_id, profile, token, uri, encoding, audio_encoding, width, height
+			FROM camera_streams
+			WHERE cam_id = $1
+			ORDER BY profile`,
+		camUUID); err != nil {
+		return nil, err
+	}
+
+	return streams, nil
+}
+
+func (repo *PgxCameraStreamsRepo) FindOne(ctx context.Context, camUUID, profile string) (*models.CameraStream, error) {
+	var stream models.CameraStream
+	if err := pgxscan.Get(ctx,
+		repo.DB,
+		&stream,
+		`SELECT cam_id, profile, token, uri, encoding, audio_encoding, width, height
+			FROM camera_streams
+			WHERE cam_id = $1 AND profile = $2`,
+		camUUID,
+		profile); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrStreamNotFound
+		}
+		return nil, err
+	}
+
+	return &stream, nil
+}
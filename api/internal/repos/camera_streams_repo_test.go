@@ -0,0 +1,133 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+func setupCameraStreamsRepoTest(t *testing.T) (*PgxCameraStreamsRepo, pgxmock.PgxPoolIface, context.Context) {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create mock pool: %v", err)
+	}
+
+	t.Cleanup(func() {
+		mock.Close()
+	})
+
+	return NewPgxCameraStreamsRepo(mock), mock, context.Background()
+}
+
+func makeCameraStream(camUUID, profile string) *models.CameraStream {
+	return &models.CameraStream{
+		CamUUID:       camUUID,
+		Profile:       profile,
+		Token:         "profile_" + profile,
+		Uri:           "rtsp://192.168.1.100:554/" + profile,
+		Encoding:      "H264",
+		AudioEncoding: "AAC",
+		Width:         1920,
+		Height:        1080,
+	}
+}
+
+var cameraStreamCols = []string{"cam_id", "profile", "token", "uri", "encoding", "audio_encoding", "width", "height"}
+
+func TestCameraStreamsReplaceTx(t *testing.T) {
+	repo, mock, ctx := setupCameraStreamsRepoTest(t)
+
+	t.Run("replace streams - should delete and insert in the given transaction", func(t *testing.T) {
+		main := makeCameraStream("cam", "main")
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM camera_streams WHERE cam_id = \$1`).
+			WithArgs("cam").
+			WillReturnResult(pgxmock.NewResult("DELETE", 2))
+		mock.ExpectExec(`INSERT INTO camera_streams(?s).*`).
+			WithArgs("cam", main.Profile, main.Token, main.Uri, main.Encoding, main.AudioEncoding, main.Width, main.Height).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		mock.ExpectCommit()
+
+		tx, err := mock.Begin(ctx)
+		if err != nil {
+			t.Fatalf("failed to create transaction: %v", err)
+		}
+		if err := repo.ReplaceTx(ctx, tx, "cam", []*models.CameraStream{main}); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("insert fails - should return the error", func(t *testing.T) {
+		main := makeCameraStream("cam", "main")
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM camera_streams WHERE cam_id = \$1`).
+			WithArgs("cam").
+			WillReturnResult(pgxmock.NewResult("DELETE", 0))
+		mock.ExpectExec(`INSERT INTO camera_streams(?s).*`).
+			WithArgs("cam", main.Profile, main.Token, main.Uri, main.Encoding, main.AudioEncoding, main.Width, main.Height).
+			WillReturnError(errors.New("db error"))
+		mock.ExpectRollback()
+
+		tx, err := mock.Begin(ctx)
+		if err != nil {
+			t.Fatalf("failed to create transaction: %v", err)
+		}
+		defer tx.Rollback(ctx)
+
+		if err := repo.ReplaceTx(ctx, tx, "cam", []*models.CameraStream{main}); err == nil {
+			t.Fatalf("expected err, got nil")
+		}
+	})
+}
+
+func TestCameraStreamsFindOne(t *testing.T) {
+	repo, mock, ctx := setupCameraStreamsRepoTest(t)
+
+	t.Run("stream exists - should return it", func(t *testing.T) {
+		expected := makeCameraStream("cam", "main")
+		mock.ExpectQuery(`SELECT .* FROM camera_streams\s+WHERE cam_id = \$1 AND profile = \$2`).
+			WithArgs("cam", "main").
+			WillReturnRows(pgxmock.NewRows(cameraStreamCols).
+				AddRow(expected.CamUUID, expected.Profile, expected.Token, expected.Uri, expected.Encoding, expected.AudioEncoding, expected.Width, expected.Height))
+
+		got, err := repo.FindOne(ctx, "cam", "main")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("stream is missing - should return ErrStreamNotFound", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT .* FROM camera_streams\s+WHERE cam_id = \$1 AND profile = \$2`).
+			WithArgs("cam", "sub").
+			WillReturnError(pgx.ErrNoRows)
+
+		if _, err := repo.FindOne(ctx, "cam", "sub"); !errors.Is(err, ErrStreamNotFound) {
+			t.Errorf("expected ErrStreamNotFound, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package repos
+
+import (
+	"context"
+	"errors"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+var ErrMotionSettingsNotFound = errors.New("motion settings not found")
+
+type MotionSettingsRepoIface interface {
+	FindOne(ctx context.Context, uuid string) (*models.MotionSettings, error)
+	Upsert(ctx context.Context, settings *models.MotionSettings) error
+}
+
+type PgxMotionSettingsRepo struct {
+	DB DBPoolIface
+}
+
+func NewPgxMotionSettingsRepo(db DBPoolIface) *PgxMotionSettingsRepo {
+	return &PgxMotionSettingsRepo{
+		DB: db,
+	}
+}
+
+func (repo *PgxMotionSettingsRepo) FindOne(ctx context.Context, uuid string) (*models.MotionSettings, error) {
+	var settings models.MotionSettings
+	if err := pgxscan.Get(ctx,
+		repo.DB,
+		&settings,
+		`SELECT id, threshold, min_area, warmup_frames, tick_ms, cooldown_ms,
+				pre_roll_ms, post_roll_ms, max_event_ms, source
+			FROM camera_motion_settings
+			WHERE id = $1`,
+		uuid); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMotionSettingsNotFound
+		}
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (repo *PgxMotionSettingsRepo) Upsert(ctx context.Context, settings *models.MotionSettings) error {
+	tag, err := repo.DB.Exec(ctx,
+		`INSERT INTO camera_motion_settings (id, threshold, min_area, warmup_frames, tick_ms, cooldown_ms,
+				pre_roll_ms, post_roll_ms, max_event_ms, source)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (id) DO UPDATE SET
+				threshold = EXCLUDED.threshold,
+				min_area = EXCLUDED.min_area,
+				warmup_frames = EXCLUDED.warmup_frames,
+				tick_ms = EXCLUDED.tick_ms,
+				cooldown_ms = EXCLUDED.cooldown_ms,
+				pre_roll_ms = EXCLUDED.pre_roll_ms,
+				post_roll_ms = EXCLUDED.post_roll_ms,
+				max_event_ms = EXCLUDED.max_event_ms,
+				source = EXCLUDED.source,
+				updated_at = NOW()`,
+		settings.UUID,
+		settings.Threshold,
+		settings.MinArea,
+		settings.WarmupFrames,
+		settings.TickMs,
+		settings.CooldownMs,
+		settings.PreRollMs,
+		settings.PostRollMs,
+		settings.MaxEventMs,
+		settings.Source)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRowsAffected
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+func setupMotionSettingsRepoTest(t *testing.T) (*PgxMotionSettingsRepo, pgxmock.PgxPoolIface, context.Context) {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create mock pool: %v", err)
+	}
+
+	t.Cleanup(func() {
+		mock.Close()
+	})
+
+	return NewPgxMotionSettingsRepo(mock), mock, context.Background()
+}
+
+func TestMotionSettingsFindOne(t *testing.T) {
+	repo, mock, ctx := setupMotionSettingsRepoTest(t)
+
+	t.Run("settings exist - should return them", func(t *testing.T) {
+		expected := &models.MotionSettings{UUID: "1", Threshold: 60, MinArea: 8000, WarmupFrames: 100, TickMs: 100, CooldownMs: 5000, PreRollMs: 2000, PostRollMs: 4000, MaxEventMs: 30000, Source: "both"}
+		mock.ExpectQuery(`SELECT .* FROM camera_motion_settings\s+WHERE id = \$1`).
+			WithArgs("1").
+			WillReturnRows(pgxmock.NewRows([]string{"id", "threshold", "min_area", "warmup_frames", "tick_ms", "cooldown_ms", "pre_roll_ms", "post_roll_ms", "max_event_ms", "source"}).
+				AddRow(expected.UUID, expected.Threshold, expected.MinArea, expected.WarmupFrames, expected.TickMs, expected.CooldownMs,
+					expected.PreRollMs, expected.PostRollMs, expected.MaxEventMs, expected.Source))
+
+		got, err := repo.FindOne(ctx, "1")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("settings do not exist - should return ErrMotionSettingsNotFound", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT .* FROM camera_motion_settings\s+WHERE id = \$1`).
+			WithArgs("missing").
+			WillReturnError(pgx.ErrNoRows)
+
+		_, err := repo.FindOne(ctx, "missing")
+		if !errors.Is(err, ErrMotionSettingsNotFound) {
+			t.Fatalf("expected ErrMotionSettingsNotFound, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestMotionSettingsUpsert(t *testing.T) {
+	repo, mock, ctx := setupMotionSettingsRepoTest(t)
+	settings := &models.MotionSettings{UUID: "1", Threshold: 60, MinArea: 8000, WarmupFrames: 100, TickMs: 100, CooldownMs: 5000, PreRollMs: 2000, PostRollMs: 4000, MaxEventMs: 30000, Source: "both"}
+
+	t.Run("upsert settings - should succeed", func(t *testing.T) {
+		mock.ExpectExec(`INSERT INTO camera_motion_settings(?s).*ON CONFLICT \(id\) DO UPDATE`).
+			WithArgs(settings.UUID, settings.Threshold, settings.MinArea, settings.WarmupFrames, settings.TickMs, settings.CooldownMs,
+				settings.PreRollMs, settings.PostRollMs, settings.MaxEventMs, settings.Source).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+		if err := repo.Upsert(ctx, settings); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("no rows affected - should return ErrNoRowsAffected", func(t *testing.T) {
+		mock.ExpectExec(`INSERT INTO camera_motion_settings(?s).*`).
+			WithArgs(settings.UUID, settings.Threshold, settings.MinArea, settings.WarmupFrames, settings.TickMs, settings.CooldownMs,
+				settings.PreRollMs, settings.PostRollMs, settings.MaxEventMs, settings.Source).
+			WillReturnResult(pgxmock.NewResult("INSERT", 0))
+
+		if err := repo.Upsert(ctx, settings); !errors.Is(err, ErrNoRowsAffected) {
+			t.Fatalf("expected ErrNoRowsAffected, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+type MotionZonesRepoIface interface {
+	FindByCamera(ctx context.Context, camUUID string) ([]*models.MotionZone, error)
+	Replace(ctx context.Context, camUUID string, zones []*models.MotionZone) error
+}
+
+type PgxMotionZonesRepo struct {
+	DB DBPoolIface
+}
+
+func NewPgxMotionZonesRepo(db DBPoolIface) *PgxMotionZonesRepo {
+	return &PgxMotionZonesRepo{
+		DB: db,
+	}
+}
+
+func (repo *PgxMotionZonesRepo) FindByCamera(ctx context.Context, camUUID string) ([]*models.MotionZone, error) {
+	zones := []*models.MotionZone{}
+	if err := pgxscan.Select(ctx,
+		repo.DB,
+		&zones,
+		`SELECT id, cam_id, name, kind, points
+			FROM camera_motion_zones
+			WHERE cam_id = $1
+			ORDER BY created_at, id`,
+		camUUID); err != nil {
+		return nil, err
+	}
+
+	for _, zone := range zones {
+		if err := json.Unmarshal(zone.PointsRaw, &zone.Points); err != nil {
+			return nil, err
+		}
+	}
+
+	return zones, nil
+}
+
+// Replace swaps all the zones of the camera in a single transaction.
+func (repo *PgxMotionZonesRepo) Replace(ctx context.Context, camUUID string, zones []*models.MotionZone) error {
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM camera_motion_zones WHERE cam_id = $1`, camUUID); err != nil {
+		return err
+	}
+
+	for _, zone := range zones {
+		pointsBytes, err := json.Marshal(zone.Points)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO camera_motion_zones (cam_id, name, kind, points)
+				VALUES ($1, $2, $3, $4)`,
+			camUUID,
+			zone.Name,
+			zone.Kind,
+			pointsBytes); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
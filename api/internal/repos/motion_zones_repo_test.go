@@ -0,0 +1,112 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	"tomerab.com/cam-hub/internal/zones"
+)
+
+func setupMotionZonesRepoTest(t *testing.T) (*PgxMotionZonesRepo, pgxmock.PgxPoolIface, context.Context) {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create mock pool: %v", err)
+	}
+
+	t.Cleanup(func() {
+		mock.Close()
+	})
+
+	return NewPgxMotionZonesRepo(mock), mock, context.Background()
+}
+
+func makeMotionZone(camUUID string) *models.MotionZone {
+	return &models.MotionZone{
+		CamUUID: camUUID,
+		Name:    "driveway",
+		Kind:    zones.KindInclude,
+		Points:  []zones.Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}},
+	}
+}
+
+const motionZonePointsJSON = `[{"x":0,"y":0},{"x":1,"y":0},{"x":1,"y":1}]`
+
+func TestMotionZonesFindByCamera(t *testing.T) {
+	repo, mock, ctx := setupMotionZonesRepoTest(t)
+
+	t.Run("zones exist - should return them with decoded points", func(t *testing.T) {
+		expected := makeMotionZone("cam")
+		expected.Id = "1"
+		mock.ExpectQuery(`SELECT .* FROM camera_motion_zones\s+WHERE cam_id = \$1`).
+			WithArgs("cam").
+			WillReturnRows(pgxmock.NewRows([]string{"id", "cam_id", "name", "kind", "points"}).
+				AddRow(expected.Id, expected.CamUUID, expected.Name, expected.Kind, []byte(motionZonePointsJSON)))
+
+		got, err := repo.FindByCamera(ctx, "cam")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("expected 1 zone, got %d", len(got))
+		}
+
+		got[0].PointsRaw = nil
+		if !reflect.DeepEqual(expected, got[0]) {
+			t.Errorf("expected: %v, got: %v", expected, got[0])
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestMotionZonesReplace(t *testing.T) {
+	repo, mock, ctx := setupMotionZonesRepoTest(t)
+
+	t.Run("replace zones - should delete and insert in one transaction", func(t *testing.T) {
+		zone := makeMotionZone("cam")
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM camera_motion_zones WHERE cam_id = \$1`).
+			WithArgs("cam").
+			WillReturnResult(pgxmock.NewResult("DELETE", 2))
+		mock.ExpectExec(`INSERT INTO camera_motion_zones(?s).*`).
+			WithArgs("cam", zone.Name, zone.Kind, []byte(motionZonePointsJSON)).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		mock.ExpectCommit()
+
+		if err := repo.Replace(ctx, "cam", []*models.MotionZone{zone}); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("insert fails - should roll back", func(t *testing.T) {
+		zone := makeMotionZone("cam")
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM camera_motion_zones WHERE cam_id = \$1`).
+			WithArgs("cam").
+			WillReturnResult(pgxmock.NewResult("DELETE", 0))
+		mock.ExpectExec(`INSERT INTO camera_motion_zones(?s).*`).
+			WithArgs("cam", zone.Name, zone.Kind, []byte(motionZonePointsJSON)).
+			WillReturnError(errors.New("db error"))
+		mock.ExpectRollback()
+
+		if err := repo.Replace(ctx, "cam", []*models.MotionZone{zone}); err == nil {
+			t.Fatalf("expected err, got nil")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
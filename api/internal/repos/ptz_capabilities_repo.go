@@ -0,0 +1,87 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+var ErrPtzCapabilitiesNotFound = errors.New("ptz capabilities not found")
+
+type PtzCapabilitiesRepoIface interface {
+	FindOne(ctx context.Context, camUUID string) (*models.PtzCapabilities, error)
+	Upsert(ctx context.Context, caps *models.PtzCapabilities) error
+	UpsertTx(ctx context.Context, tx pgx.Tx, caps *models.PtzCapabilities) error
+}
+
+type PgxPtzCapabilitiesRepo struct {
+	DB DBPoolIface
+}
+
+func NewPgxPtzCapabilitiesRepo(db DBPoolIface) *PgxPtzCapabilitiesRepo {
+	return &PgxPtzCapabilitiesRepo{
+		DB: db,
+	}
+}
+
+func (repo *PgxPtzCapabilitiesRepo) FindOne(ctx context.Context, camUUID string) (*models.PtzCapabilities, error) {
+	var caps models.PtzCapabilities
+	if err := pgxscan.Get(ctx,
+		repo.DB,
+		&caps,
+		`SELECT cam_id, supported, spaces, max_presets, home_supported, min_timeout_ms, max_timeout_ms, updated_at
+			FROM ptz_capabilities
+			WHERE cam_id = $1`,
+		camUUID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPtzCapabilitiesNotFound
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(caps.SpacesRaw, &caps.Spaces); err != nil {
+		return nil, err
+	}
+
+	return &caps, nil
+}
+
+func (repo *PgxPtzCapabilitiesRepo) Upsert(ctx context.Context, caps *models.PtzCapabilities) error {
+	return upsertPtzCapabilities(ctx, repo.DB, caps)
+}
+
+// UpsertTx stores the capabilities as part of the pairing transaction.
+func (repo *PgxPtzCapabilitiesRepo) UpsertTx(ctx context.Context, tx pgx.Tx, caps *models.PtzCapabilities) error {
+	return upsertPtzCapabilities(ctx, tx, caps)
+}
+
+func upsertPtzCapabilities(ctx context.Context, db DBPoolIface, caps *models.PtzCapabilities) error {
+	spacesBytes, err := json.Marshal(caps.Spaces)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(ctx,
+		`INSERT INTO ptz_capabilities (cam_id, supported, spaces, max_presets, home_supported, min_timeout_ms, max_timeout_ms)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (cam_id) DO UPDATE SET
+				supported = EXCLUDED.supported,
+				spaces = EXCLUDED.spaces,
+				max_presets = EXCLUDED.max_presets,
+				home_supported = EXCLUDED.home_supported,
+				min_timeout_ms = EXCLUDED.min_timeout_ms,
+				max_timeout_ms = EXCLUDED.max_timeout_ms,
+				updated_at = NOW()`,
+		caps.CamUUID,
+		caps.Supported,
+		spacesBytes,
+		caps.MaxPresets,
+		caps.HomeSupported,
+		caps.MinTimeoutMs,
+		caps.MaxTimeoutMs)
+	return err
+}
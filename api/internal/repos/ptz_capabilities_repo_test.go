@@ -0,0 +1,103 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+func setupPtzCapabilitiesRepoTest(t *testing.T) (*PgxPtzCapabilitiesRepo, pgxmock.PgxPoolIface, context.Context) {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create mock pool: %v", err)
+	}
+
+	t.Cleanup(func() {
+		mock.Close()
+	})
+
+	return NewPgxPtzCapabilitiesRepo(mock), mock, context.Background()
+}
+
+const ptzSpacesJSON = `{"absolute_pan_tilt":{"x":{"min":-1,"max":1},"y":{"min":-1,"max":1}},"zoom_speed":{"min":0,"max":1}}`
+
+func makePtzCapabilities() *models.PtzCapabilities {
+	minTimeout, maxTimeout := 1000, 600000
+	return &models.PtzCapabilities{
+		CamUUID:   "cam",
+		Supported: true,
+		Spaces: models.PtzSpaces{
+			AbsolutePanTilt: &models.PtzRange2D{X: models.PtzRange{Min: -1, Max: 1}, Y: models.PtzRange{Min: -1, Max: 1}},
+			ZoomSpeed:       &models.PtzRange{Min: 0, Max: 1},
+		},
+		MaxPresets:    128,
+		HomeSupported: true,
+		MinTimeoutMs:  &minTimeout,
+		MaxTimeoutMs:  &maxTimeout,
+	}
+}
+
+func TestPtzCapabilitiesFindOne(t *testing.T) {
+	repo, mock, ctx := setupPtzCapabilitiesRepoTest(t)
+
+	t.Run("capabilities exist - should decode the spaces", func(t *testing.T) {
+		expected := makePtzCapabilities()
+		expected.SpacesRaw = []byte(ptzSpacesJSON)
+		mock.ExpectQuery(`SELECT .* FROM ptz_capabilities\s+WHERE cam_id = \$1`).
+			WithArgs("cam").
+			WillReturnRows(pgxmock.NewRows([]string{"cam_id", "supported", "spaces", "max_presets", "home_supported", "min_timeout_ms", "max_timeout_ms", "updated_at"}).
+				AddRow(expected.CamUUID, expected.Supported, expected.SpacesRaw, expected.MaxPresets, expected.HomeSupported, expected.MinTimeoutMs, expected.MaxTimeoutMs, expected.UpdatedAt))
+
+		got, err := repo.FindOne(ctx, "cam")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %+v, got: %+v", expected, got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("missing capabilities - should return ErrPtzCapabilitiesNotFound", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT .* FROM ptz_capabilities\s+WHERE cam_id = \$1`).
+			WithArgs("missing").
+			WillReturnError(pgx.ErrNoRows)
+
+		if _, err := repo.FindOne(ctx, "missing"); !errors.Is(err, ErrPtzCapabilitiesNotFound) {
+			t.Fatalf("expected ErrPtzCapabilitiesNotFound, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestPtzCapabilitiesUpsert(t *testing.T) {
+	repo, mock, ctx := setupPtzCapabilitiesRepoTest(t)
+
+	t.Run("upsert capabilities - should store the spaces as json", func(t *testing.T) {
+		caps := makePtzCapabilities()
+		mock.ExpectExec(`INSERT INTO ptz_capabilities(?s).*ON CONFLICT \(cam_id\) DO UPDATE`).
+			WithArgs("cam", true, []byte(ptzSpacesJSON), 128, true, caps.MinTimeoutMs, caps.MaxTimeoutMs).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+		if err := repo.Upsert(ctx, caps); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
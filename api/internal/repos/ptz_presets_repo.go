@@ -0,0 +1,78 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+type PtzPresetsRepoIface interface {
+	FindByCamera(ctx context.Context, camUUID string) ([]*models.PtzPreset, error)
+	Replace(ctx context.Context, camUUID string, presets []*models.PtzPreset) error
+	Delete(ctx context.Context, camUUID, token string) error
+}
+
+type PgxPtzPresetsRepo struct {
+	DB DBPoolIface
+}
+
+func NewPgxPtzPresetsRepo(db DBPoolIface) *PgxPtzPresetsRepo {
+	return &PgxPtzPresetsRepo{
+		DB: db,
+	}
+}
+
+func (repo *PgxPtzPresetsRepo) FindByCamera(ctx context.Context, camUUID string) ([]*models.PtzPreset, error) {
+	presets := []*models.PtzPreset{}
+	if err := pgxscan.Select(ctx,
+		repo.DB,
+		&presets,
+		`SELECT cam_id, token, name, pan, tilt, zoom
+			FROM ptz_presets
+			WHERE cam_id = $1
+			ORDER BY name, token`,
+		camUUID); err != nil {
+		return nil, err
+	}
+
+	return presets, nil
+}
+
+// Replace swaps all the cached presets of the camera in a single transaction.
+func (repo *PgxPtzPresetsRepo) Replace(ctx context.Context, camUUID string, presets []*models.PtzPreset) error {
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM ptz_presets WHERE cam_id = $1`, camUUID); err != nil {
+		return err
+	}
+
+	for _, preset := range presets {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO ptz_presets (cam_id, token, name, pan, tilt, zoom)
+				VALUES ($1, $2, $3, $4, $5, $6)`,
+			camUUID,
+			preset.Token,
+			preset.Name,
+			preset.Pan,
+			preset.Tilt,
+			preset.Zoom); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (repo *PgxPtzPresetsRepo) Delete(ctx context.Context, camUUID, token string) error {
+	_, err := repo.DB.Exec(ctx,
+		`DELETE FROM ptz_presets WHERE cam_id = $1 AND token = $2`,
+		camUUID,
+		token)
+	return err
+}
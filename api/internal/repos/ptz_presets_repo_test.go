@@ -0,0 +1,124 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+func setupPtzPresetsRepoTest(t *testing.T) (*PgxPtzPresetsRepo, pgxmock.PgxPoolIface, context.Context) {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create mock pool: %v", err)
+	}
+
+	t.Cleanup(func() {
+		mock.Close()
+	})
+
+	return NewPgxPtzPresetsRepo(mock), mock, context.Background()
+}
+
+func makePtzPreset(camUUID string) *models.PtzPreset {
+	pan, tilt, zoom := 0.25, -0.5, 0.0
+	return &models.PtzPreset{
+		CamUUID: camUUID,
+		Token:   "1",
+		Name:    "gate",
+		Pan:     &pan,
+		Tilt:    &tilt,
+		Zoom:    &zoom,
+	}
+}
+
+func TestPtzPresetsFindByCamera(t *testing.T) {
+	repo, mock, ctx := setupPtzPresetsRepoTest(t)
+
+	t.Run("presets exist - should return them", func(t *testing.T) {
+		expected := makePtzPreset("cam")
+		mock.ExpectQuery(`SELECT .* FROM ptz_presets\s+WHERE cam_id = \$1`).
+			WithArgs("cam").
+			WillReturnRows(pgxmock.NewRows([]string{"cam_id", "token", "name", "pan", "tilt", "zoom"}).
+				AddRow(expected.CamUUID, expected.Token, expected.Name, expected.Pan, expected.Tilt, expected.Zoom))
+
+		got, err := repo.FindByCamera(ctx, "cam")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if len(got) != 1 || !reflect.DeepEqual(expected, got[0]) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestPtzPresetsReplace(t *testing.T) {
+	repo, mock, ctx := setupPtzPresetsRepoTest(t)
+
+	t.Run("replace presets - should delete and insert in one transaction", func(t *testing.T) {
+		preset := makePtzPreset("cam")
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM ptz_presets WHERE cam_id = \$1`).
+			WithArgs("cam").
+			WillReturnResult(pgxmock.NewResult("DELETE", 3))
+		mock.ExpectExec(`INSERT INTO ptz_presets(?s).*`).
+			WithArgs("cam", preset.Token, preset.Name, preset.Pan, preset.Tilt, preset.Zoom).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		mock.ExpectCommit()
+
+		if err := repo.Replace(ctx, "cam", []*models.PtzPreset{preset}); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("insert fails - should roll back", func(t *testing.T) {
+		preset := makePtzPreset("cam")
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM ptz_presets WHERE cam_id = \$1`).
+			WithArgs("cam").
+			WillReturnResult(pgxmock.NewResult("DELETE", 0))
+		mock.ExpectExec(`INSERT INTO ptz_presets(?s).*`).
+			WithArgs("cam", preset.Token, preset.Name, preset.Pan, preset.Tilt, preset.Zoom).
+			WillReturnError(errors.New("db error"))
+		mock.ExpectRollback()
+
+		if err := repo.Replace(ctx, "cam", []*models.PtzPreset{preset}); err == nil {
+			t.Fatalf("expected err, got nil")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestPtzPresetsDelete(t *testing.T) {
+	repo, mock, ctx := setupPtzPresetsRepoTest(t)
+
+	t.Run("delete preset - should delete the camera's preset", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM ptz_presets WHERE cam_id = \$1 AND token = \$2`).
+			WithArgs("cam", "1").
+			WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+		if err := repo.Delete(ctx, "cam", "1"); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+var (
+	ErrTourNotFound  = errors.New("ptz tour not found")
+	ErrTourNameTaken = errors.New("ptz tour name is already taken")
+)
+
+type PtzToursRepoIface interface {
+	Create(ctx context.Context, tour *models.PtzTour) error
+	FindOne(ctx context.Context, id string) (*models.PtzTour, error)
+	FindByCamera(ctx context.Context, camUUID string) ([]*models.PtzTour, error)
+	FindScheduled(ctx context.Context) ([]*models.PtzTour, error)
+	Update(ctx context.Context, tour *models.PtzTour) error
+	Delete(ctx context.Context, id string) error
+}
+
+type PgxPtzToursRepo struct {
+	DB DBPoolIface
+}
+
+func NewPgxPtzToursRepo(db DBPoolIface) *PgxPtzToursRepo {
+	return &PgxPtzToursRepo{
+		DB: db,
+	}
+}
+
+// Create inserts the tour under its id and fills its creation time.
+func (repo *PgxPtzToursRepo) Create(ctx context.Context, tour *models.PtzTour) error {
+	stopsBytes, err := json.Marshal(tour.Stops)
+	if err != nil {
+		return err
+	}
+
+	err = repo.DB.QueryRow(ctx,
+		`INSERT INTO ptz_tours (id, cam_id, name, stops, schedule, loops)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING created_at, updated_at`,
+		tour.Id,
+		tour.CamUUID,
+		tour.Name,
+		stopsBytes,
+		tour.Schedule,
+		tour.Loops).Scan(&tour.CreatedAt, &tour.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrTourNameTaken
+	}
+
+	return err
+}
+
+func (repo *PgxPtzToursRepo) FindOne(ctx context.Context, id string) (*models.PtzTour, error) {
+	var tour models.PtzTour
+	if err := pgxscan.Get(ctx,
+		repo.DB,
+		&tour,
+		`SELECT id, cam_id, name, stops, schedule, loops, created_at, updated_at
+			FROM ptz_tours
+			WHERE id = $1`,
+		id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTourNotFound
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(tour.StopsRaw, &tour.Stops); err != nil {
+		return nil, err
+	}
+
+	return &tour, nil
+}
+
+func (repo *PgxPtzToursRepo) FindByCamera(ctx context.Context, camUUID string) ([]*models.PtzTour, error) {
+	return repo.findMany(ctx,
+		`SELECT id, cam_id, name, stops, schedule, loops, created_at, updated_at
+			FROM ptz_tours
+			WHERE cam_id = $1
+			ORDER BY name`,
+		camUUID)
+}
+
+// FindScheduled returns the tours of all the cameras that have a schedule.
+func (repo *PgxPtzToursRepo) FindScheduled(ctx context.Context) ([]*models.PtzTour, error) {
+	return repo.findMany(ctx,
+		`SELECT id, cam_id, name, stops, schedule, loops, created_at, updated_at
+			FROM ptz_tours
+			WHERE schedule IS NOT NULL
+			ORDER BY cam_id, name`)
+}
+
+func (repo *PgxPtzToursRepo) findMany(ctx context.Context, query string, args ...any) ([]*models.PtzTour, error) {
+	tours := []*models.PtzTour{}
+	if err := pgxscan.Select(ctx, repo.DB, &tours, query, args...); err != nil {
+		return nil, err
+	}
+
+	for _, tour := range tours {
+		if err := json.Unmarshal(tour.StopsRaw, &tour.Stops); err != nil {
+			return nil, err
+		}
+	}
+
+	return tours, nil
+}
+
+// Update stores the tour's name, stops, schedule and loops.
+func (repo *PgxPtzToursRepo) Update(ctx context.Context, tour *models.PtzTour) error {
+	stopsBytes, err := json.Marshal(tour.Stops)
+	if err != nil {
+		return err
+	}
+
+	err = repo.DB.QueryRow(ctx,
+		`UPDATE ptz_tours
+			SET name = $2, stops = $3, schedule = $4, loops = $5, updated_at = NOW()
+			WHERE id = $1
+			RETURNING updated_at`,
+		tour.Id,
+		tour.Name,
+		stopsBytes,
+		tour.Schedule,
+		tour.Loops).Scan(&tour.UpdatedAt)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return ErrTourNotFound
+	case isUniqueViolation(err):
+		return ErrTourNameTaken
+	}
+
+	return err
+}
+
+func (repo *PgxPtzToursRepo) Delete(ctx context.Context, id string) error {
+	tag, err := repo.DB.Exec(ctx, `DELETE FROM ptz_tours WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrTourNotFound
+	}
+
+	return nil
+}
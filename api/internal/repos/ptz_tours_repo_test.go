@@ -0,0 +1,157 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+func setupPtzToursRepoTest(t *testing.T) (*PgxPtzToursRepo, pgxmock.PgxPoolIface, context.Context) {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create mock pool: %v", err)
+	}
+
+	t.Cleanup(func() {
+		mock.Close()
+	})
+
+	return NewPgxPtzToursRepo(mock), mock, context.Background()
+}
+
+func makePtzTour() *models.PtzTour {
+	schedule := "0 * * * *"
+	return &models.PtzTour{
+		Id:       "tour",
+		CamUUID:  "cam",
+		Name:     "perimeter",
+		Stops:    []models.PtzTourStop{{Preset: "gate", DwellMs: 10000}, {Preset: "yard", DwellMs: 5000}},
+		Schedule: &schedule,
+		Loops:    1,
+	}
+}
+
+const ptzTourStopsJSON = `[{"preset":"gate","dwell_ms":10000},{"preset":"yard","dwell_ms":5000}]`
+
+func TestPtzToursCreate(t *testing.T) {
+	repo, mock, ctx := setupPtzToursRepoTest(t)
+
+	t.Run("new tour - should store the stops as json", func(t *testing.T) {
+		createdAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		tour := makePtzTour()
+		mock.ExpectQuery(`INSERT INTO ptz_tours(?s).*RETURNING created_at, updated_at`).
+			WithArgs("tour", "cam", "perimeter", []byte(ptzTourStopsJSON), tour.Schedule, 1).
+			WillReturnRows(pgxmock.NewRows([]string{"created_at", "updated_at"}).AddRow(createdAt, createdAt))
+
+		if err := repo.Create(ctx, tour); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if !tour.CreatedAt.Equal(createdAt) {
+			t.Errorf("expected created_at to be filled, got: %+v", tour)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("duplicate name - should return ErrTourNameTaken", func(t *testing.T) {
+		tour := makePtzTour()
+		mock.ExpectQuery(`INSERT INTO ptz_tours`).
+			WithArgs("tour", "cam", "perimeter", []byte(ptzTourStopsJSON), tour.Schedule, 1).
+			WillReturnError(&pgconn.PgError{Code: pgUniqueViolation})
+
+		if err := repo.Create(ctx, tour); !errors.Is(err, ErrTourNameTaken) {
+			t.Fatalf("expected ErrTourNameTaken, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestPtzToursFindOne(t *testing.T) {
+	repo, mock, ctx := setupPtzToursRepoTest(t)
+
+	t.Run("tour exists - should decode its stops", func(t *testing.T) {
+		expected := makePtzTour()
+		expected.StopsRaw = []byte(ptzTourStopsJSON)
+		mock.ExpectQuery(`SELECT .* FROM ptz_tours\s+WHERE id = \$1`).
+			WithArgs("tour").
+			WillReturnRows(pgxmock.NewRows([]string{"id", "cam_id", "name", "stops", "schedule", "loops", "created_at", "updated_at"}).
+				AddRow(expected.Id, expected.CamUUID, expected.Name, expected.StopsRaw, expected.Schedule, expected.Loops, expected.CreatedAt, expected.UpdatedAt))
+
+		got, err := repo.FindOne(ctx, "tour")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %+v, got: %+v", expected, got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("missing tour - should return ErrTourNotFound", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT .* FROM ptz_tours\s+WHERE id = \$1`).
+			WithArgs("missing").
+			WillReturnError(pgx.ErrNoRows)
+
+		if _, err := repo.FindOne(ctx, "missing"); !errors.Is(err, ErrTourNotFound) {
+			t.Fatalf("expected ErrTourNotFound, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestPtzToursUpdate(t *testing.T) {
+	repo, mock, ctx := setupPtzToursRepoTest(t)
+
+	t.Run("missing tour - should return ErrTourNotFound", func(t *testing.T) {
+		tour := makePtzTour()
+		mock.ExpectQuery(`UPDATE ptz_tours(?s).*WHERE id = \$1`).
+			WithArgs("tour", "perimeter", []byte(ptzTourStopsJSON), tour.Schedule, 1).
+			WillReturnError(pgx.ErrNoRows)
+
+		if err := repo.Update(ctx, tour); !errors.Is(err, ErrTourNotFound) {
+			t.Fatalf("expected ErrTourNotFound, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestPtzToursDelete(t *testing.T) {
+	repo, mock, ctx := setupPtzToursRepoTest(t)
+
+	t.Run("missing tour - should return ErrTourNotFound", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM ptz_tours WHERE id = \$1`).
+			WithArgs("missing").
+			WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+		if err := repo.Delete(ctx, "missing"); !errors.Is(err, ErrTourNotFound) {
+			t.Fatalf("expected ErrTourNotFound, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package repos
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+type RecordingSegmentsRepoIface interface {
+	Insert(ctx context.Context, seg *models.RecordingSegment) error
+	FindSpans(ctx context.Context, camUUID string, from, to time.Time, maxGap time.Duration) ([]*models.RecordingSpan, error)
+	DeleteByCamera(ctx context.Context, camUUID string) (int64, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type PgxRecordingSegmentsRepo struct {
+	DB DBPoolIface
+}
+
+func NewPgxRecordingSegmentsRepo(db DBPoolIface) *PgxRecordingSegmentsRepo {
+	return &PgxRecordingSegmentsRepo{
+		DB: db,
+	}
+}
+
+// Insert ignores segments that were already indexed, mediamtx may run the hook again for the
+// same file after a restart.
+func (repo *PgxRecordingSegmentsRepo) Insert(ctx context.Context, seg *models.RecordingSegment) error {
+	_, err := repo.DB.Exec(ctx,
+		`INSERT INTO recording_segments (cam_id, mtx_path, file_name, start_ts, end_ts)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (mtx_path, file_name) DO NOTHING`,
+		seg.CamUUID,
+		seg.MtxPath,
+		seg.FileName,
+		seg.StartTs.UTC(),
+		seg.EndTs.UTC())
+
+	return err
+}
+
+// FindSpans merges the segments overlapping [from, to) into the spans of the timeline, a new
+// span starts whenever the gap to the previous segment of the same path is above maxGap.
+func (repo *PgxRecordingSegmentsRepo) FindSpans(ctx context.Context, camUUID string, from, to time.Time, maxGap time.Duration) ([]*models.RecordingSpan, error) {
+	spans := []*models.RecordingSpan{}
+	if err := pgxscan.Select(ctx,
+		repo.DB,
+		&spans,
+		`SELECT mtx_path, MIN(start_ts) AS start_ts, MAX(end_ts) AS end_ts
+			FROM (
+				SELECT mtx_path, start_ts, end_ts,
+					SUM(new_span) OVER (PARTITION BY mtx_path ORDER BY start_ts) AS span
+				FROM (
+					SELECT mtx_path, start_ts, end_ts,
+						CASE WHEN start_ts - LAG(end_ts) OVER (PARTITION BY mtx_path ORDER BY start_ts)
+							<= $4 * INTERVAL '1 millisecond' THEN 0 ELSE 1 END AS new_span
+					FROM recording_segments
+					WHERE cam_id = $1 AND end_ts > $2 AND start_ts < $3
+				) marked
+			) grouped
+			GROUP BY mtx_path, span
+			ORDER BY start_ts`,
+		camUUID,
+		from.UTC(),
+		to.UTC(),
+		maxGap.Milliseconds()); err != nil {
+		return nil, err
+	}
+
+	return spans, nil
+}
+
+func (repo *PgxRecordingSegmentsRepo) DeleteByCamera(ctx context.Context, camUUID string) (int64, error) {
+	tag, err := repo.DB.Exec(ctx, `DELETE FROM recording_segments WHERE cam_id = $1`, camUUID)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// DeleteExpired drops the segments mediamtx already deleted, those older than the camera's
+// retention and those of cameras that stopped recording continuously.
+func (repo *PgxRecordingSegmentsRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := repo.DB.Exec(ctx,
+		`DELETE FROM recording_segments s
+			USING camera_recording_settings c
+			WHERE s.cam_id = c.id
+				AND (NOT c.continuous OR s.end_ts < $1 - (c.retention_days * INTERVAL '1 day'))`,
+		now.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,140 @@
+package repos
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+func setupRecordingSegmentsRepoTest(t *testing.T) (*PgxRecordingSegmentsRepo, pgxmock.PgxPoolIface, context.Context) {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create mock pool: %v", err)
+	}
+
+	t.Cleanup(func() {
+		mock.Close()
+	})
+
+	return NewPgxRecordingSegmentsRepo(mock), mock, context.Background()
+}
+
+func TestRecordingSegmentsInsert(t *testing.T) {
+	repo, mock, ctx := setupRecordingSegmentsRepoTest(t)
+	start := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	seg := &models.RecordingSegment{
+		CamUUID:  "cam",
+		MtxPath:  "cam/main",
+		FileName: "2026-10-17_12-00-00-000000.mp4",
+		StartTs:  start,
+		EndTs:    start.Add(time.Second),
+	}
+
+	t.Run("new segment - should be inserted once", func(t *testing.T) {
+		mock.ExpectExec(`INSERT INTO recording_segments(?s).*ON CONFLICT \(mtx_path, file_name\) DO NOTHING`).
+			WithArgs(seg.CamUUID, seg.MtxPath, seg.FileName, seg.StartTs, seg.EndTs).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+		if err := repo.Insert(ctx, seg); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("segment already indexed - should not fail", func(t *testing.T) {
+		mock.ExpectExec(`INSERT INTO recording_segments(?s).*`).
+			WithArgs(seg.CamUUID, seg.MtxPath, seg.FileName, seg.StartTs, seg.EndTs).
+			WillReturnResult(pgxmock.NewResult("INSERT", 0))
+
+		if err := repo.Insert(ctx, seg); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestRecordingSegmentsFindSpans(t *testing.T) {
+	repo, mock, ctx := setupRecordingSegmentsRepoTest(t)
+	from := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	t.Run("segments in range - should return the merged spans", func(t *testing.T) {
+		expected := []*models.RecordingSpan{
+			{MtxPath: "cam/main", StartTs: from.Add(time.Hour), EndTs: from.Add(2 * time.Hour)},
+			{MtxPath: "cam/main", StartTs: from.Add(3 * time.Hour), EndTs: from.Add(4 * time.Hour)},
+		}
+		rows := pgxmock.NewRows([]string{"mtx_path", "start_ts", "end_ts"})
+		for _, span := range expected {
+			rows.AddRow(span.MtxPath, span.StartTs, span.EndTs)
+		}
+		mock.ExpectQuery(`SELECT mtx_path, MIN\(start_ts\)(?s).*FROM recording_segments(?s).*GROUP BY mtx_path, span`).
+			WithArgs("cam", from, to, int64(2000)).
+			WillReturnRows(rows)
+
+		got, err := repo.FindSpans(ctx, "cam", from, to, 2*time.Second)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("no segments - should return an empty list", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT mtx_path, MIN\(start_ts\)(?s).*`).
+			WithArgs("cam", from, to, int64(2000)).
+			WillReturnRows(pgxmock.NewRows([]string{"mtx_path", "start_ts", "end_ts"}))
+
+		got, err := repo.FindSpans(ctx, "cam", from, to, 2*time.Second)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if got == nil || len(got) != 0 {
+			t.Errorf("expected an empty list, got: %v", got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestRecordingSegmentsDeleteExpired(t *testing.T) {
+	repo, mock, ctx := setupRecordingSegmentsRepoTest(t)
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	t.Run("expired segments - should return the deleted count", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM recording_segments s\s+USING camera_recording_settings c`).
+			WithArgs(now).
+			WillReturnResult(pgxmock.NewResult("DELETE", 42))
+
+		deleted, err := repo.DeleteExpired(ctx, now)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if deleted != 42 {
+			t.Errorf("expected 42 deleted, got: %d", deleted)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
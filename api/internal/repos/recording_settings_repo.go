@@ -0,0 +1,67 @@
+package repos
+
+import (
+	"context"
+	"errors"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+var ErrRecordingSettingsNotFound = errors.New("recording settings not found")
+
+type RecordingSettingsRepoIface interface {
+	FindOne(ctx context.Context, uuid string) (*models.RecordingSettings, error)
+	Upsert(ctx context.Context, settings *models.RecordingSettings) error
+}
+
+type PgxRecordingSettingsRepo struct {
+	DB DBPoolIface
+}
+
+func NewPgxRecordingSettingsRepo(db DBPoolIface) *PgxRecordingSettingsRepo {
+	return &PgxRecordingSettingsRepo{
+		DB: db,
+	}
+}
+
+func (repo *PgxRecordingSettingsRepo) FindOne(ctx context.Context, uuid string) (*models.RecordingSettings, error) {
+	var settings models.RecordingSettings
+	if err := pgxscan.Get(ctx,
+		repo.DB,
+		&settings,
+		`SELECT id, continuous, retention_days
+			FROM camera_recording_settings
+			WHERE id = $1`,
+		uuid); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordingSettingsNotFound
+		}
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (repo *PgxRecordingSettingsRepo) Upsert(ctx context.Context, settings *models.RecordingSettings) error {
+	tag, err := repo.DB.Exec(ctx,
+		`INSERT INTO camera_recording_settings (id, continuous, retention_days)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (id) DO UPDATE SET
+				continuous = EXCLUDED.continuous,
+				retention_days = EXCLUDED.retention_days,
+				updated_at = NOW()`,
+		settings.UUID,
+		settings.Continuous,
+		settings.RetentionDays)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRowsAffected
+	}
+
+	return nil
+}
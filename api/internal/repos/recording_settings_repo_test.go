@@ -0,0 +1,100 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+func setupRecordingSettingsRepoTest(t *testing.T) (*PgxRecordingSettingsRepo, pgxmock.PgxPoolIface, context.Context) {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create mock pool: %v", err)
+	}
+
+	t.Cleanup(func() {
+		mock.Close()
+	})
+
+	return NewPgxRecordingSettingsRepo(mock), mock, context.Background()
+}
+
+func TestRecordingSettingsFindOne(t *testing.T) {
+	repo, mock, ctx := setupRecordingSettingsRepoTest(t)
+
+	t.Run("settings exist - should return them", func(t *testing.T) {
+		expected := &models.RecordingSettings{UUID: "1", Continuous: true, RetentionDays: 14}
+		mock.ExpectQuery(`SELECT .* FROM camera_recording_settings\s+WHERE id = \$1`).
+			WithArgs("1").
+			WillReturnRows(pgxmock.NewRows([]string{"id", "continuous", "retention_days"}).
+				AddRow(expected.UUID, expected.Continuous, expected.RetentionDays))
+
+		got, err := repo.FindOne(ctx, "1")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("settings do not exist - should return ErrRecordingSettingsNotFound", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT .* FROM camera_recording_settings\s+WHERE id = \$1`).
+			WithArgs("missing").
+			WillReturnError(pgx.ErrNoRows)
+
+		_, err := repo.FindOne(ctx, "missing")
+		if !errors.Is(err, ErrRecordingSettingsNotFound) {
+			t.Fatalf("expected ErrRecordingSettingsNotFound, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestRecordingSettingsUpsert(t *testing.T) {
+	repo, mock, ctx := setupRecordingSettingsRepoTest(t)
+	settings := &models.RecordingSettings{UUID: "1", Continuous: true, RetentionDays: 14}
+
+	t.Run("upsert settings - should succeed", func(t *testing.T) {
+		mock.ExpectExec(`INSERT INTO camera_recording_settings(?s).*ON CONFLICT \(id\) DO UPDATE`).
+			WithArgs(settings.UUID, settings.Continuous, settings.RetentionDays).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+		if err := repo.Upsert(ctx, settings); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("no rows affected - should return ErrNoRowsAffected", func(t *testing.T) {
+		mock.ExpectExec(`INSERT INTO camera_recording_settings(?s).*`).
+			WithArgs(settings.UUID, settings.Continuous, settings.RetentionDays).
+			WillReturnResult(pgxmock.NewResult("INSERT", 0))
+
+		if err := repo.Upsert(ctx, settings); !errors.Is(err, ErrNoRowsAffected) {
+			t.Fatalf("expected ErrNoRowsAffected, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
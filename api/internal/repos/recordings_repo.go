@@ -3,13 +3,29 @@ package repos
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
 	"tomerab.com/cam-hub/internal/api/v1/models"
 )
 
+var (
+	ErrRecordingNotFound = errors.New("recording not found")
+)
+
+const recordingsColumns = `id, cam_id, bucket_name, vid_key, COALESCE(best_frame_key, '') AS best_frame_key,
+	evidence, score, state, needs_publish, promoted_at, retention_days, start_ts, end_ts`
+
 type RecordingsRepoIface interface {
 	Upsert(ctx context.Context, rec *models.Recordings) (*models.Recordings, error)
+	FindOne(ctx context.Context, id string) (*models.Recordings, error)
+	FindMany(ctx context.Context, filter *models.RecordingsFilter) ([]*models.Recordings, error)
+	FindExpired(ctx context.Context, now time.Time, limit int) ([]*models.Recordings, error)
+	DeleteMany(ctx context.Context, ids []string) (int64, error)
 }
 
 type PgxRecordingsRepo struct {
@@ -25,7 +41,12 @@ func NewPgxRecordingsRepo(db DBPoolIface) *PgxRecordingsRepo {
 func (repo *PgxRecordingsRepo) Upsert(ctx context.Context, rec *models.Recordings) (*models.Recordings, error) {
 	var out models.Recordings
 
-	evidenceBytes, err := json.Marshal(rec.Evidence)
+	evidence := rec.Evidence
+	if evidence == nil {
+		evidence = []models.Evidence{}
+	}
+
+	evidenceBytes, err := json.Marshal(evidence)
 	if err != nil {
 		return nil, err
 	}
@@ -72,3 +93,121 @@ func (repo *PgxRecordingsRepo) Upsert(ctx context.Context, rec *models.Recording
 
 	return &out, nil
 }
+
+func (repo *PgxRecordingsRepo) FindOne(ctx context.Context, id string) (*models.Recordings, error) {
+	var rec models.Recordings
+	if err := pgxscan.Get(ctx, repo.DB, &rec,
+		`SELECT `+recordingsColumns+` FROM recordings WHERE id = $1`, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordingNotFound
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(rec.EvidenceRaw, &rec.Evidence); err != nil {
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+func (repo *PgxRecordingsRepo) FindMany(ctx context.Context, filter *models.RecordingsFilter) ([]*models.Recordings, error) {
+	q, args := buildRecordingsQuery(filter)
+
+	var recs []*models.Recordings
+	if err := pgxscan.Select(ctx, repo.DB, &recs, q, args...); err != nil {
+		return nil, err
+	}
+
+	for _, rec := range recs {
+		if err := json.Unmarshal(rec.EvidenceRaw, &rec.Evidence); err != nil {
+			return nil, err
+		}
+	}
+
+	return recs, nil
+}
+
+// buildRecordingsQuery only adds the predicates that were requested, the
+// placeholders are numbered in the order the arguments are appended.
+func buildRecordingsQuery(filter *models.RecordingsFilter) (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+	addCond := func(cond string, vals ...any) {
+		for _, val := range vals {
+			args = append(args, val)
+			cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		conds = append(conds, cond)
+	}
+
+	if filter.CamUUID != "" {
+		addCond("cam_id = ?", filter.CamUUID)
+	}
+	if filter.State != "" {
+		addCond("state = ?", filter.State)
+	}
+	if filter.MinScore != nil {
+		addCond("score >= ?", *filter.MinScore)
+	}
+	if filter.MaxScore != nil {
+		addCond("score <= ?", *filter.MaxScore)
+	}
+	// The window keeps the clips that overlap it
+	if filter.From != nil {
+		addCond("end_ts >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		addCond("start_ts < ?", filter.To.UTC())
+	}
+	if filter.After != nil {
+		addCond("(promoted_at, id) < (?, ?)", filter.After.PromotedAt.UTC(), filter.After.Id)
+	}
+
+	q := `SELECT ` + recordingsColumns + ` FROM recordings`
+	if len(conds) > 0 {
+		q += " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	args = append(args, filter.Limit)
+	q += fmt.Sprintf(" ORDER BY promoted_at DESC, id DESC LIMIT $%d", len(args))
+
+	return q, args
+}
+
+// FindExpired returns the recordings whose retention period ended before now, oldest first.
+// The predicate matches the expression of ix_recordings_due so the index can be used.
+func (repo *PgxRecordingsRepo) FindExpired(ctx context.Context, now time.Time, limit int) ([]*models.Recordings, error) {
+	var recs []*models.Recordings
+	if err := pgxscan.Select(ctx, repo.DB, &recs,
+		`SELECT `+recordingsColumns+` FROM recordings
+			WHERE (promoted_at + (retention_days * INTERVAL '1 day')) <= $1
+			ORDER BY (promoted_at + (retention_days * INTERVAL '1 day'))
+			LIMIT $2`,
+		now.UTC(), limit); err != nil {
+		return nil, err
+	}
+
+	for _, rec := range recs {
+		if err := json.Unmarshal(rec.EvidenceRaw, &rec.Evidence); err != nil {
+			return nil, err
+		}
+	}
+
+	return recs, nil
+}
+
+func (repo *PgxRecordingsRepo) DeleteMany(ctx context.Context, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	tag, err := repo.DB.Exec(ctx, `DELETE FROM recordings WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -2,8 +2,12 @@ package repos
 
 import (
 	"context"
+	"errors"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/pashagolub/pgxmock/v4"
 	"tomerab.com/cam-hub/internal/api/v1/models"
 )
@@ -14,7 +18,7 @@ func makeRecording(id string, cameraUUID string) *models.Recordings {
 		CamUUID:       cameraUUID,
 		BucketName:    "media",
 		VidBucketKey:  "cam/" + cameraUUID + "/2025-09-30T12-00-00.mp4",
-		Evidence:      models.Evidence{Conf: 0.9, Xmin: 0, Ymin: 0, Xmax: 1, Ymax: 1},
+		Evidence:      []models.Evidence{{Conf: 0.9, Xmin: 0, Ymin: 0, Xmax: 1, Ymax: 1, Label: "person"}},
 		Score:         0.9,
 		State:         "promoted",
 		NeedsPublish:  true,
@@ -50,7 +54,7 @@ func TestUpsert(t *testing.T) {
 				recording.BucketName,
 				recording.VidBucketKey,
 				recording.BestFrameBucketKey,
-				recording.Evidence,
+				[]byte(`[{"conf":0.9,"x_min":0,"y_min":0,"x_max":1,"y_max":1,"label":"person","frame":0}]`),
 				recording.Score,
 				recording.State,
 				recording.NeedsPublish,
@@ -79,7 +83,7 @@ func TestUpsert(t *testing.T) {
 					recording.BucketName,
 					recording.VidBucketKey,
 					recording.BestFrameBucketKey,
-					[]byte(`{"conf":0.9,"x_min":0,"y_min":0,"x_max":1,"y_max":1}`),
+					[]byte(`[{"conf":0.9,"x_min":0,"y_min":0,"x_max":1,"y_max":1,"label":"person","frame":0}]`),
 					recording.Score,
 					recording.State,
 					recording.NeedsPublish,
@@ -107,7 +111,7 @@ func TestUpsert(t *testing.T) {
 				recording.BucketName,
 				recording.VidBucketKey,
 				recording.BestFrameBucketKey,
-				recording.Evidence,
+				[]byte(`[{"conf":0.9,"x_min":0,"y_min":0,"x_max":1,"y_max":1,"label":"person","frame":0}]`),
 				recording.Score,
 				recording.State,
 				recording.NeedsPublish,
@@ -136,7 +140,7 @@ func TestUpsert(t *testing.T) {
 					recording.BucketName,
 					recording.VidBucketKey,
 					recording.BestFrameBucketKey,
-					[]byte(`{"conf":0.9,"x_min":0,"y_min":0,"x_max":1,"y_max":1}`),
+					[]byte(`[{"conf":0.9,"x_min":0,"y_min":0,"x_max":1,"y_max":1,"label":"person","frame":0}]`),
 					recording.Score,
 					recording.State,
 					recording.NeedsPublish,
@@ -156,3 +160,197 @@ func TestUpsert(t *testing.T) {
 		}
 	})
 }
+
+func recordingRows(recs ...*models.Recordings) *pgxmock.Rows {
+	rows := pgxmock.NewRows([]string{
+		"id",
+		"cam_id",
+		"bucket_name",
+		"vid_key",
+		"best_frame_key",
+		"evidence",
+		"score",
+		"state",
+		"needs_publish",
+		"promoted_at",
+		"retention_days",
+		"start_ts",
+		"end_ts",
+	})
+
+	for _, rec := range recs {
+		rows.AddRow(
+			rec.Id,
+			rec.CamUUID,
+			rec.BucketName,
+			rec.VidBucketKey,
+			rec.BestFrameBucketKey,
+			[]byte(`[{"conf":0.9,"x_min":0,"y_min":0,"x_max":1,"y_max":1,"label":"person","frame":0}]`),
+			rec.Score,
+			rec.State,
+			rec.NeedsPublish,
+			rec.PromotedAt,
+			rec.RetentionDays,
+			rec.StartTs,
+			rec.EndTs,
+		)
+	}
+
+	return rows
+}
+
+func TestRecordingsFindOne(t *testing.T) {
+	repo, mock, ctx := setupRecordingsRepoTest(t)
+
+	t.Run("recording exists - should return it", func(t *testing.T) {
+		expected := makeRecording("1", "1")
+		mock.ExpectQuery(`SELECT .* FROM recordings WHERE id = \$1`).
+			WithArgs("1").
+			WillReturnRows(recordingRows(expected))
+
+		got, err := repo.FindOne(ctx, "1")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		got.EvidenceRaw = nil
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("recording does not exist - should return ErrRecordingNotFound", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT .* FROM recordings WHERE id = \$1`).
+			WithArgs("missing").
+			WillReturnError(pgx.ErrNoRows)
+
+		_, err := repo.FindOne(ctx, "missing")
+		if !errors.Is(err, ErrRecordingNotFound) {
+			t.Fatalf("expected ErrRecordingNotFound, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestRecordingsFindMany(t *testing.T) {
+	repo, mock, ctx := setupRecordingsRepoTest(t)
+
+	t.Run("no filters - should only limit", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT .* FROM recordings ORDER BY promoted_at DESC, id DESC LIMIT \$1`).
+			WithArgs(10).
+			WillReturnRows(recordingRows(makeRecording("1", "1"), makeRecording("2", "1")))
+
+		got, err := repo.FindMany(ctx, &models.RecordingsFilter{Limit: 10})
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if len(got) != 2 {
+			t.Errorf("expected 2 recordings, got: %d", len(got))
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("all filters - should number the placeholders in order", func(t *testing.T) {
+		var (
+			minScore = float32(0.5)
+			maxScore = float32(0.9)
+			from     = time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+			to       = time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+			after    = &models.RecordingsCursor{PromotedAt: time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC), Id: "9"}
+		)
+
+		mock.ExpectQuery(`SELECT .* FROM recordings WHERE cam_id = \$1 AND state = \$2 AND score >= \$3 AND score <= \$4 `+
+			`AND end_ts >= \$5 AND start_ts < \$6 AND \(promoted_at, id\) < \(\$7, \$8\) `+
+			`ORDER BY promoted_at DESC, id DESC LIMIT \$9`).
+			WithArgs("1", "promoted", minScore, maxScore, from, to, after.PromotedAt, after.Id, 20).
+			WillReturnRows(recordingRows(makeRecording("1", "1")))
+
+		_, err := repo.FindMany(ctx, &models.RecordingsFilter{
+			CamUUID:  "1",
+			State:    "promoted",
+			MinScore: &minScore,
+			MaxScore: &maxScore,
+			From:     &from,
+			To:       &to,
+			After:    after,
+			Limit:    20,
+		})
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestRecordingsFindExpired(t *testing.T) {
+	repo, mock, ctx := setupRecordingsRepoTest(t)
+
+	t.Run("expired recordings - should return them", func(t *testing.T) {
+		now := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(`SELECT .* FROM recordings\s+WHERE \(promoted_at \+ \(retention_days \* INTERVAL '1 day'\)\) <= \$1(?s).*LIMIT \$2`).
+			WithArgs(now, 5).
+			WillReturnRows(recordingRows(makeRecording("1", "1")))
+
+		got, err := repo.FindExpired(ctx, now, 5)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if len(got) != 1 {
+			t.Errorf("expected 1 recording, got: %d", len(got))
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestRecordingsDeleteMany(t *testing.T) {
+	repo, mock, ctx := setupRecordingsRepoTest(t)
+
+	t.Run("empty ids - should not query", func(t *testing.T) {
+		deleted, err := repo.DeleteMany(ctx, nil)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if deleted != 0 {
+			t.Errorf("expected 0 deleted, got: %d", deleted)
+		}
+	})
+
+	t.Run("ids - should delete them", func(t *testing.T) {
+		ids := []string{"1", "2"}
+		mock.ExpectExec(`DELETE FROM recordings WHERE id = ANY\(\$1\)`).
+			WithArgs(ids).
+			WillReturnResult(pgxmock.NewResult("DELETE", 2))
+
+		deleted, err := repo.DeleteMany(ctx, ids)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if deleted != 2 {
+			t.Errorf("expected 2 deleted, got: %d", deleted)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
@@ -12,8 +12,27 @@ type RedisIface interface {
 	Set(ctx context.Context, key string, value string, ttl time.Duration) error
 	Get(ctx context.Context, key string) (string, error)
 	Del(ctx context.Context, keys ...string) error
+	SAdd(ctx context.Context, key string, ttl time.Duration, members ...string) error
+	SMembers(ctx context.Context, key string) ([]string, error)
+	SRem(ctx context.Context, key string, members ...string) error
+	CompareAndSet(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error)
 }
 
+// compareAndSetScript replaces KEYS[1] only while it still holds ARGV[1], an absent key holds "".
+// An empty ARGV[2] deletes the key, otherwise it is set with a ttl of ARGV[3] ms.
+var compareAndSetScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if (cur or '') ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == '' then
+	redis.call('DEL', KEYS[1])
+else
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+end
+return 1
+`)
+
 type RedisRepo struct {
 	Rdb    *redis.Client
 	Logger *slog.Logger
@@ -42,3 +61,43 @@ func (repo *RedisRepo) Del(ctx context.Context, keys ...string) error {
 	repo.Logger.Info("Cache del", "keys", keys)
 	return status.Err()
 }
+
+// SAdd adds members to the set at key and (re)sets its ttl, so the set lives as long as the
+// last member added to it.
+func (repo *RedisRepo) SAdd(ctx context.Context, key string, ttl time.Duration, members ...string) error {
+	pipe := repo.Rdb.TxPipeline()
+	pipe.SAdd(ctx, key, toAny(members)...)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	repo.Logger.Info("Cache sadd", "key", key, "members", len(members))
+	return err
+}
+
+func (repo *RedisRepo) SMembers(ctx context.Context, key string) ([]string, error) {
+	cmd := repo.Rdb.SMembers(ctx, key)
+	repo.Logger.Info("Cache smembers", "key", key)
+	return cmd.Result()
+}
+
+func (repo *RedisRepo) SRem(ctx context.Context, key string, members ...string) error {
+	status := repo.Rdb.SRem(ctx, key, toAny(members)...)
+	repo.Logger.Info("Cache srem", "key", key, "members", len(members))
+	return status.Err()
+}
+
+func toAny(members []string) []any {
+	out := make([]any, len(members))
+	for i, member := range members {
+		out[i] = member
+	}
+	return out
+}
+
+// CompareAndSet atomically sets key to value when it still holds old, an empty old expects the
+// key to be absent and an empty value deletes it. It reports whether the key was changed.
+func (repo *RedisRepo) CompareAndSet(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error) {
+	ttlMs := max(ttl.Milliseconds(), 1)
+	swapped, err := compareAndSetScript.Run(ctx, repo.Rdb, []string{key}, old, value, ttlMs).Bool()
+	repo.Logger.Info("Cache compare and set", "key", key, "swapped", swapped)
+	return swapped, err
+}
@@ -0,0 +1,69 @@
+package repos
+
+import (
+	"context"
+	"errors"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+var ErrStreamSelectionNotFound = errors.New("stream selection not found")
+
+type StreamSelectionRepoIface interface {
+	FindOne(ctx context.Context, uuid string) (*models.StreamSelection, error)
+	Upsert(ctx context.Context, selection *models.StreamSelection) error
+}
+
+type PgxStreamSelectionRepo struct {
+	DB DBPoolIface
+}
+
+func NewPgxStreamSelectionRepo(db DBPoolIface) *PgxStreamSelectionRepo {
+	return &PgxStreamSelectionRepo{
+		DB: db,
+	}
+}
+
+func (repo *PgxStreamSelectionRepo) FindOne(ctx context.Context, uuid string) (*models.StreamSelection, error) {
+	var selection models.StreamSelection
+	if err := pgxscan.Get(ctx,
+		repo.DB,
+		&selection,
+		`SELECT id, viewer, recorder, motion
+			FROM camera_stream_selection
+			WHERE id = $1`,
+		uuid); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrStreamSelectionNotFound
+		}
+		return nil, err
+	}
+
+	return &selection, nil
+}
+
+func (repo *PgxStreamSelectionRepo) Upsert(ctx context.Context, selection *models.StreamSelection) error {
+	tag, err := repo.DB.Exec(ctx,
+		`INSERT INTO camera_stream_selection (id, viewer, recorder, motion)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (id) DO UPDATE SET
+				viewer = EXCLUDED.viewer,
+				recorder = EXCLUDED.recorder,
+				motion = EXCLUDED.motion,
+				updated_at = NOW()`,
+		selection.UUID,
+		selection.Viewer,
+		selection.Recorder,
+		selection.Motion)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRowsAffected
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+func setupStreamSelectionRepoTest(t *testing.T) (*PgxStreamSelectionRepo, pgxmock.PgxPoolIface, context.Context) {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create mock pool: %v", err)
+	}
+
+	t.Cleanup(func() {
+		mock.Close()
+	})
+
+	return NewPgxStreamSelectionRepo(mock), mock, context.Background()
+}
+
+func TestStreamSelectionFindOne(t *testing.T) {
+	repo, mock, ctx := setupStreamSelectionRepoTest(t)
+
+	t.Run("selection exists - should return it", func(t *testing.T) {
+		expected := &models.StreamSelection{UUID: "1", Viewer: "sub", Recorder: "main", Motion: "sub"}
+		mock.ExpectQuery(`SELECT .* FROM camera_stream_selection\s+WHERE id = \$1`).
+			WithArgs("1").
+			WillReturnRows(pgxmock.NewRows([]string{"id", "viewer", "recorder", "motion"}).
+				AddRow(expected.UUID, expected.Viewer, expected.Recorder, expected.Motion))
+
+		got, err := repo.FindOne(ctx, "1")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("selection does not exist - should return ErrStreamSelectionNotFound", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT .* FROM camera_stream_selection\s+WHERE id = \$1`).
+			WithArgs("missing").
+			WillReturnError(pgx.ErrNoRows)
+
+		if _, err := repo.FindOne(ctx, "missing"); !errors.Is(err, ErrStreamSelectionNotFound) {
+			t.Fatalf("expected ErrStreamSelectionNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestStreamSelectionUpsert(t *testing.T) {
+	repo, mock, ctx := setupStreamSelectionRepoTest(t)
+
+	t.Run("upsert selection - should succeed", func(t *testing.T) {
+		selection := &models.StreamSelection{UUID: "1", Viewer: "main", Recorder: "main", Motion: "sub"}
+		mock.ExpectExec(`INSERT INTO camera_stream_selection`).
+			WithArgs(selection.UUID, selection.Viewer, selection.Recorder, selection.Motion).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+		if err := repo.Upsert(ctx, selection); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("no rows affected - should return ErrNoRowsAffected", func(t *testing.T) {
+		selection := &models.StreamSelection{UUID: "1", Viewer: "main", Recorder: "main", Motion: "sub"}
+		mock.ExpectExec(`INSERT INTO camera_stream_selection`).
+			WithArgs(selection.UUID, selection.Viewer, selection.Recorder, selection.Motion).
+			WillReturnResult(pgxmock.NewResult("INSERT", 0))
+
+		if err := repo.Upsert(ctx, selection); !errors.Is(err, ErrNoRowsAffected) {
+			t.Fatalf("expected ErrNoRowsAffected, got: %v", err)
+		}
+	})
+}
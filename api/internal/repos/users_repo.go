@@ -0,0 +1,132 @@
+package repos
+
+import (
+	"context"
+	"errors"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+const pgUniqueViolation = "23505"
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUsernameTaken = errors.New("username is already taken")
+)
+
+type UsersRepoIface interface {
+	Create(ctx context.Context, user *models.User) error
+	FindOne(ctx context.Context, id string) (*models.User, error)
+	FindByUsername(ctx context.Context, username string) (*models.User, error)
+	FindMany(ctx context.Context) ([]*models.User, error)
+	Update(ctx context.Context, user *models.User) error
+	Delete(ctx context.Context, id string) error
+	Count(ctx context.Context) (int, error)
+}
+
+type PgxUsersRepo struct {
+	DB DBPoolIface
+}
+
+func NewPgxUsersRepo(db DBPoolIface) *PgxUsersRepo {
+	return &PgxUsersRepo{
+		DB: db,
+	}
+}
+
+// Create inserts the user and fills its generated id and creation time.
+func (repo *PgxUsersRepo) Create(ctx context.Context, user *models.User) error {
+	err := repo.DB.QueryRow(ctx,
+		`INSERT INTO users (username, password_hash, role)
+			VALUES ($1, $2, $3)
+			RETURNING id, created_at`,
+		user.Username,
+		user.PasswordHash,
+		user.Role).Scan(&user.Id, &user.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrUsernameTaken
+	}
+
+	return err
+}
+
+func (repo *PgxUsersRepo) FindOne(ctx context.Context, id string) (*models.User, error) {
+	return repo.findBy(ctx, "id", id)
+}
+
+func (repo *PgxUsersRepo) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	return repo.findBy(ctx, "username", username)
+}
+
+func (repo *PgxUsersRepo) findBy(ctx context.Context, col string, value string) (*models.User, error) {
+	var user models.User
+	if err := pgxscan.Get(ctx,
+		repo.DB,
+		&user,
+		`SELECT id, username, password_hash, role, created_at
+			FROM users
+			WHERE `+col+` = $1`,
+		value); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (repo *PgxUsersRepo) FindMany(ctx context.Context) ([]*models.User, error) {
+	users := []*models.User{}
+	err := pgxscan.Select(ctx, repo.DB, &users, `SELECT id, username, password_hash, role, created_at
+													FROM users
+													ORDER BY username`)
+	return users, err
+}
+
+// Update stores the user's role and password hash.
+func (repo *PgxUsersRepo) Update(ctx context.Context, user *models.User) error {
+	tag, err := repo.DB.Exec(ctx,
+		`UPDATE users
+			SET password_hash = $2, role = $3, updated_at = NOW()
+			WHERE id = $1`,
+		user.Id,
+		user.PasswordHash,
+		user.Role)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (repo *PgxUsersRepo) Delete(ctx context.Context, id string) error {
+	tag, err := repo.DB.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (repo *PgxUsersRepo) Count(ctx context.Context) (int, error) {
+	var count int
+	err := repo.DB.QueryRow(ctx, `SELECT COUNT(*) FROM users`).Scan(&count)
+	return count, err
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
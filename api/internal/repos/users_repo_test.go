@@ -0,0 +1,123 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+)
+
+func setupUsersRepoTest(t *testing.T) (*PgxUsersRepo, pgxmock.PgxPoolIface, context.Context) {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create mock pool: %v", err)
+	}
+
+	t.Cleanup(func() {
+		mock.Close()
+	})
+
+	return NewPgxUsersRepo(mock), mock, context.Background()
+}
+
+func TestUsersCreate(t *testing.T) {
+	repo, mock, ctx := setupUsersRepoTest(t)
+
+	t.Run("new user - should fill id and created_at", func(t *testing.T) {
+		createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		user := &models.User{Username: "bob", PasswordHash: "hash", Role: "viewer"}
+		mock.ExpectQuery(`INSERT INTO users(?s).*RETURNING id, created_at`).
+			WithArgs("bob", "hash", "viewer").
+			WillReturnRows(pgxmock.NewRows([]string{"id", "created_at"}).AddRow("1", createdAt))
+
+		if err := repo.Create(ctx, user); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if user.Id != "1" || !user.CreatedAt.Equal(createdAt) {
+			t.Errorf("expected generated fields to be filled, got: %+v", user)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("duplicate username - should return ErrUsernameTaken", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO users`).
+			WithArgs("bob", "hash", "viewer").
+			WillReturnError(&pgconn.PgError{Code: pgUniqueViolation})
+
+		err := repo.Create(ctx, &models.User{Username: "bob", PasswordHash: "hash", Role: "viewer"})
+		if !errors.Is(err, ErrUsernameTaken) {
+			t.Fatalf("expected ErrUsernameTaken, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestUsersFindByUsername(t *testing.T) {
+	repo, mock, ctx := setupUsersRepoTest(t)
+
+	t.Run("user exists - should return it", func(t *testing.T) {
+		expected := &models.User{Id: "1", Username: "bob", PasswordHash: "hash", Role: "admin", CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+		mock.ExpectQuery(`SELECT .* FROM users\s+WHERE username = \$1`).
+			WithArgs("bob").
+			WillReturnRows(pgxmock.NewRows([]string{"id", "username", "password_hash", "role", "created_at"}).
+				AddRow(expected.Id, expected.Username, expected.PasswordHash, expected.Role, expected.CreatedAt))
+
+		got, err := repo.FindByUsername(ctx, "bob")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("user does not exist - should return ErrUserNotFound", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT .* FROM users\s+WHERE username = \$1`).
+			WithArgs("ghost").
+			WillReturnError(pgx.ErrNoRows)
+
+		if _, err := repo.FindByUsername(ctx, "ghost"); !errors.Is(err, ErrUserNotFound) {
+			t.Fatalf("expected ErrUserNotFound, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestUsersDelete(t *testing.T) {
+	repo, mock, ctx := setupUsersRepoTest(t)
+
+	t.Run("unknown user - should return ErrUserNotFound", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM users WHERE id = \$1`).
+			WithArgs("ghost").
+			WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+		if err := repo.Delete(ctx, "ghost"); !errors.Is(err, ErrUserNotFound) {
+			t.Fatalf("expected ErrUserNotFound, got: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
@@ -0,0 +1,224 @@
+// Package secrets implements envelope encryption for the secrets stored in the database.
+//
+// Every value is sealed with its own random data key (DEK), the DEK is then wrapped by a
+// master key from the keyring. Rotating the master key only rewraps the DEKs, the values
+// themselves are never re-encrypted.
+package secrets
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const KeySize = 32 // AES-256
+
+var (
+	ErrUnknownKey = errors.New("unknown master key")
+	ErrNoKeys     = errors.New("no master keys configured")
+)
+
+// Envelope is a sealed value, KeyID names the master key that wrapped the DEK.
+type Envelope struct {
+	KeyID      string
+	WrappedDEK []byte
+	Ciphertext []byte
+}
+
+// Keyring holds the master keys, new values are always sealed with the active one and the
+// others are kept so values sealed before a rotation can still be opened.
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+func NewKeyring(active string, keys map[string][]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	for id, key := range keys {
+		if id == "" || strings.ContainsAny(id, ":,") {
+			return nil, fmt.Errorf("invalid master key id %q", id)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("master key %q must be %d bytes, got %d", id, KeySize, len(key))
+		}
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("%w: active key %q", ErrUnknownKey, active)
+	}
+
+	return &Keyring{active: active, keys: keys}, nil
+}
+
+// LoadKeyring reads the master keys as "id:base64key" entries, comma separated from
+// CREDS_MASTER_KEYS or one per line from the file at CREDS_MASTER_KEY_FILE. The active key
+// is CREDS_MASTER_KEY_ID, or the only key when a single one is configured.
+func LoadKeyring() (*Keyring, error) {
+	var entries []string
+	if path := os.Getenv("CREDS_MASTER_KEY_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			entries = append(entries, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		entries = strings.Split(os.Getenv("CREDS_MASTER_KEYS"), ",")
+	}
+
+	keys := make(map[string][]byte)
+	var last string
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed master key entry, expected id:base64key")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("master key %q is not valid base64: %w", id, err)
+		}
+		keys[id] = key
+		last = id
+	}
+
+	active := os.Getenv("CREDS_MASTER_KEY_ID")
+	if active == "" && len(keys) == 1 {
+		active = last
+	}
+
+	return NewKeyring(active, keys)
+}
+
+// GenerateKey returns a new random master key encoded the way LoadKeyring expects it.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func (kr *Keyring) ActiveID() string {
+	return kr.active
+}
+
+// Seal encrypts plaintext under a fresh DEK. The aad isn't stored, it binds the envelope to
+// its owner (e.g. the row id) so it can't be copied over to another row.
+func (kr *Keyring) Seal(plaintext, aad []byte) (*Envelope, error) {
+	dek := make([]byte, KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := seal(dek, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := seal(kr.keys[kr.active], dek, []byte(kr.active))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		KeyID:      kr.active,
+		WrappedDEK: wrapped,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+func (kr *Keyring) Open(env *Envelope, aad []byte) ([]byte, error) {
+	dek, err := kr.unwrap(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(dek, env.Ciphertext, aad)
+}
+
+// Rewrap wraps the envelope's DEK with the active master key.
+func (kr *Keyring) Rewrap(env *Envelope) (*Envelope, error) {
+	dek, err := kr.unwrap(env)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := seal(kr.keys[kr.active], dek, []byte(kr.active))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		KeyID:      kr.active,
+		WrappedDEK: wrapped,
+		Ciphertext: env.Ciphertext,
+	}, nil
+}
+
+func (kr *Keyring) unwrap(env *Envelope) ([]byte, error) {
+	master, ok := kr.keys[env.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, env.KeyID)
+	}
+
+	return open(master, env.WrappedDEK, []byte(env.KeyID))
+}
+
+// seal returns the random nonce followed by the AES-GCM ciphertext.
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed value is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestSealOpen(t *testing.T) {
+	kr, err := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+	if err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+
+	env, err := kr.Seal([]byte("hunter2"), []byte("cam-1"))
+	if err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+	if env.KeyID != "k1" || bytes.Contains(env.Ciphertext, []byte("hunter2")) {
+		t.Fatalf("unexpected envelope: %+v", env)
+	}
+
+	got, err := kr.Open(env, []byte("cam-1"))
+	if err != nil || string(got) != "hunter2" {
+		t.Fatalf("expected hunter2, got %q (%v)", got, err)
+	}
+
+	if _, err := kr.Open(env, []byte("cam-2")); err == nil {
+		t.Error("expected opening with another aad to fail")
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	old, _ := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+	env, err := old.Seal([]byte("hunter2"), nil)
+	if err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+
+	rotated, _ := NewKeyring("k2", map[string][]byte{"k1": testKey(1), "k2": testKey(2)})
+	rewrapped, err := rotated.Rewrap(env)
+	if err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+	if rewrapped.KeyID != "k2" || !bytes.Equal(rewrapped.Ciphertext, env.Ciphertext) {
+		t.Fatalf("expected only the DEK to be rewrapped, got: %+v", rewrapped)
+	}
+
+	// The old key can be dropped once every envelope was rewrapped.
+	retired, _ := NewKeyring("k2", map[string][]byte{"k2": testKey(2)})
+	got, err := retired.Open(rewrapped, nil)
+	if err != nil || string(got) != "hunter2" {
+		t.Fatalf("expected hunter2, got %q (%v)", got, err)
+	}
+	if _, err := retired.Open(env, nil); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got: %v", err)
+	}
+}
+
+func TestNewKeyringValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		active string
+		keys   map[string][]byte
+	}{
+		{"no keys", "k1", nil},
+		{"short key", "k1", map[string][]byte{"k1": []byte("short")}},
+		{"unknown active", "k2", map[string][]byte{"k1": testKey(1)}},
+		{"bad id", "k:1", map[string][]byte{"k:1": testKey(1)}},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewKeyring(tt.active, tt.keys); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(testKey(1))
+	k2 := base64.StdEncoding.EncodeToString(testKey(2))
+
+	t.Run("single key from env - should be active", func(t *testing.T) {
+		t.Setenv("CREDS_MASTER_KEY_FILE", "")
+		t.Setenv("CREDS_MASTER_KEY_ID", "")
+		t.Setenv("CREDS_MASTER_KEYS", "k1:"+k1)
+
+		kr, err := LoadKeyring()
+		if err != nil || kr.ActiveID() != "k1" {
+			t.Fatalf("expected k1 to be active, got %v (%v)", kr, err)
+		}
+	})
+
+	t.Run("key file - should use the configured active key", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys")
+		if err := os.WriteFile(path, []byte("# retired soon\nk1:"+k1+"\nk2:"+k2+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("CREDS_MASTER_KEY_FILE", path)
+		t.Setenv("CREDS_MASTER_KEY_ID", "k2")
+
+		kr, err := LoadKeyring()
+		if err != nil || kr.ActiveID() != "k2" {
+			t.Fatalf("expected k2 to be active, got %v (%v)", kr, err)
+		}
+	})
+
+	t.Run("several keys without an active one - should fail", func(t *testing.T) {
+		t.Setenv("CREDS_MASTER_KEY_FILE", "")
+		t.Setenv("CREDS_MASTER_KEY_ID", "")
+		t.Setenv("CREDS_MASTER_KEYS", "k1:"+k1+",k2:"+k2)
+
+		if _, err := LoadKeyring(); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
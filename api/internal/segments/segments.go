@@ -0,0 +1,182 @@
+// Package segments finds the recording segments mediamtx wrote for a path and assembles
+// clips out of them with ffmpeg.
+package segments
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"tomerab.com/cam-hub/internal/mtxapi"
+)
+
+const defaultRecordingsDir = "../recordings"
+
+type Segment struct {
+	Path  string
+	Start time.Time
+}
+
+// Dir is the directory holding the recordings of a mediamtx path, %path in the recordPath
+// of mediamtx.yml. RECORDINGS_DIR points at the directory mounted into mediamtx.
+func Dir(recordPath string) string {
+	root := os.Getenv("RECORDINGS_DIR")
+	if root == "" {
+		root = defaultRecordingsDir
+	}
+
+	return filepath.Join(root, filepath.FromSlash(recordPath))
+}
+
+// fileNames returns the segment names of dir, os.ReadDir sorts them by name which is also
+// their start time.
+func fileNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// InRange returns the segments covering [from, to), the first one may start before from.
+// Files whose name isn't a segment start are skipped.
+func InRange(recordPath string, from, to time.Time) ([]Segment, error) {
+	dir := Dir(recordPath)
+
+	names, err := fileNames(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Segment
+	for _, name := range names {
+		start, err := mtxapi.ParseSegmentStart(name)
+		if err != nil {
+			continue
+		}
+		if !start.Before(to) {
+			break
+		}
+
+		seg := Segment{Path: filepath.Join(dir, name), Start: start}
+		// Only the last segment starting before from holds its beginning.
+		if !start.After(from) {
+			out = append(out[:0], seg)
+			continue
+		}
+		out = append(out, seg)
+	}
+
+	return out, nil
+}
+
+// Trim joins the segments and cuts [from, to) out of them without re-encoding, the cut snaps
+// to the keyframes. onProgress gets the fraction of the clip written so far.
+func Trim(ctx context.Context, logger *slog.Logger, outputFileName string, segs []Segment, from, to time.Time, onProgress func(float64)) error {
+	if len(segs) == 0 {
+		return fmt.Errorf("no segments to trim")
+	}
+
+	listFileName := outputFileName + ".txt"
+	paths := make([]string, 0, len(segs))
+	for _, seg := range segs {
+		paths = append(paths, seg.Path)
+	}
+	if err := writeFileList(listFileName, paths); err != nil {
+		return err
+	}
+	defer os.Remove(listFileName)
+
+	offset := max(from.Sub(segs[0].Start), 0)
+	duration := to.Sub(from)
+	cmdArgs := []string{
+		"-hide_banner", "-nostdin", "-y",
+		"-f", "concat",
+		"-safe", "0",
+		"-i", listFileName,
+		"-ss", formatSeconds(offset),
+		"-t", formatSeconds(duration),
+		"-c", "copy",
+		"-movflags", "+faststart",
+		"-progress", "pipe:1", "-nostats",
+		outputFileName,
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", cmdArgs...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	readProgress(stdout, duration, onProgress)
+
+	if err := cmd.Wait(); err != nil {
+		logger.Error(
+			"ffmpeg failed",
+			"segments", paths,
+			"stderr", stderr.String(),
+			"error", err.Error(),
+		)
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	return nil
+}
+
+// readProgress follows the key=value lines of ffmpeg's -progress output, out_time_us is the
+// position written so far.
+func readProgress(r io.Reader, duration time.Duration, onProgress func(float64)) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, val, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || key != "out_time_us" || onProgress == nil || duration <= 0 {
+			continue
+		}
+
+		usec, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			continue
+		}
+		onProgress(min(max(float64(usec)/float64(duration.Microseconds()), 0), 1))
+	}
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// The concat demuxer resolves relative paths against the list's directory, not the cwd.
+func writeFileList(dstName string, fileList []string) error {
+	var content strings.Builder
+	for _, fileName := range fileList {
+		abs, err := filepath.Abs(fileName)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&content, "file '%s'\n", abs)
+	}
+
+	return os.WriteFile(dstName, []byte(content.String()), 0644)
+}
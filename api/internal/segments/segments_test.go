@@ -0,0 +1,104 @@
+package segments
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupRecordings(t *testing.T, names ...string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	t.Setenv("RECORDINGS_DIR", root)
+
+	dir := filepath.Join(root, "cam", "main")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create recordings dir: %v", err)
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("segment"), 0644); err != nil {
+			t.Fatalf("failed to create segment: %v", err)
+		}
+	}
+
+	return dir
+}
+
+func TestInRange(t *testing.T) {
+	dir := setupRecordings(t,
+		"2026-10-17_12-00-00-000000.mp4",
+		"2026-10-17_12-01-00-000000.mp4",
+		"2026-10-17_12-02-00-000000.mp4",
+		"2026-10-17_12-03-00-000000.mp4",
+		"notes.txt",
+	)
+	base := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		expected []string
+	}{
+		{
+			name:     "should start at the segment holding from",
+			from:     base.Add(90 * time.Second),
+			to:       base.Add(150 * time.Second),
+			expected: []string{"2026-10-17_12-01-00-000000.mp4", "2026-10-17_12-02-00-000000.mp4"},
+		},
+		{
+			name:     "should not include the segment starting at to",
+			from:     base,
+			to:       base.Add(2 * time.Minute),
+			expected: []string{"2026-10-17_12-00-00-000000.mp4", "2026-10-17_12-01-00-000000.mp4"},
+		},
+		{
+			name: "should return nothing before the recordings",
+			from: base.Add(-time.Hour),
+			to:   base.Add(-time.Minute),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InRange("cam/main", tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("expected nil, got err: %v", err)
+			}
+
+			var names []string
+			for _, seg := range got {
+				if filepath.Dir(seg.Path) != dir {
+					t.Errorf("expected the segment to be in %s, got: %s", dir, seg.Path)
+				}
+				names = append(names, filepath.Base(seg.Path))
+			}
+			if !slices.Equal(names, tt.expected) {
+				t.Errorf("expected: %v, got: %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestReadProgress(t *testing.T) {
+	out := strings.Join([]string{
+		"frame=10",
+		"out_time_us=N/A",
+		"out_time_us=5000000",
+		"progress=continue",
+		"out_time_us=12000000",
+		"progress=end",
+	}, "\n")
+
+	var got []float64
+	readProgress(strings.NewReader(out), 10*time.Second, func(p float64) {
+		got = append(got, p)
+	})
+
+	if !slices.Equal(got, []float64{0.5, 1}) {
+		t.Errorf("expected: [0.5 1], got: %v", got)
+	}
+}
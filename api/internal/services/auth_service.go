@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/repos"
+)
+
+const (
+	DefaultSessionTTL = 12 * time.Hour
+
+	sessionKeyPrefix  = "session:"
+	userSessionsKey   = "session:user:"
+	sessionTokenBytes = 32
+	minPasswordLen    = 8
+	maxUsernameLen    = 64
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidSession     = errors.New("invalid or expired session")
+	ErrInvalidUser        = errors.New("invalid user")
+	ErrLastAdmin          = errors.New("at least one admin must remain")
+)
+
+// Compared against when the username doesn't exist so both cases take the same time.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("cam-hub-dummy-password"), bcrypt.DefaultCost)
+
+// AuthService manages the users and their sessions. Sessions are opaque tokens, only their
+// hash is kept in redis so a leaked cache doesn't leak usable tokens. Each user's session keys
+// are also kept in a set so they can be revoked together.
+type AuthService struct {
+	UsersRepo  repos.UsersRepoIface
+	Sessions   repos.RedisIface
+	SessionTTL time.Duration
+	Logger     *slog.Logger
+}
+
+func (svc *AuthService) Login(ctx context.Context, username, password string) (*v1.LoginResp, error) {
+	user, err := svc.UsersRepo.FindByUsername(ctx, username)
+	if errors.Is(err, repos.ErrUserNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	raw := make([]byte, sessionTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	ttl := svc.sessionTTL()
+	if err := svc.Sessions.Set(ctx, sessionKey(token), user.Id, ttl); err != nil {
+		return nil, fmt.Errorf("failed to store session: %w", err)
+	}
+	if err := svc.Sessions.SAdd(ctx, userSessionsKey+user.Id, ttl, sessionKey(token)); err != nil {
+		_ = svc.Sessions.Del(ctx, sessionKey(token))
+		return nil, fmt.Errorf("failed to store session: %w", err)
+	}
+
+	svc.Logger.Info("user logged in", "user", user.Username)
+	return &v1.LoginResp{
+		Token:     token,
+		ExpiresAt: time.Now().Add(ttl).UTC(),
+		User:      user,
+	}, nil
+}
+
+func (svc *AuthService) Logout(ctx context.Context, token string) error {
+	key := sessionKey(token)
+	userId, err := svc.Sessions.Get(ctx, key)
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := svc.Sessions.Del(ctx, key); err != nil {
+		return err
+	}
+	return svc.Sessions.SRem(ctx, userSessionsKey+userId, key)
+}
+
+// Authenticate resolves a session token to its user. The user is loaded on every call so
+// role changes and deletions apply to existing sessions right away.
+func (svc *AuthService) Authenticate(ctx context.Context, token string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrInvalidSession
+	}
+
+	userId, err := svc.Sessions.Get(ctx, sessionKey(token))
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidSession
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := svc.UsersRepo.FindOne(ctx, userId)
+	if errors.Is(err, repos.ErrUserNotFound) {
+		_ = svc.Sessions.Del(ctx, sessionKey(token))
+		return nil, ErrInvalidSession
+	}
+
+	return user, err
+}
+
+func (svc *AuthService) ListUsers(ctx context.Context) ([]*models.User, error) {
+	return svc.UsersRepo.FindMany(ctx)
+}
+
+func (svc *AuthService) CreateUser(ctx context.Context, req v1.CreateUserReq) (*models.User, error) {
+	if err := validateUser(req.Username, req.Password, req.Role); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username:     req.Username,
+		PasswordHash: string(hash),
+		Role:         req.Role,
+	}
+	if err := svc.UsersRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// UpdateUser changes the user's role and/or password. A password change or a demotion revokes
+// every other session of the user, keepToken (the caller's own session) survives when it is
+// one of them so changing your own password doesn't log you out.
+func (svc *AuthService) UpdateUser(ctx context.Context, id string, req v1.UpdateUserReq, keepToken string) (*models.User, error) {
+	user, err := svc.UsersRepo.FindOne(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	revoke := false
+	if req.Role != nil && *req.Role != user.Role {
+		if !v1.ValidRole(*req.Role) {
+			return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidUser, *req.Role)
+		}
+		if user.Role == v1.RoleAdmin {
+			if err := svc.ensureAnotherAdmin(ctx, user.Id); err != nil {
+				return nil, err
+			}
+		}
+		revoke = !v1.RoleAllows(*req.Role, user.Role)
+		user.Role = *req.Role
+	}
+
+	if req.Password != nil {
+		if len(*req.Password) < minPasswordLen {
+			return nil, fmt.Errorf("%w: password must be at least %d characters", ErrInvalidUser, minPasswordLen)
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		user.PasswordHash = string(hash)
+		revoke = true
+	}
+
+	if err := svc.UsersRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if revoke {
+		if err := svc.revokeSessions(ctx, user.Id, keepToken); err != nil {
+			return nil, fmt.Errorf("user updated but failed to revoke its sessions: %w", err)
+		}
+	}
+
+	return user, nil
+}
+
+func (svc *AuthService) DeleteUser(ctx context.Context, id string) error {
+	user, err := svc.UsersRepo.FindOne(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if user.Role == v1.RoleAdmin {
+		if err := svc.ensureAnotherAdmin(ctx, user.Id); err != nil {
+			return err
+		}
+	}
+
+	if err := svc.UsersRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	return svc.revokeSessions(ctx, id, "")
+}
+
+// Bootstrap creates the first admin so a fresh install isn't locked out, it does nothing
+// once any user exists.
+func (svc *AuthService) Bootstrap(ctx context.Context, username, password string) error {
+	count, err := svc.UsersRepo.Count(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	if username == "" || password == "" {
+		svc.Logger.Warn("no users exist and no bootstrap admin is configured, nobody can log in")
+		return nil
+	}
+
+	if _, err := svc.CreateUser(ctx, v1.CreateUserReq{
+		Username: username,
+		Password: password,
+		Role:     v1.RoleAdmin,
+	}); err != nil {
+		return err
+	}
+
+	svc.Logger.Info("created bootstrap admin", "user", username)
+	return nil
+}
+
+func (svc *AuthService) ensureAnotherAdmin(ctx context.Context, id string) error {
+	users, err := svc.UsersRepo.FindMany(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if user.Role == v1.RoleAdmin && user.Id != id {
+			return nil
+		}
+	}
+
+	return ErrLastAdmin
+}
+
+// revokeSessions deletes the user's sessions other than keepToken's.
+func (svc *AuthService) revokeSessions(ctx context.Context, userId, keepToken string) error {
+	setKey := userSessionsKey + userId
+	keys, err := svc.Sessions.SMembers(ctx, setKey)
+	if err != nil {
+		return err
+	}
+
+	keep := ""
+	if keepToken != "" {
+		keep = sessionKey(keepToken)
+	}
+	keys = slices.DeleteFunc(keys, func(key string) bool { return key == keep })
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if err := svc.Sessions.Del(ctx, keys...); err != nil {
+		return err
+	}
+	if err := svc.Sessions.SRem(ctx, setKey, keys...); err != nil {
+		return err
+	}
+
+	svc.Logger.Info("revoked user sessions", "user", userId, "sessions", len(keys))
+	return nil
+}
+
+func (svc *AuthService) sessionTTL() time.Duration {
+	if svc.SessionTTL <= 0 {
+		return DefaultSessionTTL
+	}
+	return svc.SessionTTL
+}
+
+func validateUser(username, password, role string) error {
+	switch {
+	case username == "" || len(username) > maxUsernameLen:
+		return fmt.Errorf("%w: username must be 1-%d characters", ErrInvalidUser, maxUsernameLen)
+	case len(password) < minPasswordLen:
+		return fmt.Errorf("%w: password must be at least %d characters", ErrInvalidUser, minPasswordLen)
+	case !v1.ValidRole(role):
+		return fmt.Errorf("%w: unknown role %q", ErrInvalidUser, role)
+	}
+
+	return nil
+}
+
+func sessionKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return sessionKeyPrefix + hex.EncodeToString(sum[:])
+}
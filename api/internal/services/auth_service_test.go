@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/repos"
+)
+
+type fakeUsersRepo struct {
+	users map[string]*models.User
+}
+
+func (repo *fakeUsersRepo) Create(ctx context.Context, user *models.User) error {
+	for _, u := range repo.users {
+		if u.Username == user.Username {
+			return repos.ErrUsernameTaken
+		}
+	}
+	user.Id = strconv.Itoa(len(repo.users) + 1)
+	repo.users[user.Id] = user
+	return nil
+}
+
+func (repo *fakeUsersRepo) FindOne(ctx context.Context, id string) (*models.User, error) {
+	user, ok := repo.users[id]
+	if !ok {
+		return nil, repos.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (repo *fakeUsersRepo) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	for _, user := range repo.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, repos.ErrUserNotFound
+}
+
+func (repo *fakeUsersRepo) FindMany(ctx context.Context) ([]*models.User, error) {
+	users := []*models.User{}
+	for _, user := range repo.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (repo *fakeUsersRepo) Update(ctx context.Context, user *models.User) error {
+	repo.users[user.Id] = user
+	return nil
+}
+
+func (repo *fakeUsersRepo) Delete(ctx context.Context, id string) error {
+	delete(repo.users, id)
+	return nil
+}
+
+func (repo *fakeUsersRepo) Count(ctx context.Context) (int, error) {
+	return len(repo.users), nil
+}
+
+type fakeRedis struct {
+	vals map[string]string
+	sets map[string][]string
+}
+
+func (rdb *fakeRedis) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	rdb.vals[key] = value
+	return nil
+}
+
+func (rdb *fakeRedis) Get(ctx context.Context, key string) (string, error) {
+	val, ok := rdb.vals[key]
+	if !ok {
+		return "", redis.Nil
+	}
+	return val, nil
+}
+
+func (rdb *fakeRedis) Del(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		delete(rdb.vals, key)
+	}
+	return nil
+}
+
+func (rdb *fakeRedis) SAdd(ctx context.Context, key string, ttl time.Duration, members ...string) error {
+	if rdb.sets == nil {
+		rdb.sets = map[string][]string{}
+	}
+	for _, member := range members {
+		if !slices.Contains(rdb.sets[key], member) {
+			rdb.sets[key] = append(rdb.sets[key], member)
+		}
+	}
+	return nil
+}
+
+func (rdb *fakeRedis) SMembers(ctx context.Context, key string) ([]string, error) {
+	return slices.Clone(rdb.sets[key]), nil
+}
+
+func (rdb *fakeRedis) SRem(ctx context.Context, key string, members ...string) error {
+	rdb.sets[key] = slices.DeleteFunc(rdb.sets[key], func(member string) bool {
+		return slices.Contains(members, member)
+	})
+	return nil
+}
+
+func (rdb *fakeRedis) CompareAndSet(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error) {
+	if rdb.vals[key] != old {
+		return false, nil
+	}
+	if value == "" {
+		delete(rdb.vals, key)
+	} else {
+		rdb.vals[key] = value
+	}
+	return true, nil
+}
+
+func setupAuthServiceTest(t *testing.T) (*AuthService, *fakeRedis) {
+	t.Helper()
+
+	sessions := &fakeRedis{vals: map[string]string{}}
+	svc := &AuthService{
+		UsersRepo: &fakeUsersRepo{users: map[string]*models.User{}},
+		Sessions:  sessions,
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	if err := svc.Bootstrap(context.Background(), "admin", "admin-password"); err != nil {
+		t.Fatalf("failed to bootstrap: %v", err)
+	}
+
+	return svc, sessions
+}
+
+func TestAuthLogin(t *testing.T) {
+	t.Run("valid credentials - should issue a session", func(t *testing.T) {
+		svc, sessions := setupAuthServiceTest(t)
+
+		resp, err := svc.Login(context.Background(), "admin", "admin-password")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if resp.Token == "" || resp.User.Role != v1.RoleAdmin {
+			t.Errorf("unexpected login response: %+v", resp)
+		}
+		if _, ok := sessions.vals[resp.Token]; ok {
+			t.Error("expected the raw token not to be stored")
+		}
+
+		user, err := svc.Authenticate(context.Background(), resp.Token)
+		if err != nil || user.Username != "admin" {
+			t.Fatalf("expected the session to resolve to admin, got: %v, %v", user, err)
+		}
+	})
+
+	t.Run("wrong password or user - should return ErrInvalidCredentials", func(t *testing.T) {
+		svc, _ := setupAuthServiceTest(t)
+
+		if _, err := svc.Login(context.Background(), "admin", "nope-nope"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("expected ErrInvalidCredentials, got: %v", err)
+		}
+		if _, err := svc.Login(context.Background(), "ghost", "admin-password"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("expected ErrInvalidCredentials, got: %v", err)
+		}
+	})
+}
+
+func TestAuthLogout(t *testing.T) {
+	svc, _ := setupAuthServiceTest(t)
+
+	resp, err := svc.Login(context.Background(), "admin", "admin-password")
+	if err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+	if err := svc.Logout(context.Background(), resp.Token); err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+
+	if _, err := svc.Authenticate(context.Background(), resp.Token); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("expected ErrInvalidSession, got: %v", err)
+	}
+}
+
+func TestAuthDeletedUserLosesSession(t *testing.T) {
+	svc, _ := setupAuthServiceTest(t)
+	ctx := context.Background()
+
+	if _, err := svc.CreateUser(ctx, v1.CreateUserReq{Username: "second", Password: "second-password", Role: v1.RoleAdmin}); err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+	resp, err := svc.Login(ctx, "second", "second-password")
+	if err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+
+	if err := svc.DeleteUser(ctx, resp.User.Id); err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, resp.Token); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("expected ErrInvalidSession, got: %v", err)
+	}
+}
+
+func TestAuthUpdateUserRevokesSessions(t *testing.T) {
+	setup := func(t *testing.T) (*AuthService, *fakeRedis, *v1.LoginResp, *v1.LoginResp) {
+		t.Helper()
+
+		svc, sessions := setupAuthServiceTest(t)
+		ctx := context.Background()
+		if _, err := svc.CreateUser(ctx, v1.CreateUserReq{Username: "second", Password: "second-password", Role: v1.RoleOperator}); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		var logins []*v1.LoginResp
+		for range 2 {
+			resp, err := svc.Login(ctx, "second", "second-password")
+			if err != nil {
+				t.Fatalf("expected nil, got err: %v", err)
+			}
+			logins = append(logins, resp)
+		}
+		return svc, sessions, logins[0], logins[1]
+	}
+
+	t.Run("password change - should revoke every session but the caller's", func(t *testing.T) {
+		svc, sessions, caller, other := setup(t)
+		ctx := context.Background()
+
+		password := "changed-password"
+		if _, err := svc.UpdateUser(ctx, caller.User.Id, v1.UpdateUserReq{Password: &password}, caller.Token); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if _, err := svc.Authenticate(ctx, other.Token); !errors.Is(err, ErrInvalidSession) {
+			t.Errorf("expected ErrInvalidSession, got: %v", err)
+		}
+		if _, err := svc.Authenticate(ctx, caller.Token); err != nil {
+			t.Errorf("expected the caller's session to survive, got err: %v", err)
+		}
+		if got := sessions.sets[userSessionsKey+caller.User.Id]; !slices.Equal(got, []string{sessionKey(caller.Token)}) {
+			t.Errorf("expected only the caller's session to be tracked, got: %v", got)
+		}
+	})
+
+	t.Run("demotion by an admin - should revoke every session", func(t *testing.T) {
+		svc, _, first, second := setup(t)
+		ctx := context.Background()
+		admin, err := svc.Login(ctx, "admin", "admin-password")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		viewer := v1.RoleViewer
+		if _, err := svc.UpdateUser(ctx, first.User.Id, v1.UpdateUserReq{Role: &viewer}, admin.Token); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		for _, resp := range []*v1.LoginResp{first, second} {
+			if _, err := svc.Authenticate(ctx, resp.Token); !errors.Is(err, ErrInvalidSession) {
+				t.Errorf("expected ErrInvalidSession, got: %v", err)
+			}
+		}
+		if _, err := svc.Authenticate(ctx, admin.Token); err != nil {
+			t.Errorf("expected the admin's session to survive, got err: %v", err)
+		}
+	})
+
+	t.Run("promotion - should keep the sessions", func(t *testing.T) {
+		svc, _, first, second := setup(t)
+		ctx := context.Background()
+
+		admin := v1.RoleAdmin
+		if _, err := svc.UpdateUser(ctx, first.User.Id, v1.UpdateUserReq{Role: &admin}, ""); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		for _, resp := range []*v1.LoginResp{first, second} {
+			if _, err := svc.Authenticate(ctx, resp.Token); err != nil {
+				t.Errorf("expected the session to survive, got err: %v", err)
+			}
+		}
+	})
+}
+
+func TestAuthCreateUser(t *testing.T) {
+	tests := []struct {
+		name string
+		req  v1.CreateUserReq
+		want error
+	}{
+		{"valid viewer", v1.CreateUserReq{Username: "viewer", Password: "viewer-password", Role: v1.RoleViewer}, nil},
+		{"short password", v1.CreateUserReq{Username: "viewer", Password: "short", Role: v1.RoleViewer}, ErrInvalidUser},
+		{"unknown role", v1.CreateUserReq{Username: "viewer", Password: "viewer-password", Role: "root"}, ErrInvalidUser},
+		{"taken username", v1.CreateUserReq{Username: "admin", Password: "viewer-password", Role: v1.RoleViewer}, repos.ErrUsernameTaken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := setupAuthServiceTest(t)
+
+			user, err := svc.CreateUser(context.Background(), tt.req)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got: %v", tt.want, err)
+			}
+			if err == nil && user.PasswordHash == tt.req.Password {
+				t.Error("expected the password to be hashed")
+			}
+		})
+	}
+}
+
+func TestAuthLastAdmin(t *testing.T) {
+	svc, _ := setupAuthServiceTest(t)
+	ctx := context.Background()
+
+	admin, err := svc.UsersRepo.FindByUsername(ctx, "admin")
+	if err != nil {
+		t.Fatalf("expected nil, got err: %v", err)
+	}
+
+	viewer := v1.RoleViewer
+	if _, err := svc.UpdateUser(ctx, admin.Id, v1.UpdateUserReq{Role: &viewer}, ""); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("expected ErrLastAdmin on demotion, got: %v", err)
+	}
+	if err := svc.DeleteUser(ctx, admin.Id); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("expected ErrLastAdmin on deletion, got: %v", err)
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role, required string
+		want           bool
+	}{
+		{v1.RoleAdmin, v1.RoleOperator, true},
+		{v1.RoleOperator, v1.RoleOperator, true},
+		{v1.RoleViewer, v1.RoleOperator, false},
+		{v1.RoleOperator, v1.RoleAdmin, false},
+		{"", v1.RoleViewer, false},
+	}
+
+	for _, tt := range tests {
+		if got := v1.RoleAllows(tt.role, tt.required); got != tt.want {
+			t.Errorf("RoleAllows(%q, %q): expected %v, got %v", tt.role, tt.required, tt.want, got)
+		}
+	}
+}
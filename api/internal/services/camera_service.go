@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+	"time"
 
 	"tomerab.com/cam-hub/internal/api/v1/models"
 	v1 "tomerab.com/cam-hub/internal/contracts/v1"
@@ -24,6 +25,8 @@ const (
 type CameraService struct {
 	CamRepo            repos.CameraRepoIface
 	CamCredsRepo       repos.CameraCredsRepoIface
+	StreamsRepo        repos.CameraStreamsRepoIface
+	PtzCapsRepo        repos.PtzCapabilitiesRepoIface
 	Rdms               repos.RedisIface
 	InMemCache         *inmemory.InMemoryPubSub
 	MtxClient          *mtxapi.MtxClient
@@ -56,7 +59,7 @@ func getAddrWithoutPort(addr string) string {
 	return strings.TrimSuffix(addr, ":")
 }
 
-func (svc *CameraService) connectAndGetDeviceInfo(req v1.PairDeviceReq) (*device.GetDeviceInfoDto, error) {
+func (svc *CameraService) connectAndGetDeviceInfo(req v1.PairDeviceReq) (*device.GetDeviceInfoDto, []*models.CameraStream, *models.PtzCapabilities, error) {
 	client, err := onvif.NewOnvifClient(onvif.OnvifClientParams{
 		Xaddr:    req.Addr,
 		Username: req.Username,
@@ -64,12 +67,12 @@ func (svc *CameraService) connectAndGetDeviceInfo(req v1.PairDeviceReq) (*device
 		Logger:   svc.Logger,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create ONVIF client: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to create ONVIF client: %w", err)
 	}
 
 	info, err := client.GetDeviceInfo()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get device info: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to get device info: %w", err)
 	}
 	if err := svc.tryCreateRootUser(client); err != nil {
 		svc.Logger.Debug("onvif err", "err", err)
@@ -79,7 +82,49 @@ func (svc *CameraService) connectAndGetDeviceInfo(req v1.PairDeviceReq) (*device
 		svc.Logger.Debug("onvif err", "err", err)
 	}
 
-	return &info, nil
+	return &info, svc.discoverStreams(client, req.Addr), svc.discoverPtz(client, req.Addr), nil
+}
+
+// discoverPtz records the camera's PTZ limits, they're discovered again on the first move when
+// the camera couldn't report them.
+func (svc *CameraService) discoverPtz(client *onvif.OnvifClient, addr string) *models.PtzCapabilities {
+	caps, err := discoverPtzCapabilities(client)
+	if err != nil {
+		svc.Logger.Warn("failed to discover ptz capabilities", "addr", addr, "err", err)
+		return nil
+	}
+
+	return caps
+}
+
+// discoverStreams resolves the camera's main and sub streams. Cameras that can't report them
+// are still paired, their source falls back to the vendor path.
+func (svc *CameraService) discoverStreams(client *onvif.OnvifClient, addr string) []*models.CameraStream {
+	profiles, err := client.GetStreamProfiles()
+	if err != nil {
+		svc.Logger.Warn("failed to discover camera streams, using the default rtsp path", "addr", addr, "err", err)
+		return nil
+	}
+
+	main, sub := onvif.PickStreams(profiles)
+	streams := []*models.CameraStream{buildCameraStream(models.StreamMain, main)}
+	if sub != nil {
+		streams = append(streams, buildCameraStream(models.StreamSub, sub))
+	}
+
+	return streams
+}
+
+func buildCameraStream(profile string, stream *onvif.StreamProfile) *models.CameraStream {
+	return &models.CameraStream{
+		Profile:       profile,
+		Token:         stream.Token,
+		Uri:           stream.Uri,
+		Encoding:      stream.Encoding,
+		AudioEncoding: stream.AudioEncoding,
+		Width:         stream.Width,
+		Height:        stream.Height,
+	}
 }
 
 func (svc *CameraService) tryCreateRootUser(client *onvif.OnvifClient) error {
@@ -111,7 +156,7 @@ func (svc *CameraService) buildCameraModel(uuid string, req v1.PairDeviceReq, in
 	}
 }
 
-func (svc *CameraService) storeCameraAndCredentials(ctx context.Context, camera *models.Camera, uuid string, req v1.PairDeviceReq) error {
+func (svc *CameraService) storeCameraAndCredentials(ctx context.Context, camera *models.Camera, streams []*models.CameraStream, ptzCaps *models.PtzCapabilities, uuid string, req v1.PairDeviceReq) error {
 	tx, err := svc.CamRepo.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -131,6 +176,20 @@ func (svc *CameraService) storeCameraAndCredentials(ctx context.Context, camera
 		return fmt.Errorf("failed to insert credentials: %w", err)
 	}
 
+	for _, stream := range streams {
+		stream.CamUUID = uuid
+	}
+	if err := svc.StreamsRepo.ReplaceTx(ctx, tx, uuid, streams); err != nil {
+		return fmt.Errorf("failed to store streams: %w", err)
+	}
+
+	if ptzCaps != nil {
+		ptzCaps.CamUUID = uuid
+		if err := svc.PtzCapsRepo.UpsertTx(ctx, tx, ptzCaps); err != nil {
+			return fmt.Errorf("failed to store ptz capabilities: %w", err)
+		}
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -139,17 +198,21 @@ func (svc *CameraService) storeCameraAndCredentials(ctx context.Context, camera
 }
 
 func (svc *CameraService) Pair(ctx context.Context, uuid string, req v1.PairDeviceReq) (*models.Camera, error) {
-	devInfo, err := svc.connectAndGetDeviceInfo(req)
+	devInfo, streams, ptzCaps, err := svc.connectAndGetDeviceInfo(req)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to device: %w", err)
 	}
 
 	camera := svc.buildCameraModel(uuid, req, devInfo)
-	err = svc.storeCameraAndCredentials(ctx, camera, uuid, req)
+	err = svc.storeCameraAndCredentials(ctx, camera, streams, ptzCaps, uuid, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to store camera data: %w", err)
 	}
+	// The codecs probed for the previous pairing may not hold for the new streams.
+	if err := svc.MtxClient.ForgetProbes(ctx, uuid); err != nil {
+		svc.Logger.Warn("failed to forget probed codecs", "uuid", uuid, "err", err)
+	}
 
 	if err := svc.connectCameraToWifi(
 		req.Addr,
@@ -159,16 +222,17 @@ func (svc *CameraService) Pair(ctx context.Context, uuid string, req v1.PairDevi
 		return nil, err
 	}
 
-	streamUrl, err := svc.MtxClient.Publish(ctx, uuid)
+	urls, err := svc.MtxClient.Publish(ctx, uuid)
 	if err != nil {
 		return nil, err
 	}
 
 	svc.CamsEventProxyChan <- v1.CameraProxyEvent{
 		CameraPairedEvent: &v1.CameraPairedEvent{
-			UUID:      uuid,
-			StreamUrl: streamUrl,
-			Revision:  camera.Version,
+			UUID:       uuid,
+			StreamUrl:  urls.Motion,
+			RecordPath: urls.RecordPath,
+			Revision:   camera.Version,
 		},
 	}
 
@@ -176,6 +240,38 @@ func (svc *CameraService) Pair(ctx context.Context, uuid string, req v1.PairDevi
 	return camera, nil
 }
 
+// PairedSnapshot lists every paired camera the way it is announced to the supervisor,
+// the snapshot is incomplete when a camera's stream couldn't be published.
+func (svc *CameraService) PairedSnapshot(ctx context.Context) (*v1.PairedCamerasSnapshot, error) {
+	cams, err := svc.CamRepo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &v1.PairedCamerasSnapshot{
+		Cameras:     make([]v1.CameraPairedEvent, 0, len(cams)),
+		Complete:    true,
+		GeneratedAt: time.Now().UTC(),
+	}
+	for _, cam := range cams {
+		urls, err := svc.MtxClient.Publish(ctx, cam.UUID)
+		if err != nil {
+			svc.Logger.Warn("failed to publish stream for snapshot", "uuid", cam.UUID, "err", err)
+			snapshot.Complete = false
+			continue
+		}
+
+		snapshot.Cameras = append(snapshot.Cameras, v1.CameraPairedEvent{
+			UUID:       cam.UUID,
+			StreamUrl:  urls.Motion,
+			RecordPath: urls.RecordPath,
+			Revision:   cam.Version,
+		})
+	}
+
+	return snapshot, nil
+}
+
 func (svc *CameraService) connectCameraToWifi(addr, ssid, psk string) error {
 	parts := strings.Split(addr, ":")
 	addrWithoutPort := parts[0]
@@ -257,3 +353,23 @@ func (svc *CameraService) CameraExists(ctx context.Context, uuid string) bool {
 	_, err := svc.CamRepo.FindOne(ctx, uuid)
 	return err == nil
 }
+
+// OnvifSource is handed to the motion detectors that pull the camera's ONVIF events, like the
+// stream source it holds the camera credentials.
+func (svc *CameraService) OnvifSource(ctx context.Context, uuid string) (*v1.CameraOnvif, error) {
+	cam, err := svc.CamRepo.FindOne(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := svc.CamCredsRepo.FindOne(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.CameraOnvif{
+		Xaddr:    cam.Addr,
+		Username: creds.Username,
+		Password: creds.Password,
+	}, nil
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/go-co-op/gocron/v2"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/events"
+	"tomerab.com/cam-hub/internal/mtxapi"
+	"tomerab.com/cam-hub/internal/repos"
+)
+
+const (
+	maxRecordingRetentionDays = 365
+	// Segments further apart than this are shown as separate spans, it covers the jitter
+	// between the end of a segment and the start of the next one.
+	timelineMaxGap = 2 * time.Second
+	// The timeline's spans are clipped to a day, nothing longer is played back at once.
+	maxPlaybackDuration = 24 * time.Hour
+)
+
+var (
+	ErrInvalidRecordingSettings = errors.New("invalid recording settings")
+	ErrInvalidSegment           = errors.New("invalid recording segment")
+	ErrInvalidPlayback          = errors.New("invalid playback request")
+)
+
+type ContinuousRecordingService struct {
+	SettingsRepo  repos.RecordingSettingsRepoIface
+	SegmentsRepo  repos.RecordingSegmentsRepoIface
+	CamRepo       repos.CameraRepoIface
+	MtxClient     *mtxapi.MtxClient
+	Bus           events.BusIface
+	Sched         gocron.Scheduler
+	Logger        *slog.Logger
+	PruneInterval time.Duration
+}
+
+func (svc *ContinuousRecordingService) InitJobs(ctx context.Context) error {
+	job, err := svc.Sched.NewJob(
+		gocron.DurationJob(svc.PruneInterval),
+		gocron.NewTask(func() {
+			runCtx, cancel := context.WithTimeout(ctx, svc.PruneInterval)
+			defer cancel()
+			svc.Prune(runCtx)
+		}),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	svc.Logger.Info("Scheduled recording segments pruning", "jobid", job.ID(), "interval", svc.PruneInterval)
+	return nil
+}
+
+// Prune drops the index rows of the segments mediamtx deleted once they outlived the retention.
+func (svc *ContinuousRecordingService) Prune(ctx context.Context) {
+	deleted, err := svc.SegmentsRepo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		svc.Logger.Error("continuous recording: failed to prune segments", "err", err)
+		return
+	}
+
+	if deleted > 0 {
+		svc.Logger.Info("continuous recording: pruned expired segments", "deleted", deleted)
+	}
+}
+
+// GetSettings returns the camera's recording settings, cameras that were never configured get the defaults.
+func (svc *ContinuousRecordingService) GetSettings(ctx context.Context, uuid string) (*v1.RecordingSettings, error) {
+	if err := svc.ensureCamera(ctx, uuid); err != nil {
+		return nil, err
+	}
+
+	return svc.MtxClient.RecordingSettings(ctx, uuid)
+}
+
+// UpdateSettings stores the settings and republishes the camera's paths, the recorder's path
+// keeps its source running while the camera records continuously.
+func (svc *ContinuousRecordingService) UpdateSettings(ctx context.Context, uuid string, settings v1.RecordingSettings) error {
+	if settings.RetentionDays <= 0 || settings.RetentionDays > maxRecordingRetentionDays {
+		return fmt.Errorf("%w: retention_days must be in [1, %d]", ErrInvalidRecordingSettings, maxRecordingRetentionDays)
+	}
+
+	if err := svc.ensureCamera(ctx, uuid); err != nil {
+		return err
+	}
+
+	if err := svc.SettingsRepo.Upsert(ctx, &models.RecordingSettings{
+		UUID:          uuid,
+		Continuous:    settings.Continuous,
+		RetentionDays: settings.RetentionDays,
+	}); err != nil {
+		return err
+	}
+
+	// mediamtx goes back to deleting the segments after a few minutes, the timeline would
+	// point at files that are gone.
+	if !settings.Continuous {
+		if _, err := svc.SegmentsRepo.DeleteByCamera(ctx, uuid); err != nil {
+			return err
+		}
+	}
+
+	if _, err := svc.MtxClient.Publish(ctx, uuid); err != nil {
+		return fmt.Errorf("failed to publish streams: %w", err)
+	}
+
+	return nil
+}
+
+// IndexSegment announces a segment mediamtx completed to the motion runners of the camera and
+// stores it when the camera records continuously.
+func (svc *ContinuousRecordingService) IndexSegment(ctx context.Context, ev v1.RecordingSegmentEvent) error {
+	if ev.Path == "" || ev.File == "" {
+		return fmt.Errorf("%w: path and file are required", ErrInvalidSegment)
+	}
+
+	start, err := mtxapi.ParseSegmentStart(ev.File)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSegment, err)
+	}
+	duration, err := mtxapi.ParseSegmentDuration(ev.Duration)
+	if err != nil || duration < 0 {
+		return fmt.Errorf("%w: invalid duration %q", ErrInvalidSegment, ev.Duration)
+	}
+
+	uuid, _ := mtxapi.ParsePathName(ev.Path)
+	bytes, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	// The runners can't cut their clips without it, the index is only used by the timeline.
+	if err := svc.Bus.Publish(ctx, v1.RecordingSegmentsExchange, uuid, bytes, nil); err != nil {
+		return fmt.Errorf("failed to publish segment: %w", err)
+	}
+
+	settings, err := svc.MtxClient.RecordingSettings(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	if !settings.Continuous {
+		return nil
+	}
+
+	return svc.SegmentsRepo.Insert(ctx, &models.RecordingSegment{
+		CamUUID:  uuid,
+		MtxPath:  ev.Path,
+		FileName: path.Base(ev.File),
+		StartTs:  start,
+		EndTs:    start.Add(duration),
+	})
+}
+
+// Timeline returns the spans of [from, to) the camera has recordings for, clipped to the
+// range and with a playback url each.
+func (svc *ContinuousRecordingService) Timeline(ctx context.Context, uuid string, from, to time.Time) (*v1.RecordingTimeline, error) {
+	if err := svc.ensureCamera(ctx, uuid); err != nil {
+		return nil, err
+	}
+
+	spans, err := svc.SegmentsRepo.FindSpans(ctx, uuid, from, to, timelineMaxGap)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := &v1.RecordingTimeline{
+		UUID:  uuid,
+		From:  from,
+		To:    to,
+		Spans: make([]v1.TimelineSpan, 0, len(spans)),
+	}
+	for _, span := range spans {
+		start, end := span.StartTs, span.EndTs
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		_, profile := mtxapi.ParsePathName(span.MtxPath)
+		timeline.Spans = append(timeline.Spans, v1.TimelineSpan{
+			Start:       start,
+			End:         end,
+			Profile:     profile,
+			PlaybackUrl: playbackRoute(uuid, profile, start, end.Sub(start)),
+		})
+	}
+
+	return timeline, nil
+}
+
+// Playback opens [start, start+duration) of the camera's recordings of profile as a single mp4,
+// the caller closes the body. mediamtx's playback server isn't reachable by the clients, the
+// recordings are only served to users of the api.
+func (svc *ContinuousRecordingService) Playback(ctx context.Context, uuid, profile string, start time.Time, duration time.Duration) (*http.Response, error) {
+	if profile != models.StreamMain && profile != models.StreamSub {
+		return nil, fmt.Errorf("%w: profile must be %q or %q", ErrInvalidPlayback, models.StreamMain, models.StreamSub)
+	}
+	if duration <= 0 || duration > maxPlaybackDuration {
+		return nil, fmt.Errorf("%w: duration must be in (0, %s]", ErrInvalidPlayback, maxPlaybackDuration)
+	}
+
+	if err := svc.ensureCamera(ctx, uuid); err != nil {
+		return nil, err
+	}
+
+	return svc.MtxClient.Playback(ctx, mtxapi.PathName(uuid, profile), start, duration)
+}
+
+// playbackRoute is the api route serving the span, see Playback.
+func playbackRoute(uuid, profile string, start time.Time, duration time.Duration) string {
+	query := url.Values{}
+	query.Set("profile", profile)
+	query.Set("start", start.UTC().Format(time.RFC3339Nano))
+	query.Set("duration", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64))
+
+	return fmt.Sprintf("/api/v1/cameras/%s/recording/playback?%s", uuid, query.Encode())
+}
+
+func (svc *ContinuousRecordingService) ensureCamera(ctx context.Context, uuid string) error {
+	if _, err := svc.CamRepo.FindOne(ctx, uuid); err != nil {
+		if pgxscan.NotFound(err) {
+			return ErrCameraNotFound
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/mtxapi"
+	"tomerab.com/cam-hub/internal/repos"
+)
+
+type fakeRecordingSettingsRepo struct {
+	settings map[string]*models.RecordingSettings
+}
+
+func (repo *fakeRecordingSettingsRepo) FindOne(ctx context.Context, uuid string) (*models.RecordingSettings, error) {
+	settings, ok := repo.settings[uuid]
+	if !ok {
+		return nil, repos.ErrRecordingSettingsNotFound
+	}
+	return settings, nil
+}
+
+func (repo *fakeRecordingSettingsRepo) Upsert(ctx context.Context, settings *models.RecordingSettings) error {
+	repo.settings[settings.UUID] = settings
+	return nil
+}
+
+type fakeRecordingSegmentsRepo struct {
+	segments []*models.RecordingSegment
+	spans    []*models.RecordingSpan
+	deleted  []string
+}
+
+func (repo *fakeRecordingSegmentsRepo) Insert(ctx context.Context, seg *models.RecordingSegment) error {
+	repo.segments = append(repo.segments, seg)
+	return nil
+}
+
+func (repo *fakeRecordingSegmentsRepo) FindSpans(ctx context.Context, camUUID string, from, to time.Time, maxGap time.Duration) ([]*models.RecordingSpan, error) {
+	return repo.spans, nil
+}
+
+func (repo *fakeRecordingSegmentsRepo) DeleteByCamera(ctx context.Context, camUUID string) (int64, error) {
+	repo.deleted = append(repo.deleted, camUUID)
+	return 0, nil
+}
+
+func (repo *fakeRecordingSegmentsRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func setupContinuousRecordingServiceTest(t *testing.T) (*ContinuousRecordingService, *fakeRecordingSettingsRepo, *fakeRecordingSegmentsRepo, *fakeMediaMtx, *fakeBus) {
+	t.Helper()
+
+	mtx := &fakeMediaMtx{}
+	server := httptest.NewServer(mtx)
+	t.Cleanup(server.Close)
+	t.Setenv("MEDIAMTX_ADDR", server.URL)
+	t.Setenv("MEDIAMTX_HOST", "mtx")
+	t.Setenv("MEDIAMTX_PLAYBACK_ADDR", server.URL)
+
+	settingsRepo := &fakeRecordingSettingsRepo{settings: map[string]*models.RecordingSettings{}}
+	segmentsRepo := &fakeRecordingSegmentsRepo{}
+	bus := &fakeBus{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return &ContinuousRecordingService{
+		SettingsRepo: settingsRepo,
+		SegmentsRepo: segmentsRepo,
+		CamRepo:      &fakeCameraRepo{cams: map[string]*models.Camera{"cam": {UUID: "cam"}}},
+		MtxClient: &mtxapi.MtxClient{
+			HttpClient:    server.Client(),
+			Logger:        logger,
+			SelectionRepo: &fakeStreamSelectionRepo{selections: map[string]*models.StreamSelection{}},
+			RecordingRepo: settingsRepo,
+		},
+		Bus:    bus,
+		Logger: logger,
+	}, settingsRepo, segmentsRepo, mtx, bus
+}
+
+func TestContinuousRecordingUpdateSettings(t *testing.T) {
+	t.Run("enable continuous recording - should store it and publish the paths", func(t *testing.T) {
+		svc, settingsRepo, segmentsRepo, mtx, _ := setupContinuousRecordingServiceTest(t)
+
+		if err := svc.UpdateSettings(context.Background(), "cam", v1.RecordingSettings{Continuous: true, RetentionDays: 14}); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		stored, ok := settingsRepo.settings["cam"]
+		if !ok || !stored.Continuous || stored.RetentionDays != 14 {
+			t.Errorf("expected the settings to be stored, got: %+v", stored)
+		}
+		if len(segmentsRepo.deleted) != 0 {
+			t.Errorf("expected the segments to be kept, got deletes for: %v", segmentsRepo.deleted)
+		}
+		if !slices.Contains(mtx.requests, "POST /v3/config/paths/add/cam/main") {
+			t.Errorf("expected the paths to be published, got: %v", mtx.requests)
+		}
+	})
+
+	t.Run("disable continuous recording - should drop the indexed segments", func(t *testing.T) {
+		svc, _, segmentsRepo, _, _ := setupContinuousRecordingServiceTest(t)
+
+		if err := svc.UpdateSettings(context.Background(), "cam", v1.RecordingSettings{Continuous: false, RetentionDays: 7}); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if !slices.Equal(segmentsRepo.deleted, []string{"cam"}) {
+			t.Errorf("expected the camera's segments to be deleted, got: %v", segmentsRepo.deleted)
+		}
+	})
+
+	t.Run("invalid retention - should be rejected", func(t *testing.T) {
+		svc, settingsRepo, _, _, _ := setupContinuousRecordingServiceTest(t)
+
+		for _, days := range []int{0, maxRecordingRetentionDays + 1} {
+			err := svc.UpdateSettings(context.Background(), "cam", v1.RecordingSettings{Continuous: true, RetentionDays: days})
+			if !errors.Is(err, ErrInvalidRecordingSettings) {
+				t.Fatalf("expected ErrInvalidRecordingSettings for %d days, got: %v", days, err)
+			}
+		}
+		if len(settingsRepo.settings) != 0 {
+			t.Errorf("expected nothing to be stored")
+		}
+	})
+
+	t.Run("unknown camera - should return ErrCameraNotFound", func(t *testing.T) {
+		svc, _, _, _, _ := setupContinuousRecordingServiceTest(t)
+
+		err := svc.UpdateSettings(context.Background(), "missing", v1.DefaultRecordingSettings())
+		if !errors.Is(err, ErrCameraNotFound) {
+			t.Fatalf("expected ErrCameraNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestContinuousRecordingIndexSegment(t *testing.T) {
+	ev := v1.RecordingSegmentEvent{
+		Path:     "cam/main",
+		File:     "/recordings/cam/main/2026-10-17_12-00-00-500000.mp4",
+		Duration: "1.5",
+	}
+
+	t.Run("continuous camera - should index and publish the segment", func(t *testing.T) {
+		svc, settingsRepo, segmentsRepo, _, bus := setupContinuousRecordingServiceTest(t)
+		settingsRepo.settings["cam"] = &models.RecordingSettings{UUID: "cam", Continuous: true, RetentionDays: 7}
+
+		if err := svc.IndexSegment(context.Background(), ev); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if len(segmentsRepo.segments) != 1 {
+			t.Fatalf("expected a single segment, got: %v", segmentsRepo.segments)
+		}
+		seg := segmentsRepo.segments[0]
+		start := time.Date(2026, 10, 17, 12, 0, 0, 500_000_000, time.UTC)
+		if seg.CamUUID != "cam" || seg.FileName != "2026-10-17_12-00-00-500000.mp4" ||
+			!seg.StartTs.Equal(start) || !seg.EndTs.Equal(start.Add(1500*time.Millisecond)) {
+			t.Errorf("unexpected segment: %+v", seg)
+		}
+
+		if len(bus.published) != 1 {
+			t.Fatalf("expected a single published segment, got: %v", bus.published)
+		}
+		msg := bus.published[0]
+		var published v1.RecordingSegmentEvent
+		if err := json.Unmarshal(msg.body, &published); err != nil {
+			t.Fatalf("failed to parse the published segment: %v", err)
+		}
+		if msg.exch != v1.RecordingSegmentsExchange || msg.key != "cam" || published != ev {
+			t.Errorf("unexpected published segment: %s %s %+v", msg.exch, msg.key, published)
+		}
+	})
+
+	t.Run("motion only camera - should only publish the segment", func(t *testing.T) {
+		svc, _, segmentsRepo, _, bus := setupContinuousRecordingServiceTest(t)
+
+		if err := svc.IndexSegment(context.Background(), ev); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if len(segmentsRepo.segments) != 0 {
+			t.Errorf("expected nothing to be indexed, got: %v", segmentsRepo.segments)
+		}
+		if len(bus.published) != 1 {
+			t.Errorf("expected the segment to be published, got: %v", bus.published)
+		}
+	})
+
+	t.Run("malformed segment - should return ErrInvalidSegment", func(t *testing.T) {
+		svc, settingsRepo, _, _, bus := setupContinuousRecordingServiceTest(t)
+		settingsRepo.settings["cam"] = &models.RecordingSettings{UUID: "cam", Continuous: true, RetentionDays: 7}
+
+		bad := ev
+		bad.Duration = "soon"
+		if err := svc.IndexSegment(context.Background(), bad); !errors.Is(err, ErrInvalidSegment) {
+			t.Fatalf("expected ErrInvalidSegment, got: %v", err)
+		}
+		if len(bus.published) != 0 {
+			t.Errorf("expected nothing to be published, got: %v", bus.published)
+		}
+	})
+}
+
+func TestContinuousRecordingTimeline(t *testing.T) {
+	from := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	t.Run("spans crossing the day - should be clipped and get playback urls", func(t *testing.T) {
+		svc, _, segmentsRepo, _, _ := setupContinuousRecordingServiceTest(t)
+		segmentsRepo.spans = []*models.RecordingSpan{
+			{MtxPath: "cam/main", StartTs: from.Add(-time.Hour), EndTs: from.Add(time.Hour)},
+			{MtxPath: "cam/sub", StartTs: from.Add(23 * time.Hour), EndTs: to.Add(time.Hour)},
+		}
+
+		got, err := svc.Timeline(context.Background(), "cam", from, to)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if len(got.Spans) != 2 {
+			t.Fatalf("expected 2 spans, got: %+v", got.Spans)
+		}
+		first, last := got.Spans[0], got.Spans[1]
+		if !first.Start.Equal(from) || !first.End.Equal(from.Add(time.Hour)) || first.Profile != models.StreamMain {
+			t.Errorf("unexpected first span: %+v", first)
+		}
+		if !last.End.Equal(to) || last.Profile != models.StreamSub {
+			t.Errorf("unexpected last span: %+v", last)
+		}
+		expected := "/api/v1/cameras/cam/recording/playback?duration=3600&profile=main&start=2026-10-17T00%3A00%3A00Z"
+		if first.PlaybackUrl != expected {
+			t.Errorf("expected: %s, got: %s", expected, first.PlaybackUrl)
+		}
+	})
+
+	t.Run("unknown camera - should return ErrCameraNotFound", func(t *testing.T) {
+		svc, _, _, _, _ := setupContinuousRecordingServiceTest(t)
+
+		if _, err := svc.Timeline(context.Background(), "missing", from, to); !errors.Is(err, ErrCameraNotFound) {
+			t.Fatalf("expected ErrCameraNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestContinuousRecordingPlayback(t *testing.T) {
+	start := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	t.Run("recorded span - should stream the clip from the playback server", func(t *testing.T) {
+		svc, _, _, mtx, _ := setupContinuousRecordingServiceTest(t)
+		mtx.playback = []byte("clip")
+
+		resp, err := svc.Playback(context.Background(), "cam", models.StreamSub, start, time.Minute)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "clip" || resp.Header.Get("Content-Type") != "video/mp4" {
+			t.Errorf("expected the clip, got: %q (%s)", body, resp.Header.Get("Content-Type"))
+		}
+		if !slices.Contains(mtx.requests, "GET /get") {
+			t.Errorf("expected the playback server to be asked, got: %v", mtx.requests)
+		}
+	})
+
+	t.Run("nothing recorded - should return ErrNoRecordings", func(t *testing.T) {
+		svc, _, _, _, _ := setupContinuousRecordingServiceTest(t)
+
+		if _, err := svc.Playback(context.Background(), "cam", models.StreamMain, start, time.Minute); !errors.Is(err, mtxapi.ErrNoRecordings) {
+			t.Fatalf("expected ErrNoRecordings, got: %v", err)
+		}
+	})
+
+	t.Run("bad profile or duration - should return ErrInvalidPlayback", func(t *testing.T) {
+		svc, _, _, mtx, _ := setupContinuousRecordingServiceTest(t)
+
+		for _, tt := range []struct {
+			profile  string
+			duration time.Duration
+		}{
+			{"hd", time.Minute},
+			{models.StreamMain, 0},
+			{models.StreamMain, maxPlaybackDuration + time.Second},
+		} {
+			if _, err := svc.Playback(context.Background(), "cam", tt.profile, start, tt.duration); !errors.Is(err, ErrInvalidPlayback) {
+				t.Errorf("expected ErrInvalidPlayback for %s %s, got: %v", tt.profile, tt.duration, err)
+			}
+		}
+		if len(mtx.requests) != 0 {
+			t.Errorf("expected the playback server not to be asked, got: %v", mtx.requests)
+		}
+	})
+
+	t.Run("unknown camera - should return ErrCameraNotFound", func(t *testing.T) {
+		svc, _, _, _, _ := setupContinuousRecordingServiceTest(t)
+
+		if _, err := svc.Playback(context.Background(), "missing", models.StreamMain, start, time.Minute); !errors.Is(err, ErrCameraNotFound) {
+			t.Fatalf("expected ErrCameraNotFound, got: %v", err)
+		}
+	})
+}
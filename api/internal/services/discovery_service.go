@@ -73,7 +73,7 @@ func (svc *DiscoveryService) Discover(ctx context.Context) {
 				At:   time.Now(),
 			}
 
-			streamUrl, err := svc.MtxClient.Publish(ctx, match.UUID)
+			urls, err := svc.MtxClient.Publish(ctx, match.UUID)
 			if err != nil {
 				svc.Logger.Warn("failed to publish stream to mediamtx", "newDevice", true, "err", err)
 				continue
@@ -81,9 +81,10 @@ func (svc *DiscoveryService) Discover(ctx context.Context) {
 
 			svc.CamsProxyEventCh <- v1.CameraProxyEvent{
 				CameraPairedEvent: &v1.CameraPairedEvent{
-					UUID:      match.UUID,
-					StreamUrl: streamUrl,
-					Revision:  version,
+					UUID:       match.UUID,
+					StreamUrl:  urls.Motion,
+					RecordPath: urls.RecordPath,
+					Revision:   version,
 				},
 			}
 		case hydrateNewDevice:
@@ -94,7 +95,7 @@ func (svc *DiscoveryService) Discover(ctx context.Context) {
 				At:   time.Now(),
 			}
 		default:
-			streamUrl, err := svc.MtxClient.Publish(ctx, match.UUID)
+			urls, err := svc.MtxClient.Publish(ctx, match.UUID)
 			if err != nil {
 				svc.Logger.Warn("failed to publish stream to mediamtx", "newDevice", true, "err", err)
 				continue
@@ -102,9 +103,10 @@ func (svc *DiscoveryService) Discover(ctx context.Context) {
 
 			svc.CamsProxyEventCh <- v1.CameraProxyEvent{
 				CameraPairedEvent: &v1.CameraPairedEvent{
-					UUID:      match.UUID,
-					StreamUrl: streamUrl,
-					Revision:  version,
+					UUID:       match.UUID,
+					StreamUrl:  urls.Motion,
+					RecordPath: urls.RecordPath,
+					Revision:   version,
 				},
 			}
 			if os.Getenv("ENV_TYPE") == "dev" {
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	inmemory "tomerab.com/cam-hub/internal/events/in_memory"
+	"tomerab.com/cam-hub/internal/mtxapi"
+	objectstorage "tomerab.com/cam-hub/internal/object_storage"
+	"tomerab.com/cam-hub/internal/repos"
+	"tomerab.com/cam-hub/internal/segments"
+)
+
+const (
+	exportsUrlExpiry       = 15 * time.Minute
+	exportJobTimeout       = 15 * time.Minute
+	exportsCleanupInterval = 10 * time.Minute
+	exportsPrefix          = "exports/"
+	// Progress events are only broadcast once the progress moved by this much.
+	exportProgressStep = 0.01
+)
+
+var (
+	ErrInvalidExportRange = errors.New("invalid export range")
+	ErrNoRecordings       = errors.New("no recordings in range")
+	ErrTooManyExports     = errors.New("too many exports in progress")
+	ErrExportNotFound     = errors.New("export not found")
+)
+
+// AssembleFunc writes the [from, to) clip of the segments to out, see segments.Trim.
+type AssembleFunc func(ctx context.Context, logger *slog.Logger, out string, segs []segments.Segment, from, to time.Time, onProgress func(float64)) error
+
+type exportJob struct {
+	v1.ExportJob
+	objKey string
+}
+
+// ExportsService cuts clips of arbitrary time ranges out of the recorder's segments. Jobs run in
+// the background and are only kept in memory, their clips are removed from the bucket along with
+// them once they are older than TTL. Clips whose job was lost to a restart are swept by their
+// age.
+type ExportsService struct {
+	CamRepo     repos.CameraRepoIface
+	MtxClient   *mtxapi.MtxClient
+	Store       objectstorage.ObjectStoreIface
+	PubSub      *inmemory.InMemoryPubSub
+	Sched       gocron.Scheduler
+	Logger      *slog.Logger
+	Bucket      string
+	MaxDuration time.Duration // longest clip that can be exported
+	MaxJobs     int           // exports assembled at the same time, the rest are rejected
+	TTL         time.Duration
+	Assemble    AssembleFunc // defaults to segments.Trim
+
+	mtx  sync.Mutex
+	jobs map[string]*exportJob
+}
+
+func (svc *ExportsService) InitJobs(ctx context.Context) error {
+	job, err := svc.Sched.NewJob(
+		gocron.DurationJob(exportsCleanupInterval),
+		gocron.NewTask(func() {
+			svc.Cleanup(time.Now())
+		}),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	svc.Logger.Info("Scheduled exports cleanup", "jobid", job.ID(), "interval", exportsCleanupInterval, "ttl", svc.TTL)
+	return nil
+}
+
+// Topic is the PubSub topic the progress of the export is broadcast on.
+func (svc *ExportsService) Topic(id string) string {
+	return "exports/" + id
+}
+
+// Create validates the range against the camera's recordings and starts assembling the clip,
+// the returned job is queued.
+func (svc *ExportsService) Create(ctx context.Context, camUUID string, req v1.ExportReq) (*v1.ExportJob, error) {
+	start, end := req.Start.UTC(), req.End.UTC()
+	if start.IsZero() || !end.After(start) {
+		return nil, fmt.Errorf("%w: end must be after start", ErrInvalidExportRange)
+	}
+	if end.Sub(start) > svc.MaxDuration {
+		return nil, fmt.Errorf("%w: exports are limited to %s", ErrInvalidExportRange, svc.MaxDuration)
+	}
+	if end.After(time.Now()) {
+		return nil, fmt.Errorf("%w: end is in the future", ErrInvalidExportRange)
+	}
+
+	if _, err := svc.CamRepo.FindOne(ctx, camUUID); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, ErrCameraNotFound
+		}
+		return nil, err
+	}
+
+	// Segments of a profile the recorder read before the selection changed aren't looked at.
+	selection, err := svc.MtxClient.Selection(ctx, camUUID)
+	if err != nil {
+		return nil, err
+	}
+	segs, err := segments.InRange(mtxapi.PathName(camUUID, selection.Recorder), start, end)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(segs) == 0) {
+		return nil, ErrNoRecordings
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	id := uuid.NewString()
+	job := &exportJob{
+		ExportJob: v1.ExportJob{
+			Id:        id,
+			UUID:      camUUID,
+			Start:     start,
+			End:       end,
+			Status:    v1.ExportQueued,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		objKey: path.Join(exportsPrefix, camUUID, id+".mp4"),
+	}
+
+	svc.mtx.Lock()
+	if svc.jobs == nil {
+		svc.jobs = make(map[string]*exportJob)
+	}
+	if svc.activeJobs() >= svc.MaxJobs {
+		svc.mtx.Unlock()
+		return nil, ErrTooManyExports
+	}
+	svc.jobs[id] = job
+	snapshot := job.ExportJob
+	svc.mtx.Unlock()
+
+	go svc.run(job, segs)
+
+	return &snapshot, nil
+}
+
+// Get returns the export, finished exports get a fresh download url.
+func (svc *ExportsService) Get(ctx context.Context, id string) (*v1.ExportJob, error) {
+	svc.mtx.Lock()
+	job, ok := svc.jobs[id]
+	if !ok {
+		svc.mtx.Unlock()
+		return nil, ErrExportNotFound
+	}
+	snapshot, objKey := job.ExportJob, job.objKey
+	svc.mtx.Unlock()
+
+	if snapshot.Status == v1.ExportDone {
+		if err := svc.attachUrl(&snapshot, objKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return &snapshot, nil
+}
+
+// Cleanup forgets the finished exports older than TTL and removes their clips, along with the
+// clips no job knows of anymore.
+func (svc *ExportsService) Cleanup(now time.Time) {
+	svc.mtx.Lock()
+	var expired []*exportJob
+	for id, job := range svc.jobs {
+		finished := job.Status == v1.ExportDone || job.Status == v1.ExportFailed
+		if finished && now.Sub(job.UpdatedAt) > svc.TTL {
+			expired = append(expired, job)
+			delete(svc.jobs, id)
+		}
+	}
+	svc.mtx.Unlock()
+
+	for _, job := range expired {
+		svc.PubSub.Purge(svc.Topic(job.Id))
+		if job.Status != v1.ExportDone {
+			continue
+		}
+		if err := svc.Store.RemoveObject(svc.Bucket, job.objKey); err != nil {
+			svc.Logger.Error("exports: failed to remove clip", "id", job.Id, "key", job.objKey, "err", err)
+		}
+	}
+
+	if len(expired) > 0 {
+		svc.Logger.Info("exports: removed expired exports", "removed", len(expired))
+	}
+
+	// The jobs are only kept in memory, a restart loses them along with their clips. Those are
+	// older than any known job's by an interval, the known ones were removed above.
+	orphans, err := svc.Store.RemoveObjectsBefore(svc.Bucket, exportsPrefix, now.Add(-(svc.TTL + exportsCleanupInterval)))
+	if err != nil {
+		svc.Logger.Error("exports: failed to sweep orphaned clips", "removed", orphans, "err", err)
+	} else if orphans > 0 {
+		svc.Logger.Info("exports: removed orphaned clips", "removed", orphans)
+	}
+}
+
+func (svc *ExportsService) run(job *exportJob, segs []segments.Segment) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportJobTimeout)
+	defer cancel()
+
+	logger := svc.Logger.With("id", job.Id, "uuid", job.UUID)
+	assemble := svc.Assemble
+	if assemble == nil {
+		assemble = segments.Trim
+	}
+
+	svc.update(job, func(j *exportJob) { j.Status = v1.ExportRunning })
+
+	out := filepath.Join(os.TempDir(), "export-"+job.Id+".mp4")
+	defer os.Remove(out)
+
+	if err := assemble(ctx, logger, out, segs, job.Start, job.End, func(p float64) {
+		svc.progress(job, p)
+	}); err != nil {
+		logger.Error("exports: failed to assemble clip", "err", err)
+		svc.fail(job, "failed to assemble the clip")
+		return
+	}
+
+	svc.update(job, func(j *exportJob) { j.Status = v1.ExportUploading })
+	if err := svc.Store.FPutObject(svc.Bucket, job.objKey, out, objectstorage.UrlVideo); err != nil {
+		logger.Error("exports: failed to upload clip", "key", job.objKey, "err", err)
+		svc.fail(job, "failed to upload the clip")
+		return
+	}
+
+	svc.update(job, func(j *exportJob) {
+		j.Status = v1.ExportDone
+		j.Progress = 1
+	})
+	logger.Info("exports: clip exported", "key", job.objKey, "start", job.Start, "end", job.End)
+}
+
+func (svc *ExportsService) progress(job *exportJob, p float64) {
+	svc.mtx.Lock()
+	moved := p-job.Progress >= exportProgressStep
+	svc.mtx.Unlock()
+
+	if moved {
+		svc.update(job, func(j *exportJob) { j.Progress = p })
+	}
+}
+
+func (svc *ExportsService) fail(job *exportJob, msg string) {
+	svc.update(job, func(j *exportJob) {
+		j.Status = v1.ExportFailed
+		j.Error = msg
+	})
+}
+
+// update applies fn to the job and broadcasts its new state.
+func (svc *ExportsService) update(job *exportJob, fn func(j *exportJob)) {
+	svc.mtx.Lock()
+	fn(job)
+	job.UpdatedAt = time.Now()
+	snapshot := job.ExportJob
+	svc.mtx.Unlock()
+
+	if snapshot.Status == v1.ExportDone {
+		if err := svc.attachUrl(&snapshot, job.objKey); err != nil {
+			svc.Logger.Error("exports: failed to presign clip url", "id", job.Id, "err", err)
+		}
+	}
+
+	bytes, err := json.Marshal(snapshot)
+	if err != nil {
+		svc.Logger.Error("exports: failed to marshal export", "id", job.Id, "err", err)
+		return
+	}
+	svc.PubSub.Broadcast(svc.Topic(job.Id), bytes)
+}
+
+func (svc *ExportsService) attachUrl(job *v1.ExportJob, objKey string) error {
+	url, err := svc.Store.PresignedViewUrl(svc.Bucket, objKey, objectstorage.UrlVideo, exportsUrlExpiry)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(exportsUrlExpiry)
+	job.DownloadUrl = url
+	job.UrlExpiresAt = &expiresAt
+	return nil
+}
+
+// activeJobs must be called with svc.mtx held.
+func (svc *ExportsService) activeJobs() int {
+	n := 0
+	for _, job := range svc.jobs {
+		if job.Status != v1.ExportDone && job.Status != v1.ExportFailed {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	inmemory "tomerab.com/cam-hub/internal/events/in_memory"
+	"tomerab.com/cam-hub/internal/mtxapi"
+	"tomerab.com/cam-hub/internal/segments"
+)
+
+func setupExportsServiceTest(t *testing.T, assemble AssembleFunc) (*ExportsService, *fakeObjectStore, time.Time) {
+	t.Helper()
+
+	root := t.TempDir()
+	t.Setenv("RECORDINGS_DIR", root)
+
+	// An hour old minute of recordings of the default recorder profile.
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Minute)
+	dir := filepath.Join(root, "cam", models.StreamMain)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create recordings dir: %v", err)
+	}
+	for i := range 3 {
+		name := base.Add(time.Duration(i)*20*time.Second).Format("2006-01-02_15-04-05") + "-000000.mp4"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("segment"), 0644); err != nil {
+			t.Fatalf("failed to create segment: %v", err)
+		}
+	}
+
+	store := &fakeObjectStore{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return &ExportsService{
+		CamRepo: &fakeCameraRepo{cams: map[string]*models.Camera{"cam": {UUID: "cam"}}},
+		MtxClient: &mtxapi.MtxClient{
+			Logger:        logger,
+			SelectionRepo: &fakeStreamSelectionRepo{selections: map[string]*models.StreamSelection{}},
+		},
+		Store:       store,
+		PubSub:      inmemory.NewInMemoryPubSub(),
+		Logger:      logger,
+		Bucket:      "recordings",
+		MaxDuration: 10 * time.Minute,
+		MaxJobs:     1,
+		TTL:         time.Hour,
+		Assemble:    assemble,
+	}, store, base
+}
+
+// readExportEvents reads the export's events until it finishes.
+func readExportEvents(t *testing.T, ch chan []byte) v1.ExportJob {
+	t.Helper()
+
+	for {
+		select {
+		case msg := <-ch:
+			var job v1.ExportJob
+			if err := json.Unmarshal(msg, &job); err != nil {
+				t.Fatalf("failed to parse export event: %v", err)
+			}
+			if job.Status == v1.ExportDone || job.Status == v1.ExportFailed {
+				return job
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for the export")
+		}
+	}
+}
+
+// waitForExport polls the export until it finishes.
+func waitForExport(t *testing.T, svc *ExportsService, id string) v1.ExportJob {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := svc.Get(context.Background(), id)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if job.Status == v1.ExportDone || job.Status == v1.ExportFailed {
+			return *job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("timed out waiting for the export")
+	return v1.ExportJob{}
+}
+
+func TestExportsCreate(t *testing.T) {
+	t.Run("recorded range - should assemble, upload and presign the clip", func(t *testing.T) {
+		var gotSegs []segments.Segment
+		subscribed := make(chan struct{})
+		svc, store, base := setupExportsServiceTest(t, func(ctx context.Context, logger *slog.Logger, out string, segs []segments.Segment, from, to time.Time, onProgress func(float64)) error {
+			<-subscribed
+			gotSegs = segs
+			onProgress(0.5)
+			onProgress(1)
+			return os.WriteFile(out, []byte("clip"), 0644)
+		})
+
+		job, err := svc.Create(context.Background(), "cam", v1.ExportReq{Start: base.Add(25 * time.Second), End: base.Add(45 * time.Second)})
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if job.Status != v1.ExportQueued || job.UUID != "cam" {
+			t.Errorf("expected a queued export, got: %+v", job)
+		}
+
+		ch := svc.PubSub.Subscribe(svc.Topic(job.Id))
+		defer svc.PubSub.Unsubscribe(svc.Topic(job.Id), ch)
+		close(subscribed)
+
+		done := readExportEvents(t, ch)
+		if done.Status != v1.ExportDone || done.Progress != 1 || done.DownloadUrl == "" || done.UrlExpiresAt == nil {
+			t.Errorf("expected a finished export with a download url, got: %+v", done)
+		}
+
+		if len(gotSegs) != 2 || !gotSegs[0].Start.Equal(base.Add(20*time.Second)) {
+			t.Errorf("expected the last 2 segments, got: %+v", gotSegs)
+		}
+		key := "exports/cam/" + job.Id + ".mp4"
+		if !slices.Equal(store.uploaded, []string{key}) {
+			t.Errorf("expected %s to be uploaded, got: %v", key, store.uploaded)
+		}
+
+		got, err := svc.Get(context.Background(), job.Id)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if got.DownloadUrl != "http://minio/recordings/"+key {
+			t.Errorf("unexpected download url: %s", got.DownloadUrl)
+		}
+	})
+
+	t.Run("failed assembly - should fail the export", func(t *testing.T) {
+		svc, store, base := setupExportsServiceTest(t, func(ctx context.Context, logger *slog.Logger, out string, segs []segments.Segment, from, to time.Time, onProgress func(float64)) error {
+			return errors.New("ffmpeg failed")
+		})
+
+		job, err := svc.Create(context.Background(), "cam", v1.ExportReq{Start: base, End: base.Add(time.Minute)})
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		done := waitForExport(t, svc, job.Id)
+		if done.Status != v1.ExportFailed || done.Error == "" || done.DownloadUrl != "" {
+			t.Errorf("expected a failed export, got: %+v", done)
+		}
+		if len(store.uploaded) != 0 {
+			t.Errorf("expected nothing to be uploaded, got: %v", store.uploaded)
+		}
+	})
+
+	t.Run("invalid range - should return ErrInvalidExportRange", func(t *testing.T) {
+		svc, _, base := setupExportsServiceTest(t, nil)
+
+		for _, req := range []v1.ExportReq{
+			{Start: base.Add(time.Minute), End: base},
+			{Start: base, End: base.Add(svc.MaxDuration + time.Second)},
+			{Start: time.Now(), End: time.Now().Add(time.Minute)},
+		} {
+			if _, err := svc.Create(context.Background(), "cam", req); !errors.Is(err, ErrInvalidExportRange) {
+				t.Errorf("expected ErrInvalidExportRange for %+v, got: %v", req, err)
+			}
+		}
+	})
+
+	t.Run("range without recordings - should return ErrNoRecordings", func(t *testing.T) {
+		svc, _, base := setupExportsServiceTest(t, nil)
+
+		_, err := svc.Create(context.Background(), "cam", v1.ExportReq{Start: base.Add(-5 * time.Minute), End: base.Add(-time.Minute)})
+		if !errors.Is(err, ErrNoRecordings) {
+			t.Fatalf("expected ErrNoRecordings, got: %v", err)
+		}
+	})
+
+	t.Run("unknown camera - should return ErrCameraNotFound", func(t *testing.T) {
+		svc, _, base := setupExportsServiceTest(t, nil)
+
+		_, err := svc.Create(context.Background(), "missing", v1.ExportReq{Start: base, End: base.Add(time.Minute)})
+		if !errors.Is(err, ErrCameraNotFound) {
+			t.Fatalf("expected ErrCameraNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("too many exports - should return ErrTooManyExports", func(t *testing.T) {
+		release := make(chan struct{})
+		svc, _, base := setupExportsServiceTest(t, func(ctx context.Context, logger *slog.Logger, out string, segs []segments.Segment, from, to time.Time, onProgress func(float64)) error {
+			<-release
+			return errors.New("released")
+		})
+		defer close(release)
+
+		req := v1.ExportReq{Start: base, End: base.Add(time.Minute)}
+		if _, err := svc.Create(context.Background(), "cam", req); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if _, err := svc.Create(context.Background(), "cam", req); !errors.Is(err, ErrTooManyExports) {
+			t.Fatalf("expected ErrTooManyExports, got: %v", err)
+		}
+	})
+}
+
+func TestExportsCleanup(t *testing.T) {
+	t.Run("expired exports - should be forgotten and their clips removed", func(t *testing.T) {
+		svc, store, base := setupExportsServiceTest(t, func(ctx context.Context, logger *slog.Logger, out string, segs []segments.Segment, from, to time.Time, onProgress func(float64)) error {
+			return os.WriteFile(out, []byte("clip"), 0644)
+		})
+
+		job, err := svc.Create(context.Background(), "cam", v1.ExportReq{Start: base, End: base.Add(time.Minute)})
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		waitForExport(t, svc, job.Id)
+
+		svc.Cleanup(time.Now())
+		if _, err := svc.Get(context.Background(), job.Id); err != nil {
+			t.Fatalf("expected the export to be kept within its ttl, got: %v", err)
+		}
+
+		svc.Cleanup(time.Now().Add(svc.TTL + time.Minute))
+		if _, err := svc.Get(context.Background(), job.Id); !errors.Is(err, ErrExportNotFound) {
+			t.Fatalf("expected ErrExportNotFound, got: %v", err)
+		}
+		if !slices.Equal(store.removed, []string{"exports/cam/" + job.Id + ".mp4"}) {
+			t.Errorf("expected the clip to be removed, got: %v", store.removed)
+		}
+	})
+
+	t.Run("clips of lost jobs - should be swept once older than any known job", func(t *testing.T) {
+		svc, store, _ := setupExportsServiceTest(t, nil)
+		now := time.Now()
+
+		svc.Cleanup(now)
+		before, ok := store.sweptBefore["exports/"]
+		if !ok {
+			t.Fatalf("expected the exports to be swept, got: %v", store.sweptBefore)
+		}
+		if !before.Before(now.Add(-svc.TTL)) {
+			t.Errorf("expected only clips older than the ttl to be swept, got: %s", before)
+		}
+	})
+}
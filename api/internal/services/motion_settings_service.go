@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/events"
+	"tomerab.com/cam-hub/internal/repos"
+	"tomerab.com/cam-hub/internal/utils"
+	"tomerab.com/cam-hub/internal/zones"
+)
+
+const (
+	maxMotionThreshold = 255
+	minMotionTickMs    = 10
+	maxMotionTickMs    = 5000
+	minMotionEventMs   = 1000
+	// The segments of the longest clip have to outlive its processing, see
+	// v1.MotionSegmentsRetention.
+	maxMotionRollMs  = v1.MaxMotionRollMs
+	maxMotionEventMs = v1.MaxMotionEventMs
+)
+
+var (
+	ErrCameraNotFound        = errors.New("camera not found")
+	ErrInvalidMotionSettings = errors.New("invalid motion settings")
+)
+
+type MotionSettingsService struct {
+	SettingsRepo repos.MotionSettingsRepoIface
+	ZonesRepo    repos.MotionZonesRepoIface
+	CamRepo      repos.CameraRepoIface
+	Bus          events.BusIface
+	Logger       *slog.Logger
+}
+
+// Get returns the camera's motion settings, cameras that were never configured get the defaults.
+func (svc *MotionSettingsService) Get(ctx context.Context, uuid string) (*v1.MotionSettings, error) {
+	if err := svc.ensureCamera(ctx, uuid); err != nil {
+		return nil, err
+	}
+
+	settings, err := svc.SettingsRepo.FindOne(ctx, uuid)
+	if errors.Is(err, repos.ErrMotionSettingsNotFound) {
+		defaults := v1.DefaultMotionSettings()
+		return &defaults, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.MotionSettings{
+		Threshold:    settings.Threshold,
+		MinArea:      settings.MinArea,
+		WarmupFrames: settings.WarmupFrames,
+		TickMs:       settings.TickMs,
+		CooldownMs:   settings.CooldownMs,
+		PreRollMs:    settings.PreRollMs,
+		PostRollMs:   settings.PostRollMs,
+		MaxEventMs:   settings.MaxEventMs,
+		Source:       settings.Source,
+	}, nil
+}
+
+// Update stores the settings and notifies the camera's running detector.
+func (svc *MotionSettingsService) Update(ctx context.Context, uuid string, settings v1.MotionSettings) error {
+	if err := validateMotionSettings(settings); err != nil {
+		return err
+	}
+
+	if err := svc.ensureCamera(ctx, uuid); err != nil {
+		return err
+	}
+
+	if err := svc.SettingsRepo.Upsert(ctx, &models.MotionSettings{
+		UUID:         uuid,
+		Threshold:    settings.Threshold,
+		MinArea:      settings.MinArea,
+		WarmupFrames: settings.WarmupFrames,
+		TickMs:       settings.TickMs,
+		CooldownMs:   settings.CooldownMs,
+		PreRollMs:    settings.PreRollMs,
+		PostRollMs:   settings.PostRollMs,
+		MaxEventMs:   settings.MaxEventMs,
+		Source:       settings.Source,
+	}); err != nil {
+		return err
+	}
+
+	return svc.publish(ctx, uuid, v1.MotionConfigTypeSettings, v1.MotionSettingsEvent{UUID: uuid, Settings: settings})
+}
+
+// GetZones returns the camera's include/exclude zones, an empty list means the whole frame is watched.
+func (svc *MotionSettingsService) GetZones(ctx context.Context, uuid string) ([]zones.Zone, error) {
+	if err := svc.ensureCamera(ctx, uuid); err != nil {
+		return nil, err
+	}
+
+	rows, err := svc.ZonesRepo.FindByCamera(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.Map(rows, func(row *models.MotionZone) zones.Zone {
+		return zones.Zone{Name: row.Name, Kind: row.Kind, Points: row.Points}
+	}), nil
+}
+
+// UpdateZones replaces all the zones of the camera and notifies its running detector.
+func (svc *MotionSettingsService) UpdateZones(ctx context.Context, uuid string, zs []zones.Zone) error {
+	if err := zones.Validate(zs); err != nil {
+		return err
+	}
+
+	if err := svc.ensureCamera(ctx, uuid); err != nil {
+		return err
+	}
+
+	rows := utils.Map(zs, func(z zones.Zone) *models.MotionZone {
+		return &models.MotionZone{CamUUID: uuid, Name: z.Name, Kind: z.Kind, Points: z.Points}
+	})
+	if err := svc.ZonesRepo.Replace(ctx, uuid, rows); err != nil {
+		return err
+	}
+
+	return svc.publish(ctx, uuid, v1.MotionConfigTypeZones, v1.MotionZonesEvent{UUID: uuid, Zones: zs})
+}
+
+func (svc *MotionSettingsService) ensureCamera(ctx context.Context, uuid string) error {
+	if _, err := svc.CamRepo.FindOne(ctx, uuid); err != nil {
+		if pgxscan.NotFound(err) {
+			return ErrCameraNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// A failed notification is only logged since the detector picks the stored
+// config up on its next start.
+func (svc *MotionSettingsService) publish(ctx context.Context, uuid, typ string, ev any) error {
+	bytes, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]any{v1.MotionConfigTypeHeader: typ}
+	if err := svc.Bus.Publish(ctx, v1.MotionConfigExchange, uuid, bytes, headers); err != nil {
+		svc.Logger.Warn("failed to publish motion config", "uuid", uuid, "type", typ, "err", err)
+	}
+
+	return nil
+}
+
+func validateMotionSettings(settings v1.MotionSettings) error {
+	switch {
+	case settings.Threshold <= 0 || settings.Threshold >= maxMotionThreshold:
+		return fmt.Errorf("%w: threshold must be in (0, %d)", ErrInvalidMotionSettings, maxMotionThreshold)
+	case settings.MinArea < 0:
+		return fmt.Errorf("%w: min_area must not be negative", ErrInvalidMotionSettings)
+	case settings.WarmupFrames < 0:
+		return fmt.Errorf("%w: warmup_frames must not be negative", ErrInvalidMotionSettings)
+	case settings.TickMs < minMotionTickMs || settings.TickMs > maxMotionTickMs:
+		return fmt.Errorf("%w: tick_ms must be in [%d, %d]", ErrInvalidMotionSettings, minMotionTickMs, maxMotionTickMs)
+	case settings.CooldownMs < 0:
+		return fmt.Errorf("%w: cooldown_ms must not be negative", ErrInvalidMotionSettings)
+	case settings.PreRollMs < 0 || settings.PreRollMs > maxMotionRollMs:
+		return fmt.Errorf("%w: pre_roll_ms must be in [0, %d]", ErrInvalidMotionSettings, maxMotionRollMs)
+	case settings.PostRollMs < 0 || settings.PostRollMs > maxMotionRollMs:
+		return fmt.Errorf("%w: post_roll_ms must be in [0, %d]", ErrInvalidMotionSettings, maxMotionRollMs)
+	case settings.MaxEventMs < minMotionEventMs || settings.MaxEventMs > maxMotionEventMs:
+		return fmt.Errorf("%w: max_event_ms must be in [%d, %d]", ErrInvalidMotionSettings, minMotionEventMs, maxMotionEventMs)
+	case !v1.ValidMotionSource(settings.Source):
+		return fmt.Errorf("%w: source must be one of %s, %s, %s", ErrInvalidMotionSettings,
+			v1.MotionSourceServer, v1.MotionSourceCamera, v1.MotionSourceBoth)
+	}
+
+	return nil
+}
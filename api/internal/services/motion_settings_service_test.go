@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/events"
+	"tomerab.com/cam-hub/internal/repos"
+	"tomerab.com/cam-hub/internal/zones"
+)
+
+type fakeCameraRepo struct {
+	repos.CameraRepoIface
+	cams map[string]*models.Camera
+}
+
+func (repo *fakeCameraRepo) FindOne(ctx context.Context, uuid string) (*models.Camera, error) {
+	cam, ok := repo.cams[uuid]
+	if !ok {
+		return &models.Camera{}, pgx.ErrNoRows
+	}
+	return cam, nil
+}
+
+type fakeMotionSettingsRepo struct {
+	settings map[string]*models.MotionSettings
+}
+
+func (repo *fakeMotionSettingsRepo) FindOne(ctx context.Context, uuid string) (*models.MotionSettings, error) {
+	settings, ok := repo.settings[uuid]
+	if !ok {
+		return nil, repos.ErrMotionSettingsNotFound
+	}
+	return settings, nil
+}
+
+func (repo *fakeMotionSettingsRepo) Upsert(ctx context.Context, settings *models.MotionSettings) error {
+	repo.settings[settings.UUID] = settings
+	return nil
+}
+
+type fakeMotionZonesRepo struct {
+	zones map[string][]*models.MotionZone
+}
+
+func (repo *fakeMotionZonesRepo) FindByCamera(ctx context.Context, camUUID string) ([]*models.MotionZone, error) {
+	return repo.zones[camUUID], nil
+}
+
+func (repo *fakeMotionZonesRepo) Replace(ctx context.Context, camUUID string, zs []*models.MotionZone) error {
+	repo.zones[camUUID] = zs
+	return nil
+}
+
+type publishedMsg struct {
+	exch    string
+	key     string
+	body    []byte
+	headers map[string]any
+}
+
+type fakeBus struct {
+	events.BusIface
+	published []publishedMsg
+}
+
+func (bus *fakeBus) Publish(ctx context.Context, exch, key string, body []byte, headers map[string]any) error {
+	bus.published = append(bus.published, publishedMsg{exch: exch, key: key, body: body, headers: headers})
+	return nil
+}
+
+func setupMotionSettingsServiceTest() (*MotionSettingsService, *fakeMotionSettingsRepo, *fakeBus) {
+	repo := &fakeMotionSettingsRepo{settings: map[string]*models.MotionSettings{}}
+	bus := &fakeBus{}
+
+	return &MotionSettingsService{
+		SettingsRepo: repo,
+		ZonesRepo:    &fakeMotionZonesRepo{zones: map[string][]*models.MotionZone{}},
+		CamRepo:      &fakeCameraRepo{cams: map[string]*models.Camera{"cam": {UUID: "cam"}}},
+		Bus:          bus,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}, repo, bus
+}
+
+func TestMotionSettingsGet(t *testing.T) {
+	t.Run("never configured - should return the defaults", func(t *testing.T) {
+		svc, _, _ := setupMotionSettingsServiceTest()
+
+		got, err := svc.Get(context.Background(), "cam")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if *got != v1.DefaultMotionSettings() {
+			t.Errorf("expected defaults, got: %+v", got)
+		}
+	})
+
+	t.Run("unknown camera - should return ErrCameraNotFound", func(t *testing.T) {
+		svc, _, _ := setupMotionSettingsServiceTest()
+
+		if _, err := svc.Get(context.Background(), "missing"); !errors.Is(err, ErrCameraNotFound) {
+			t.Fatalf("expected ErrCameraNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestMotionSettingsUpdate(t *testing.T) {
+	t.Run("valid settings - should store and publish them", func(t *testing.T) {
+		svc, repo, bus := setupMotionSettingsServiceTest()
+		settings := v1.MotionSettings{
+			Threshold:    80,
+			MinArea:      4000,
+			WarmupFrames: 50,
+			TickMs:       100,
+			CooldownMs:   3000,
+			PreRollMs:    2000,
+			PostRollMs:   5000,
+			MaxEventMs:   120000,
+			Source:       v1.MotionSourceBoth,
+		}
+
+		if err := svc.Update(context.Background(), "cam", settings); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		stored, ok := repo.settings["cam"]
+		if !ok || stored.Threshold != 80 || stored.MinArea != 4000 || stored.TickMs != 100 {
+			t.Errorf("expected settings to be stored, got: %+v", stored)
+		}
+
+		if len(bus.published) != 1 {
+			t.Fatalf("expected 1 published message, got %d", len(bus.published))
+		}
+		msg := bus.published[0]
+		if msg.exch != v1.MotionConfigExchange || msg.key != "cam" {
+			t.Errorf("unexpected routing: exchange=%s key=%s", msg.exch, msg.key)
+		}
+		if msg.headers[v1.MotionConfigTypeHeader] != v1.MotionConfigTypeSettings {
+			t.Errorf("expected settings type header, got: %v", msg.headers)
+		}
+
+		var ev v1.MotionSettingsEvent
+		if err := json.Unmarshal(msg.body, &ev); err != nil {
+			t.Fatalf("failed to decode event: %v", err)
+		}
+		if ev.UUID != "cam" || ev.Settings != settings {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+
+		got, err := svc.Get(context.Background(), "cam")
+		if err != nil || *got != settings {
+			t.Errorf("expected stored settings back, got: %+v, err: %v", got, err)
+		}
+	})
+
+	t.Run("invalid settings - should be rejected", func(t *testing.T) {
+		svc, repo, bus := setupMotionSettingsServiceTest()
+
+		tests := []v1.MotionSettings{
+			{Threshold: 0, MinArea: 1, TickMs: 50, MaxEventMs: 60000},
+			{Threshold: 300, MinArea: 1, TickMs: 50, MaxEventMs: 60000},
+			{Threshold: 50, MinArea: -1, TickMs: 50, MaxEventMs: 60000},
+			{Threshold: 50, WarmupFrames: -1, TickMs: 50, MaxEventMs: 60000},
+			{Threshold: 50, TickMs: 1, MaxEventMs: 60000},
+			{Threshold: 50, TickMs: 50, CooldownMs: -1, MaxEventMs: 60000},
+			{Threshold: 50, TickMs: 50, PreRollMs: -1, MaxEventMs: 60000},
+			{Threshold: 50, TickMs: 50, PostRollMs: maxMotionRollMs + 1, MaxEventMs: 60000},
+			{Threshold: 50, TickMs: 50, MaxEventMs: 0},
+			{Threshold: 50, TickMs: 50, MaxEventMs: maxMotionEventMs + 1},
+			{Threshold: 50, TickMs: 50, MaxEventMs: 60000, Source: "cloud"},
+		}
+		for _, settings := range tests {
+			if err := svc.Update(context.Background(), "cam", settings); !errors.Is(err, ErrInvalidMotionSettings) {
+				t.Errorf("expected ErrInvalidMotionSettings for %+v, got: %v", settings, err)
+			}
+		}
+
+		if len(repo.settings) != 0 || len(bus.published) != 0 {
+			t.Errorf("expected nothing to be stored or published")
+		}
+	})
+
+	t.Run("unknown camera - should return ErrCameraNotFound", func(t *testing.T) {
+		svc, _, _ := setupMotionSettingsServiceTest()
+
+		err := svc.Update(context.Background(), "missing", v1.DefaultMotionSettings())
+		if !errors.Is(err, ErrCameraNotFound) {
+			t.Fatalf("expected ErrCameraNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestMotionZonesUpdate(t *testing.T) {
+	driveway := zones.Zone{
+		Name:   "driveway",
+		Kind:   zones.KindInclude,
+		Points: []zones.Point{{X: 0, Y: 0.5}, {X: 1, Y: 0.5}, {X: 1, Y: 1}, {X: 0, Y: 1}},
+	}
+
+	t.Run("valid zones - should replace and publish them", func(t *testing.T) {
+		svc, _, bus := setupMotionSettingsServiceTest()
+
+		if err := svc.UpdateZones(context.Background(), "cam", []zones.Zone{driveway}); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		got, err := svc.GetZones(context.Background(), "cam")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if !reflect.DeepEqual(got, []zones.Zone{driveway}) {
+			t.Errorf("expected stored zones back, got: %+v", got)
+		}
+
+		if len(bus.published) != 1 {
+			t.Fatalf("expected 1 published message, got %d", len(bus.published))
+		}
+		msg := bus.published[0]
+		if msg.key != "cam" || msg.headers[v1.MotionConfigTypeHeader] != v1.MotionConfigTypeZones {
+			t.Errorf("unexpected routing: key=%s headers=%v", msg.key, msg.headers)
+		}
+
+		var ev v1.MotionZonesEvent
+		if err := json.Unmarshal(msg.body, &ev); err != nil {
+			t.Fatalf("failed to decode event: %v", err)
+		}
+		if ev.UUID != "cam" || !reflect.DeepEqual(ev.Zones, []zones.Zone{driveway}) {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	})
+
+	t.Run("invalid zone - should be rejected", func(t *testing.T) {
+		svc, _, bus := setupMotionSettingsServiceTest()
+		invalid := driveway
+		invalid.Kind = "mask"
+
+		err := svc.UpdateZones(context.Background(), "cam", []zones.Zone{invalid})
+		if !errors.Is(err, zones.ErrInvalidZone) {
+			t.Fatalf("expected ErrInvalidZone, got: %v", err)
+		}
+		if len(bus.published) != 0 {
+			t.Errorf("expected nothing to be published")
+		}
+	})
+
+	t.Run("unknown camera - should return ErrCameraNotFound", func(t *testing.T) {
+		svc, _, _ := setupMotionSettingsServiceTest()
+
+		err := svc.UpdateZones(context.Background(), "missing", []zones.Zone{driveway})
+		if !errors.Is(err, ErrCameraNotFound) {
+			t.Fatalf("expected ErrCameraNotFound, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	inmemory "tomerab.com/cam-hub/internal/events/in_memory"
+	"tomerab.com/cam-hub/internal/repos"
+)
+
+const (
+	DefaultPtzLeaseTTL = 30 * time.Second
+
+	ptzLockKeyPrefix = "ptz:lock:"
+	minPtzLeaseMs    = 1000
+	maxPtzLeaseMs    = 10 * 60 * 1000
+	maxPtzLockTries  = 5
+)
+
+var ErrPtzLocked = errors.New("ptz is controlled by someone else")
+
+var ptzLockRanks = map[v1.PtzLockPriority]int{
+	v1.PtzLockAutomation: 1,
+	v1.PtzLockOperator:   2,
+	v1.PtzLockAdmin:      3,
+}
+
+// PtzLockService arbitrates the PTZ of each camera through a lease kept in redis, the lease
+// expires along with its key. A lease is only replaced by a compare-and-set against the one it
+// was decided on, so replicas sharing the redis never both take it. The mutex orders this
+// replica's own changes and their announcements.
+type PtzLockService struct {
+	Rdb    repos.RedisIface
+	PubSub *inmemory.InMemoryPubSub
+	TTL    time.Duration // lease length when none is asked for
+	Logger *slog.Logger
+
+	mtx      sync.Mutex
+	expiries map[string]*time.Timer // by camera, announces that the lease ran out
+}
+
+// PtzUserHolder is the lease holder of a user, admins outrank everyone else.
+func PtzUserHolder(user *models.User) v1.PtzLockHolder {
+	priority := v1.PtzLockOperator
+	if user.Role == v1.RoleAdmin {
+		priority = v1.PtzLockAdmin
+	}
+
+	return v1.PtzLockHolder{Id: "user:" + user.Id, Name: user.Username, Priority: priority}
+}
+
+// Topic is the PubSub topic the camera's lease changes are broadcast on.
+func (svc *PtzLockService) Topic(camUUID string) string {
+	return ptzLockKeyPrefix + camUUID
+}
+
+func (svc *PtzLockService) State(ctx context.Context, camUUID string) (*v1.PtzLockState, error) {
+	lease, _, err := svc.lease(ctx, camUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.PtzLockState{CamUUID: camUUID, Lease: lease}, nil
+}
+
+// Lock takes the lease on behalf of a user for the asked length.
+func (svc *PtzLockService) Lock(ctx context.Context, camUUID string, holder v1.PtzLockHolder, req v1.AcquirePtzLockReq) (*v1.PtzLease, error) {
+	var ttl time.Duration
+	if req.TtlMs != nil {
+		if *req.TtlMs < minPtzLeaseMs || *req.TtlMs > maxPtzLeaseMs {
+			return nil, fmt.Errorf("%w: ttl_ms must be between %d and %d", ErrInvalidPtzRequest, minPtzLeaseMs, maxPtzLeaseMs)
+		}
+		ttl = time.Duration(*req.TtlMs) * time.Millisecond
+	}
+
+	return svc.Acquire(ctx, camUUID, holder, ttl)
+}
+
+// Acquire grants the lease when the camera is free or held by a lower priority, and renews it
+// for its holder, a renewal never shortens the lease. The default length is used when ttl is 0.
+func (svc *PtzLockService) Acquire(ctx context.Context, camUUID string, holder v1.PtzLockHolder, ttl time.Duration) (*v1.PtzLease, error) {
+	if ttl <= 0 {
+		ttl = svc.TTL
+	}
+	if ttl <= 0 {
+		ttl = DefaultPtzLeaseTTL
+	}
+
+	svc.mtx.Lock()
+	defer svc.mtx.Unlock()
+
+	for range maxPtzLockTries {
+		cur, raw, err := svc.lease(ctx, camUUID)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		lease := &v1.PtzLease{Holder: holder, AcquiredAt: now, ExpiresAt: now.Add(ttl)}
+		if cur != nil {
+			switch {
+			case cur.Holder.Id == holder.Id:
+				lease.AcquiredAt = cur.AcquiredAt
+				if cur.ExpiresAt.After(lease.ExpiresAt) {
+					lease.ExpiresAt = cur.ExpiresAt
+				}
+			case !outranks(holder.Priority, cur.Holder.Priority):
+				return nil, lockedError(cur)
+			}
+		}
+
+		bytes, err := json.Marshal(lease)
+		if err != nil {
+			return nil, err
+		}
+		swapped, err := svc.Rdb.CompareAndSet(ctx, svc.Topic(camUUID), raw, string(bytes), time.Until(lease.ExpiresAt))
+		if err != nil {
+			return nil, err
+		}
+		if !swapped {
+			// changed since it was read, decide again against the new lease
+			continue
+		}
+
+		if cur != nil && cur.Holder.Id != holder.Id {
+			svc.Logger.Info("ptz lease preempted", "uuid", camUUID, "holder", holder.Id, "previous", cur.Holder.Id)
+		}
+		svc.announce(camUUID, lease)
+		return lease, nil
+	}
+
+	return nil, fmt.Errorf("%w: the lease kept changing", ErrPtzLocked)
+}
+
+// Release frees the camera, a lease of someone else may only be released by a higher priority.
+func (svc *PtzLockService) Release(ctx context.Context, camUUID string, holder v1.PtzLockHolder) error {
+	svc.mtx.Lock()
+	defer svc.mtx.Unlock()
+
+	for range maxPtzLockTries {
+		cur, raw, err := svc.lease(ctx, camUUID)
+		if err != nil || cur == nil {
+			return err
+		}
+		if cur.Holder.Id != holder.Id && !outranks(holder.Priority, cur.Holder.Priority) {
+			return lockedError(cur)
+		}
+
+		swapped, err := svc.Rdb.CompareAndSet(ctx, svc.Topic(camUUID), raw, "", 0)
+		if err != nil {
+			return err
+		}
+		if swapped {
+			svc.announce(camUUID, nil)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: the lease kept changing", ErrPtzLocked)
+}
+
+// lease returns the camera's current lease, nil when the camera is free, along with the value
+// it was read from ("" when there is none) for a compare-and-set against it.
+func (svc *PtzLockService) lease(ctx context.Context, camUUID string) (*v1.PtzLease, string, error) {
+	val, err := svc.Rdb.Get(ctx, svc.Topic(camUUID))
+	if errors.Is(err, redis.Nil) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var lease v1.PtzLease
+	if err := json.Unmarshal([]byte(val), &lease); err != nil {
+		return nil, "", err
+	}
+	if !lease.ExpiresAt.After(time.Now()) {
+		return nil, val, nil
+	}
+
+	return &lease, val, nil
+}
+
+// announce broadcasts the camera's lease and arms the timer announcing its expiry, redis drops
+// the key by itself but nobody would hear of it. Callers hold mtx.
+func (svc *PtzLockService) announce(camUUID string, lease *v1.PtzLease) {
+	if timer, ok := svc.expiries[camUUID]; ok {
+		timer.Stop()
+		delete(svc.expiries, camUUID)
+	}
+	if lease != nil {
+		if svc.expiries == nil {
+			svc.expiries = make(map[string]*time.Timer)
+		}
+		svc.expiries[camUUID] = time.AfterFunc(time.Until(lease.ExpiresAt), func() {
+			svc.expire(camUUID)
+		})
+	}
+
+	bytes, err := json.Marshal(v1.PtzLockState{CamUUID: camUUID, Lease: lease})
+	if err != nil {
+		return
+	}
+	svc.PubSub.Broadcast(svc.Topic(camUUID), bytes)
+}
+
+func (svc *PtzLockService) expire(camUUID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	svc.mtx.Lock()
+	defer svc.mtx.Unlock()
+
+	// the lease may have been renewed while the timer fired
+	lease, _, err := svc.lease(ctx, camUUID)
+	if err != nil {
+		svc.Logger.Warn("failed to read ptz lease", "uuid", camUUID, "err", err)
+		return
+	}
+	if lease == nil {
+		svc.announce(camUUID, nil)
+	}
+}
+
+func outranks(priority, other v1.PtzLockPriority) bool {
+	return ptzLockRanks[priority] > ptzLockRanks[other]
+}
+
+func lockedError(lease *v1.PtzLease) error {
+	return fmt.Errorf("%w: held by %s (%s) until %s",
+		ErrPtzLocked,
+		lease.Holder.Name,
+		lease.Holder.Priority,
+		lease.ExpiresAt.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	inmemory "tomerab.com/cam-hub/internal/events/in_memory"
+)
+
+var (
+	ptzAdmin    = v1.PtzLockHolder{Id: "user:1", Name: "admin", Priority: v1.PtzLockAdmin}
+	ptzOperator = v1.PtzLockHolder{Id: "user:2", Name: "tomer", Priority: v1.PtzLockOperator}
+	ptzTour     = v1.PtzLockHolder{Id: "tour:1", Name: "perimeter", Priority: v1.PtzLockAutomation}
+)
+
+func setupPtzLockServiceTest() *PtzLockService {
+	return &PtzLockService{
+		Rdb:    &fakeRedis{vals: map[string]string{}},
+		PubSub: inmemory.NewInMemoryPubSub(),
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// racingRedis runs race before its first compare-and-set, as another replica changing the lease
+// between the read and the swap.
+type racingRedis struct {
+	*fakeRedis
+	race func()
+}
+
+func (rdb *racingRedis) CompareAndSet(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error) {
+	if race := rdb.race; race != nil {
+		rdb.race = nil
+		race()
+	}
+	return rdb.fakeRedis.CompareAndSet(ctx, key, old, value, ttl)
+}
+
+func TestPtzLockAcquire(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("held by the same priority - should return ErrPtzLocked", func(t *testing.T) {
+		svc := setupPtzLockServiceTest()
+		if _, err := svc.Acquire(ctx, "cam", ptzOperator, 0); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		other := v1.PtzLockHolder{Id: "user:3", Name: "dana", Priority: v1.PtzLockOperator}
+		if _, err := svc.Acquire(ctx, "cam", other, 0); !errors.Is(err, ErrPtzLocked) {
+			t.Errorf("expected ErrPtzLocked, got: %v", err)
+		}
+		if _, err := svc.Acquire(ctx, "cam", ptzTour, 0); !errors.Is(err, ErrPtzLocked) {
+			t.Errorf("expected ErrPtzLocked, got: %v", err)
+		}
+	})
+
+	t.Run("higher priority - should preempt the holder", func(t *testing.T) {
+		svc := setupPtzLockServiceTest()
+		svc.Acquire(ctx, "cam", ptzTour, 0)
+		svc.Acquire(ctx, "cam", ptzOperator, 0)
+
+		lease, err := svc.Acquire(ctx, "cam", ptzAdmin, 0)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if lease.Holder != ptzAdmin {
+			t.Errorf("expected the admin to hold the lease, got: %+v", lease.Holder)
+		}
+	})
+
+	t.Run("renewal - should keep the longer lease", func(t *testing.T) {
+		svc := setupPtzLockServiceTest()
+		first, _ := svc.Acquire(ctx, "cam", ptzOperator, time.Minute)
+
+		renewed, err := svc.Acquire(ctx, "cam", ptzOperator, time.Second)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if !renewed.ExpiresAt.Equal(first.ExpiresAt) || !renewed.AcquiredAt.Equal(first.AcquiredAt) {
+			t.Errorf("expected: %+v, got: %+v", first, renewed)
+		}
+	})
+
+	t.Run("taken by another replica meanwhile - should return ErrPtzLocked", func(t *testing.T) {
+		svc := setupPtzLockServiceTest()
+		replica := setupPtzLockServiceTest()
+		rdb := &racingRedis{fakeRedis: &fakeRedis{vals: map[string]string{}}}
+		svc.Rdb, replica.Rdb = rdb, rdb.fakeRedis
+
+		other := v1.PtzLockHolder{Id: "user:3", Name: "dana", Priority: v1.PtzLockOperator}
+		rdb.race = func() {
+			if _, err := replica.Acquire(ctx, "cam", other, 0); err != nil {
+				t.Fatalf("expected nil, got err: %v", err)
+			}
+		}
+
+		if _, err := svc.Acquire(ctx, "cam", ptzOperator, 0); !errors.Is(err, ErrPtzLocked) {
+			t.Errorf("expected ErrPtzLocked, got: %v", err)
+		}
+		state, err := svc.State(ctx, "cam")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if state.Lease == nil || state.Lease.Holder != other {
+			t.Errorf("expected the other replica to hold the lease, got: %+v", state.Lease)
+		}
+	})
+
+	t.Run("preempted by another replica meanwhile - should retry against the new lease", func(t *testing.T) {
+		svc := setupPtzLockServiceTest()
+		replica := setupPtzLockServiceTest()
+		rdb := &racingRedis{fakeRedis: &fakeRedis{vals: map[string]string{}}}
+		svc.Rdb, replica.Rdb = rdb, rdb.fakeRedis
+
+		replica.Acquire(ctx, "cam", ptzTour, 0)
+		rdb.race = func() {
+			if _, err := replica.Acquire(ctx, "cam", ptzOperator, 0); err != nil {
+				t.Fatalf("expected nil, got err: %v", err)
+			}
+		}
+
+		lease, err := svc.Acquire(ctx, "cam", ptzAdmin, 0)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if lease.Holder != ptzAdmin {
+			t.Errorf("expected the admin to hold the lease, got: %+v", lease.Holder)
+		}
+	})
+
+	t.Run("bad ttl - should return ErrInvalidPtzRequest", func(t *testing.T) {
+		svc := setupPtzLockServiceTest()
+		ttl := 10
+		if _, err := svc.Lock(ctx, "cam", ptzOperator, v1.AcquirePtzLockReq{TtlMs: &ttl}); !errors.Is(err, ErrInvalidPtzRequest) {
+			t.Errorf("expected ErrInvalidPtzRequest, got: %v", err)
+		}
+	})
+}
+
+func TestPtzLockRelease(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("someone else's lease - should return ErrPtzLocked", func(t *testing.T) {
+		svc := setupPtzLockServiceTest()
+		svc.Acquire(ctx, "cam", ptzOperator, 0)
+
+		if err := svc.Release(ctx, "cam", ptzTour); !errors.Is(err, ErrPtzLocked) {
+			t.Errorf("expected ErrPtzLocked, got: %v", err)
+		}
+		if err := svc.Release(ctx, "cam", ptzOperator); err != nil {
+			t.Errorf("expected nil, got err: %v", err)
+		}
+
+		state, err := svc.State(ctx, "cam")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if state.Lease != nil {
+			t.Errorf("expected a free camera, got: %+v", state.Lease)
+		}
+	})
+
+	t.Run("renewed by another replica meanwhile - should release the renewed lease", func(t *testing.T) {
+		svc := setupPtzLockServiceTest()
+		replica := setupPtzLockServiceTest()
+		rdb := &racingRedis{fakeRedis: &fakeRedis{vals: map[string]string{}}}
+		svc.Rdb, replica.Rdb = rdb, rdb.fakeRedis
+
+		replica.Acquire(ctx, "cam", ptzOperator, 0)
+		rdb.race = func() {
+			if _, err := replica.Acquire(ctx, "cam", ptzOperator, time.Minute); err != nil {
+				t.Fatalf("expected nil, got err: %v", err)
+			}
+		}
+
+		if err := svc.Release(ctx, "cam", ptzTour); !errors.Is(err, ErrPtzLocked) {
+			t.Errorf("expected ErrPtzLocked, got: %v", err)
+		}
+		if err := svc.Release(ctx, "cam", ptzOperator); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if state, _ := svc.State(ctx, "cam"); state.Lease != nil {
+			t.Errorf("expected a free camera, got: %+v", state.Lease)
+		}
+	})
+
+	t.Run("lease runs out - should announce the free camera", func(t *testing.T) {
+		svc := setupPtzLockServiceTest()
+		subCh := svc.PubSub.Subscribe(svc.Topic("cam"))
+		defer svc.PubSub.Unsubscribe(svc.Topic("cam"), subCh)
+
+		svc.Acquire(ctx, "cam", ptzOperator, 20*time.Millisecond)
+		<-subCh
+
+		select {
+		case msg := <-subCh:
+			var state v1.PtzLockState
+			if err := json.Unmarshal(msg, &state); err != nil {
+				t.Fatalf("expected nil, got err: %v", err)
+			}
+			if state.Lease != nil {
+				t.Errorf("expected a free camera, got: %+v", state.Lease)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected the expiry to be announced")
+		}
+	})
+}
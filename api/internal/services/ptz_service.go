@@ -5,99 +5,449 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"strings"
 	"time"
 
+	"github.com/georgysavva/scany/v2/pgxscan"
 	"tomerab.com/cam-hub/internal/api/v1/models"
 	v1 "tomerab.com/cam-hub/internal/contracts/v1"
 	"tomerab.com/cam-hub/internal/onvif"
 	"tomerab.com/cam-hub/internal/onvif/ptz"
 	"tomerab.com/cam-hub/internal/repos"
+	"tomerab.com/cam-hub/internal/utils"
 )
 
 const (
 	ptzCacheKeyPrefix = "ptz:token:"
 	ptzCacheTTL       = time.Hour
+
+	minMoveTimeoutMs  = 100
+	maxMoveTimeoutMs  = 60 * 1000
+	maxPresetNameSize = 64
+)
+
+var (
+	ErrInvalidPtzRequest = errors.New("invalid ptz request")
+	ErrPtzUnsupported    = errors.New("ptz feature not supported by this camera")
+	ErrPresetNotFound    = errors.New("ptz preset not found")
 )
 
 type PtzService struct {
 	CamRepo      repos.CameraRepoIface
 	CamCredsRepo repos.CameraCredsRepoIface
 	PtzTokenRepo repos.PtzTokenRepoIface
+	PresetsRepo  repos.PtzPresetsRepoIface
+	CapsRepo     repos.PtzCapabilitiesRepoIface
 	Rdb          repos.RedisIface
 	Logger       *slog.Logger
+
+	control PtzControlFunc
+}
+
+// PtzControlFunc takes control of the camera before a move, see PtzService.Controlled.
+type PtzControlFunc func(ctx context.Context, uuid string) error
+
+// Controlled returns a copy of the service that runs control before each move. The move runs it
+// once the request and the camera were checked, so a rejected move leaves the camera alone.
+func (svc *PtzService) Controlled(control PtzControlFunc) *PtzService {
+	controlled := *svc
+	controlled.control = control
+	return &controlled
 }
 
 func (svc *PtzService) MoveCamera(ctx context.Context, uuid string, move v1.MoveCameraReq) error {
-	token, err := svc.resolvePtzToken(ctx, uuid)
+	if move.Translation == nil && move.Zoom == nil {
+		return fmt.Errorf("%w: translation or zoom is required", ErrInvalidPtzRequest)
+	}
+
+	var timeout time.Duration
+	if move.TimeoutMs != nil {
+		if *move.TimeoutMs < minMoveTimeoutMs || *move.TimeoutMs > maxMoveTimeoutMs {
+			return fmt.Errorf("%w: timeout_ms must be between %d and %d", ErrInvalidPtzRequest, minMoveTimeoutMs, maxMoveTimeoutMs)
+		}
+		timeout = time.Duration(*move.TimeoutMs) * time.Millisecond
+	}
+
+	caps, err := svc.supportedCapabilities(ctx, uuid)
 	if err != nil {
 		return err
 	}
 
-	cam, err := svc.CamRepo.FindOne(ctx, uuid)
+	velocity, err := limitVector(v1.PtzVector{PanTilt: move.Translation, Zoom: move.Zoom}, caps.Spaces.ContinuousPanTilt, caps.Spaces.ContinuousZoom)
 	if err != nil {
-		return fmt.Errorf("camera not found: %w", err)
+		return err
+	}
+	if timeout > 0 {
+		timeout = limitTimeout(timeout, caps)
 	}
 
-	creds, err := svc.CamCredsRepo.FindOne(ctx, uuid)
+	return svc.move(ctx, uuid, func(client *onvif.OnvifClient, token string) error {
+		return client.MoveCamera(ptz.MoveCameraDto{
+			Token:       token,
+			Translation: velocity.PanTilt,
+			Zoom:        velocity.Zoom,
+			Timeout:     timeout,
+		})
+	})
+}
+
+func (svc *PtzService) AbsoluteMove(ctx context.Context, uuid string, move v1.AbsoluteMoveReq) error {
+	if isEmptyVector(move.Position) {
+		return fmt.Errorf("%w: position must set pan_tilt or zoom", ErrInvalidPtzRequest)
+	}
+
+	caps, err := svc.supportedCapabilities(ctx, uuid)
 	if err != nil {
-		return fmt.Errorf("camera creds not found: %w", err)
+		return err
 	}
 
-	client, err := onvif.NewOnvifClient(onvif.OnvifClientParams{
-		Xaddr:    cam.Addr,
-		Username: creds.Username,
-		Password: creds.Password,
-		Logger:   svc.Logger,
+	position, err := limitVector(move.Position, caps.Spaces.AbsolutePanTilt, caps.Spaces.AbsoluteZoom)
+	if err != nil {
+		return err
+	}
+	speed, err := limitSpeed(move.Speed, caps.Spaces)
+	if err != nil {
+		return err
+	}
+
+	return svc.move(ctx, uuid, func(client *onvif.OnvifClient, token string) error {
+		return client.AbsoluteMove(ptz.AbsoluteMoveDto{
+			Token:    token,
+			Position: toPtzVector(position),
+			Speed:    optPtzVector(speed),
+		})
 	})
+}
+
+func (svc *PtzService) RelativeMove(ctx context.Context, uuid string, move v1.RelativeMoveReq) error {
+	if isEmptyVector(move.Translation) {
+		return fmt.Errorf("%w: translation must set pan_tilt or zoom", ErrInvalidPtzRequest)
+	}
+
+	caps, err := svc.supportedCapabilities(ctx, uuid)
 	if err != nil {
-		return fmt.Errorf("onvif client: %w", err)
+		return err
 	}
 
-	if err := client.MoveCamera(ptz.MoveCameraDto{
-		Token:       token,
-		Translation: move.Translation,
-		Zoom:        move.Zoom,
+	translation, err := limitVector(move.Translation, caps.Spaces.RelativePanTilt, caps.Spaces.RelativeZoom)
+	if err != nil {
+		return err
+	}
+	speed, err := limitSpeed(move.Speed, caps.Spaces)
+	if err != nil {
+		return err
+	}
+
+	return svc.move(ctx, uuid, func(client *onvif.OnvifClient, token string) error {
+		return client.RelativeMove(ptz.RelativeMoveDto{
+			Token:       token,
+			Translation: toPtzVector(translation),
+			Speed:       optPtzVector(speed),
+		})
+	})
+}
+
+func (svc *PtzService) Stop(ctx context.Context, uuid string, stop v1.StopCameraReq) error {
+	if !stop.PanTilt && !stop.Zoom {
+		stop.PanTilt, stop.Zoom = true, true
+	}
+
+	if _, err := svc.supportedCapabilities(ctx, uuid); err != nil {
+		return err
+	}
+
+	return svc.move(ctx, uuid, func(client *onvif.OnvifClient, token string) error {
+		return client.StopCamera(ptz.StopCameraMovementDto{
+			Token:   token,
+			PanTilt: stop.PanTilt,
+			Zoom:    stop.Zoom,
+		})
+	})
+}
+
+func (svc *PtzService) Status(ctx context.Context, uuid string) (*v1.PtzStatus, error) {
+	var status *ptz.Status
+	if err := svc.withPtz(ctx, uuid, func(client *onvif.OnvifClient, token string) error {
+		var err error
+		status, err = client.GetPtzStatus(token)
+		return err
 	}); err != nil {
-		if isInvalidToken(err) {
-			newTok, rerr := client.GetPtzProfile()
-			if rerr != nil {
-				return fmt.Errorf("refresh ptz token: %w", rerr)
-			}
-			if uerr := svc.upsertAndCache(ctx, uuid, newTok.Token); uerr != nil {
-				svc.Logger.Warn("failed to upsert/cache refreshed PTZ token", "err", uerr)
-			}
-			return client.MoveCamera(ptz.MoveCameraDto{
-				Token:       token,
-				Translation: move.Translation,
-				Zoom:        move.Zoom,
-			})
+		return nil, err
+	}
+
+	out := &v1.PtzStatus{
+		Position:      fromPtzVector(status.Position),
+		PanTiltStatus: status.PanTiltStatus,
+		ZoomStatus:    status.ZoomStatus,
+		Error:         status.Error,
+	}
+	if !status.UtcTime.IsZero() {
+		out.UtcTime = &status.UtcTime
+	}
+
+	return out, nil
+}
+
+func (svc *PtzService) GotoHome(ctx context.Context, uuid string) error {
+	caps, err := svc.supportedCapabilities(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	if !caps.HomeSupported {
+		return fmt.Errorf("%w: home position", ErrPtzUnsupported)
+	}
+
+	return svc.move(ctx, uuid, func(client *onvif.OnvifClient, token string) error {
+		return client.GotoHomePosition(token)
+	})
+}
+
+// Presets returns the cached presets of the camera, they're fetched from the camera when the
+// cache is empty or a refresh is asked for.
+func (svc *PtzService) Presets(ctx context.Context, uuid string, refresh bool) ([]v1.PtzPreset, error) {
+	if !refresh {
+		cached, err := svc.PresetsRepo.FindByCamera(ctx, uuid)
+		if err != nil {
+			return nil, err
+		}
+		if len(cached) > 0 {
+			return utils.Map(cached, fromPresetModel), nil
+		}
+	}
+
+	return svc.syncPresets(ctx, uuid)
+}
+
+func (svc *PtzService) SetPreset(ctx context.Context, uuid string, req v1.SetPresetReq) (*v1.PtzPreset, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxPresetNameSize {
+		return nil, fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidPtzRequest, maxPresetNameSize)
+	}
+
+	caps, err := svc.supportedCapabilities(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if caps.MaxPresets > 0 && req.Token == "" {
+		presets, err := svc.Presets(ctx, uuid, false)
+		if err != nil {
+			return nil, err
 		}
+		if len(presets) >= caps.MaxPresets {
+			return nil, fmt.Errorf("%w: the camera holds at most %d presets", ErrInvalidPtzRequest, caps.MaxPresets)
+		}
+	}
+
+	var presetToken string
+	if err := svc.withPtz(ctx, uuid, func(client *onvif.OnvifClient, token string) error {
+		var err error
+		presetToken, err = client.SetPreset(token, req.Name, req.Token)
 		return err
+	}); err != nil {
+		return nil, err
 	}
-	return nil
+
+	// the camera may have rounded the position or renamed the preset, the cache is refilled from it
+	presets, err := svc.syncPresets(ctx, uuid)
+	if err != nil {
+		svc.Logger.Warn("failed to sync ptz presets", "uuid", uuid, "err", err)
+		return &v1.PtzPreset{Token: presetToken, Name: req.Name}, nil
+	}
+
+	if preset := findPreset(presets, presetToken); preset != nil {
+		return preset, nil
+	}
+	return &v1.PtzPreset{Token: presetToken, Name: req.Name}, nil
 }
 
-func (svc *PtzService) resolvePtzToken(ctx context.Context, uuid string) (string, error) {
-	if svc.Rdb != nil {
-		if tok, err := svc.Rdb.Get(ctx, ptzCacheKeyPrefix+uuid); err == nil && tok != "" {
-			return tok, nil
+// GotoPreset moves the camera to the preset with the given token or name.
+func (svc *PtzService) GotoPreset(ctx context.Context, uuid, preset string) error {
+	found, err := svc.resolvePreset(ctx, uuid, preset)
+	if err != nil {
+		return err
+	}
+
+	return svc.move(ctx, uuid, func(client *onvif.OnvifClient, token string) error {
+		return client.GotoPreset(token, found.Token)
+	})
+}
+
+func (svc *PtzService) RemovePreset(ctx context.Context, uuid, preset string) error {
+	found, err := svc.resolvePreset(ctx, uuid, preset)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.withPtz(ctx, uuid, func(client *onvif.OnvifClient, token string) error {
+		return client.RemovePreset(token, found.Token)
+	}); err != nil {
+		return err
+	}
+
+	return svc.PresetsRepo.Delete(ctx, uuid, found.Token)
+}
+
+// Capabilities returns the cached capabilities of the camera, they're discovered again when the
+// camera was paired before they were recorded or a refresh is asked for.
+func (svc *PtzService) Capabilities(ctx context.Context, uuid string, refresh bool) (*v1.CameraCapabilities, error) {
+	caps, err := svc.capabilities(ctx, uuid, refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	spaces := caps.Spaces
+	return &v1.CameraCapabilities{
+		Ptz: v1.PtzCapabilities{
+			Supported:    caps.Supported,
+			PanTilt:      spaces.AbsolutePanTilt != nil || spaces.RelativePanTilt != nil || spaces.ContinuousPanTilt != nil,
+			Zoom:         spaces.AbsoluteZoom != nil || spaces.RelativeZoom != nil || spaces.ContinuousZoom != nil,
+			Home:         caps.HomeSupported,
+			MaxPresets:   caps.MaxPresets,
+			Spaces:       spaces,
+			MinTimeoutMs: caps.MinTimeoutMs,
+			MaxTimeoutMs: caps.MaxTimeoutMs,
+			UpdatedAt:    caps.UpdatedAt,
+		},
+	}, nil
+}
+
+func (svc *PtzService) capabilities(ctx context.Context, uuid string, refresh bool) (*models.PtzCapabilities, error) {
+	if !refresh {
+		caps, err := svc.CapsRepo.FindOne(ctx, uuid)
+		if err == nil {
+			return caps, nil
+		}
+		if !errors.Is(err, repos.ErrPtzCapabilitiesNotFound) {
+			return nil, err
 		}
 	}
 
-	if rec, err := svc.PtzTokenRepo.FindOne(ctx, uuid); err == nil && rec != nil && rec.Token != "" {
-		_ = svc.cacheToken(ctx, uuid, rec.Token)
-		return rec.Token, nil
+	client, err := svc.client(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	caps, err := discoverPtzCapabilities(client)
+	if err != nil {
+		return nil, err
+	}
+
+	caps.CamUUID = uuid
+	caps.UpdatedAt = time.Now()
+	if err := svc.CapsRepo.Upsert(ctx, caps); err != nil {
+		svc.Logger.Warn("failed to store ptz capabilities", "uuid", uuid, "err", err)
+	}
+
+	return caps, nil
+}
+
+// supportedCapabilities returns the capabilities of a camera that has PTZ.
+func (svc *PtzService) supportedCapabilities(ctx context.Context, uuid string) (*models.PtzCapabilities, error) {
+	caps, err := svc.capabilities(ctx, uuid, false)
+	if err != nil {
+		return nil, err
+	}
+	if !caps.Supported {
+		return nil, onvif.ErrNoPtz
+	}
+
+	return caps, nil
+}
+
+// resolvePreset looks the preset up by token, then by name, in the cache first and the camera
+// second.
+func (svc *PtzService) resolvePreset(ctx context.Context, uuid, preset string) (*v1.PtzPreset, error) {
+	presets, err := svc.Presets(ctx, uuid, false)
+	if err != nil {
+		return nil, err
+	}
+	if found := findPreset(presets, preset); found != nil {
+		return found, nil
+	}
+
+	if presets, err = svc.syncPresets(ctx, uuid); err != nil {
+		return nil, err
+	}
+	if found := findPreset(presets, preset); found != nil {
+		return found, nil
+	}
+
+	return nil, ErrPresetNotFound
+}
+
+func (svc *PtzService) syncPresets(ctx context.Context, uuid string) ([]v1.PtzPreset, error) {
+	var presets []ptz.Preset
+	if err := svc.withPtz(ctx, uuid, func(client *onvif.OnvifClient, token string) error {
+		var err error
+		presets, err = client.GetPresets(token)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	out := utils.Map(presets, fromPtzPreset)
+	if err := svc.PresetsRepo.Replace(ctx, uuid, utils.Map(out, func(preset v1.PtzPreset) *models.PtzPreset {
+		return toPresetModel(uuid, preset)
+	})); err != nil {
+		svc.Logger.Warn("failed to cache ptz presets", "uuid", uuid, "err", err)
+	}
+
+	return out, nil
+}
+
+// move takes control of the camera and runs fn through withPtz, callers check the request first.
+func (svc *PtzService) move(ctx context.Context, uuid string, fn func(client *onvif.OnvifClient, token string) error) error {
+	if svc.control != nil {
+		if err := svc.control(ctx, uuid); err != nil {
+			return err
+		}
+	}
+
+	return svc.withPtz(ctx, uuid, fn)
+}
+
+// withPtz runs fn with an onvif client of the camera and its PTZ profile token. A token the camera
+// no longer knows is refreshed and fn is retried once.
+func (svc *PtzService) withPtz(ctx context.Context, uuid string, fn func(client *onvif.OnvifClient, token string) error) error {
+	client, err := svc.client(ctx, uuid)
+	if err != nil {
+		return err
+	}
+
+	token, err := svc.resolvePtzToken(ctx, uuid, client)
+	if err != nil {
+		return err
 	}
 
+	err = fn(client, token)
+	if !isInvalidToken(err) {
+		return err
+	}
+
+	newTok, err := client.GetPtzProfile()
+	if err != nil {
+		return fmt.Errorf("refresh ptz token: %w", err)
+	}
+	if err := svc.upsertAndCache(ctx, uuid, newTok.Token); err != nil {
+		svc.Logger.Warn("failed to upsert/cache refreshed PTZ token", "err", err)
+	}
+
+	return fn(client, newTok.Token)
+}
+
+func (svc *PtzService) client(ctx context.Context, uuid string) (*onvif.OnvifClient, error) {
 	cam, err := svc.CamRepo.FindOne(ctx, uuid)
 	if err != nil {
-		return "", fmt.Errorf("camera not found: %w", err)
+		if pgxscan.NotFound(err) {
+			return nil, ErrCameraNotFound
+		}
+		return nil, fmt.Errorf("camera not found: %w", err)
 	}
 
 	creds, err := svc.CamCredsRepo.FindOne(ctx, uuid)
 	if err != nil {
-		return "", fmt.Errorf("camera creds not found: %w", err)
+		return nil, fmt.Errorf("camera creds not found: %w", err)
 	}
 
 	client, err := onvif.NewOnvifClient(onvif.OnvifClientParams{
@@ -107,7 +457,22 @@ func (svc *PtzService) resolvePtzToken(ctx context.Context, uuid string) (string
 		Logger:   svc.Logger,
 	})
 	if err != nil {
-		return "", fmt.Errorf("onvif client: %w", err)
+		return nil, fmt.Errorf("onvif client: %w", err)
+	}
+
+	return client, nil
+}
+
+func (svc *PtzService) resolvePtzToken(ctx context.Context, uuid string, client *onvif.OnvifClient) (string, error) {
+	if svc.Rdb != nil {
+		if tok, err := svc.Rdb.Get(ctx, ptzCacheKeyPrefix+uuid); err == nil && tok != "" {
+			return tok, nil
+		}
+	}
+
+	if rec, err := svc.PtzTokenRepo.FindOne(ctx, uuid); err == nil && rec != nil && rec.Token != "" {
+		_ = svc.cacheToken(ctx, uuid, rec.Token)
+		return rec.Token, nil
 	}
 
 	ptzTok, err := client.GetPtzProfile()
@@ -150,3 +515,200 @@ func isInvalidToken(err error) bool {
 	s := err.Error()
 	return strings.Contains(s, "Invalid") && strings.Contains(s, "Token")
 }
+
+// discoverPtzCapabilities asks the camera for its PTZ limits, a camera without PTZ gets
+// unsupported capabilities.
+func discoverPtzCapabilities(client *onvif.OnvifClient) (*models.PtzCapabilities, error) {
+	caps, err := client.GetPtzCapabilities()
+	if errors.Is(err, onvif.ErrNoPtz) {
+		return &models.PtzCapabilities{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := &models.PtzCapabilities{
+		Supported: true,
+		Spaces: models.PtzSpaces{
+			AbsolutePanTilt:   toRange2DModel(caps.Spaces.AbsolutePanTilt),
+			AbsoluteZoom:      toRangeModel(caps.Spaces.AbsoluteZoom),
+			RelativePanTilt:   toRange2DModel(caps.Spaces.RelativePanTilt),
+			RelativeZoom:      toRangeModel(caps.Spaces.RelativeZoom),
+			ContinuousPanTilt: toRange2DModel(caps.Spaces.ContinuousPanTilt),
+			ContinuousZoom:    toRangeModel(caps.Spaces.ContinuousZoom),
+			PanTiltSpeed:      toRangeModel(caps.Spaces.PanTiltSpeed),
+			ZoomSpeed:         toRangeModel(caps.Spaces.ZoomSpeed),
+		},
+		MaxPresets:    caps.MaxPresets,
+		HomeSupported: caps.HomeSupported,
+	}
+	if caps.MaxTimeout > 0 {
+		minTimeout, maxTimeout := int(caps.MinTimeout.Milliseconds()), int(caps.MaxTimeout.Milliseconds())
+		out.MinTimeoutMs, out.MaxTimeoutMs = &minTimeout, &maxTimeout
+	}
+
+	return out, nil
+}
+
+// limitVector checks the vector against the ranges of its spaces, coordinates out of range are
+// clamped.
+func limitVector(vec v1.PtzVector, panTilt *models.PtzRange2D, zoom *models.PtzRange) (v1.PtzVector, error) {
+	var out v1.PtzVector
+	if vec.PanTilt != nil {
+		if !isFinite(vec.PanTilt.X) || !isFinite(vec.PanTilt.Y) {
+			return out, fmt.Errorf("%w: pan_tilt must be finite", ErrInvalidPtzRequest)
+		}
+		if panTilt == nil {
+			return out, fmt.Errorf("%w: pan/tilt", ErrPtzUnsupported)
+		}
+		out.PanTilt = &utils.Vec2D{X: clamp(vec.PanTilt.X, panTilt.X), Y: clamp(vec.PanTilt.Y, panTilt.Y)}
+	}
+
+	if vec.Zoom != nil {
+		if !isFinite(float64(*vec.Zoom)) {
+			return out, fmt.Errorf("%w: zoom must be finite", ErrInvalidPtzRequest)
+		}
+		if zoom == nil {
+			return out, fmt.Errorf("%w: zoom", ErrPtzUnsupported)
+		}
+		clamped := float32(clamp(float64(*vec.Zoom), *zoom))
+		out.Zoom = &clamped
+	}
+
+	return out, nil
+}
+
+// limitSpeed checks the speed against the speed spaces, the pan/tilt speed space bounds both
+// axes.
+func limitSpeed(speed *v1.PtzVector, spaces models.PtzSpaces) (*v1.PtzVector, error) {
+	if speed == nil {
+		return nil, nil
+	}
+
+	var panTilt *models.PtzRange2D
+	if r := spaces.PanTiltSpeed; r != nil {
+		panTilt = &models.PtzRange2D{X: *r, Y: *r}
+	}
+
+	out, err := limitVector(*speed, panTilt, spaces.ZoomSpeed)
+	if errors.Is(err, ErrPtzUnsupported) {
+		return nil, fmt.Errorf("%w: speed", err)
+	}
+	return &out, err
+}
+
+func limitTimeout(timeout time.Duration, caps *models.PtzCapabilities) time.Duration {
+	if caps.MinTimeoutMs == nil || caps.MaxTimeoutMs == nil {
+		return timeout
+	}
+
+	r := models.PtzRange{Min: float64(*caps.MinTimeoutMs), Max: float64(*caps.MaxTimeoutMs)}
+	return time.Duration(clamp(float64(timeout.Milliseconds()), r)) * time.Millisecond
+}
+
+// clamp leaves v alone when the range is inverted, which cameras report for spaces they don't
+// bound.
+func clamp(v float64, r models.PtzRange) float64 {
+	if r.Min > r.Max {
+		return v
+	}
+	return math.Max(r.Min, math.Min(r.Max, v))
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func toRangeModel(r *ptz.Range) *models.PtzRange {
+	if r == nil {
+		return nil
+	}
+	return &models.PtzRange{Min: r.Min, Max: r.Max}
+}
+
+func toRange2DModel(r *ptz.Range2D) *models.PtzRange2D {
+	if r == nil {
+		return nil
+	}
+	return &models.PtzRange2D{
+		X: models.PtzRange{Min: r.X.Min, Max: r.X.Max},
+		Y: models.PtzRange{Min: r.Y.Min, Max: r.Y.Max},
+	}
+}
+
+// findPreset matches the token exactly and the name case insensitively.
+func findPreset(presets []v1.PtzPreset, preset string) *v1.PtzPreset {
+	for i := range presets {
+		if presets[i].Token == preset {
+			return &presets[i]
+		}
+	}
+	for i := range presets {
+		if strings.EqualFold(presets[i].Name, preset) {
+			return &presets[i]
+		}
+	}
+	return nil
+}
+
+func isEmptyVector(vec v1.PtzVector) bool {
+	return vec.PanTilt == nil && vec.Zoom == nil
+}
+
+func toPtzVector(vec v1.PtzVector) ptz.Vector {
+	return ptz.Vector{PanTilt: vec.PanTilt, Zoom: vec.Zoom}
+}
+
+func optPtzVector(vec *v1.PtzVector) *ptz.Vector {
+	if vec == nil {
+		return nil
+	}
+	out := toPtzVector(*vec)
+	return &out
+}
+
+func fromPtzVector(vec ptz.Vector) v1.PtzVector {
+	return v1.PtzVector{PanTilt: vec.PanTilt, Zoom: vec.Zoom}
+}
+
+func fromPtzPreset(preset ptz.Preset) v1.PtzPreset {
+	out := v1.PtzPreset{Token: preset.Token, Name: preset.Name}
+	if preset.Position != nil {
+		position := fromPtzVector(*preset.Position)
+		out.Position = &position
+	}
+	return out
+}
+
+func toPresetModel(uuid string, preset v1.PtzPreset) *models.PtzPreset {
+	out := &models.PtzPreset{CamUUID: uuid, Token: preset.Token, Name: preset.Name}
+	if preset.Position == nil {
+		return out
+	}
+	if pt := preset.Position.PanTilt; pt != nil {
+		out.Pan, out.Tilt = &pt.X, &pt.Y
+	}
+	if preset.Position.Zoom != nil {
+		zoom := float64(*preset.Position.Zoom)
+		out.Zoom = &zoom
+	}
+	return out
+}
+
+func fromPresetModel(preset *models.PtzPreset) v1.PtzPreset {
+	out := v1.PtzPreset{Token: preset.Token, Name: preset.Name}
+	if preset.Pan == nil && preset.Tilt == nil && preset.Zoom == nil {
+		return out
+	}
+
+	position := v1.PtzVector{}
+	if preset.Pan != nil && preset.Tilt != nil {
+		position.PanTilt = &utils.Vec2D{X: *preset.Pan, Y: *preset.Tilt}
+	}
+	if preset.Zoom != nil {
+		zoom := float32(*preset.Zoom)
+		position.Zoom = &zoom
+	}
+	out.Position = &position
+	return out
+}
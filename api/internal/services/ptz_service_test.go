@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/onvif"
+	"tomerab.com/cam-hub/internal/repos"
+	"tomerab.com/cam-hub/internal/utils"
+)
+
+type fakePtzPresetsRepo struct {
+	presets map[string][]*models.PtzPreset
+}
+
+func (repo *fakePtzPresetsRepo) FindByCamera(ctx context.Context, camUUID string) ([]*models.PtzPreset, error) {
+	return repo.presets[camUUID], nil
+}
+
+func (repo *fakePtzPresetsRepo) Replace(ctx context.Context, camUUID string, presets []*models.PtzPreset) error {
+	repo.presets[camUUID] = presets
+	return nil
+}
+
+func (repo *fakePtzPresetsRepo) Delete(ctx context.Context, camUUID, token string) error {
+	return nil
+}
+
+type fakePtzCapabilitiesRepo struct {
+	repos.PtzCapabilitiesRepoIface
+	caps map[string]*models.PtzCapabilities
+}
+
+func (repo *fakePtzCapabilitiesRepo) FindOne(ctx context.Context, camUUID string) (*models.PtzCapabilities, error) {
+	caps, ok := repo.caps[camUUID]
+	if !ok {
+		return nil, repos.ErrPtzCapabilitiesNotFound
+	}
+	return caps, nil
+}
+
+func setupPtzServiceTest() (*PtzService, *fakePtzPresetsRepo) {
+	presetsRepo := &fakePtzPresetsRepo{presets: map[string][]*models.PtzPreset{}}
+	unit := models.PtzRange{Min: -1, Max: 1}
+	return &PtzService{
+		CamRepo:     &fakeCameraRepo{cams: map[string]*models.Camera{}},
+		PresetsRepo: presetsRepo,
+		CapsRepo: &fakePtzCapabilitiesRepo{caps: map[string]*models.PtzCapabilities{
+			"cam":   {CamUUID: "cam", Supported: true, Spaces: models.PtzSpaces{AbsolutePanTilt: &models.PtzRange2D{X: unit, Y: unit}}},
+			"fixed": {CamUUID: "fixed"},
+		}},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}, presetsRepo
+}
+
+func TestPtzPresets(t *testing.T) {
+	svc, presetsRepo := setupPtzServiceTest()
+	ctx := context.Background()
+
+	t.Run("cached presets - should return them without asking the camera", func(t *testing.T) {
+		pan, tilt, zoom := 0.25, -0.5, 0.0
+		presetsRepo.presets["cam"] = []*models.PtzPreset{
+			{CamUUID: "cam", Token: "1", Name: "gate", Pan: &pan, Tilt: &tilt, Zoom: &zoom},
+			{CamUUID: "cam", Token: "2", Name: "yard"},
+		}
+
+		got, err := svc.Presets(ctx, "cam", false)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		zoom32 := float32(0)
+		expected := []v1.PtzPreset{
+			{Token: "1", Name: "gate", Position: &v1.PtzVector{PanTilt: &utils.Vec2D{X: 0.25, Y: -0.5}, Zoom: &zoom32}},
+			{Token: "2", Name: "yard"},
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %+v, got: %+v", expected, got)
+		}
+	})
+
+	t.Run("unknown camera - should return ErrCameraNotFound", func(t *testing.T) {
+		if _, err := svc.Presets(ctx, "missing", false); !errors.Is(err, ErrCameraNotFound) {
+			t.Errorf("expected ErrCameraNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestPtzValidation(t *testing.T) {
+	svc, _ := setupPtzServiceTest()
+	ctx := context.Background()
+
+	t.Run("empty move - should return ErrInvalidPtzRequest", func(t *testing.T) {
+		if err := svc.MoveCamera(ctx, "cam", v1.MoveCameraReq{}); !errors.Is(err, ErrInvalidPtzRequest) {
+			t.Errorf("expected ErrInvalidPtzRequest, got: %v", err)
+		}
+	})
+
+	t.Run("move timeout out of range - should return ErrInvalidPtzRequest", func(t *testing.T) {
+		timeout := 10
+		move := v1.MoveCameraReq{Zoom: new(float32), TimeoutMs: &timeout}
+		if err := svc.MoveCamera(ctx, "cam", move); !errors.Is(err, ErrInvalidPtzRequest) {
+			t.Errorf("expected ErrInvalidPtzRequest, got: %v", err)
+		}
+	})
+
+	t.Run("empty absolute position - should return ErrInvalidPtzRequest", func(t *testing.T) {
+		if err := svc.AbsoluteMove(ctx, "cam", v1.AbsoluteMoveReq{}); !errors.Is(err, ErrInvalidPtzRequest) {
+			t.Errorf("expected ErrInvalidPtzRequest, got: %v", err)
+		}
+	})
+
+	t.Run("blank preset name - should return ErrInvalidPtzRequest", func(t *testing.T) {
+		if _, err := svc.SetPreset(ctx, "cam", v1.SetPresetReq{Name: "  "}); !errors.Is(err, ErrInvalidPtzRequest) {
+			t.Errorf("expected ErrInvalidPtzRequest, got: %v", err)
+		}
+	})
+}
+
+func TestPtzControl(t *testing.T) {
+	svc, presetsRepo := setupPtzServiceTest()
+	presetsRepo.presets["cam"] = []*models.PtzPreset{{CamUUID: "cam", Token: "1", Name: "gate"}}
+	ctx := context.Background()
+
+	var taken []string
+	controlled := svc.Controlled(func(ctx context.Context, uuid string) error {
+		taken = append(taken, uuid)
+		return ErrPtzLocked
+	})
+
+	t.Run("rejected moves - should not take control of the camera", func(t *testing.T) {
+		taken = nil
+		position := v1.PtzVector{PanTilt: &utils.Vec2D{}}
+		errs := []error{
+			controlled.MoveCamera(ctx, "cam", v1.MoveCameraReq{}),
+			controlled.AbsoluteMove(ctx, "cam", v1.AbsoluteMoveReq{Position: v1.PtzVector{Zoom: new(float32)}}),
+			controlled.AbsoluteMove(ctx, "fixed", v1.AbsoluteMoveReq{Position: position}),
+			controlled.AbsoluteMove(ctx, "missing", v1.AbsoluteMoveReq{Position: position}),
+			controlled.Stop(ctx, "missing", v1.StopCameraReq{}),
+			controlled.GotoHome(ctx, "cam"),
+		}
+		for i, err := range errs {
+			if err == nil || errors.Is(err, ErrPtzLocked) {
+				t.Errorf("move %d: expected a validation error, got: %v", i, err)
+			}
+		}
+		if len(taken) != 0 {
+			t.Errorf("expected no control taken, got: %v", taken)
+		}
+	})
+
+	t.Run("valid moves - should take control before moving the camera", func(t *testing.T) {
+		taken = nil
+		errs := []error{
+			controlled.AbsoluteMove(ctx, "cam", v1.AbsoluteMoveReq{Position: v1.PtzVector{PanTilt: &utils.Vec2D{X: 2}}}),
+			controlled.Stop(ctx, "cam", v1.StopCameraReq{}),
+			controlled.GotoPreset(ctx, "cam", "gate"),
+		}
+		for i, err := range errs {
+			if !errors.Is(err, ErrPtzLocked) {
+				t.Errorf("move %d: expected ErrPtzLocked, got: %v", i, err)
+			}
+		}
+		if expected := []string{"cam", "cam", "cam"}; !reflect.DeepEqual(expected, taken) {
+			t.Errorf("expected: %v, got: %v", expected, taken)
+		}
+	})
+
+	t.Run("service without control - should not be changed by Controlled", func(t *testing.T) {
+		if svc.control != nil {
+			t.Error("expected the original service to stay without control")
+		}
+	})
+}
+
+func TestPtzLimits(t *testing.T) {
+	svc, _ := setupPtzServiceTest()
+	ctx := context.Background()
+
+	t.Run("out of range position - should be clamped", func(t *testing.T) {
+		unit := models.PtzRange{Min: -1, Max: 1}
+		zoom := float32(1.5)
+		got, err := limitVector(v1.PtzVector{PanTilt: &utils.Vec2D{X: -3, Y: 0.5}, Zoom: &zoom}, &models.PtzRange2D{X: unit, Y: unit}, &models.PtzRange{Min: 0, Max: 1})
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		one := float32(1)
+		expected := v1.PtzVector{PanTilt: &utils.Vec2D{X: -1, Y: 0.5}, Zoom: &one}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %+v, got: %+v", expected, got)
+		}
+	})
+
+	t.Run("zoom without a zoom space - should return ErrPtzUnsupported", func(t *testing.T) {
+		move := v1.AbsoluteMoveReq{Position: v1.PtzVector{Zoom: new(float32)}}
+		if err := svc.AbsoluteMove(ctx, "cam", move); !errors.Is(err, ErrPtzUnsupported) {
+			t.Errorf("expected ErrPtzUnsupported, got: %v", err)
+		}
+	})
+
+	t.Run("no home position - should return ErrPtzUnsupported", func(t *testing.T) {
+		if err := svc.GotoHome(ctx, "cam"); !errors.Is(err, ErrPtzUnsupported) {
+			t.Errorf("expected ErrPtzUnsupported, got: %v", err)
+		}
+	})
+
+	t.Run("camera without ptz - should return ErrNoPtz", func(t *testing.T) {
+		move := v1.AbsoluteMoveReq{Position: v1.PtzVector{PanTilt: &utils.Vec2D{}}}
+		if err := svc.AbsoluteMove(ctx, "fixed", move); !errors.Is(err, onvif.ErrNoPtz) {
+			t.Errorf("expected ErrNoPtz, got: %v", err)
+		}
+	})
+}
+
+func TestFindPreset(t *testing.T) {
+	presets := []v1.PtzPreset{
+		{Token: "1", Name: "Gate"},
+		{Token: "gate", Name: "yard"},
+	}
+
+	t.Run("token match - should win over a name match", func(t *testing.T) {
+		if got := findPreset(presets, "gate"); got == nil || got.Token != "gate" {
+			t.Errorf("expected the preset with token gate, got: %+v", got)
+		}
+	})
+
+	t.Run("name match - should ignore case", func(t *testing.T) {
+		if got := findPreset(presets, "YARD"); got == nil || got.Token != "gate" {
+			t.Errorf("expected the yard preset, got: %+v", got)
+		}
+	})
+
+	t.Run("no match - should return nil", func(t *testing.T) {
+		if got := findPreset(presets, "porch"); got != nil {
+			t.Errorf("expected nil, got: %+v", got)
+		}
+	})
+}
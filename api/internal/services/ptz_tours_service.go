@@ -0,0 +1,555 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/repos"
+	"tomerab.com/cam-hub/internal/utils"
+)
+
+const (
+	DefaultTourIdleTimeout = 2 * time.Minute
+
+	tourMoveTimeout = 10 * time.Second
+	maxTourStops    = 64
+	maxTourNameSize = 64
+	minTourDwellMs  = 1000
+	maxTourDwellMs  = 60 * 60 * 1000
+)
+
+var ErrInvalidTour = errors.New("invalid ptz tour")
+
+// GotoPresetFunc moves the camera to the preset with the given token or name, see
+// PtzService.GotoPreset.
+type GotoPresetFunc func(ctx context.Context, uuid, preset string) error
+
+type tourRun struct {
+	state       v1.PtzTourState
+	cancel      context.CancelFunc
+	pausedUntil time.Time
+	wake        chan struct{} // signaled when the run is paused
+}
+
+// PtzToursService runs the patrol tours of the cameras, at most one per camera at a time. Runs
+// only live in memory, tours with a schedule are started by a gocron job each. A run takes an
+// automation lease for each stop and pauses while anyone else holds the camera.
+type PtzToursService struct {
+	ToursRepo   repos.PtzToursRepoIface
+	CamRepo     repos.CameraRepoIface
+	GotoPreset  GotoPresetFunc
+	Locks       *PtzLockService
+	Sched       gocron.Scheduler
+	IdleTimeout time.Duration // how long a tour stays paused after the last manual move
+	Logger      *slog.Logger
+
+	mtx  sync.Mutex
+	ctx  context.Context
+	runs map[string]*tourRun  // by camera
+	jobs map[string]uuid.UUID // by tour
+}
+
+// InitJobs schedules the stored tours, runs are stopped once ctx is done.
+func (svc *PtzToursService) InitJobs(ctx context.Context) error {
+	svc.mtx.Lock()
+	svc.ctx = ctx
+	svc.mtx.Unlock()
+
+	tours, err := svc.ToursRepo.FindScheduled(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, tour := range tours {
+		jobID, err := svc.newJob(tour.Id, *tour.Schedule)
+		if err != nil {
+			svc.Logger.Error("ptz tours: failed to schedule tour", "tour", tour.Id, "schedule", *tour.Schedule, "err", err)
+			continue
+		}
+		svc.setJob(tour.Id, &jobID)
+	}
+
+	svc.Logger.Info("Scheduled ptz tours", "tours", len(tours))
+	return nil
+}
+
+func (svc *PtzToursService) List(ctx context.Context, camUUID string) ([]v1.PtzTour, error) {
+	if err := svc.ensureCamera(ctx, camUUID); err != nil {
+		return nil, err
+	}
+
+	tours, err := svc.ToursRepo.FindByCamera(ctx, camUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.Map(tours, fromTourModel), nil
+}
+
+func (svc *PtzToursService) Create(ctx context.Context, camUUID string, req v1.PtzTourReq) (*v1.PtzTour, error) {
+	tour, err := toTourModel(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.ensureCamera(ctx, camUUID); err != nil {
+		return nil, err
+	}
+
+	tour.Id = uuid.NewString()
+	tour.CamUUID = camUUID
+
+	// the job is created first so a bad schedule is rejected before the tour is stored
+	var jobID *uuid.UUID
+	if tour.Schedule != nil {
+		jid, err := svc.newJob(tour.Id, *tour.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		jobID = &jid
+	}
+
+	if err := svc.ToursRepo.Create(ctx, tour); err != nil {
+		if jobID != nil {
+			svc.removeJob(*jobID)
+		}
+		return nil, err
+	}
+	svc.setJob(tour.Id, jobID)
+
+	out := fromTourModel(tour)
+	return &out, nil
+}
+
+// Update replaces the tour, a run of it is stopped.
+func (svc *PtzToursService) Update(ctx context.Context, camUUID, id string, req v1.PtzTourReq) (*v1.PtzTour, error) {
+	tour, err := toTourModel(req)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := svc.find(ctx, camUUID, id)
+	if err != nil {
+		return nil, err
+	}
+	tour.Id, tour.CamUUID, tour.CreatedAt = stored.Id, stored.CamUUID, stored.CreatedAt
+
+	var jobID *uuid.UUID
+	if tour.Schedule != nil {
+		jid, err := svc.newJob(tour.Id, *tour.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		jobID = &jid
+	}
+
+	if err := svc.ToursRepo.Update(ctx, tour); err != nil {
+		if jobID != nil {
+			svc.removeJob(*jobID)
+		}
+		return nil, err
+	}
+	svc.setJob(tour.Id, jobID)
+	svc.stopTour(camUUID, tour.Id)
+
+	out := fromTourModel(tour)
+	return &out, nil
+}
+
+// Delete removes the tour along with its schedule, a run of it is stopped.
+func (svc *PtzToursService) Delete(ctx context.Context, camUUID, id string) error {
+	if _, err := svc.find(ctx, camUUID, id); err != nil {
+		return err
+	}
+
+	if err := svc.ToursRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	svc.setJob(id, nil)
+	svc.stopTour(camUUID, id)
+
+	return nil
+}
+
+// Start runs the tour on demand, replacing the camera's current run.
+func (svc *PtzToursService) Start(ctx context.Context, camUUID, id string) (*v1.PtzTourState, error) {
+	tour, err := svc.find(ctx, camUUID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	state, _ := svc.start(tour, true)
+	return &state, nil
+}
+
+// Stop stops the camera's current run, if any.
+func (svc *PtzToursService) Stop(ctx context.Context, camUUID string) error {
+	if err := svc.ensureCamera(ctx, camUUID); err != nil {
+		return err
+	}
+
+	svc.stopTour(camUUID, "")
+	return nil
+}
+
+func (svc *PtzToursService) State(ctx context.Context, camUUID string) (*v1.PtzTourState, error) {
+	if err := svc.ensureCamera(ctx, camUUID); err != nil {
+		return nil, err
+	}
+
+	svc.mtx.Lock()
+	defer svc.mtx.Unlock()
+
+	run, ok := svc.runs[camUUID]
+	if !ok {
+		return &v1.PtzTourState{Status: v1.PtzTourIdle}, nil
+	}
+
+	state := run.state
+	return &state, nil
+}
+
+// Interrupt pauses the camera's current run when a user takes manual control, the run resumes
+// from its current stop once the camera was left alone for IdleTimeout.
+func (svc *PtzToursService) Interrupt(camUUID string) {
+	idle := svc.IdleTimeout
+	if idle <= 0 {
+		idle = DefaultTourIdleTimeout
+	}
+
+	svc.mtx.Lock()
+	run, ok := svc.runs[camUUID]
+	if ok {
+		until := time.Now().Add(idle)
+		run.pausedUntil = until
+		run.state.Status = v1.PtzTourPaused
+		run.state.ResumesAt = &until
+	}
+	svc.mtx.Unlock()
+
+	if ok {
+		select {
+		case run.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// startScheduled is the task of the tour's job, a camera that is already touring is left alone.
+func (svc *PtzToursService) startScheduled(id string) {
+	ctx, cancel := context.WithTimeout(svc.runCtx(), 5*time.Second)
+	defer cancel()
+
+	tour, err := svc.ToursRepo.FindOne(ctx, id)
+	if err != nil {
+		if errors.Is(err, repos.ErrTourNotFound) {
+			// the camera was removed along with its tours
+			svc.setJob(id, nil)
+			return
+		}
+		svc.Logger.Error("ptz tours: failed to load scheduled tour", "tour", id, "err", err)
+		return
+	}
+
+	if _, started := svc.start(tour, false); !started {
+		svc.Logger.Info("ptz tours: camera is already touring, skipped scheduled tour", "uuid", tour.CamUUID, "tour", id)
+	}
+}
+
+func (svc *PtzToursService) start(tour *models.PtzTour, replace bool) (v1.PtzTourState, bool) {
+	parent := svc.runCtx()
+
+	svc.mtx.Lock()
+	defer svc.mtx.Unlock()
+
+	if cur, ok := svc.runs[tour.CamUUID]; ok {
+		if !replace {
+			return cur.state, false
+		}
+		cur.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	now := time.Now()
+	run := &tourRun{
+		state: v1.PtzTourState{
+			Status:    v1.PtzTourRunning,
+			TourId:    tour.Id,
+			TourName:  tour.Name,
+			Preset:    tour.Stops[0].Preset,
+			Loop:      1,
+			StartedAt: &now,
+		},
+		cancel: cancel,
+		wake:   make(chan struct{}, 1),
+	}
+
+	if svc.runs == nil {
+		svc.runs = make(map[string]*tourRun)
+	}
+	svc.runs[tour.CamUUID] = run
+	go svc.run(ctx, tour, run)
+
+	return run.state, true
+}
+
+func (svc *PtzToursService) run(ctx context.Context, tour *models.PtzTour, run *tourRun) {
+	defer func() {
+		svc.mtx.Lock()
+		if svc.runs[tour.CamUUID] == run {
+			delete(svc.runs, tour.CamUUID)
+		}
+		svc.mtx.Unlock()
+		run.cancel()
+	}()
+
+	holder := v1.PtzLockHolder{Id: "tour:" + tour.Id, Name: tour.Name, Priority: v1.PtzLockAutomation}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		// fails when a user took over the camera, their lease is left alone
+		_ = svc.Locks.Release(ctx, tour.CamUUID, holder)
+	}()
+
+	logger := svc.Logger.With("uuid", tour.CamUUID, "tour", tour.Id)
+	logger.Info("ptz tours: tour started", "stops", len(tour.Stops), "loops", tour.Loops)
+
+	for loop := 1; tour.Loops == 0 || loop <= tour.Loops; loop++ {
+		for i := 0; i < len(tour.Stops); {
+			if !svc.waitIdle(ctx, run) {
+				logger.Info("ptz tours: tour stopped")
+				return
+			}
+
+			stop := tour.Stops[i]
+			svc.mtx.Lock()
+			run.state.Stop, run.state.Preset, run.state.Loop = i, stop.Preset, loop
+			svc.mtx.Unlock()
+
+			dwell := time.Duration(stop.DwellMs) * time.Millisecond
+			moveCtx, cancel := context.WithTimeout(ctx, tourMoveTimeout)
+			err := svc.moveTo(moveCtx, tour.CamUUID, holder, stop.Preset, tourMoveTimeout+dwell)
+			cancel()
+			if errors.Is(err, ErrPtzLocked) {
+				logger.Debug("ptz tours: camera is held, pausing", "err", err)
+				svc.Interrupt(tour.CamUUID)
+				continue
+			}
+			if err != nil && ctx.Err() == nil {
+				logger.Warn("ptz tours: failed to go to preset", "preset", stop.Preset, "err", err)
+			}
+
+			// a stop the user interrupted is visited again once the tour resumes
+			if svc.dwell(ctx, run, dwell) {
+				i++
+			}
+		}
+	}
+
+	logger.Info("ptz tours: tour finished")
+}
+
+// moveTo leases the camera to the tour for the stop before going to its preset.
+func (svc *PtzToursService) moveTo(ctx context.Context, camUUID string, holder v1.PtzLockHolder, preset string, ttl time.Duration) error {
+	if _, err := svc.Locks.Acquire(ctx, camUUID, holder, ttl); err != nil {
+		return err
+	}
+
+	return svc.GotoPreset(ctx, camUUID, preset)
+}
+
+// waitIdle blocks while the run is paused, it returns false once the run is stopped.
+func (svc *PtzToursService) waitIdle(ctx context.Context, run *tourRun) bool {
+	for {
+		svc.mtx.Lock()
+		wait := time.Until(run.pausedUntil)
+		if wait <= 0 {
+			run.state.Status = v1.PtzTourRunning
+			run.state.ResumesAt = nil
+		}
+		svc.mtx.Unlock()
+
+		if wait <= 0 {
+			return ctx.Err() == nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-run.wake:
+			// paused again, the deadline moved
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// dwell reports whether the camera stayed on the stop for d without being interrupted.
+func (svc *PtzToursService) dwell(ctx context.Context, run *tourRun, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-run.wake:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// stopTour stops the camera's current run, when id is set only if it is a run of that tour.
+func (svc *PtzToursService) stopTour(camUUID, id string) {
+	svc.mtx.Lock()
+	defer svc.mtx.Unlock()
+
+	run, ok := svc.runs[camUUID]
+	if !ok || (id != "" && run.state.TourId != id) {
+		return
+	}
+	run.cancel()
+	delete(svc.runs, camUUID)
+}
+
+func (svc *PtzToursService) newJob(id, schedule string) (uuid.UUID, error) {
+	job, err := svc.Sched.NewJob(
+		gocron.CronJob(schedule, false),
+		gocron.NewTask(func() {
+			svc.startScheduled(id)
+		}),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: bad schedule: %v", ErrInvalidTour, err)
+	}
+
+	return job.ID(), nil
+}
+
+// setJob swaps the job of the tour, the tour is left unscheduled when jobID is nil.
+func (svc *PtzToursService) setJob(id string, jobID *uuid.UUID) {
+	svc.mtx.Lock()
+	old, ok := svc.jobs[id]
+	if jobID != nil {
+		if svc.jobs == nil {
+			svc.jobs = make(map[string]uuid.UUID)
+		}
+		svc.jobs[id] = *jobID
+	} else {
+		delete(svc.jobs, id)
+	}
+	svc.mtx.Unlock()
+
+	if ok {
+		svc.removeJob(old)
+	}
+}
+
+func (svc *PtzToursService) removeJob(jobID uuid.UUID) {
+	if err := svc.Sched.RemoveJob(jobID); err != nil {
+		svc.Logger.Warn("ptz tours: failed to remove job", "jobid", jobID, "err", err)
+	}
+}
+
+func (svc *PtzToursService) runCtx() context.Context {
+	svc.mtx.Lock()
+	defer svc.mtx.Unlock()
+
+	if svc.ctx == nil {
+		return context.Background()
+	}
+	return svc.ctx
+}
+
+// find returns the tour when it belongs to the camera.
+func (svc *PtzToursService) find(ctx context.Context, camUUID, id string) (*models.PtzTour, error) {
+	if err := uuid.Validate(id); err != nil {
+		return nil, repos.ErrTourNotFound
+	}
+
+	tour, err := svc.ToursRepo.FindOne(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tour.CamUUID != camUUID {
+		return nil, repos.ErrTourNotFound
+	}
+
+	return tour, nil
+}
+
+func (svc *PtzToursService) ensureCamera(ctx context.Context, uuid string) error {
+	if _, err := svc.CamRepo.FindOne(ctx, uuid); err != nil {
+		if pgxscan.NotFound(err) {
+			return ErrCameraNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func toTourModel(req v1.PtzTourReq) (*models.PtzTour, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxTourNameSize {
+		return nil, fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidTour, maxTourNameSize)
+	}
+	if len(req.Stops) == 0 || len(req.Stops) > maxTourStops {
+		return nil, fmt.Errorf("%w: a tour needs 1-%d stops", ErrInvalidTour, maxTourStops)
+	}
+
+	tour := &models.PtzTour{Name: name, Loops: 1}
+	for i, stop := range req.Stops {
+		preset := strings.TrimSpace(stop.Preset)
+		if preset == "" {
+			return nil, fmt.Errorf("%w: stop %d has no preset", ErrInvalidTour, i)
+		}
+		if stop.DwellMs < minTourDwellMs || stop.DwellMs > maxTourDwellMs {
+			return nil, fmt.Errorf("%w: dwell_ms of stop %d must be between %d and %d", ErrInvalidTour, i, minTourDwellMs, maxTourDwellMs)
+		}
+		tour.Stops = append(tour.Stops, models.PtzTourStop{Preset: preset, DwellMs: stop.DwellMs})
+	}
+
+	if req.Loops != nil {
+		if *req.Loops < 0 {
+			return nil, fmt.Errorf("%w: loops must not be negative", ErrInvalidTour)
+		}
+		tour.Loops = *req.Loops
+	}
+
+	if schedule := strings.TrimSpace(req.Schedule); schedule != "" {
+		tour.Schedule = &schedule
+	}
+
+	return tour, nil
+}
+
+func fromTourModel(tour *models.PtzTour) v1.PtzTour {
+	out := v1.PtzTour{
+		Id:        tour.Id,
+		UUID:      tour.CamUUID,
+		Name:      tour.Name,
+		Loops:     tour.Loops,
+		CreatedAt: tour.CreatedAt,
+		UpdatedAt: tour.UpdatedAt,
+		Stops: utils.Map(tour.Stops, func(stop models.PtzTourStop) v1.PtzTourStop {
+			return v1.PtzTourStop{Preset: stop.Preset, DwellMs: stop.DwellMs}
+		}),
+	}
+	if tour.Schedule != nil {
+		out.Schedule = *tour.Schedule
+	}
+
+	return out
+}
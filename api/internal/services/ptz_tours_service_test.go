@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	inmemory "tomerab.com/cam-hub/internal/events/in_memory"
+	"tomerab.com/cam-hub/internal/repos"
+)
+
+type fakePtzToursRepo struct {
+	repos.PtzToursRepoIface
+	tours map[string]*models.PtzTour
+}
+
+func (repo *fakePtzToursRepo) Create(ctx context.Context, tour *models.PtzTour) error {
+	repo.tours[tour.Id] = tour
+	return nil
+}
+
+func (repo *fakePtzToursRepo) FindOne(ctx context.Context, id string) (*models.PtzTour, error) {
+	tour, ok := repo.tours[id]
+	if !ok {
+		return nil, repos.ErrTourNotFound
+	}
+	return tour, nil
+}
+
+type presetVisits struct {
+	mtx     sync.Mutex
+	presets []string
+}
+
+func (v *presetVisits) gotoPreset(ctx context.Context, uuid, preset string) error {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	v.presets = append(v.presets, preset)
+	return nil
+}
+
+func (v *presetVisits) get() []string {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	return append([]string(nil), v.presets...)
+}
+
+func setupPtzToursServiceTest(t *testing.T) (*PtzToursService, *fakePtzToursRepo, *presetVisits) {
+	t.Helper()
+
+	sched, err := gocron.NewScheduler()
+	if err != nil {
+		t.Fatalf("failed to create scheduler: %v", err)
+	}
+	t.Cleanup(func() {
+		sched.Shutdown()
+	})
+
+	toursRepo := &fakePtzToursRepo{tours: map[string]*models.PtzTour{}}
+	visits := &presetVisits{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return &PtzToursService{
+		ToursRepo:  toursRepo,
+		CamRepo:    &fakeCameraRepo{cams: map[string]*models.Camera{"cam": {}}},
+		GotoPreset: visits.gotoPreset,
+		Locks: &PtzLockService{
+			Rdb:    &fakeRedis{vals: map[string]string{}},
+			PubSub: inmemory.NewInMemoryPubSub(),
+			Logger: logger,
+		},
+		Sched:       sched,
+		IdleTimeout: 50 * time.Millisecond,
+		Logger:      logger,
+	}, toursRepo, visits
+}
+
+func waitTourIdle(t *testing.T, svc *PtzToursService, camUUID string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		state, err := svc.State(context.Background(), camUUID)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if state.Status == v1.PtzTourIdle {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("tour didn't finish in time")
+}
+
+func TestPtzToursRun(t *testing.T) {
+	t.Run("two loops - should visit the stops in order twice", func(t *testing.T) {
+		svc, _, visits := setupPtzToursServiceTest(t)
+		tour := &models.PtzTour{
+			Id:      "tour",
+			CamUUID: "cam",
+			Stops:   []models.PtzTourStop{{Preset: "gate", DwellMs: 5}, {Preset: "yard", DwellMs: 5}},
+			Loops:   2,
+		}
+
+		if _, started := svc.start(tour, false); !started {
+			t.Fatalf("expected the tour to start")
+		}
+		waitTourIdle(t, svc, "cam")
+
+		expected := []string{"gate", "yard", "gate", "yard"}
+		if got := visits.get(); !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+	})
+
+	t.Run("camera already touring - should skip a scheduled start", func(t *testing.T) {
+		svc, _, _ := setupPtzToursServiceTest(t)
+		tour := &models.PtzTour{Id: "tour", CamUUID: "cam", Stops: []models.PtzTourStop{{Preset: "gate", DwellMs: 1000}}}
+
+		svc.start(tour, false)
+		defer svc.stopTour("cam", "")
+
+		if _, started := svc.start(tour, false); started {
+			t.Errorf("expected the second start to be skipped")
+		}
+		if _, started := svc.start(tour, true); !started {
+			t.Errorf("expected an on demand start to replace the run")
+		}
+	})
+
+	t.Run("manual move - should pause and resume from the current stop", func(t *testing.T) {
+		svc, _, visits := setupPtzToursServiceTest(t)
+		tour := &models.PtzTour{
+			Id:      "tour",
+			CamUUID: "cam",
+			Stops:   []models.PtzTourStop{{Preset: "gate", DwellMs: 30}, {Preset: "yard", DwellMs: 5}},
+			Loops:   1,
+		}
+
+		svc.start(tour, false)
+		time.Sleep(10 * time.Millisecond)
+		svc.Interrupt("cam")
+
+		state, err := svc.State(context.Background(), "cam")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if state.Status != v1.PtzTourPaused || state.ResumesAt == nil {
+			t.Errorf("expected a paused tour, got: %+v", state)
+		}
+
+		waitTourIdle(t, svc, "cam")
+
+		expected := []string{"gate", "gate", "yard"}
+		if got := visits.get(); !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+	})
+}
+
+func TestPtzToursLock(t *testing.T) {
+	t.Run("camera held by a user - should pause instead of moving", func(t *testing.T) {
+		svc, _, visits := setupPtzToursServiceTest(t)
+		ctx := context.Background()
+		user := v1.PtzLockHolder{Id: "user:1", Name: "tomer", Priority: v1.PtzLockOperator}
+		if _, err := svc.Locks.Acquire(ctx, "cam", user, time.Minute); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		tour := &models.PtzTour{Id: "tour", CamUUID: "cam", Stops: []models.PtzTourStop{{Preset: "gate", DwellMs: 5}}, Loops: 1}
+		svc.start(tour, false)
+		defer svc.stopTour("cam", "")
+		time.Sleep(20 * time.Millisecond)
+
+		state, err := svc.State(ctx, "cam")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if state.Status != v1.PtzTourPaused {
+			t.Errorf("expected a paused tour, got: %+v", state)
+		}
+		if got := visits.get(); len(got) != 0 {
+			t.Errorf("expected no moves, got: %v", got)
+		}
+	})
+}
+
+func TestPtzToursCreate(t *testing.T) {
+	svc, toursRepo, _ := setupPtzToursServiceTest(t)
+	ctx := context.Background()
+	stops := []v1.PtzTourStop{{Preset: "gate", DwellMs: 10000}}
+
+	t.Run("valid tour - should store it with one loop", func(t *testing.T) {
+		got, err := svc.Create(ctx, "cam", v1.PtzTourReq{Name: " perimeter ", Stops: stops, Schedule: "0 * * * *"})
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if got.Name != "perimeter" || got.Loops != 1 || got.Schedule != "0 * * * *" {
+			t.Errorf("unexpected tour: %+v", got)
+		}
+		if _, ok := toursRepo.tours[got.Id]; !ok {
+			t.Errorf("expected the tour to be stored")
+		}
+		if len(svc.Sched.Jobs()) != 1 {
+			t.Errorf("expected the tour to be scheduled, got %d jobs", len(svc.Sched.Jobs()))
+		}
+	})
+
+	t.Run("bad schedule - should return ErrInvalidTour", func(t *testing.T) {
+		if _, err := svc.Create(ctx, "cam", v1.PtzTourReq{Name: "night", Stops: stops, Schedule: "every night"}); !errors.Is(err, ErrInvalidTour) {
+			t.Errorf("expected ErrInvalidTour, got: %v", err)
+		}
+	})
+
+	t.Run("short dwell - should return ErrInvalidTour", func(t *testing.T) {
+		req := v1.PtzTourReq{Name: "fast", Stops: []v1.PtzTourStop{{Preset: "gate", DwellMs: 10}}}
+		if _, err := svc.Create(ctx, "cam", req); !errors.Is(err, ErrInvalidTour) {
+			t.Errorf("expected ErrInvalidTour, got: %v", err)
+		}
+	})
+
+	t.Run("unknown camera - should return ErrCameraNotFound", func(t *testing.T) {
+		if _, err := svc.Create(ctx, "missing", v1.PtzTourReq{Name: "perimeter", Stops: stops}); !errors.Is(err, ErrCameraNotFound) {
+			t.Errorf("expected ErrCameraNotFound, got: %v", err)
+		}
+	})
+}
@@ -2,12 +2,19 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"tomerab.com/cam-hub/internal/api/v1/models"
 	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	objectstorage "tomerab.com/cam-hub/internal/object_storage"
 	"tomerab.com/cam-hub/internal/repos"
+	"tomerab.com/cam-hub/internal/utils"
 )
 
 type RecordingState = string
@@ -17,20 +24,34 @@ const (
 	StateDiscarded RecordingState = "discarded"
 )
 
+const (
+	recordingsDefaultLimit = 50
+	recordingsMaxLimit     = 200
+	recordingsUrlExpiry    = 15 * time.Minute
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidFilter = errors.New("invalid recordings filter")
+)
+
 type RecordingsService struct {
 	recordingsRepo repos.RecordingsRepoIface
 	camerasRepo    repos.CameraRepoIface
+	store          objectstorage.ObjectStoreIface
 	logger         *slog.Logger
 }
 
 func NewRecordingsService(
 	logger *slog.Logger,
 	recordingsRepo repos.RecordingsRepoIface,
-	camsRepo repos.CameraRepoIface) *RecordingsService {
+	camsRepo repos.CameraRepoIface,
+	store objectstorage.ObjectStoreIface) *RecordingsService {
 	return &RecordingsService{
 		logger:         logger,
 		recordingsRepo: recordingsRepo,
 		camerasRepo:    camsRepo,
+		store:          store,
 	}
 }
 
@@ -53,13 +74,17 @@ func (svc *RecordingsService) Upsert(
 		BucketName:         req.BucketName,
 		VidBucketKey:       req.VidBucketKey,
 		BestFrameBucketKey: req.BestFrameBucketKey,
-		Evidence: models.Evidence{
-			Conf: req.Evidence.Conf,
-			Xmin: req.Evidence.Xmin,
-			Xmax: req.Evidence.Xmax,
-			Ymin: req.Evidence.Ymin,
-			Ymax: req.Evidence.Ymax,
-		},
+		Evidence: utils.Map(req.Evidence, func(ev v1.Evidence) models.Evidence {
+			return models.Evidence{
+				Conf:  ev.Conf,
+				Xmin:  ev.Xmin,
+				Xmax:  ev.Xmax,
+				Ymin:  ev.Ymin,
+				Ymax:  ev.Ymax,
+				Label: ev.Label,
+				Frame: ev.Frame,
+			}
+		}),
 		Score:         req.Score,
 		State:         state,
 		NeedsPublish:  needsPublish,
@@ -73,3 +98,108 @@ func (svc *RecordingsService) Upsert(
 
 	return rec, nil
 }
+
+// Query returns a single page of recordings matching the filter, newest first.
+// cursor is the opaque value returned in the previous page (empty for the first one).
+func (svc *RecordingsService) Query(ctx context.Context, filter models.RecordingsFilter, cursor string) (*v1.RecordingsPage, error) {
+	if filter.CamUUID != "" && uuid.Validate(filter.CamUUID) != nil {
+		return nil, fmt.Errorf("%w: cam_id is not a uuid", ErrInvalidFilter)
+	}
+	if filter.State != "" && filter.State != StatePromoted && filter.State != StateDiscarded {
+		return nil, fmt.Errorf("%w: unknown state %q", ErrInvalidFilter, filter.State)
+	}
+	if filter.MinScore != nil && filter.MaxScore != nil && *filter.MinScore > *filter.MaxScore {
+		return nil, fmt.Errorf("%w: min_score is greater than max_score", ErrInvalidFilter)
+	}
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		return nil, fmt.Errorf("%w: from is after to", ErrInvalidFilter)
+	}
+
+	switch {
+	case filter.Limit <= 0:
+		filter.Limit = recordingsDefaultLimit
+	case filter.Limit > recordingsMaxLimit:
+		filter.Limit = recordingsMaxLimit
+	}
+
+	if cursor != "" {
+		after, err := decodeRecordingsCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		filter.After = after
+	}
+
+	recs, err := svc.recordingsRepo.FindMany(ctx, &filter)
+	if err != nil {
+		return nil, fmt.Errorf("recordings: failed to query recordings: %w", err)
+	}
+
+	page := &v1.RecordingsPage{Recordings: recs}
+	if page.Recordings == nil {
+		page.Recordings = []*models.Recordings{}
+	}
+	if len(recs) == filter.Limit {
+		last := recs[len(recs)-1]
+		page.NextCursor = encodeRecordingsCursor(&models.RecordingsCursor{
+			PromotedAt: last.PromotedAt,
+			Id:         last.Id,
+		})
+	}
+
+	return page, nil
+}
+
+// GetWithUrls returns the recording along with presigned urls for its video and best frame.
+func (svc *RecordingsService) GetWithUrls(ctx context.Context, id string) (*v1.RecordingDetails, error) {
+	rec, err := svc.recordingsRepo.FindOne(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().UTC().Add(recordingsUrlExpiry)
+	vidUrl, err := svc.store.PresignedViewUrl(rec.BucketName, rec.VidBucketKey, objectstorage.UrlVideo, recordingsUrlExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("recordings: failed to presign video url: %w", err)
+	}
+
+	details := &v1.RecordingDetails{
+		Recordings:   rec,
+		VideoUrl:     vidUrl,
+		UrlsExpireAt: expiresAt,
+	}
+
+	if rec.BestFrameBucketKey != "" {
+		frameUrl, err := svc.store.PresignedViewUrl(rec.BucketName, rec.BestFrameBucketKey, objectstorage.UrlPng, recordingsUrlExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("recordings: failed to presign best frame url: %w", err)
+		}
+		details.BestFrameUrl = frameUrl
+	}
+
+	return details, nil
+}
+
+func encodeRecordingsCursor(cursor *models.RecordingsCursor) string {
+	raw := cursor.PromotedAt.Format(time.RFC3339Nano) + "|" + cursor.Id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeRecordingsCursor(cursor string) (*models.RecordingsCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	tsPart, id, ok := strings.Cut(string(raw), "|")
+	if !ok || uuid.Validate(id) != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, tsPart)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &models.RecordingsCursor{PromotedAt: ts, Id: id}, nil
+}
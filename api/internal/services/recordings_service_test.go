@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	"tomerab.com/cam-hub/internal/repos"
+)
+
+func (repo *fakeRecordingsRepo) FindOne(ctx context.Context, id string) (*models.Recordings, error) {
+	for _, rec := range repo.expired {
+		if rec.Id == id {
+			return rec, nil
+		}
+	}
+	return nil, repos.ErrNotFound
+}
+
+func (repo *fakeRecordingsRepo) FindMany(ctx context.Context, filter *models.RecordingsFilter) ([]*models.Recordings, error) {
+	return repo.expired, nil
+}
+
+func TestRecordingsQuery(t *testing.T) {
+	svc := NewRecordingsService(slog.New(slog.NewTextHandler(io.Discard, nil)), &fakeRecordingsRepo{}, nil, &fakeObjectStore{})
+	cursor := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	t.Run("cam_id is not a uuid - should return ErrInvalidFilter", func(t *testing.T) {
+		_, err := svc.Query(context.Background(), models.RecordingsFilter{CamUUID: "cam'1"}, "")
+		if !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("expected ErrInvalidFilter, got: %v", err)
+		}
+	})
+
+	t.Run("cursor id is not a uuid - should return ErrInvalidCursor", func(t *testing.T) {
+		_, err := svc.Query(context.Background(), models.RecordingsFilter{}, cursor("2025-09-15T00:00:00Z|9"))
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor, got: %v", err)
+		}
+	})
+
+	t.Run("valid cursor - should query the next page", func(t *testing.T) {
+		page, err := svc.Query(context.Background(), models.RecordingsFilter{CamUUID: "6f1c3a52-8a0e-4c1e-9d4b-2f9e7b1a0c11"},
+			cursor("2025-09-15T00:00:00Z|0b8f2d9e-3c4a-4e7b-8f1d-5a6c7e9b2d40"))
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if len(page.Recordings) != 0 || page.NextCursor != "" {
+			t.Errorf("expected an empty last page, got: %+v", page)
+		}
+	})
+}
+
+func TestRecordingsGetWithUrls(t *testing.T) {
+	t.Run("analyzed recording - should presign the objects the analyzer stored", func(t *testing.T) {
+		ev := makeAnalyzeEvent("2024-05-01_10-00-00")
+		repo := &fakeRecordingsRepo{expired: []*models.Recordings{makeExpiredRecording(ev.Tp)}}
+		svc := NewRecordingsService(slog.New(slog.NewTextHandler(io.Discard, nil)), repo, nil, &fakeObjectStore{})
+
+		details, err := svc.GetWithUrls(context.Background(), ev.Tp)
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if expected := "http://minio/recordings/detections/cam/2024-05-01_10-00-00/motion_cam_2024-05-01_10-00-00.mp4"; details.VideoUrl != expected {
+			t.Errorf("expected: %s, got: %s", expected, details.VideoUrl)
+		}
+		if expected := "http://minio/recordings/detections/cam/2024-05-01_10-00-00/motion_frame_cam_2024-05-01_10-00-00_0001.png"; details.BestFrameUrl != expected {
+			t.Errorf("expected: %s, got: %s", expected, details.BestFrameUrl)
+		}
+	})
+}
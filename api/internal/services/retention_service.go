@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	objectstorage "tomerab.com/cam-hub/internal/object_storage"
+	"tomerab.com/cam-hub/internal/repos"
+)
+
+// RetentionMetrics are cumulative counters since the process started.
+type RetentionMetrics struct {
+	runs              atomic.Int64
+	recordingsDeleted atomic.Int64
+	objectsDeleted    atomic.Int64
+	failures          atomic.Int64
+	dryRunMatches     atomic.Int64
+
+	mtx             sync.Mutex
+	lastRunAt       time.Time
+	lastRunDuration time.Duration
+}
+
+type RetentionService struct {
+	RecordingsRepo repos.RecordingsRepoIface
+	Store          objectstorage.ObjectStoreIface
+	Sched          gocron.Scheduler
+	Logger         *slog.Logger
+	Interval       time.Duration
+	BatchSize      int // max recordings fetched and deleted per batch
+	MaxBatches     int // max batches per run, the rest is picked up on the next tick
+	DryRun         bool
+	Metrics        RetentionMetrics
+}
+
+func (svc *RetentionService) InitJobs(ctx context.Context) error {
+	job, err := svc.Sched.NewJob(
+		gocron.DurationJob(svc.Interval),
+		gocron.NewTask(func() {
+			runCtx, cancel := context.WithTimeout(ctx, svc.Interval)
+			defer cancel()
+			svc.Logger.Info("Running retention tick", "dry_run", svc.DryRun)
+			svc.Enforce(runCtx)
+		}),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	svc.Logger.Info("Scheduled retention", "jobid", job.ID(), "interval", svc.Interval)
+	return nil
+}
+
+// Enforce deletes the objects of every expired recording and then the recording rows.
+// A recording whose objects could not be deleted is kept so it is retried on the next run.
+func (svc *RetentionService) Enforce(ctx context.Context) {
+	start := time.Now()
+	defer func() {
+		svc.Metrics.runs.Add(1)
+		svc.Metrics.mtx.Lock()
+		svc.Metrics.lastRunAt = start.UTC()
+		svc.Metrics.lastRunDuration = time.Since(start)
+		svc.Metrics.mtx.Unlock()
+	}()
+
+	for batch := 0; batch < svc.MaxBatches; batch++ {
+		if ctx.Err() != nil {
+			return
+		}
+
+		recs, err := svc.RecordingsRepo.FindExpired(ctx, time.Now(), svc.BatchSize)
+		if err != nil {
+			svc.Metrics.failures.Add(1)
+			svc.Logger.Error("retention: failed to find expired recordings", "err", err)
+			return
+		}
+		if len(recs) == 0 {
+			return
+		}
+
+		if svc.DryRun {
+			svc.Metrics.dryRunMatches.Add(int64(len(recs)))
+			for _, rec := range recs {
+				svc.Logger.Info("retention: dry run, would delete recording",
+					"id", rec.Id,
+					"cam_id", rec.CamUUID,
+					"vid_key", rec.VidBucketKey,
+					"promoted_at", rec.PromotedAt,
+					"retention_days", rec.RetentionDays,
+				)
+			}
+			// Nothing is deleted, fetching again would return the same rows.
+			return
+		}
+
+		ids := make([]string, 0, len(recs))
+		for _, rec := range recs {
+			if err := svc.removeObjects(rec); err != nil {
+				svc.Metrics.failures.Add(1)
+				svc.Logger.Error("retention: failed to remove objects", "id", rec.Id, "vid_key", rec.VidBucketKey, "err", err)
+				continue
+			}
+			ids = append(ids, rec.Id)
+		}
+
+		deleted, err := svc.RecordingsRepo.DeleteMany(ctx, ids)
+		if err != nil {
+			svc.Metrics.failures.Add(1)
+			svc.Logger.Error("retention: failed to delete recordings", "count", len(ids), "err", err)
+			return
+		}
+		svc.Metrics.recordingsDeleted.Add(deleted)
+		svc.Logger.Info("retention: deleted expired recordings", "batch", batch, "deleted", deleted, "failed", len(recs)-len(ids))
+
+		// Either there is nothing left or some rows failed, in which case the next
+		// fetch would return them again, leave them for the next run.
+		if len(recs) < svc.BatchSize || len(ids) < len(recs) {
+			return
+		}
+	}
+}
+
+func (svc *RetentionService) removeObjects(rec *models.Recordings) error {
+	keys := []string{rec.VidBucketKey}
+	if rec.BestFrameBucketKey != "" {
+		keys = append(keys, rec.BestFrameBucketKey)
+	}
+
+	for _, key := range keys {
+		if err := svc.Store.RemoveObject(rec.BucketName, key); err != nil {
+			return err
+		}
+		svc.Metrics.objectsDeleted.Add(1)
+	}
+
+	// The rest of the extracted frames live next to the video under the same "dir".
+	if dir := path.Dir(rec.VidBucketKey); dir != "." && dir != "/" {
+		if err := svc.Store.RemoveObjects(rec.BucketName, dir+"/"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (svc *RetentionService) Stats() v1.RetentionStats {
+	svc.Metrics.mtx.Lock()
+	defer svc.Metrics.mtx.Unlock()
+
+	return v1.RetentionStats{
+		Runs:              svc.Metrics.runs.Load(),
+		RecordingsDeleted: svc.Metrics.recordingsDeleted.Load(),
+		ObjectsDeleted:    svc.Metrics.objectsDeleted.Load(),
+		Failures:          svc.Metrics.failures.Load(),
+		DryRunMatches:     svc.Metrics.dryRunMatches.Load(),
+		LastRunAt:         svc.Metrics.lastRunAt,
+		LastRunDuration:   svc.Metrics.lastRunDuration.String(),
+		DryRun:            svc.DryRun,
+	}
+}
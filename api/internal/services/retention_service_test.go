@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	objectstorage "tomerab.com/cam-hub/internal/object_storage"
+	"tomerab.com/cam-hub/internal/repos"
+)
+
+type fakeRecordingsRepo struct {
+	repos.RecordingsRepoIface
+	expired []*models.Recordings
+	deleted []string
+}
+
+func (repo *fakeRecordingsRepo) FindExpired(ctx context.Context, now time.Time, limit int) ([]*models.Recordings, error) {
+	out := []*models.Recordings{}
+	for _, rec := range repo.expired {
+		if len(out) == limit {
+			break
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (repo *fakeRecordingsRepo) DeleteMany(ctx context.Context, ids []string) (int64, error) {
+	remaining := []*models.Recordings{}
+	for _, rec := range repo.expired {
+		keep := true
+		for _, id := range ids {
+			if rec.Id == id {
+				keep = false
+			}
+		}
+		if keep {
+			remaining = append(remaining, rec)
+		}
+	}
+	repo.expired = remaining
+	repo.deleted = append(repo.deleted, ids...)
+	return int64(len(ids)), nil
+}
+
+type fakeObjectStore struct {
+	mu           sync.Mutex
+	removed      []string
+	removedDir   []string
+	uploaded     []string
+	failKey      string
+	sweptBefore  map[string]time.Time // by prefix
+	sweptObjects int
+}
+
+func (store *fakeObjectStore) RemoveObject(bucketName, objName string) error {
+	if objName == store.failKey {
+		return errors.New("remove failed")
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.removed = append(store.removed, objName)
+	return nil
+}
+
+func (store *fakeObjectStore) RemoveObjects(bucketName, objPrefix string) error {
+	store.removedDir = append(store.removedDir, objPrefix)
+	return nil
+}
+
+func (store *fakeObjectStore) RemoveObjectsBefore(bucketName, objPrefix string, before time.Time) (int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.sweptBefore == nil {
+		store.sweptBefore = make(map[string]time.Time)
+	}
+	store.sweptBefore[objPrefix] = before
+	return store.sweptObjects, nil
+}
+
+func (store *fakeObjectStore) FPutObject(bucketName, objName, filePath string, contentType objectstorage.ContentType) error {
+	if objName == store.failKey {
+		return errors.New("upload failed")
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.uploaded = append(store.uploaded, objName)
+	return nil
+}
+
+func (store *fakeObjectStore) PresignedViewUrl(bucketName, objName string, contentType objectstorage.ContentType, expiry time.Duration) (string, error) {
+	return "http://minio/" + bucketName + "/" + objName, nil
+}
+
+// makeAnalyzeEvent stages the objects the way the motion runner uploads them.
+func makeAnalyzeEvent(tp string) *v1.AnalyzeImgsEvent {
+	staged := "staging/cam/" + tp
+	return &v1.AnalyzeImgsEvent{
+		UUID:       "cam",
+		Tp:         tp,
+		VidPath:    staged + "/motion_cam_" + tp + ".mp4",
+		FramePaths: []string{staged + "/motion_frame_cam_" + tp + "_0001.png"},
+	}
+}
+
+// makeExpiredRecording stores the keys the analyzer copies the staged objects to.
+func makeExpiredRecording(id string) *models.Recordings {
+	ev := makeAnalyzeEvent(id)
+	return &models.Recordings{
+		Id:                 id,
+		CamUUID:            "cam",
+		BucketName:         "recordings",
+		VidBucketKey:       ev.StoredKey("detections", ev.VidPath),
+		BestFrameBucketKey: ev.StoredKey("detections", ev.FramePaths[0]),
+	}
+}
+
+func setupRetentionServiceTest(recs ...*models.Recordings) (*RetentionService, *fakeRecordingsRepo, *fakeObjectStore) {
+	repo := &fakeRecordingsRepo{expired: recs}
+	store := &fakeObjectStore{}
+
+	return &RetentionService{
+		RecordingsRepo: repo,
+		Store:          store,
+		Logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		BatchSize:      2,
+		MaxBatches:     10,
+	}, repo, store
+}
+
+func TestRetentionEnforce(t *testing.T) {
+	t.Run("expired recordings - should delete objects and rows in batches", func(t *testing.T) {
+		svc, repo, store := setupRetentionServiceTest(makeExpiredRecording("1"), makeExpiredRecording("2"), makeExpiredRecording("3"))
+
+		svc.Enforce(context.Background())
+
+		if len(repo.deleted) != 3 {
+			t.Errorf("expected 3 deleted rows, got: %v", repo.deleted)
+		}
+		if len(store.removed) != 6 {
+			t.Errorf("expected 6 removed objects, got: %v", store.removed)
+		}
+		if len(store.removedDir) != 3 {
+			t.Errorf("expected 3 removed prefixes, got: %v", store.removedDir)
+		}
+
+		stats := svc.Stats()
+		if stats.RecordingsDeleted != 3 || stats.Runs != 1 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("analyzed recording - should delete the objects the analyzer stored", func(t *testing.T) {
+		svc, _, store := setupRetentionServiceTest(makeExpiredRecording("2024-05-01_10-00-00"))
+
+		svc.Enforce(context.Background())
+
+		expected := []string{
+			"detections/cam/2024-05-01_10-00-00/motion_cam_2024-05-01_10-00-00.mp4",
+			"detections/cam/2024-05-01_10-00-00/motion_frame_cam_2024-05-01_10-00-00_0001.png",
+		}
+		if !reflect.DeepEqual(expected, store.removed) {
+			t.Errorf("expected: %v, got: %v", expected, store.removed)
+		}
+		if expectedDirs := []string{"detections/cam/2024-05-01_10-00-00/"}; !reflect.DeepEqual(expectedDirs, store.removedDir) {
+			t.Errorf("expected: %v, got: %v", expectedDirs, store.removedDir)
+		}
+	})
+
+	t.Run("dry run - should not delete anything", func(t *testing.T) {
+		svc, repo, store := setupRetentionServiceTest(makeExpiredRecording("1"), makeExpiredRecording("2"))
+		svc.DryRun = true
+
+		svc.Enforce(context.Background())
+
+		if len(repo.deleted) != 0 || len(store.removed) != 0 {
+			t.Errorf("expected nothing deleted, got rows: %v, objects: %v", repo.deleted, store.removed)
+		}
+
+		if stats := svc.Stats(); stats.DryRunMatches != 2 {
+			t.Errorf("expected 2 dry run matches, got: %d", stats.DryRunMatches)
+		}
+	})
+
+	t.Run("object removal fails - should keep the row", func(t *testing.T) {
+		failing := makeExpiredRecording("1")
+		svc, repo, store := setupRetentionServiceTest(failing, makeExpiredRecording("2"))
+		store.failKey = failing.VidBucketKey
+
+		svc.Enforce(context.Background())
+
+		if len(repo.deleted) != 1 || repo.deleted[0] != "2" {
+			t.Errorf("expected only recording 2 to be deleted, got: %v", repo.deleted)
+		}
+
+		if stats := svc.Stats(); stats.Failures != 1 {
+			t.Errorf("expected 1 failure, got: %d", stats.Failures)
+		}
+	})
+}
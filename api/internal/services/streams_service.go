@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/mtxapi"
+	"tomerab.com/cam-hub/internal/repos"
+)
+
+var (
+	ErrInvalidStreamSelection = errors.New("invalid stream selection")
+	ErrInvalidTranscodePolicy = errors.New("invalid transcode policy")
+)
+
+type StreamsService struct {
+	CamRepo            repos.CameraRepoIface
+	StreamsRepo        repos.CameraStreamsRepoIface
+	SelectionRepo      repos.StreamSelectionRepoIface
+	MtxClient          *mtxapi.MtxClient
+	CamsEventProxyChan chan v1.CameraProxyEvent
+	Logger             *slog.Logger
+}
+
+// Get returns the streams discovered for the camera and the profile each consumer reads.
+func (svc *StreamsService) Get(ctx context.Context, uuid string) (*v1.CameraStreams, error) {
+	cam, err := svc.findCamera(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	streams, err := svc.StreamsRepo.FindByCamera(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	selection, err := svc.MtxClient.Selection(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.CameraStreams{
+		Streams:         streams,
+		Selection:       *selection,
+		TranscodePolicy: cam.TranscodePolicy,
+	}, nil
+}
+
+// UpdateSelection stores the selection and republishes the camera's paths. The camera's
+// revision is bumped so the supervisor restarts its motion worker on the new profile.
+func (svc *StreamsService) UpdateSelection(ctx context.Context, uuid string, selection v1.StreamSelection) error {
+	if err := validateStreamSelection(selection); err != nil {
+		return err
+	}
+
+	cam, err := svc.findCamera(ctx, uuid)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.SelectionRepo.Upsert(ctx, &models.StreamSelection{
+		UUID:     uuid,
+		Viewer:   selection.Viewer,
+		Recorder: selection.Recorder,
+		Motion:   selection.Motion,
+	}); err != nil {
+		return err
+	}
+
+	urls, err := svc.MtxClient.Publish(ctx, uuid)
+	if err != nil {
+		return fmt.Errorf("failed to publish streams: %w", err)
+	}
+
+	if err := svc.CamRepo.Save(ctx, cam); err != nil {
+		return err
+	}
+
+	svc.announce(cam, urls)
+	return nil
+}
+
+// UpdateTranscodePolicy stores the policy and restarts the camera's paths so it applies to
+// the streams being watched.
+func (svc *StreamsService) UpdateTranscodePolicy(ctx context.Context, uuid, policy string) error {
+	if !v1.ValidTranscodePolicy(policy) {
+		return fmt.Errorf("%w: policy must be %q, %q or %q", ErrInvalidTranscodePolicy, v1.TranscodeAuto, v1.TranscodeAlways, v1.TranscodeNever)
+	}
+
+	cam, err := svc.findCamera(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	if cam.TranscodePolicy == policy {
+		return nil
+	}
+
+	cam.TranscodePolicy = policy
+	if err := svc.CamRepo.Save(ctx, cam); err != nil {
+		return err
+	}
+
+	urls, err := svc.MtxClient.Restart(ctx, uuid)
+	if err != nil {
+		return fmt.Errorf("failed to restart streams: %w", err)
+	}
+
+	svc.announce(cam, urls)
+	return nil
+}
+
+// announce sends the saved camera to the supervisor, Save bumped its revision so the motion
+// worker is restarted on the new stream.
+func (svc *StreamsService) announce(cam *models.Camera, urls *mtxapi.StreamUrls) {
+	svc.CamsEventProxyChan <- v1.CameraProxyEvent{
+		CameraPairedEvent: &v1.CameraPairedEvent{
+			UUID:       cam.UUID,
+			StreamUrl:  urls.Motion,
+			RecordPath: urls.RecordPath,
+			Revision:   cam.Version + 1,
+		},
+	}
+}
+
+func (svc *StreamsService) findCamera(ctx context.Context, uuid string) (*models.Camera, error) {
+	cam, err := svc.CamRepo.FindOne(ctx, uuid)
+	if err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, ErrCameraNotFound
+		}
+		return nil, err
+	}
+
+	return cam, nil
+}
+
+func validateStreamSelection(selection v1.StreamSelection) error {
+	for consumer, profile := range map[string]string{
+		"viewer":   selection.Viewer,
+		"recorder": selection.Recorder,
+		"motion":   selection.Motion,
+	} {
+		if !v1.ValidStreamProfile(profile) {
+			return fmt.Errorf("%w: %s must be %q or %q", ErrInvalidStreamSelection, consumer, models.StreamMain, models.StreamSub)
+		}
+	}
+
+	return nil
+}
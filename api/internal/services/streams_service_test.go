@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"tomerab.com/cam-hub/internal/api/v1/models"
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/mtxapi"
+	"tomerab.com/cam-hub/internal/repos"
+)
+
+type fakeSavingCameraRepo struct {
+	fakeCameraRepo
+	saved int
+}
+
+func (repo *fakeSavingCameraRepo) Save(ctx context.Context, cam *models.Camera) error {
+	repo.saved++
+	return nil
+}
+
+type fakeCameraStreamsRepo struct {
+	repos.CameraStreamsRepoIface
+	streams map[string][]*models.CameraStream
+}
+
+func (repo *fakeCameraStreamsRepo) FindByCamera(ctx context.Context, camUUID string) ([]*models.CameraStream, error) {
+	return repo.streams[camUUID], nil
+}
+
+type fakeStreamSelectionRepo struct {
+	selections map[string]*models.StreamSelection
+}
+
+func (repo *fakeStreamSelectionRepo) FindOne(ctx context.Context, uuid string) (*models.StreamSelection, error) {
+	selection, ok := repo.selections[uuid]
+	if !ok {
+		return nil, repos.ErrStreamSelectionNotFound
+	}
+	return selection, nil
+}
+
+func (repo *fakeStreamSelectionRepo) Upsert(ctx context.Context, selection *models.StreamSelection) error {
+	repo.selections[selection.UUID] = selection
+	return nil
+}
+
+// fakeMediaMtx accepts every path it is asked to add and records the requests.
+type fakeMediaMtx struct {
+	mu       sync.Mutex
+	requests []string
+	playback []byte // served by the playback server's /get, a 404 when nil
+}
+
+func (mtx *fakeMediaMtx) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mtx.mu.Lock()
+	mtx.requests = append(mtx.requests, r.Method+" "+r.URL.Path)
+	mtx.mu.Unlock()
+
+	if r.URL.Path == "/get" {
+		if mtx.playback == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Write(mtx.playback)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/v3/config/paths/get/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func setupStreamsServiceTest(t *testing.T) (*StreamsService, *fakeStreamSelectionRepo, *fakeSavingCameraRepo, *fakeMediaMtx) {
+	t.Helper()
+
+	mtx := &fakeMediaMtx{}
+	server := httptest.NewServer(mtx)
+	t.Cleanup(server.Close)
+	t.Setenv("MEDIAMTX_ADDR", server.URL)
+	t.Setenv("MEDIAMTX_HOST", "mtx")
+
+	camRepo := &fakeSavingCameraRepo{
+		fakeCameraRepo: fakeCameraRepo{cams: map[string]*models.Camera{"cam": {UUID: "cam", Version: 3, TranscodePolicy: v1.TranscodeAuto}}},
+	}
+	selectionRepo := &fakeStreamSelectionRepo{selections: map[string]*models.StreamSelection{}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return &StreamsService{
+		CamRepo: camRepo,
+		StreamsRepo: &fakeCameraStreamsRepo{streams: map[string][]*models.CameraStream{
+			"cam": {{CamUUID: "cam", Profile: models.StreamMain, Uri: "rtsp://cam/main"}},
+		}},
+		SelectionRepo: selectionRepo,
+		MtxClient: &mtxapi.MtxClient{
+			HttpClient:    server.Client(),
+			Logger:        logger,
+			SelectionRepo: selectionRepo,
+		},
+		CamsEventProxyChan: make(chan v1.CameraProxyEvent, 1),
+		Logger:             logger,
+	}, selectionRepo, camRepo, mtx
+}
+
+func TestStreamsGet(t *testing.T) {
+	t.Run("never configured - should return the streams with the default selection", func(t *testing.T) {
+		svc, _, _, _ := setupStreamsServiceTest(t)
+
+		got, err := svc.Get(context.Background(), "cam")
+		if err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if len(got.Streams) != 1 || got.Streams[0].Profile != models.StreamMain {
+			t.Errorf("expected the main stream, got: %+v", got.Streams)
+		}
+		if got.Selection != v1.DefaultStreamSelection() {
+			t.Errorf("expected the default selection, got: %+v", got.Selection)
+		}
+	})
+
+	t.Run("unknown camera - should return ErrCameraNotFound", func(t *testing.T) {
+		svc, _, _, _ := setupStreamsServiceTest(t)
+
+		if _, err := svc.Get(context.Background(), "missing"); !errors.Is(err, ErrCameraNotFound) {
+			t.Fatalf("expected ErrCameraNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestStreamsUpdateSelection(t *testing.T) {
+	t.Run("valid selection - should store it, publish both paths and bump the revision", func(t *testing.T) {
+		svc, selectionRepo, camRepo, mtx := setupStreamsServiceTest(t)
+		selection := v1.StreamSelection{Viewer: models.StreamSub, Recorder: models.StreamSub, Motion: models.StreamMain}
+
+		if err := svc.UpdateSelection(context.Background(), "cam", selection); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		stored, ok := selectionRepo.selections["cam"]
+		if !ok || stored.Viewer != models.StreamSub || stored.Motion != models.StreamMain {
+			t.Errorf("expected the selection to be stored, got: %+v", stored)
+		}
+
+		for _, path := range []string{"POST /v3/config/paths/add/cam/main", "POST /v3/config/paths/add/cam/sub"} {
+			if !slices.Contains(mtx.requests, path) {
+				t.Errorf("expected %q, got: %v", path, mtx.requests)
+			}
+		}
+
+		if camRepo.saved != 1 {
+			t.Errorf("expected the camera to be saved once, got %d", camRepo.saved)
+		}
+
+		ev := (<-svc.CamsEventProxyChan).CameraPairedEvent
+		if ev == nil || ev.Revision != 4 {
+			t.Fatalf("expected a paired event with revision 4, got: %+v", ev)
+		}
+		if ev.StreamUrl != "http://mtx:8889/cam/main/whep" || ev.RecordPath != "cam/sub" {
+			t.Errorf("unexpected paired event: %+v", ev)
+		}
+	})
+
+	t.Run("invalid selection - should be rejected", func(t *testing.T) {
+		svc, selectionRepo, _, _ := setupStreamsServiceTest(t)
+
+		err := svc.UpdateSelection(context.Background(), "cam", v1.StreamSelection{Viewer: "main", Recorder: "4k", Motion: "sub"})
+		if !errors.Is(err, ErrInvalidStreamSelection) {
+			t.Fatalf("expected ErrInvalidStreamSelection, got: %v", err)
+		}
+		if len(selectionRepo.selections) != 0 {
+			t.Errorf("expected nothing to be stored")
+		}
+	})
+
+	t.Run("unknown camera - should return ErrCameraNotFound", func(t *testing.T) {
+		svc, _, _, _ := setupStreamsServiceTest(t)
+
+		err := svc.UpdateSelection(context.Background(), "missing", v1.DefaultStreamSelection())
+		if !errors.Is(err, ErrCameraNotFound) {
+			t.Fatalf("expected ErrCameraNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestStreamsUpdateTranscodePolicy(t *testing.T) {
+	t.Run("new policy - should save it and restart the paths", func(t *testing.T) {
+		svc, _, camRepo, mtx := setupStreamsServiceTest(t)
+
+		if err := svc.UpdateTranscodePolicy(context.Background(), "cam", v1.TranscodeAlways); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+
+		if camRepo.saved != 1 || camRepo.cams["cam"].TranscodePolicy != v1.TranscodeAlways {
+			t.Errorf("expected the policy to be saved, got: %+v (saved %d)", camRepo.cams["cam"], camRepo.saved)
+		}
+		if !slices.Contains(mtx.requests, "POST /v3/config/paths/add/cam/main") {
+			t.Errorf("expected the paths to be published, got: %v", mtx.requests)
+		}
+
+		ev := (<-svc.CamsEventProxyChan).CameraPairedEvent
+		if ev == nil || ev.Revision != 4 {
+			t.Fatalf("expected a paired event with revision 4, got: %+v", ev)
+		}
+	})
+
+	t.Run("same policy - should do nothing", func(t *testing.T) {
+		svc, _, camRepo, mtx := setupStreamsServiceTest(t)
+
+		if err := svc.UpdateTranscodePolicy(context.Background(), "cam", v1.TranscodeAuto); err != nil {
+			t.Fatalf("expected nil, got err: %v", err)
+		}
+		if camRepo.saved != 0 || len(mtx.requests) != 0 {
+			t.Errorf("expected nothing to be saved or published, got %d saves and %v", camRepo.saved, mtx.requests)
+		}
+	})
+
+	t.Run("unknown policy - should be rejected", func(t *testing.T) {
+		svc, _, _, _ := setupStreamsServiceTest(t)
+
+		if err := svc.UpdateTranscodePolicy(context.Background(), "cam", "sometimes"); !errors.Is(err, ErrInvalidTranscodePolicy) {
+			t.Fatalf("expected ErrInvalidTranscodePolicy, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+)
+
+// WorkersService keeps the latest motion worker states reported by the supervisor.
+type WorkersService struct {
+	mtx    sync.RWMutex
+	states map[string]v1.WorkerState
+	logger *slog.Logger
+}
+
+func NewWorkersService(logger *slog.Logger) *WorkersService {
+	return &WorkersService{
+		states: make(map[string]v1.WorkerState),
+		logger: logger,
+	}
+}
+
+// Apply records a reported state, states older than the one already known are ignored
+// and stopped workers are forgotten.
+func (svc *WorkersService) Apply(state v1.WorkerState) {
+	svc.mtx.Lock()
+	defer svc.mtx.Unlock()
+
+	if cur, ok := svc.states[state.UUID]; ok && state.UpdatedAt.Before(cur.UpdatedAt) {
+		return
+	}
+
+	if state.Status == v1.WorkerStopped {
+		delete(svc.states, state.UUID)
+		return
+	}
+
+	if state.Status == v1.WorkerFailed {
+		svc.logger.Warn("motion worker failed", "uuid", state.UUID, "restarts", state.Restarts, "last_error", state.LastError)
+	}
+	svc.states[state.UUID] = state
+}
+
+func (svc *WorkersService) List() []v1.WorkerState {
+	svc.mtx.RLock()
+	defer svc.mtx.RUnlock()
+
+	states := make([]v1.WorkerState, 0, len(svc.states))
+	for _, state := range svc.states {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].UUID < states[j].UUID
+	})
+
+	return states
+}
+
+func (svc *WorkersService) Get(uuid string) (v1.WorkerState, bool) {
+	svc.mtx.RLock()
+	defer svc.mtx.RUnlock()
+
+	state, ok := svc.states[uuid]
+	return state, ok
+}
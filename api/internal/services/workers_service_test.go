@@ -0,0 +1,50 @@
+package services
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+)
+
+func TestWorkersApply(t *testing.T) {
+	now := time.Now()
+	newSvc := func() *WorkersService {
+		return NewWorkersService(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}
+
+	t.Run("newer state - should replace the known one", func(t *testing.T) {
+		svc := newSvc()
+		svc.Apply(v1.WorkerState{UUID: "cam", Status: v1.WorkerRunning, UpdatedAt: now})
+		svc.Apply(v1.WorkerState{UUID: "cam", Status: v1.WorkerBackoff, Restarts: 1, UpdatedAt: now.Add(time.Second)})
+
+		state, ok := svc.Get("cam")
+		if !ok || state.Status != v1.WorkerBackoff || state.Restarts != 1 {
+			t.Errorf("expected backoff state, got: %+v", state)
+		}
+	})
+
+	t.Run("out of order state - should be ignored", func(t *testing.T) {
+		svc := newSvc()
+		svc.Apply(v1.WorkerState{UUID: "cam", Status: v1.WorkerFailed, UpdatedAt: now})
+		svc.Apply(v1.WorkerState{UUID: "cam", Status: v1.WorkerRunning, UpdatedAt: now.Add(-time.Second)})
+
+		if state, _ := svc.Get("cam"); state.Status != v1.WorkerFailed {
+			t.Errorf("expected failed state to stay, got: %+v", state)
+		}
+	})
+
+	t.Run("stopped worker - should be forgotten", func(t *testing.T) {
+		svc := newSvc()
+		svc.Apply(v1.WorkerState{UUID: "a", Status: v1.WorkerRunning, UpdatedAt: now})
+		svc.Apply(v1.WorkerState{UUID: "b", Status: v1.WorkerRunning, UpdatedAt: now})
+		svc.Apply(v1.WorkerState{UUID: "a", Status: v1.WorkerStopped, UpdatedAt: now.Add(time.Second)})
+
+		states := svc.List()
+		if len(states) != 1 || states[0].UUID != "b" {
+			t.Errorf("expected only worker b, got: %+v", states)
+		}
+	})
+}
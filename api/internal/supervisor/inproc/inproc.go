@@ -0,0 +1,85 @@
+// Package inproc runs the motion detectors as goroutines of the supervisor process, it lives
+// apart from the supervisor so only installs that use it link against OpenCV.
+package inproc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/motion"
+	objectstorage "tomerab.com/cam-hub/internal/object_storage"
+	"tomerab.com/cam-hub/internal/supervisor"
+)
+
+type Launcher struct {
+	Bus    motion.DetectionBusIface
+	Store  *objectstorage.MinIOStore
+	Logger *slog.Logger
+	// The internal API the detectors pulling the cameras' ONVIF events resolve them from.
+	ApiAddr  string
+	ApiToken string
+}
+
+func (launcher *Launcher) Launch(spec supervisor.WorkerSpec) (supervisor.Worker, error) {
+	settings := v1.DefaultMotionSettings()
+	if spec.Motion != nil {
+		settings = *spec.Motion
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &worker{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(w.done)
+		defer func() {
+			// A panicking detector must not take the other cameras down with it.
+			if r := recover(); r != nil {
+				w.err = fmt.Errorf("motion detection panicked: %v", r)
+			}
+		}()
+
+		w.err = motion.RunDetection(ctx, motion.DetectionParams{
+			CamUUID:    spec.CamUUID,
+			StreamUrl:  spec.StreamUrl,
+			RecordPath: spec.RecordPath,
+			Settings:   settings,
+			Zones:      spec.Zones,
+			Bus:        launcher.Bus,
+			Store:      launcher.Store,
+			Logger:     launcher.Logger.With("cam", spec.CamUUID),
+
+			OnvifSource: motion.InternalOnvifSource(launcher.ApiAddr, launcher.ApiToken, spec.CamUUID),
+		})
+	}()
+
+	return w, nil
+}
+
+type worker struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+func (w *worker) Pid() int {
+	return 0
+}
+
+func (w *worker) Stop() {
+	w.cancel()
+}
+
+func (w *worker) Wait() (int, error) {
+	<-w.done
+	w.cancel()
+	if w.err != nil {
+		return 1, w.err
+	}
+
+	return 0, nil
+}
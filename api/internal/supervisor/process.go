@@ -0,0 +1,113 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const defaultKillTimeout = 5 * time.Second
+
+// ProcessLauncher runs every worker as a prebuilt motion detection binary in its own process group.
+type ProcessLauncher struct {
+	Bin         string
+	KillTimeout time.Duration // grace period between SIGTERM and SIGKILL
+	Logger      *slog.Logger
+}
+
+func NewProcessLauncher(bin string, logger *slog.Logger) *ProcessLauncher {
+	return &ProcessLauncher{
+		Bin:         bin,
+		KillTimeout: defaultKillTimeout,
+		Logger:      logger,
+	}
+}
+
+func (launcher *ProcessLauncher) Launch(spec WorkerSpec) (Worker, error) {
+	cmd := exec.Command(launcher.Bin, ProcessArgs(spec)...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &processWorker{
+		cmd:         cmd,
+		killTimeout: launcher.KillTimeout,
+		logger:      launcher.Logger,
+	}, nil
+}
+
+// ProcessArgs maps the spec to the motion detection binary flags.
+func ProcessArgs(spec WorkerSpec) Args {
+	args := Args{
+		"-addr", spec.StreamUrl,
+		"-uuid", spec.CamUUID,
+	}
+
+	if spec.RecordPath != "" {
+		args = append(args, "-record-path", spec.RecordPath)
+	}
+
+	if spec.Motion != nil {
+		args = append(args,
+			"-threshold", strconv.FormatFloat(float64(spec.Motion.Threshold), 'f', -1, 32),
+			"-min-area", strconv.Itoa(spec.Motion.MinArea),
+			"-warmup-frames", strconv.Itoa(spec.Motion.WarmupFrames),
+			"-tick-ms", strconv.Itoa(spec.Motion.TickMs),
+			"-cooldown-ms", strconv.Itoa(spec.Motion.CooldownMs),
+			"-pre-roll-ms", strconv.Itoa(spec.Motion.PreRollMs),
+			"-post-roll-ms", strconv.Itoa(spec.Motion.PostRollMs),
+			"-max-event-ms", strconv.Itoa(spec.Motion.MaxEventMs),
+		)
+		if spec.Motion.Source != "" {
+			args = append(args, "-source", spec.Motion.Source)
+		}
+	}
+	if len(spec.Zones) > 0 {
+		// Marshaling plain structs can't fail.
+		zonesBytes, _ := json.Marshal(spec.Zones)
+		args = append(args, "-zones", string(zonesBytes))
+	}
+
+	return args
+}
+
+type processWorker struct {
+	cmd         *exec.Cmd
+	killTimeout time.Duration
+	logger      *slog.Logger
+}
+
+func (w *processWorker) Pid() int {
+	return w.cmd.Process.Pid
+}
+
+// Stop sends SIGTERM to the process group and SIGKILL if it is still alive after the grace period.
+func (w *processWorker) Stop() {
+	pid := w.cmd.Process.Pid
+	if err := syscall.Kill(-pid, syscall.SIGTERM); err != nil {
+		if err := syscall.Kill(-pid, syscall.SIGKILL); err != nil {
+			w.logger.Error("stop: kill failed", "pid", pid, "err", err)
+		}
+		return
+	}
+
+	go func() {
+		time.Sleep(w.killTimeout)
+		// Check if the process is still alive, if it is send sigkill
+		if err := syscall.Kill(-pid, 0); err == nil {
+			if err := syscall.Kill(-pid, syscall.SIGKILL); err != nil {
+				w.logger.Error("stop: kill failed", "pid", pid, "err", err)
+			}
+		}
+	}()
+}
+
+func (w *processWorker) Wait() (int, error) {
+	err := w.cmd.Wait()
+	return w.cmd.ProcessState.ExitCode(), err
+}
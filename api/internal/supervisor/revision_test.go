@@ -0,0 +1,316 @@
+package supervisor
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+)
+
+type fakeWorker struct {
+	pid       int
+	spec      WorkerSpec
+	exitCh    chan int
+	stopped   chan struct{}
+	stopDelay time.Duration // how long the worker takes to exit once stopped
+	exited    atomic.Bool
+	once      sync.Once
+}
+
+func (w *fakeWorker) Pid() int { return w.pid }
+
+func (w *fakeWorker) Stop() {
+	w.once.Do(func() { close(w.stopped) })
+}
+
+func (w *fakeWorker) Wait() (int, error) {
+	select {
+	case code := <-w.exitCh:
+		return code, nil
+	case <-w.stopped:
+		time.Sleep(w.stopDelay)
+		w.exited.Store(true)
+		return 0, nil
+	}
+}
+
+type fakeLauncher struct {
+	mtx       sync.Mutex
+	workers   []*fakeWorker
+	stopDelay time.Duration
+}
+
+func (l *fakeLauncher) Launch(spec WorkerSpec) (Worker, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	w := &fakeWorker{
+		pid:       len(l.workers) + 1,
+		spec:      spec,
+		exitCh:    make(chan int, 1),
+		stopped:   make(chan struct{}),
+		stopDelay: l.stopDelay,
+	}
+	l.workers = append(l.workers, w)
+	return w, nil
+}
+
+func (l *fakeLauncher) launched() []*fakeWorker {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return append([]*fakeWorker(nil), l.workers...)
+}
+
+func newTestSupervisor(t *testing.T, launcher Launcher) (*Supervisor, func() []v1.WorkerState) {
+	t.Helper()
+
+	var mtx sync.Mutex
+	var states []v1.WorkerState
+	visor := NewSupervisor(Params{
+		MaxProcs:       4,
+		Logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Launcher:       launcher,
+		Policy:         RestartOnFailure,
+		BackoffInitial: 5 * time.Millisecond,
+		BackoffMax:     10 * time.Millisecond,
+		MaxRestarts:    2,
+		OnState: func(s v1.WorkerState) {
+			mtx.Lock()
+			states = append(states, s)
+			mtx.Unlock()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go visor.Run(ctx)
+
+	return visor, func() []v1.WorkerState {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return append([]v1.WorkerState(nil), states...)
+	}
+}
+
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func register(visor *Supervisor, rev int) {
+	visor.NotifyCtrl(CtrlEvent{
+		Kind:    CtrlRegister,
+		CamUUID: "cam-1",
+		Spec:    WorkerSpec{CamUUID: "cam-1", Revision: rev},
+	})
+}
+
+func lastStatus(states []v1.WorkerState) string {
+	if len(states) == 0 {
+		return ""
+	}
+	return states[len(states)-1].Status
+}
+
+func TestRegisterIgnoresDuplicateAndStaleRevisions(t *testing.T) {
+	launcher := &fakeLauncher{}
+	visor, _ := newTestSupervisor(t, launcher)
+
+	register(visor, 2)
+	eventually(t, func() bool { return len(launcher.launched()) == 1 })
+
+	register(visor, 2)
+	register(visor, 1)
+	time.Sleep(20 * time.Millisecond)
+
+	if n := len(launcher.launched()); n != 1 {
+		t.Fatalf("expected a single worker, got %d", n)
+	}
+	if rev, err := visor.GetCameraRevision("cam-1"); err != nil || rev != 2 {
+		t.Errorf("expected revision 2, got %d (%v)", rev, err)
+	}
+}
+
+func TestRegisterNewerRevisionReplacesWorker(t *testing.T) {
+	launcher := &fakeLauncher{}
+	visor, _ := newTestSupervisor(t, launcher)
+
+	register(visor, 1)
+	eventually(t, func() bool { return len(launcher.launched()) == 1 })
+
+	register(visor, 2)
+	eventually(t, func() bool { return len(launcher.launched()) == 2 })
+
+	workers := launcher.launched()
+	select {
+	case <-workers[0].stopped:
+	default:
+		t.Fatal("expected the old worker to be stopped before the new one started")
+	}
+	if workers[1].spec.Revision != 2 {
+		t.Errorf("expected revision 2, got %d", workers[1].spec.Revision)
+	}
+	eventually(t, func() bool {
+		rev, _ := visor.GetCameraRevision("cam-1")
+		return rev == 2
+	})
+}
+
+func TestCrashedWorkerIsRestarted(t *testing.T) {
+	launcher := &fakeLauncher{}
+	visor, states := newTestSupervisor(t, launcher)
+
+	register(visor, 1)
+	eventually(t, func() bool { return len(launcher.launched()) == 1 })
+
+	launcher.launched()[0].exitCh <- 1
+	eventually(t, func() bool { return len(launcher.launched()) == 2 })
+	eventually(t, func() bool { return lastStatus(states()) == v1.WorkerRunning })
+
+	if got := states()[len(states())-1].Restarts; got != 1 {
+		t.Errorf("expected 1 restart, got %d", got)
+	}
+}
+
+func TestCrashLoopGivesUp(t *testing.T) {
+	launcher := &fakeLauncher{}
+	visor, states := newTestSupervisor(t, launcher)
+
+	register(visor, 1)
+	for i := range 3 {
+		eventually(t, func() bool { return len(launcher.launched()) == i+1 })
+		launcher.launched()[i].exitCh <- 1
+	}
+
+	eventually(t, func() bool { return lastStatus(states()) == v1.WorkerFailed })
+	if rev, err := visor.GetCameraRevision("cam-1"); err != nil || rev != 1 {
+		t.Errorf("expected the failed worker to be kept at revision 1, got %d (%v)", rev, err)
+	}
+
+	// A replayed registration or reconcile must not revive it, a newer revision does.
+	register(visor, 1)
+	visor.NotifyCtrl(CtrlEvent{
+		Kind:    CtrlReconcile,
+		Desired: &Desired{Specs: []WorkerSpec{{CamUUID: "cam-1", Revision: 1}}, Complete: true, AsOf: time.Now()},
+	})
+	time.Sleep(20 * time.Millisecond)
+	if n := len(launcher.launched()); n != 3 {
+		t.Fatalf("expected the failed worker to stay down, got %d workers", n)
+	}
+
+	register(visor, 2)
+	eventually(t, func() bool { return len(launcher.launched()) == 4 })
+}
+
+func TestShutdownWaitsForWorkers(t *testing.T) {
+	launcher := &fakeLauncher{stopDelay: 30 * time.Millisecond}
+	visor, _ := newTestSupervisor(t, launcher)
+
+	register(visor, 1)
+	eventually(t, func() bool { return len(launcher.launched()) == 1 })
+
+	visor.Shutdown()
+	if !launcher.launched()[0].exited.Load() {
+		t.Error("expected the worker to have exited")
+	}
+}
+
+func TestUnregisterStopsWorker(t *testing.T) {
+	launcher := &fakeLauncher{}
+	visor, states := newTestSupervisor(t, launcher)
+
+	register(visor, 1)
+	eventually(t, func() bool { return len(launcher.launched()) == 1 })
+
+	visor.NotifyCtrl(CtrlEvent{Kind: CtrlUnregister, CamUUID: "cam-1"})
+	eventually(t, func() bool { return lastStatus(states()) == v1.WorkerStopped })
+
+	if n := len(launcher.launched()); n != 1 {
+		t.Errorf("expected no restart, got %d workers", n)
+	}
+}
+
+func TestReconcileStartsMissingAndStopsOrphans(t *testing.T) {
+	launcher := &fakeLauncher{}
+	visor, _ := newTestSupervisor(t, launcher)
+
+	register(visor, 1)
+	eventually(t, func() bool { return len(launcher.launched()) == 1 })
+
+	visor.NotifyCtrl(CtrlEvent{
+		Kind: CtrlReconcile,
+		Desired: &Desired{
+			Specs:    []WorkerSpec{{CamUUID: "cam-2", Revision: 1}},
+			Complete: true,
+			AsOf:     time.Now(),
+		},
+	})
+
+	eventually(t, func() bool { return len(launcher.launched()) == 2 })
+	eventually(t, func() bool {
+		_, err := visor.GetCameraRevision("cam-1")
+		return err != nil
+	})
+	if rev, err := visor.GetCameraRevision("cam-2"); err != nil || rev != 1 {
+		t.Errorf("expected cam-2 at revision 1, got %d (%v)", rev, err)
+	}
+}
+
+func TestReconcileKeepsWorkersOnIncompleteOrOlderState(t *testing.T) {
+	launcher := &fakeLauncher{}
+	visor, _ := newTestSupervisor(t, launcher)
+
+	asOf := time.Now()
+	register(visor, 1)
+	eventually(t, func() bool { return len(launcher.launched()) == 1 })
+
+	visor.NotifyCtrl(CtrlEvent{Kind: CtrlReconcile, Desired: &Desired{Complete: false, AsOf: time.Now()}})
+	visor.NotifyCtrl(CtrlEvent{Kind: CtrlReconcile, Desired: &Desired{Complete: true, AsOf: asOf}})
+	time.Sleep(20 * time.Millisecond)
+
+	select {
+	case <-launcher.launched()[0].stopped:
+		t.Fatal("expected the worker to keep running")
+	default:
+	}
+}
+
+func TestReconcileIgnoresCamerasUnregisteredAfterTheSnapshot(t *testing.T) {
+	launcher := &fakeLauncher{}
+	visor, _ := newTestSupervisor(t, launcher)
+
+	asOf := time.Now()
+	register(visor, 1)
+	eventually(t, func() bool { return len(launcher.launched()) == 1 })
+
+	visor.NotifyCtrl(CtrlEvent{Kind: CtrlUnregister, CamUUID: "cam-1"})
+	eventually(t, func() bool {
+		_, err := visor.GetCameraRevision("cam-1")
+		return err != nil
+	})
+
+	visor.NotifyCtrl(CtrlEvent{
+		Kind:    CtrlReconcile,
+		Desired: &Desired{Specs: []WorkerSpec{{CamUUID: "cam-1", Revision: 1}}, Complete: true, AsOf: asOf},
+	})
+	time.Sleep(20 * time.Millisecond)
+	if n := len(launcher.launched()); n != 1 {
+		t.Fatalf("expected the unregistered camera to stay down, got %d workers", n)
+	}
+
+	// Pairing it again lifts the tombstone.
+	register(visor, 1)
+	eventually(t, func() bool { return len(launcher.launched()) == 2 })
+}
@@ -4,51 +4,94 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"os"
-	"os/exec"
 	"sync"
-	"syscall"
 	"time"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+)
+
+const (
+	defaultBackoffInitial = time.Second
+	defaultBackoffMax     = time.Minute
+	defaultMaxRestarts    = 5
+	defaultStableAfter    = time.Minute
 )
 
 type Supervisor struct {
-	mtx    sync.Mutex
-	procs  map[string]*Proc
-	exitCh chan ExitEvent
-	ctrlCh chan CtrlEvent
-	logger *slog.Logger
+	mtx          sync.Mutex
+	procs        map[string]*Proc
+	exitCh       chan ExitEvent
+	ctrlCh       chan CtrlEvent
+	logger       *slog.Logger
+	params       Params
+	shuttingDown bool
+	running      int        // workers that didn't exit yet
+	exited       *sync.Cond // signaled on mtx whenever a worker exits
+	// When each camera was last unregistered, a snapshot taken before that is outdated.
+	unregisteredAt map[string]time.Time
 }
 
-func NewSupervisor(maxProcs int, logger *slog.Logger) *Supervisor {
-	return &Supervisor{
-		mtx:    sync.Mutex{},
-		procs:  make(map[string]*Proc),
-		exitCh: make(chan ExitEvent, maxProcs),
-		ctrlCh: make(chan CtrlEvent, maxProcs),
-		logger: logger,
+func NewSupervisor(params Params) *Supervisor {
+	if params.Policy == "" {
+		params.Policy = RestartOnFailure
+	}
+	if params.BackoffInitial <= 0 {
+		params.BackoffInitial = defaultBackoffInitial
+	}
+	if params.BackoffMax < params.BackoffInitial {
+		params.BackoffMax = max(defaultBackoffMax, params.BackoffInitial)
+	}
+	if params.MaxRestarts <= 0 {
+		params.MaxRestarts = defaultMaxRestarts
+	}
+	if params.StableAfter <= 0 {
+		params.StableAfter = defaultStableAfter
+	}
+
+	visor := &Supervisor{
+		mtx:            sync.Mutex{},
+		procs:          make(map[string]*Proc),
+		unregisteredAt: make(map[string]time.Time),
+		exitCh:         make(chan ExitEvent, params.MaxProcs),
+		ctrlCh:         make(chan CtrlEvent, params.MaxProcs),
+		logger:         params.Logger,
+		params:         params,
 	}
+	visor.exited = sync.NewCond(&visor.mtx)
+
+	return visor
 }
 
 func (visor *Supervisor) Run(ctx context.Context) {
+	var snapshotC <-chan time.Time
+	if visor.params.StateInterval > 0 {
+		ticker := time.NewTicker(visor.params.StateInterval)
+		defer ticker.Stop()
+		snapshotC = ticker.C
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case exit := <-visor.exitCh:
-			visor.logger.Info("Process exit",
-				"cam", exit.camID,
-				"pid", exit.procID,
-				"status", exit.status,
-				"err", exit.err,
-			)
+			visor.onExit(exit)
 		case ev := <-visor.ctrlCh:
 			switch ev.Kind {
 			case CtrlRegister:
-				visor.Register(ev.CamUUID, ev.Args)
+				visor.Register(ev.Spec)
 			case CtrlUnregister:
 				visor.Unregister(ev.CamUUID)
 			case CtrlShutdown:
 				return
+			case CtrlReconcile:
+				visor.Reconcile(ev.Desired)
+			case ctrlRestart:
+				visor.restart(ev.CamUUID, ev.proc)
+			}
+		case <-snapshotC:
+			for _, state := range visor.States() {
+				visor.report(state)
 			}
 		}
 	}
@@ -64,104 +107,364 @@ func (visor *Supervisor) GetCameraRevision(camUUID string) (int, error) {
 	return p.Version, nil
 }
 
+// States returns the current state of every registered worker.
+func (visor *Supervisor) States() []v1.WorkerState {
+	visor.mtx.Lock()
+	defer visor.mtx.Unlock()
+
+	states := make([]v1.WorkerState, 0, len(visor.procs))
+	for _, proc := range visor.procs {
+		states = append(states, proc.state)
+	}
+
+	return states
+}
+
+// Shutdown stops every worker and returns once all of them exited.
 func (visor *Supervisor) Shutdown() {
 	visor.logger.Info("Shutting down")
+
+	visor.mtx.Lock()
+	visor.shuttingDown = true
+	uuids := make([]string, 0, len(visor.procs))
 	for uuid := range visor.procs {
+		uuids = append(uuids, uuid)
+	}
+	visor.mtx.Unlock()
+
+	for _, uuid := range uuids {
 		visor.Unregister(uuid)
 	}
+
+	visor.mtx.Lock()
+	for visor.running > 0 {
+		visor.exited.Wait()
+	}
+	visor.mtx.Unlock()
 }
 
 func (visor *Supervisor) NotifyCtrl(ev CtrlEvent) {
 	visor.ctrlCh <- ev
 }
 
-func (visor *Supervisor) Register(camUUID string, args Args) {
-	if visor.findProc(camUUID) != nil {
-		visor.logger.Info("register: process for camera is already running", "uuid", camUUID)
+// Register starts a worker for the camera. A newer revision replaces the running worker once
+// it fully stopped, stale and duplicate revisions are ignored so replayed events are harmless.
+func (visor *Supervisor) Register(spec WorkerSpec) {
+	visor.mtx.Lock()
+	delete(visor.unregisteredAt, spec.CamUUID)
+	visor.mtx.Unlock()
+
+	visor.register(spec)
+}
+
+func (visor *Supervisor) register(spec WorkerSpec) {
+	camUUID := spec.CamUUID
+
+	visor.mtx.Lock()
+	if visor.shuttingDown {
+		visor.mtx.Unlock()
+		return
+	}
+
+	proc, ok := visor.procs[camUUID]
+	if !ok {
+		proc = newProc(spec)
+		visor.procs[camUUID] = proc
+		visor.mtx.Unlock()
+
+		visor.start(camUUID, proc)
 		return
 	}
 
-	cmd := exec.Command("go", args...)
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	switch {
+	case proc.pending != nil:
+		// A replacement is already waiting for the old worker to exit.
+		if spec.Revision > proc.pending.Revision {
+			proc.pending = &spec
+		}
+		visor.mtx.Unlock()
+		return
+	case proc.stopping:
+		// Unregistered but not gone yet, revisions may restart from scratch after an unpair.
+		proc.pending = &spec
+		visor.mtx.Unlock()
+		return
+	case spec.Revision <= proc.Version:
+		visor.mtx.Unlock()
+		visor.logger.Info("register: ignoring stale or duplicate revision",
+			"uuid", camUUID,
+			"running", proc.Version,
+			"revision", spec.Revision,
+		)
+		return
+	}
+
+	visor.logger.Info("register: replacing worker", "uuid", camUUID, "from", proc.Version, "to", spec.Revision)
+	proc.pending = &spec
+	proc.stopping = true
+	worker := proc.worker
+	if worker == nil {
+		// Waiting for a restart, there is nothing to wait for.
+		if proc.restartTmr != nil {
+			proc.restartTmr.Stop()
+		}
+		next := newProc(spec)
+		visor.procs[camUUID] = next
+		visor.mtx.Unlock()
 
-	err := cmd.Start()
+		visor.start(camUUID, next)
+		return
+	}
+	visor.mtx.Unlock()
+
+	worker.Stop()
+}
+
+func newProc(spec WorkerSpec) *Proc {
+	return &Proc{
+		spec:         spec,
+		Version:      spec.Revision,
+		registeredAt: time.Now(),
+		state:        v1.WorkerState{UUID: spec.CamUUID},
+	}
+}
+
+func (visor *Supervisor) start(camUUID string, proc *Proc) {
+	worker, err := visor.params.Launcher.Launch(proc.spec)
 	if err != nil {
-		visor.exitCh <- ExitEvent{
+		// Failing to start counts as a crash so it goes through the same restart policy.
+		visor.onExit(ExitEvent{
 			camID:  camUUID,
 			procID: -1,
 			status: -1,
 			err:    err,
-		}
+			proc:   proc,
+		})
 		return
 	}
-	visor.logger.Info("started new process", "pid", cmd.Process.Pid, "args", args)
+	visor.logger.Info("started new worker", "uuid", camUUID, "pid", worker.Pid())
 
 	visor.mtx.Lock()
-	defer visor.mtx.Unlock()
-	visor.procs[camUUID] = &Proc{
-		procArgs: args,
-		cmd:      cmd,
-		Version:  1,
+	visor.running++
+	proc.worker = worker
+	proc.startedAt = time.Now()
+	proc.state.Status = v1.WorkerRunning
+	proc.state.Pid = worker.Pid()
+	proc.state.NextRestartAt = nil
+	state := proc.state
+	stopping := proc.stopping
+	visor.mtx.Unlock()
+
+	if stopping {
+		// Replaced or unregistered while launching, the exit is handled like any other stop.
+		worker.Stop()
+	} else {
+		visor.report(state)
 	}
 
 	go func() {
-		err := cmd.Wait()
+		status, err := worker.Wait()
+
+		visor.mtx.Lock()
+		visor.running--
+		visor.exited.Broadcast()
+		visor.mtx.Unlock()
+
 		visor.exitCh <- ExitEvent{
 			camID:  camUUID,
-			procID: cmd.Process.Pid,
-			status: cmd.ProcessState.ExitCode(),
+			procID: worker.Pid(),
+			status: status,
 			err:    err,
+			proc:   proc,
 		}
+	}()
+}
 
-		if visor.findProc(camUUID) == nil {
-			return
+// onExit applies the restart policy to a worker that exited, crashes are restarted with an
+// exponential backoff until the worker crash-loops more than MaxRestarts times in a row.
+func (visor *Supervisor) onExit(exit ExitEvent) {
+	visor.logger.Info("Process exit",
+		"cam", exit.camID,
+		"pid", exit.procID,
+		"status", exit.status,
+		"err", exit.err,
+	)
+
+	visor.mtx.Lock()
+	proc := exit.proc
+	if visor.procs[exit.camID] != proc {
+		visor.mtx.Unlock()
+		return
+	}
+
+	code := exit.status
+	proc.worker = nil
+	proc.state.Pid = 0
+	proc.state.LastExitCode = &code
+	proc.state.LastError = ""
+	if exit.err != nil {
+		proc.state.LastError = exit.err.Error()
+	}
+
+	failed := exit.status != 0 || exit.err != nil
+	switch {
+	case proc.stopping || visor.shuttingDown:
+		proc.state.Status = v1.WorkerStopped
+	case !shouldRestart(visor.params.Policy, failed):
+		proc.state.Status = v1.WorkerExited
+		if failed {
+			proc.state.Status = v1.WorkerFailed
 		}
+	default:
+		if time.Since(proc.startedAt) >= visor.params.StableAfter {
+			proc.consecutive = 0
+		}
+		proc.consecutive++
 
-		ok := visor.deleteProc(camUUID)
-		if !ok {
-			visor.logger.Error("register: wait: failed to delete the camera", "uuid", camUUID)
-			return
+		if proc.consecutive > visor.params.MaxRestarts {
+			visor.logger.Error("process is crash looping, giving up", "uuid", exit.camID, "restarts", proc.state.Restarts)
+			proc.state.Status = v1.WorkerFailed
+			break
 		}
-	}()
+
+		delay := backoffDelay(visor.params.BackoffInitial, visor.params.BackoffMax, proc.consecutive)
+		next := time.Now().Add(delay)
+		proc.state.Status = v1.WorkerBackoff
+		proc.state.NextRestartAt = &next
+		proc.restartTmr = time.AfterFunc(delay, func() {
+			visor.NotifyCtrl(CtrlEvent{Kind: ctrlRestart, CamUUID: exit.camID, proc: proc})
+		})
+	}
+
+	// Stopped workers are forgotten so the camera can be registered again, a pending
+	// replacement only starts now that the old worker is fully gone. Workers that failed or
+	// exited by themselves are kept so replayed registrations and reconciles don't revive them,
+	// until they are unregistered or replaced by a newer revision.
+	var next *Proc
+	if proc.state.Status == v1.WorkerStopped {
+		delete(visor.procs, exit.camID)
+		if proc.pending != nil && !visor.shuttingDown {
+			next = newProc(*proc.pending)
+			visor.procs[exit.camID] = next
+		}
+	}
+	state := proc.state
+	visor.mtx.Unlock()
+
+	visor.report(state)
+	if next != nil {
+		visor.start(exit.camID, next)
+	}
+}
+
+func (visor *Supervisor) restart(camUUID string, proc *Proc) {
+	visor.mtx.Lock()
+	if visor.procs[camUUID] != proc || proc.stopping || proc.worker != nil {
+		visor.mtx.Unlock()
+		return
+	}
+	proc.restartTmr = nil
+	proc.state.Restarts++
+	visor.mtx.Unlock()
+
+	visor.logger.Info("restarting process", "uuid", camUUID, "attempt", proc.consecutive)
+	visor.start(camUUID, proc)
 }
 
 func (visor *Supervisor) Unregister(camUUID string) {
-	proc := visor.findProc(camUUID)
-	if proc == nil {
+	visor.mtx.Lock()
+	visor.unregisteredAt[camUUID] = time.Now()
+	proc, ok := visor.procs[camUUID]
+	if !ok {
+		visor.mtx.Unlock()
 		visor.logger.Error("unregister: failed to find process", "uuid", camUUID)
 		return
 	}
 	visor.logger.Debug("unregister", "camUUID", camUUID)
 
-	p := proc.cmd.Process
-	if err := syscall.Kill(-p.Pid, syscall.SIGTERM); err != nil {
-		if err := syscall.Kill(-p.Pid, syscall.SIGKILL); err != nil {
-			visor.logger.Error("unregister: kill failed", "uuid", camUUID, "err", err)
+	proc.stopping = true
+	proc.pending = nil
+	if proc.worker == nil {
+		// Waiting for a restart, there is nothing to stop.
+		if proc.restartTmr != nil {
+			proc.restartTmr.Stop()
 		}
-	} else {
-		go func(p *os.Process) {
-			time.Sleep(5 * time.Second)
-			// Check if the process is still alive, if it is send sigkill
-			if err := syscall.Kill(-p.Pid, 0); err == nil {
-				if err := syscall.Kill(-p.Pid, syscall.SIGKILL); err != nil {
-					visor.logger.Error("unregister: kill failed", "uuid", camUUID, "err", err)
-				}
-			}
-		}(p)
+		delete(visor.procs, camUUID)
+		proc.state.Status = v1.WorkerStopped
+		proc.state.NextRestartAt = nil
+		state := proc.state
+		visor.mtx.Unlock()
+
+		visor.report(state)
+		return
 	}
+	worker := proc.worker
+	visor.mtx.Unlock()
+
+	worker.Stop()
 }
 
-func (visor *Supervisor) deleteProc(camUUID string) bool {
+// Reconcile registers every desired worker, the usual revision rules apply so running workers
+// are only replaced when outdated, and stops the workers of cameras that are no longer paired.
+// Cameras unregistered after the snapshot was taken are left alone, it predates their unpair.
+func (visor *Supervisor) Reconcile(desired *Desired) {
+	if desired == nil {
+		return
+	}
+
 	visor.mtx.Lock()
-	defer visor.mtx.Unlock()
+	specs := make([]WorkerSpec, 0, len(desired.Specs))
+	for _, spec := range desired.Specs {
+		if at, ok := visor.unregisteredAt[spec.CamUUID]; ok && at.After(desired.AsOf) {
+			visor.logger.Info("reconcile: ignoring camera unregistered after the snapshot", "uuid", spec.CamUUID)
+			continue
+		}
+		specs = append(specs, spec)
+	}
+	if desired.Complete {
+		// A complete snapshot taken after the unregister already knows about it.
+		for uuid, at := range visor.unregisteredAt {
+			if !at.After(desired.AsOf) {
+				delete(visor.unregisteredAt, uuid)
+			}
+		}
+	}
+	visor.mtx.Unlock()
 
-	_, ok := visor.procs[camUUID]
-	if !ok {
-		return false
+	wanted := make(map[string]struct{}, len(desired.Specs))
+	for _, spec := range specs {
+		wanted[spec.CamUUID] = struct{}{}
+		visor.register(spec)
+	}
+
+	if !desired.Complete {
+		return
+	}
+
+	visor.mtx.Lock()
+	orphans := []string{}
+	for uuid, proc := range visor.procs {
+		gone := proc.stopping && proc.pending == nil
+		if _, ok := wanted[uuid]; ok || gone || proc.registeredAt.After(desired.AsOf) {
+			continue
+		}
+		orphans = append(orphans, uuid)
+	}
+	visor.mtx.Unlock()
+
+	for _, uuid := range orphans {
+		visor.logger.Info("reconcile: stopping orphaned worker", "uuid", uuid)
+		visor.Unregister(uuid)
+	}
+}
+
+func (visor *Supervisor) report(state v1.WorkerState) {
+	if visor.params.OnState == nil {
+		return
 	}
 
-	delete(visor.procs, camUUID)
-	return true
+	state.UpdatedAt = time.Now().UTC()
+	visor.params.OnState(state)
 }
 
 func (visor *Supervisor) findProc(camUUID string) *Proc {
@@ -174,3 +477,36 @@ func (visor *Supervisor) findProc(camUUID string) *Proc {
 
 	return proc
 }
+
+func shouldRestart(policy RestartPolicy, failed bool) bool {
+	switch policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return failed
+	default:
+		return false
+	}
+}
+
+// backoffDelay doubles the initial delay for every consecutive crash, capped at maxDelay.
+func backoffDelay(initial, maxDelay time.Duration, attempt int) time.Duration {
+	delay := initial
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxDelay)
+}
+
+// ParseRestartPolicy validates a policy coming from the environment.
+func ParseRestartPolicy(raw string) (RestartPolicy, error) {
+	switch raw {
+	case RestartAlways, RestartOnFailure, RestartNever:
+		return raw, nil
+	case "":
+		return RestartOnFailure, nil
+	default:
+		return "", fmt.Errorf("unknown restart policy %q", raw)
+	}
+}
@@ -0,0 +1,54 @@
+package supervisor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 30 * time.Second},
+		{50, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := backoffDelay(time.Second, 30*time.Second, tt.attempt); got != tt.want {
+			t.Errorf("attempt %d: expected %v, got %v", tt.attempt, tt.want, got)
+		}
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	tests := []struct {
+		policy RestartPolicy
+		failed bool
+		want   bool
+	}{
+		{RestartAlways, false, true},
+		{RestartAlways, true, true},
+		{RestartOnFailure, false, false},
+		{RestartOnFailure, true, true},
+		{RestartNever, true, false},
+	}
+
+	for _, tt := range tests {
+		if got := shouldRestart(tt.policy, tt.failed); got != tt.want {
+			t.Errorf("policy %s failed=%v: expected %v, got %v", tt.policy, tt.failed, tt.want, got)
+		}
+	}
+}
+
+func TestParseRestartPolicy(t *testing.T) {
+	if policy, err := ParseRestartPolicy(""); err != nil || policy != RestartOnFailure {
+		t.Errorf("expected on-failure default, got: %q, %v", policy, err)
+	}
+	if _, err := ParseRestartPolicy("sometimes"); err == nil {
+		t.Errorf("expected unknown policy to fail")
+	}
+}
@@ -1,31 +1,81 @@
 package supervisor
 
-import "os/exec"
+import (
+	"log/slog"
+	"time"
+
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+)
 
 type Args = []string
 type CtrlKind int
+type RestartPolicy = string
 
 const (
 	CtrlRegister CtrlKind = iota
 	CtrlUnregister
 	CtrlShutdown
+	CtrlReconcile
+	ctrlRestart
+)
+
+const (
+	RestartAlways    RestartPolicy = "always"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartNever     RestartPolicy = "never"
 )
 
+type Params struct {
+	MaxProcs int
+	Logger   *slog.Logger
+	Launcher Launcher
+
+	Policy         RestartPolicy
+	BackoffInitial time.Duration // delay before the first restart, doubled on every consecutive crash
+	BackoffMax     time.Duration
+	MaxRestarts    int           // consecutive crashes before the worker is marked as failed
+	StableAfter    time.Duration // uptime after which a crash is no longer counted as consecutive
+	StateInterval  time.Duration // how often a snapshot of all the states is reported, 0 disables it
+
+	// Called on every state change, must not block.
+	OnState func(state v1.WorkerState)
+}
+
 type ExitEvent struct {
 	camID  string
 	procID int
 	status int
 	err    error
+	proc   *Proc
 }
 
 type CtrlEvent struct {
 	Kind    CtrlKind
 	CamUUID string
-	Args    Args
+	Spec    WorkerSpec
+	Desired *Desired // for CtrlReconcile
+	proc    *Proc
+}
+
+// Desired is the set of workers that should be running.
+type Desired struct {
+	Specs []WorkerSpec
+	// Orphans are only stopped for a complete set, workers registered after AsOf are kept
+	// since the set can't know about them.
+	Complete bool
+	AsOf     time.Time
 }
 
 type Proc struct {
-	procArgs Args
-	cmd      *exec.Cmd
-	Version  int // For revision control
+	spec    WorkerSpec
+	worker  Worker // nil while waiting for a restart
+	Version int    // For revision control
+
+	registeredAt time.Time
+	startedAt    time.Time
+	stopping     bool
+	pending      *WorkerSpec // replacement started once this worker exits
+	consecutive  int         // crashes since the last stable run
+	restartTmr   *time.Timer
+	state        v1.WorkerState
 }
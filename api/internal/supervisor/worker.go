@@ -0,0 +1,31 @@
+package supervisor
+
+import (
+	v1 "tomerab.com/cam-hub/internal/contracts/v1"
+	"tomerab.com/cam-hub/internal/zones"
+)
+
+// WorkerSpec describes the motion detector the supervisor keeps running for a camera.
+type WorkerSpec struct {
+	CamUUID    string
+	StreamUrl  string
+	RecordPath string // mediamtx path the clips are cut from, the camera uuid when empty
+	Revision   int
+	Motion     *v1.MotionSettings // nil runs the detector with the default settings
+	Zones      []zones.Zone
+}
+
+// Worker is a started motion detector.
+type Worker interface {
+	// Pid of the detector process, 0 for workers that don't run in their own process.
+	Pid() int
+	// Stop asks the worker to shut down, Wait returns once it did.
+	Stop()
+	// Wait blocks until the worker exits, it must be called exactly once.
+	Wait() (exitCode int, err error)
+}
+
+// Launcher starts the workers, the supervisor is agnostic to how they run.
+type Launcher interface {
+	Launch(spec WorkerSpec) (Worker, error)
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"os"
 	"os/signal"
+	"strconv"
+	"time"
 )
 
 var (
@@ -96,3 +98,33 @@ func GracefullShutdown(ctx context.Context, onShutdown func(), sigs ...os.Signal
 
 	return ctx, cancel
 }
+
+// EnvInt returns the integer value of the env variable key, or def if it is unset or malformed.
+func EnvInt(key string, def int) int {
+	val, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+
+	return val
+}
+
+// EnvBool returns the boolean value of the env variable key, or def if it is unset or malformed.
+func EnvBool(key string, def bool) bool {
+	val, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+
+	return val
+}
+
+// EnvDuration returns the duration value (e.g. "1h30m") of the env variable key, or def if it is unset or malformed.
+func EnvDuration(key string, def time.Duration) time.Duration {
+	val, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+
+	return val
+}
@@ -0,0 +1,102 @@
+// Package zones holds the polygon include/exclude regions of a camera. Coordinates are
+// normalized to [0, 1] so the same zones apply to every stream resolution.
+package zones
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+const (
+	KindInclude = "include"
+	KindExclude = "exclude"
+
+	minPoints = 3
+	maxPoints = 64
+)
+
+var ErrInvalidZone = errors.New("invalid zone")
+
+type Point struct {
+	X float32 `json:"x"`
+	Y float32 `json:"y"`
+}
+
+type Zone struct {
+	Name   string  `json:"name"`
+	Kind   string  `json:"kind"`
+	Points []Point `json:"points"`
+}
+
+func Validate(zs []Zone) error {
+	for i, z := range zs {
+		if z.Kind != KindInclude && z.Kind != KindExclude {
+			return fmt.Errorf("%w: zone %d: kind must be %q or %q", ErrInvalidZone, i, KindInclude, KindExclude)
+		}
+		if len(z.Points) < minPoints || len(z.Points) > maxPoints {
+			return fmt.Errorf("%w: zone %d: expected %d..%d points, got %d", ErrInvalidZone, i, minPoints, maxPoints, len(z.Points))
+		}
+		for _, p := range z.Points {
+			if p.X < 0 || p.X > 1 || p.Y < 0 || p.Y > 1 {
+				return fmt.Errorf("%w: zone %d: point (%v, %v) is not normalized", ErrInvalidZone, i, p.X, p.Y)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Contains reports whether (x, y) is inside the polygon (even-odd rule).
+func (z Zone) Contains(x, y float32) bool {
+	inside := false
+	for i, j := 0, len(z.Points)-1; i < len(z.Points); j, i = i, i+1 {
+		a, b := z.Points[i], z.Points[j]
+		if (a.Y > y) != (b.Y > y) && x < (b.X-a.X)*(y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// Allows reports whether (x, y) is inside one of the include zones (or there are none)
+// and outside all the exclude zones.
+func Allows(zs []Zone, x, y float32) bool {
+	included, hasInclude := false, false
+	for _, z := range zs {
+		switch z.Kind {
+		case KindExclude:
+			if z.Contains(x, y) {
+				return false
+			}
+		case KindInclude:
+			hasInclude = true
+			included = included || z.Contains(x, y)
+		}
+	}
+
+	return included || !hasInclude
+}
+
+// Pixels scales the zones to a width x height frame and splits them by kind.
+func Pixels(zs []Zone, width, height int) (include, exclude [][]image.Point) {
+	for _, z := range zs {
+		pts := make([]image.Point, 0, len(z.Points))
+		for _, p := range z.Points {
+			pts = append(pts, image.Pt(
+				int(math.Round(float64(p.X)*float64(width-1))),
+				int(math.Round(float64(p.Y)*float64(height-1))),
+			))
+		}
+
+		if z.Kind == KindExclude {
+			exclude = append(exclude, pts)
+		} else {
+			include = append(include, pts)
+		}
+	}
+
+	return include, exclude
+}
@@ -0,0 +1,95 @@
+package zones
+
+import (
+	"errors"
+	"image"
+	"reflect"
+	"testing"
+)
+
+func square(kind string, x0, y0, x1, y1 float32) Zone {
+	return Zone{Kind: kind, Points: []Point{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		zones []Zone
+		valid bool
+	}{
+		{"no zones", nil, true},
+		{"valid zones", []Zone{square(KindInclude, 0, 0, 1, 1), square(KindExclude, 0.2, 0.2, 0.4, 0.4)}, true},
+		{"unknown kind", []Zone{square("mask", 0, 0, 1, 1)}, false},
+		{"too few points", []Zone{{Kind: KindInclude, Points: []Point{{0, 0}, {1, 1}}}}, false},
+		{"not normalized", []Zone{square(KindInclude, 0, 0, 1.5, 1)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.zones)
+			if tt.valid && err != nil {
+				t.Errorf("expected nil, got err: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidZone) {
+				t.Errorf("expected ErrInvalidZone, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestContains(t *testing.T) {
+	triangle := Zone{Kind: KindInclude, Points: []Point{{0, 0}, {1, 0}, {0, 1}}}
+
+	if !triangle.Contains(0.2, 0.2) {
+		t.Errorf("expected (0.2, 0.2) to be inside")
+	}
+	if triangle.Contains(0.8, 0.8) {
+		t.Errorf("expected (0.8, 0.8) to be outside")
+	}
+}
+
+func TestAllows(t *testing.T) {
+	t.Run("no zones - should allow everything", func(t *testing.T) {
+		if !Allows(nil, 0.5, 0.5) {
+			t.Errorf("expected point to be allowed")
+		}
+	})
+
+	t.Run("only exclude zones - should allow everything outside them", func(t *testing.T) {
+		zs := []Zone{square(KindExclude, 0, 0, 0.5, 0.5)}
+		if Allows(zs, 0.25, 0.25) {
+			t.Errorf("expected point inside the exclude zone to be dropped")
+		}
+		if !Allows(zs, 0.75, 0.75) {
+			t.Errorf("expected point outside the exclude zone to be allowed")
+		}
+	})
+
+	t.Run("include zones - should allow only inside them, minus the excluded holes", func(t *testing.T) {
+		zs := []Zone{square(KindInclude, 0, 0, 0.5, 0.5), square(KindExclude, 0.1, 0.1, 0.2, 0.2)}
+		if !Allows(zs, 0.4, 0.4) {
+			t.Errorf("expected point inside the include zone to be allowed")
+		}
+		if Allows(zs, 0.75, 0.75) {
+			t.Errorf("expected point outside the include zone to be dropped")
+		}
+		if Allows(zs, 0.15, 0.15) {
+			t.Errorf("expected point inside the hole to be dropped")
+		}
+	})
+}
+
+func TestPixels(t *testing.T) {
+	zs := []Zone{square(KindInclude, 0, 0, 1, 1), square(KindExclude, 0.5, 0.5, 1, 1)}
+
+	include, exclude := Pixels(zs, 101, 51)
+	expectedInclude := [][]image.Point{{{0, 0}, {100, 0}, {100, 50}, {0, 50}}}
+	expectedExclude := [][]image.Point{{{50, 25}, {100, 25}, {100, 50}, {50, 50}}}
+
+	if !reflect.DeepEqual(include, expectedInclude) {
+		t.Errorf("expected include: %v, got: %v", expectedInclude, include)
+	}
+	if !reflect.DeepEqual(exclude, expectedExclude) {
+		t.Errorf("expected exclude: %v, got: %v", expectedExclude, exclude)
+	}
+}
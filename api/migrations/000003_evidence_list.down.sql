@@ -0,0 +1,3 @@
+UPDATE recordings
+SET evidence = COALESCE(evidence->0, '{}'::jsonb)
+WHERE jsonb_typeof(evidence) = 'array';
\ No newline at end of file
@@ -0,0 +1,3 @@
+UPDATE recordings
+SET evidence = jsonb_build_array(evidence)
+WHERE jsonb_typeof(evidence) = 'object';
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS camera_motion_settings;
\ No newline at end of file
@@ -0,0 +1,21 @@
+-- Create camera motion settings table
+CREATE TABLE IF NOT EXISTS camera_motion_settings (
+    id UUID PRIMARY KEY REFERENCES cameras(id) ON DELETE CASCADE,
+    threshold REAL NOT NULL DEFAULT 50,
+    min_area INT NOT NULL DEFAULT 15000,
+    warmup_frames INT NOT NULL DEFAULT 150,
+    tick_ms INT NOT NULL DEFAULT 50,
+    cooldown_ms INT NOT NULL DEFAULT 10000,
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Add constraints
+ALTER TABLE camera_motion_settings
+  ADD CONSTRAINT chk_motion_threshold CHECK (threshold > 0 AND threshold < 255);
+
+ALTER TABLE camera_motion_settings
+  ADD CONSTRAINT chk_motion_non_negative
+  CHECK (min_area >= 0 AND warmup_frames >= 0 AND cooldown_ms >= 0);
+
+ALTER TABLE camera_motion_settings
+  ADD CONSTRAINT chk_motion_tick CHECK (tick_ms > 0);
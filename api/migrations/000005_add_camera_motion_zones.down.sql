@@ -0,0 +1 @@
+DROP TABLE IF EXISTS camera_motion_zones;
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- Create camera motion zones table, points are normalized [{"x": 0.1, "y": 0.2}, ...]
+CREATE TABLE IF NOT EXISTS camera_motion_zones (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    cam_id UUID NOT NULL REFERENCES cameras(id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL CHECK (kind IN ('include','exclude')),
+    points JSONB NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS ix_camera_motion_zones_cam
+  ON camera_motion_zones (cam_id);
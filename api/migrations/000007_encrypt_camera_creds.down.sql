@@ -0,0 +1,13 @@
+-- Encrypted rows must be decrypted first (`credskey decrypt`), the SET NOT NULL fails otherwise.
+DROP INDEX IF EXISTS ix_camera_creds_key_id;
+
+ALTER TABLE camera_creds
+  DROP CONSTRAINT IF EXISTS chk_creds_password_set;
+
+ALTER TABLE camera_creds
+  ALTER COLUMN password SET NOT NULL;
+
+ALTER TABLE camera_creds
+  DROP COLUMN IF EXISTS password_enc,
+  DROP COLUMN IF EXISTS password_dek,
+  DROP COLUMN IF EXISTS key_id;
@@ -0,0 +1,18 @@
+-- Passwords are envelope encrypted: password_enc is sealed with a per-row data key and
+-- password_dek is that key wrapped by the master key named in key_id.
+-- Existing plaintext rows are encrypted by the api on startup (or `credskey migrate`),
+-- which also clears the plaintext column.
+ALTER TABLE camera_creds
+  ADD COLUMN IF NOT EXISTS password_enc BYTEA,
+  ADD COLUMN IF NOT EXISTS password_dek BYTEA,
+  ADD COLUMN IF NOT EXISTS key_id TEXT;
+
+ALTER TABLE camera_creds
+  ALTER COLUMN password DROP NOT NULL;
+
+ALTER TABLE camera_creds
+  ADD CONSTRAINT chk_creds_password_set
+  CHECK (password IS NOT NULL OR (password_enc IS NOT NULL AND password_dek IS NOT NULL AND key_id IS NOT NULL));
+
+CREATE INDEX IF NOT EXISTS ix_camera_creds_key_id
+  ON camera_creds (key_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS camera_streams;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- Create camera streams table, the rtsp uris the camera reported over ONVIF at pairing.
+-- The uris hold no credentials, they are added when the source is resolved.
+CREATE TABLE IF NOT EXISTS camera_streams (
+    cam_id UUID NOT NULL REFERENCES cameras(id) ON DELETE CASCADE,
+    profile TEXT NOT NULL CHECK (profile IN ('main','sub')),
+    token TEXT NOT NULL,
+    uri TEXT NOT NULL,
+    encoding TEXT NOT NULL DEFAULT '',
+    width INT NOT NULL DEFAULT 0,
+    height INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (cam_id, profile)
+);
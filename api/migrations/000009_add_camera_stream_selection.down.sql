@@ -0,0 +1 @@
+DROP TABLE IF EXISTS camera_stream_selection;
\ No newline at end of file
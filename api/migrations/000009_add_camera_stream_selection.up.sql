@@ -0,0 +1,9 @@
+-- Create camera stream selection table, which profile each consumer of the camera reads.
+-- Cameras without a row use the defaults: main for the viewer and the recorder, sub for motion.
+CREATE TABLE IF NOT EXISTS camera_stream_selection (
+    id UUID PRIMARY KEY REFERENCES cameras(id) ON DELETE CASCADE,
+    viewer TEXT NOT NULL DEFAULT 'main' CHECK (viewer IN ('main','sub')),
+    recorder TEXT NOT NULL DEFAULT 'main' CHECK (recorder IN ('main','sub')),
+    motion TEXT NOT NULL DEFAULT 'sub' CHECK (motion IN ('main','sub')),
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+ALTER TABLE cameras DROP CONSTRAINT IF EXISTS chk_cameras_transcode_policy;
+ALTER TABLE cameras DROP COLUMN IF EXISTS transcode_policy;
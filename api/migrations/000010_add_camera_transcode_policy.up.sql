@@ -0,0 +1,8 @@
+-- How the camera's streams reach mediamtx: 'auto' copies browser compatible video as is and
+-- re-encodes the rest, 'always' re-encodes everything, 'never' always copies.
+ALTER TABLE cameras
+  ADD COLUMN IF NOT EXISTS transcode_policy TEXT NOT NULL DEFAULT 'auto';
+
+ALTER TABLE cameras
+  ADD CONSTRAINT chk_cameras_transcode_policy
+  CHECK (transcode_policy IN ('auto','always','never'));
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS ix_recording_segments_cam_start;
+DROP TABLE IF EXISTS recording_segments;
+DROP TABLE IF EXISTS camera_recording_settings;
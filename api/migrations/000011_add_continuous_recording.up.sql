@@ -0,0 +1,24 @@
+-- Create camera recording settings table, cameras without a row only keep the short lived
+-- segments the motion clips are cut from.
+CREATE TABLE IF NOT EXISTS camera_recording_settings (
+    id UUID PRIMARY KEY REFERENCES cameras(id) ON DELETE CASCADE,
+    continuous BOOLEAN NOT NULL DEFAULT FALSE,
+    retention_days INT NOT NULL DEFAULT 7 CHECK (retention_days > 0),
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Create recording segments table, the index of the segments mediamtx completed for the
+-- cameras that record continuously. The files live under <recordings>/<mtx_path>/<file_name>.
+CREATE TABLE IF NOT EXISTS recording_segments (
+    id BIGSERIAL PRIMARY KEY,
+    cam_id UUID NOT NULL REFERENCES cameras(id) ON DELETE CASCADE,
+    mtx_path TEXT NOT NULL,
+    file_name TEXT NOT NULL,
+    start_ts TIMESTAMP(3) WITH TIME ZONE NOT NULL,
+    end_ts TIMESTAMP(3) WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (mtx_path, file_name),
+    CHECK (end_ts >= start_ts)
+);
+
+CREATE INDEX IF NOT EXISTS ix_recording_segments_cam_start ON recording_segments (cam_id, start_ts);
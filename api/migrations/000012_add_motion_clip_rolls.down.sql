@@ -0,0 +1,6 @@
+ALTER TABLE camera_motion_settings DROP CONSTRAINT IF EXISTS chk_motion_rolls;
+ALTER TABLE camera_motion_settings ALTER COLUMN cooldown_ms SET DEFAULT 10000;
+ALTER TABLE camera_motion_settings
+  DROP COLUMN IF EXISTS max_event_ms,
+  DROP COLUMN IF EXISTS post_roll_ms,
+  DROP COLUMN IF EXISTS pre_roll_ms;
@@ -0,0 +1,14 @@
+-- Motion clips start pre_roll_ms before the first detection and end post_roll_ms after the last
+-- one, an event is extended while motion continues for at most max_event_ms. The cooldown now
+-- only separates two events so it no longer defaults to 10s.
+ALTER TABLE camera_motion_settings
+  ADD COLUMN IF NOT EXISTS pre_roll_ms INT NOT NULL DEFAULT 3000,
+  ADD COLUMN IF NOT EXISTS post_roll_ms INT NOT NULL DEFAULT 3000,
+  ADD COLUMN IF NOT EXISTS max_event_ms INT NOT NULL DEFAULT 60000;
+
+ALTER TABLE camera_motion_settings
+  ALTER COLUMN cooldown_ms SET DEFAULT 0;
+
+ALTER TABLE camera_motion_settings
+  ADD CONSTRAINT chk_motion_rolls
+  CHECK (pre_roll_ms >= 0 AND post_roll_ms >= 0 AND max_event_ms > 0);
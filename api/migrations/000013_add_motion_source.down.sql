@@ -0,0 +1,2 @@
+ALTER TABLE camera_motion_settings DROP CONSTRAINT IF EXISTS chk_motion_source;
+ALTER TABLE camera_motion_settings DROP COLUMN IF EXISTS source;
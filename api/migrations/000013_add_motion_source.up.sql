@@ -0,0 +1,8 @@
+-- Where the detections of the camera come from: MOG2 on the server, the camera's own analytics
+-- pulled from its ONVIF events, or both.
+ALTER TABLE camera_motion_settings
+  ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'server';
+
+ALTER TABLE camera_motion_settings
+  ADD CONSTRAINT chk_motion_source
+  CHECK (source IN ('server', 'camera', 'both'));
@@ -0,0 +1,12 @@
+-- Cache of the presets stored on the cameras, next to their ptz_tokens. The position is the one
+-- the camera reported when the presets were last synced, it is NULL when it doesn't report any.
+CREATE TABLE IF NOT EXISTS ptz_presets (
+    cam_id UUID NOT NULL REFERENCES cameras(id) ON DELETE CASCADE,
+    token TEXT NOT NULL,
+    name TEXT NOT NULL,
+    pan DOUBLE PRECISION,
+    tilt DOUBLE PRECISION,
+    zoom DOUBLE PRECISION,
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (cam_id, token)
+);
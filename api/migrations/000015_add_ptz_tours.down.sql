@@ -0,0 +1 @@
+DROP TABLE IF EXISTS ptz_tours;
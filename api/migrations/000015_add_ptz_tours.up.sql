@@ -0,0 +1,15 @@
+-- Create PTZ tours table, a tour visits the presets of its stops in order and dwells on each,
+-- stops are [{"preset": "gate", "dwell_ms": 10000}, ...] with presets referenced by token or name.
+-- Tours with a schedule (a cron expression) are also started by the API, a run makes `loops`
+-- passes over the stops, 0 keeps going until the tour is stopped.
+CREATE TABLE IF NOT EXISTS ptz_tours (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    cam_id UUID NOT NULL REFERENCES cameras(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    stops JSONB NOT NULL,
+    schedule TEXT,
+    loops INT NOT NULL DEFAULT 1 CHECK (loops >= 0),
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (cam_id, name)
+);
@@ -0,0 +1,14 @@
+-- Create PTZ capabilities table, what the camera's PTZ node reported at pairing (or on the first
+-- move of cameras paired before). Spaces holds the ranges of the generic spaces by name, e.g.
+-- {"absolute_pan_tilt": {"x": {"min": -1, "max": 1}, "y": {...}}, "zoom_speed": {...}}, a space
+-- the camera doesn't support is left out. Supported is false for cameras without PTZ.
+CREATE TABLE IF NOT EXISTS ptz_capabilities (
+    cam_id UUID PRIMARY KEY REFERENCES cameras(id) ON DELETE CASCADE,
+    supported BOOLEAN NOT NULL,
+    spaces JSONB NOT NULL DEFAULT '{}',
+    max_presets INT NOT NULL DEFAULT 0,
+    home_supported BOOLEAN NOT NULL DEFAULT FALSE,
+    min_timeout_ms INT,
+    max_timeout_ms INT,
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
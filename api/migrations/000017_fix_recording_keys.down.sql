@@ -0,0 +1,4 @@
+UPDATE recordings
+SET vid_key = regexp_replace(vid_key, '^([^/]+)/', '\1/staging/'),
+    best_frame_key = regexp_replace(best_frame_key, '^([^/]+)/', '\1/staging/')
+WHERE vid_key !~ '^[^/]+/staging/';
@@ -0,0 +1,7 @@
+-- Recordings were stored under <prefix>/staging/<uuid>/<tp>/ while the analyzer copied their
+-- objects to <prefix>/<uuid>/<tp>/, point the rows at the objects (staging is the default
+-- MINIO_STAGING_KEY).
+UPDATE recordings
+SET vid_key = regexp_replace(vid_key, '^([^/]+)/staging/', '\1/'),
+    best_frame_key = regexp_replace(best_frame_key, '^([^/]+)/staging/', '\1/')
+WHERE vid_key ~ '^[^/]+/staging/';
@@ -0,0 +1 @@
+ALTER TABLE camera_streams DROP COLUMN IF EXISTS audio_encoding;
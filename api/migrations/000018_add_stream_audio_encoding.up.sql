@@ -0,0 +1,3 @@
+-- The audio encoding the camera reported for the stream's profile over ONVIF, empty when the
+-- profile has no audio. Lets the helper copy audio WebRTC can carry instead of re-encoding it.
+ALTER TABLE camera_streams ADD COLUMN IF NOT EXISTS audio_encoding TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,51 @@
+export const API_URL = "http://localhost:5555/api/v1";
+
+const TOKEN_KEY = "camhub.session";
+const UNAUTHORIZED_EVENT = "camhub:unauthorized";
+
+export function getToken(): string | null {
+	return localStorage.getItem(TOKEN_KEY);
+}
+
+export function setToken(token: string | null) {
+	if (token) {
+		localStorage.setItem(TOKEN_KEY, token);
+	} else {
+		localStorage.removeItem(TOKEN_KEY);
+	}
+}
+
+// Called when the api rejects the session, e.g. it expired or was revoked.
+export function onUnauthorized(handler: () => void): () => void {
+	window.addEventListener(UNAUTHORIZED_EVENT, handler);
+	return () => window.removeEventListener(UNAUTHORIZED_EVENT, handler);
+}
+
+// apiFetch calls the api with the session token, path is relative to API_URL.
+export async function apiFetch(
+	path: string,
+	init: RequestInit = {}
+): Promise<Response> {
+	const headers = new Headers(init.headers);
+	const token = getToken();
+	if (token) {
+		headers.set("Authorization", `Bearer ${token}`);
+	}
+
+	const resp = await fetch(`${API_URL}${path}`, { ...init, headers });
+	if (resp.status === 401 && token) {
+		setToken(null);
+		window.dispatchEvent(new Event(UNAUTHORIZED_EVENT));
+	}
+	return resp;
+}
+
+// EventSource can't set headers, the SSE routes take the token in the query instead.
+export function eventSourceUrl(path: string): string {
+	const url = new URL(`${API_URL}${path}`);
+	const token = getToken();
+	if (token) {
+		url.searchParams.set("access_token", token);
+	}
+	return url.toString();
+}
@@ -2,6 +2,7 @@ import { Box, Card, Typography, alpha, CardMedia, Button } from "@mui/material";
 import { useNavigate } from "react-router-dom";
 import ClearIcon from "@mui/icons-material/Clear";
 import { useState } from "react";
+import { apiFetch } from "../api/client";
 
 type Status = "online" | "offline";
 
@@ -20,10 +21,9 @@ export function CameraCard({ id, status, imgUri, location }: CameraCardProps) {
 	const unpairCamera = async () => {
 		try {
 			setError(null);
-			const res = await fetch(
-				`http://localhost:5555/api/v1/cameras/${id}/pair`,
-				{ method: "DELETE" }
-			);
+			const res = await apiFetch(`/cameras/${id}/pair`, {
+				method: "DELETE",
+			});
 			if (!res.ok) {
 				throw new Error(`HTTP ${res.status}`);
 			}
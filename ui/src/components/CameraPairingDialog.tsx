@@ -7,6 +7,7 @@ import DialogContent from "@mui/material/DialogContent";
 import DialogContentText from "@mui/material/DialogContentText";
 import DialogTitle from "@mui/material/DialogTitle";
 import type { PairCameraDto } from "../contracts/PairCameraDto";
+import { apiFetch } from "../api/client";
 
 interface CameraPairingDialogProps {
 	uuid: string;
@@ -33,15 +34,12 @@ export default function CameraPairingDialog({
 		const sendPairingReq = async () => {
 			try {
 				console.log(pairReq);
-				const resp = await fetch(
-					`http://localhost:5555/api/v1/cameras/${uuid}/pair`,
-					{
-						method: "POST",
-						mode: "cors",
-						headers: { "Content-Type": "application/json" },
-						body: JSON.stringify(pairReq),
-					}
-				);
+				const resp = await apiFetch(`/cameras/${uuid}/pair`, {
+					method: "POST",
+					mode: "cors",
+					headers: { "Content-Type": "application/json" },
+					body: JSON.stringify(pairReq),
+				});
 
 				console.log(resp);
 			} catch (err) {
@@ -9,6 +9,7 @@ import {
 import { Box, IconButton, Tooltip } from "@mui/material";
 import { useCallback, useEffect, useRef } from "react";
 import { type MoveCameraDto } from "../contracts/MoveCameraDto";
+import { apiFetch } from "../api/client";
 
 interface PtzControlsProps {
 	uuid: string;
@@ -30,7 +31,7 @@ export default function PtzControls({ uuid }: PtzControlsProps) {
 
 			const dto: MoveCameraDto = { translation: { x: dx, y: dy }, zoom: null };
 			try {
-				await fetch(`http://localhost:5555/api/v1/cameras/${uuid}/ptz/move`, {
+				await apiFetch(`/cameras/${uuid}/ptz/move`, {
 					method: "POST",
 					headers: { "Content-Type": "application/json" },
 					body: JSON.stringify(dto),
@@ -53,7 +54,7 @@ export default function PtzControls({ uuid }: PtzControlsProps) {
 				zoom: factor,
 			};
 			try {
-				await fetch(`http://localhost:5555/api/v1/cameras/${uuid}/ptz/move`, {
+				await apiFetch(`/cameras/${uuid}/ptz/move`, {
 					method: "POST",
 					headers: { "Content-Type": "application/json" },
 					body: JSON.stringify(dto),
@@ -0,0 +1,27 @@
+import { Box, Typography } from "@mui/material";
+import { useAuth } from "../providers/AuthProvider";
+import LoginPage from "../pages/LoginPage";
+
+// RequireAuth renders the login page until there is a session, every api route needs one.
+export default function RequireAuth({
+	children,
+}: {
+	children: React.ReactNode;
+}) {
+	const { user, loading } = useAuth();
+
+	if (loading) {
+		return (
+			<Box sx={{ minHeight: "100vh", bgcolor: "background.default", p: 4 }}>
+				<Typography sx={{ color: "oklch(82% 0.001 106.424)" }}>
+					Loading…
+				</Typography>
+			</Box>
+		);
+	}
+	if (!user) {
+		return <LoginPage />;
+	}
+
+	return <>{children}</>;
+}
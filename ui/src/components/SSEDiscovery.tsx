@@ -1,13 +1,12 @@
 import { useSnackbar } from "notistack";
 import { useEffect } from "react";
+import { eventSourceUrl } from "../api/client";
 
 export default function DiscoverySSE() {
 	const { enqueueSnackbar } = useSnackbar();
 
 	useEffect(() => {
-		const sse = new EventSource(
-			"http://localhost:5555/api/v1/events/discovery"
-		);
+		const sse = new EventSource(eventSourceUrl("/events/discovery"));
 
 		sse.onmessage = (e) => {
 			try {
@@ -0,0 +1,17 @@
+export interface UserDto {
+	id: string;
+	username: string;
+	role: "viewer" | "operator" | "admin";
+	created_at: string;
+}
+
+export interface LoginReqDto {
+	username: string;
+	password: string;
+}
+
+export interface LoginRespDto {
+	token: string;
+	expires_at: string;
+	user: UserDto;
+}
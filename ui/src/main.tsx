@@ -11,6 +11,8 @@ import { createTheme, styled, ThemeProvider } from "@mui/material";
 import { MaterialDesignContent, SnackbarProvider } from "notistack";
 import DiscoverySSE from "./components/SSEDiscovery.tsx";
 import { CameraProvider } from "./providers/CamerasProvider.tsx";
+import { AuthProvider } from "./providers/AuthProvider.tsx";
+import RequireAuth from "./components/RequireAuth.tsx";
 
 const customTheme = createTheme({
 	palette: {
@@ -41,12 +43,16 @@ createRoot(document.getElementById("root")!).render(
 				error: StyledMaterialDesignContent,
 			}}
 		>
-			<CameraProvider>
-				<ThemeProvider theme={customTheme}>
-					<DiscoverySSE />
-					<App />
-				</ThemeProvider>
-			</CameraProvider>
+			<ThemeProvider theme={customTheme}>
+				<AuthProvider>
+					<RequireAuth>
+						<CameraProvider>
+							<DiscoverySSE />
+							<App />
+						</CameraProvider>
+					</RequireAuth>
+				</AuthProvider>
+			</ThemeProvider>
 		</SnackbarProvider>
 	</StrictMode>
 );
@@ -4,6 +4,7 @@ import type { DiscoveryDto } from "../contracts/DiscoveryDto";
 import CameraDiscoveryTable from "../components/CameraDiscoveryTable";
 import { NavigateBeforeOutlined } from "@mui/icons-material";
 import { useNavigate } from "react-router-dom";
+import { apiFetch } from "../api/client";
 
 export default function CameraDiscoveryPage() {
 	const [matches, setMatches] = useState<DiscoveryDto>({ matches: [] });
@@ -13,9 +14,7 @@ export default function CameraDiscoveryPage() {
 	const discoverCameras = async () => {
 		try {
 			setLoading(true);
-			const resp = await fetch(
-				"http://localhost:5555/api/v1/cameras/discovery"
-			);
+			const resp = await apiFetch("/cameras/discovery");
 			const matches: DiscoveryDto = await resp.json();
 			setMatches(matches);
 			console.log(matches);
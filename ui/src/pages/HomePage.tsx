@@ -6,13 +6,16 @@ import {
 	WarningAmberRounded,
 } from "@mui/icons-material";
 import WifiFindIcon from "@mui/icons-material/WifiFind";
+import LogoutIcon from "@mui/icons-material/Logout";
 import { CameraCard } from "../components/CameraCard";
 import { useNavigate } from "react-router-dom";
 import { useCameras } from "../providers/CamerasProvider";
+import { useAuth } from "../providers/AuthProvider";
 
 export default function HomePage() {
 	const navigate = useNavigate();
 	const { cameras, loading, error } = useCameras();
+	const { user, logout } = useAuth();
 
 	return (
 		<Box
@@ -55,14 +58,23 @@ export default function HomePage() {
 						>
 							Security Dashboard
 						</Typography>
-						<Button
-							sx={{
-								color: "oklch(97% 0.001 106.424)",
-								"& .MuiButton-startIcon > *:nth-of-type(1)": { fontSize: 25 },
-							}}
-							startIcon={<WifiFindIcon />}
-							onClick={() => navigate("/discovery")}
-						></Button>
+						<Box sx={{ display: "flex", alignItems: "center" }}>
+							<Button
+								sx={{
+									color: "oklch(97% 0.001 106.424)",
+									"& .MuiButton-startIcon > *:nth-of-type(1)": { fontSize: 25 },
+								}}
+								startIcon={<WifiFindIcon />}
+								onClick={() => navigate("/discovery")}
+							></Button>
+							<Button
+								sx={{ color: "oklch(97% 0.001 106.424)" }}
+								startIcon={<LogoutIcon />}
+								onClick={logout}
+							>
+								{user?.username}
+							</Button>
+						</Box>
 					</Box>
 					<Typography sx={{ color: "oklch(70.9% 0.01 56.259)" }}>
 						Monitor and manage your home security cameras
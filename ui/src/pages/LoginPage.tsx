@@ -0,0 +1,97 @@
+import { useState } from "react";
+import {
+	Alert,
+	Box,
+	Button,
+	Card,
+	CardContent,
+	TextField,
+	Typography,
+} from "@mui/material";
+import { useAuth } from "../providers/AuthProvider";
+
+export default function LoginPage() {
+	const { login } = useAuth();
+	const [err, setErr] = useState<string | null>(null);
+	const [submitting, setSubmitting] = useState(false);
+
+	const handleSubmit = async (event: React.FormEvent<HTMLFormElement>) => {
+		event.preventDefault();
+		const formData = new FormData(event.currentTarget);
+
+		try {
+			setErr(null);
+			setSubmitting(true);
+			await login(
+				String(formData.get("username") ?? ""),
+				String(formData.get("password") ?? "")
+			);
+		} catch (e) {
+			console.error(e);
+			setErr(e instanceof Error ? e.message : "Login failed");
+		} finally {
+			setSubmitting(false);
+		}
+	};
+
+	return (
+		<Box
+			sx={{
+				display: "flex",
+				minHeight: "100vh",
+				bgcolor: "background.default",
+				alignItems: "center",
+				justifyContent: "center",
+			}}
+		>
+			<Card sx={{ width: 360, borderRadius: 3 }}>
+				<CardContent>
+					<Typography
+						variant="h5"
+						fontWeight="bold"
+						sx={{ color: "oklch(97% 0.001 106.424)", mb: 2 }}
+					>
+						Sign in
+					</Typography>
+					<form onSubmit={handleSubmit}>
+						<TextField
+							autoFocus
+							required
+							margin="dense"
+							name="username"
+							label="Username"
+							type="text"
+							autoComplete="username"
+							fullWidth
+							variant="standard"
+						/>
+						<TextField
+							required
+							margin="dense"
+							name="password"
+							label="Password"
+							type="password"
+							autoComplete="current-password"
+							fullWidth
+							variant="standard"
+						/>
+						{err && (
+							<Alert severity="error" sx={{ mt: 2 }}>
+								{err}
+							</Alert>
+						)}
+						<Button
+							type="submit"
+							variant="contained"
+							fullWidth
+							disabled={submitting}
+							sx={{ mt: 3 }}
+						>
+							Sign in
+						</Button>
+					</form>
+				</CardContent>
+			</Card>
+		</Box>
+	);
+}
@@ -0,0 +1,86 @@
+import {
+	createContext,
+	useCallback,
+	useContext,
+	useEffect,
+	useMemo,
+	useState,
+} from "react";
+import type { LoginRespDto, UserDto } from "../contracts/LoginDto";
+import type ErrorDto from "../contracts/ErrorDto";
+import { apiFetch, getToken, onUnauthorized, setToken } from "../api/client";
+
+type Ctx = {
+	user: UserDto | null;
+	loading: boolean;
+	login: (username: string, password: string) => Promise<void>;
+	logout: () => Promise<void>;
+};
+
+const AuthCtx = createContext<Ctx | null>(null);
+
+export function AuthProvider({ children }: { children: React.ReactNode }) {
+	const [user, setUser] = useState<UserDto | null>(null);
+	const [loading, setLoading] = useState(getToken() !== null);
+
+	// Resume the stored session, it may have expired or been revoked meanwhile.
+	useEffect(() => {
+		if (!getToken()) return;
+
+		(async () => {
+			try {
+				const resp = await apiFetch("/auth/me");
+				if (resp.ok) {
+					setUser(await resp.json());
+				}
+			} catch (err) {
+				console.error(err);
+			} finally {
+				setLoading(false);
+			}
+		})();
+	}, []);
+
+	useEffect(() => onUnauthorized(() => setUser(null)), []);
+
+	const login = useCallback(async (username: string, password: string) => {
+		const resp = await apiFetch("/auth/login", {
+			method: "POST",
+			headers: { "Content-Type": "application/json" },
+			body: JSON.stringify({ username, password }),
+		});
+		if (!resp.ok) {
+			const err: ErrorDto = await resp
+				.json()
+				.catch(() => ({ error: `HTTP ${resp.status}` }));
+			throw new Error(err.error);
+		}
+
+		const data: LoginRespDto = await resp.json();
+		setToken(data.token);
+		setUser(data.user);
+	}, []);
+
+	const logout = useCallback(async () => {
+		try {
+			await apiFetch("/auth/logout", { method: "POST" });
+		} catch (err) {
+			console.error(err);
+		}
+		setToken(null);
+		setUser(null);
+	}, []);
+
+	const value = useMemo<Ctx>(
+		() => ({ user, loading, login, logout }),
+		[user, loading, login, logout]
+	);
+
+	return <AuthCtx.Provider value={value}>{children}</AuthCtx.Provider>;
+}
+
+export function useAuth() {
+	const ctx = useContext(AuthCtx);
+	if (!ctx) throw new Error("useAuth must be used within AuthProvider");
+	return ctx;
+}
@@ -7,6 +7,7 @@ import {
 	useState,
 } from "react";
 import type { CameraDto } from "../contracts/CameraDto";
+import { apiFetch, eventSourceUrl } from "../api/client";
 
 type Ctx = {
 	cameras: CameraDto[];
@@ -41,9 +42,7 @@ export function CameraProvider({ children }: { children: React.ReactNode }) {
 			try {
 				setLoading(true);
 				setError(null);
-				const res = await fetch(
-					"http://localhost:5555/api/v1/cameras?offset=0&limit=100"
-				);
+				const res = await apiFetch("/cameras?offset=0&limit=100");
 				if (!res.ok) {
 					throw new Error(`HTTP ${res.status}`);
 				}
@@ -91,14 +90,11 @@ export function CameraProvider({ children }: { children: React.ReactNode }) {
 	const deleteStream = useCallback(async (uuid: string) => {
 		const ctrl = new AbortController();
 		try {
-			const resp = await fetch(
-				`http://localhost:5555/api/v1/cameras/${uuid}/stream`,
-				{
-					method: "DELETE",
-					signal: ctrl.signal,
-					keepalive: true,
-				}
-			);
+			const resp = await apiFetch(`/cameras/${uuid}/stream`, {
+				method: "DELETE",
+				signal: ctrl.signal,
+				keepalive: true,
+			});
 			if (!resp.ok || resp.status !== 404) {
 				throw new Error(`HTTP ${resp.status}`);
 			}
@@ -118,9 +114,7 @@ export function CameraProvider({ children }: { children: React.ReactNode }) {
 
 			const p = (async () => {
 				try {
-					const resp = await fetch(
-						`http://localhost:5555/api/v1/cameras/${uuid}/stream`
-					);
+					const resp = await apiFetch(`/cameras/${uuid}/stream`);
 					if (!resp.ok) {
 						throw new Error(`HTTP ${resp.status}`);
 					}
@@ -154,7 +148,7 @@ export function CameraProvider({ children }: { children: React.ReactNode }) {
 	);
 
 	useEffect(() => {
-		const es = new EventSource("http://localhost:5555/api/v1/events/discovery");
+		const es = new EventSource(eventSourceUrl("/events/discovery"));
 		es.onmessage = (e) => {
 			try {
 				const evt = JSON.parse(e.data);